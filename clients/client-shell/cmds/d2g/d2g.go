@@ -79,16 +79,17 @@ func convert(cmd *cobra.Command, args []string) (err error) {
 	}
 
 	d2gConfig := map[string]any{
-		"allowChainOfTrust":     true,
-		"allowDisableSeccomp":   true,
-		"allowHostSharedMemory": true,
-		"allowInteractive":      true,
-		"allowKVM":              true,
-		"allowLoopbackAudio":    true,
-		"allowLoopbackVideo":    true,
-		"allowPrivileged":       true,
-		"allowPtrace":           true,
-		"allowTaskclusterProxy": true,
+		"allowChainOfTrust":          true,
+		"allowDisableSeccomp":        true,
+		"allowHostSharedMemory":      true,
+		"allowInteractive":           true,
+		"allowKVM":                   true,
+		"allowLoopbackAudio":         true,
+		"allowLoopbackVideo":         true,
+		"allowPrivileged":            true,
+		"allowPtrace":                true,
+		"allowTaskclusterProxy":      true,
+		"taskclusterProxyUnixSocket": false,
 	}
 
 	if isTaskDef {
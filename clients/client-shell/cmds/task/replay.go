@@ -0,0 +1,79 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/pflag"
+	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
+	"github.com/taskcluster/taskcluster/v84/internal/asciicast"
+)
+
+// defaultRecordingArtifact is the artifact that generic-worker uploads
+// recordings of interactive sessions to.
+const defaultRecordingArtifact = "private/generic-worker/interactive-session.cast"
+
+// sleep is replaced in tests, to replay recordings without waiting
+var sleep = time.Sleep
+
+// runReplay downloads the recording of the interactive sessions of a task,
+// and replays it to out.
+func runReplay(credentials *tcclient.Credentials, args []string, out io.Writer, flagSet *pflag.FlagSet) error {
+	q := makeQueue(credentials)
+	taskID := args[0]
+
+	runID, _ := flagSet.GetInt("run")
+	artifact, _ := flagSet.GetString("artifact")
+	speed, _ := flagSet.GetFloat64("speed")
+	idleTimeLimit, _ := flagSet.GetDuration("idle-time-limit")
+
+	if speed <= 0 {
+		return fmt.Errorf("speed must be greater than 0")
+	}
+
+	recording, _, _, err := q.DownloadArtifactToBuf(taskID, int64(runID), artifact)
+	if err != nil {
+		return fmt.Errorf("could not download recording %s of task %s: %v", artifact, taskID, err)
+	}
+
+	return replay(bytes.NewReader(recording), out, speed, idleTimeLimit)
+}
+
+// replay writes the output of the given asciicast recording to out, with the
+// recorded timing divided by speed. Pauses are shortened to at most
+// idleTimeLimit, unless it is 0.
+func replay(recording io.Reader, out io.Writer, speed float64, idleTimeLimit time.Duration) error {
+	reader, err := asciicast.NewReader(recording)
+	if err != nil {
+		return err
+	}
+	last := 0.0
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// input is echoed in the output, if at all, so only output is
+		// replayed
+		if event.Type != asciicast.Output {
+			continue
+		}
+		delay := time.Duration((event.Time - last) / speed * float64(time.Second))
+		last = event.Time
+		if idleTimeLimit > 0 && delay > idleTimeLimit {
+			delay = idleTimeLimit
+		}
+		if delay > 0 {
+			sleep(delay)
+		}
+		_, err = io.WriteString(out, event.Data)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
+)
+
+const fakeRecording = `{"version": 2, "width": 80, "height": 24, "timestamp": 1504467315}
+[0.5, "o", "$ "]
+[1.0, "i", "ls\r"]
+[1.1, "o", "ls\r\n"]
+[1.2, "r", "100x50"]
+[11.2, "o", "file.txt\r\n$ "]
+`
+
+// returns the location of the recording artifact on request
+func recordingArtifactHandler(w http.ResponseWriter, r *http.Request) {
+	_, _ = fmt.Fprintf(w, `{"storageType": "s3", "url": "http://%s/recording.cast"}`, r.Host)
+}
+
+// returns the recording artifact content on request
+func recordingHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	_, _ = io.WriteString(w, fakeRecording)
+}
+
+// fakeSleep replaces sleep for the duration of the test, returning the
+// durations slept for.
+func fakeSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	slept := &[]time.Duration{}
+	sleep = func(d time.Duration) {
+		*slept = append(*slept, d)
+	}
+	t.Cleanup(func() {
+		sleep = time.Sleep
+	})
+	return slept
+}
+
+func TestReplay(t *testing.T) {
+	slept := fakeSleep(t)
+	out := &strings.Builder{}
+	require.NoError(t, replay(strings.NewReader(fakeRecording), out, 2, 3*time.Second))
+	assert.Equal(t, "$ ls\r\nfile.txt\r\n$ ", out.String())
+	assert.Equal(t, []time.Duration{
+		250 * time.Millisecond,
+		300 * time.Millisecond,
+		// 10s idle, at double speed, limited to 3s
+		3 * time.Second,
+	}, *slept)
+}
+
+func TestReplayInvalidRecording(t *testing.T) {
+	fakeSleep(t)
+	assert.Error(t, replay(strings.NewReader("not a recording"), io.Discard, 1, 0))
+}
+
+func (suite *FakeServerSuite) TestReplayCommand() {
+	fakeSleep(suite.T())
+	buf, cmd := setUpCommand()
+	cmd.Flags().AddFlagSet(replayCmd.Flags())
+
+	args := []string{fakeTaskID}
+	assert.NoError(suite.T(), runReplay(&tcclient.Credentials{}, args, cmd.OutOrStdout(), cmd.Flags()))
+
+	suite.Equal("$ ls\r\nfile.txt\r\n$ ", buf.String())
+}
@@ -2,6 +2,8 @@
 package task
 
 import (
+	"time"
+
 	"github.com/taskcluster/taskcluster/v84/clients/client-shell/cmds/root"
 
 	"github.com/spf13/cobra"
@@ -45,6 +47,12 @@ var (
 		Short: "Completes a task.",
 		RunE:  executeHelperE(runComplete),
 	}
+
+	replayCmd = &cobra.Command{
+		Use:   "replay <taskId>",
+		Short: "Replay the recorded interactive sessions of a task in the terminal.",
+		RunE:  executeHelperE(runReplay),
+	}
 )
 
 var log = root.Logger
@@ -55,6 +63,11 @@ func init() {
 
 	artifactsCmd.Flags().IntP("run", "r", -1, "Specifies which run to consider.")
 
+	replayCmd.Flags().IntP("run", "r", -1, "Specifies which run to consider.")
+	replayCmd.Flags().StringP("artifact", "a", defaultRecordingArtifact, "Name of the artifact containing the asciicast recording.")
+	replayCmd.Flags().Float64P("speed", "s", 1, "Playback speed, e.g. 2 for double speed.")
+	replayCmd.Flags().DurationP("idle-time-limit", "i", 2*time.Second, "Shorten pauses to at most this long (0 for no limit).")
+
 	retriggerCmd.Flags().BoolP("exact", "e", false, "Retrigger in exact mode. WARNING: THIS MAY HAVE SIDE EFFECTS. USE AFTER YOU READ THE SOURCE CODE.")
 
 	rerunCmd.Flags().BoolP("noop", "n", false, "Using this flag, will tell the command to not actually run, but prints out what it would do.")
@@ -96,6 +109,8 @@ func init() {
 			Short: "Streams the log until completion.",
 			RunE:  executeHelperE(runLog),
 		},
+		// replay
+		replayCmd,
 	)
 
 	// Commands that take actions
@@ -0,0 +1,213 @@
+// Package asciicast reads and writes terminal session recordings in the
+// asciicast v2 format, see
+// https://docs.asciinema.org/manual/asciicast/v2/.
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Event types
+const (
+	Output = "o"
+	Input  = "i"
+	Resize = "r"
+)
+
+// Header is the first line of an asciicast v2 file.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is a single recorded event, which is serialised as a json array of
+// the form [time, type, data].
+type Event struct {
+	// Time is the number of seconds since the start of the recording.
+	Time float64
+	// Type is one of Output, Input or Resize.
+	Type string
+	// Data is the terminal output or input, or for Resize events, the new
+	// terminal size in the form <columns>x<rows>.
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Appendf(nil, `[%s,%q,%s]`, strconv.FormatFloat(e.Time, 'f', 6, 64), e.Type, data), nil
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var fields []any
+	err := json.Unmarshal(b, &fields)
+	if err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("asciicast event %s should have 3 fields, but has %v", b, len(fields))
+	}
+	var ok bool
+	if e.Time, ok = fields[0].(float64); !ok {
+		return fmt.Errorf("asciicast event %s has invalid time", b)
+	}
+	if e.Type, ok = fields[1].(string); !ok {
+		return fmt.Errorf("asciicast event %s has invalid type", b)
+	}
+	if e.Data, ok = fields[2].(string); !ok {
+		return fmt.Errorf("asciicast event %s has invalid data", b)
+	}
+	return nil
+}
+
+// Writer writes an asciicast v2 recording. It is safe for concurrent use.
+type Writer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	// bytes of an incomplete UTF-8 sequence at the end of the last write,
+	// per event type
+	partial map[string][]byte
+	events  int
+}
+
+// NewWriter writes the given header to w, and returns a Writer for recording
+// events after it. The version and (if not set) timestamp of the header are
+// filled in.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	start := time.Now()
+	header.Version = 2
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+	line, err := json.Marshal(&header)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(append(line, '\n'))
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:       w,
+		start:   start,
+		partial: map[string][]byte{},
+	}, nil
+}
+
+// Write records data as an event of the given type. Since event data must be
+// valid UTF-8, a multi-byte character split across two writes of the same
+// type is held back until the rest of it is written.
+func (cw *Writer) Write(eventType string, data []byte) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	data = append(cw.partial[eventType], data...)
+	data, cw.partial[eventType] = splitIncompleteRune(data)
+	if len(data) == 0 {
+		return nil
+	}
+	return cw.writeEvent(eventType, string(data))
+}
+
+// Resize records a change of the terminal size.
+func (cw *Writer) Resize(columns int, rows int) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.writeEvent(Resize, fmt.Sprintf("%dx%d", columns, rows))
+}
+
+// Events returns the number of events written so far.
+func (cw *Writer) Events() int {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.events
+}
+
+func (cw *Writer) writeEvent(eventType string, data string) error {
+	line, err := json.Marshal(Event{
+		Time: time.Since(cw.start).Seconds(),
+		Type: eventType,
+		Data: data,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = cw.w.Write(append(line, '\n'))
+	if err == nil {
+		cw.events++
+	}
+	return err
+}
+
+// splitIncompleteRune splits off an incomplete UTF-8 encoded character from
+// the end of b, if there is one.
+func splitIncompleteRune(b []byte) (complete []byte, incomplete []byte) {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i], append([]byte{}, b[len(b)-i:]...)
+			}
+			break
+		}
+	}
+	return b, nil
+}
+
+// Reader reads an asciicast v2 recording.
+type Reader struct {
+	Header  Header
+	scanner *bufio.Scanner
+}
+
+// NewReader reads the header of an asciicast v2 recording from r, and returns
+// a Reader for reading its events.
+func NewReader(r io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), math.MaxInt32)
+	cr := &Reader{scanner: scanner}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("asciicast recording is empty")
+	}
+	err := json.Unmarshal(scanner.Bytes(), &cr.Header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asciicast header: %v", err)
+	}
+	if cr.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %v", cr.Header.Version)
+	}
+	return cr, nil
+}
+
+// Next returns the next event of the recording, or io.EOF after the last
+// one.
+func (cr *Reader) Next() (*Event, error) {
+	for cr.scanner.Scan() {
+		if len(cr.scanner.Bytes()) == 0 {
+			continue
+		}
+		event := new(Event)
+		err := json.Unmarshal(cr.scanner.Bytes(), event)
+		return event, err
+	}
+	if err := cr.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,102 @@
+package asciicast
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, Header{Width: 80, Height: 24, Env: map[string]string{"TERM": "xterm"}})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(Output, []byte("hello\r\n")))
+	require.NoError(t, w.Write(Input, []byte("ls\r")))
+	require.NoError(t, w.Resize(120, 40))
+	// a character split across writes is recorded whole
+	euro := []byte("€")
+	require.NoError(t, w.Write(Output, euro[:1]))
+	require.NoError(t, w.Write(Output, euro[1:]))
+	assert.Equal(t, 4, w.Events())
+
+	r, err := NewReader(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, r.Header.Version)
+	assert.Equal(t, 80, r.Header.Width)
+	assert.NotZero(t, r.Header.Timestamp)
+	assert.Equal(t, "xterm", r.Header.Env["TERM"])
+
+	expected := []Event{
+		{Type: Output, Data: "hello\r\n"},
+		{Type: Input, Data: "ls\r"},
+		{Type: Resize, Data: "120x40"},
+		{Type: Output, Data: "€"},
+	}
+	lastTime := 0.0
+	for _, exp := range expected {
+		event, err := r.Next()
+		require.NoError(t, err)
+		assert.Equal(t, exp.Type, event.Type)
+		assert.Equal(t, exp.Data, event.Data)
+		assert.GreaterOrEqual(t, event.Time, lastTime)
+		lastTime = event.Time
+	}
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadAsciinemaFile(t *testing.T) {
+	r, err := NewReader(strings.NewReader(`{"version": 2, "width": 80, "height": 24, "timestamp": 1504467315}
+[0.248848, "o", "\u001b[1;31mHello \u001b[32mWorld!\u001b[0m\n"]
+[1.001376, "o", "That was ok\rThis is better."]
+`))
+	require.NoError(t, err)
+	event, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, Event{Time: 0.248848, Type: Output, Data: "\x1b[1;31mHello \x1b[32mWorld!\x1b[0m\n"}, *event)
+	event, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 1.001376, event.Time)
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestInvalidRecordings(t *testing.T) {
+	for _, recording := range []string{
+		"",
+		"not json\n",
+		`{"version": 1, "width": 80, "height": 24}`,
+	} {
+		_, err := NewReader(strings.NewReader(recording))
+		assert.Error(t, err, "recording %q", recording)
+	}
+	r, err := NewReader(strings.NewReader(`{"version": 2, "width": 80, "height": 24}
+[0.1, "o"]
+`))
+	require.NoError(t, err)
+	_, err = r.Next()
+	assert.Error(t, err)
+}
+
+func TestSplitIncompleteRune(t *testing.T) {
+	for _, test := range []struct {
+		in         string
+		complete   string
+		incomplete string
+	}{
+		{"abc", "abc", ""},
+		{"ab\xe2\x82", "ab", "\xe2\x82"},
+		{"ab\xe2", "ab", "\xe2"},
+		{"ab€", "ab€", ""},
+		{"ab\x82", "ab\x82", ""},
+		{"", "", ""},
+	} {
+		complete, incomplete := splitIncompleteRune([]byte(test.in))
+		assert.Equal(t, test.complete, string(complete), "input %q", test.in)
+		assert.Equal(t, test.incomplete, string(incomplete), "input %q", test.in)
+	}
+}
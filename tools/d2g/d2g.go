@@ -189,6 +189,11 @@ func ConvertPayload(
 	config map[string]any,
 	directoryReader func(string) ([]os.DirEntry, error),
 ) (gwPayload *genericworker.GenericWorkerPayload, conversionInfo ConversionInfo, err error) {
+	if dwPayload.Features.TaskclusterProxy && config["allowTaskclusterProxy"].(bool) && config["taskclusterProxyUnixSocket"].(bool) {
+		err = fmt.Errorf("cannot convert taskclusterProxy feature: taskclusterProxyUnixSocket is enabled in the d2g config, but Docker Worker tasks expect to reach the proxy at http://taskcluster rather than over a Unix domain socket")
+		return
+	}
+
 	gwPayload = new(genericworker.GenericWorkerPayload)
 	defaults.SetDefaults(gwPayload)
 
@@ -123,6 +123,40 @@ func TestDataTestCases(t *testing.T) {
 	}
 }
 
+func TestTaskclusterProxyUnixSocketRefused(t *testing.T) {
+	var d2gConfig D2GConfiguration
+	defaults.SetDefaults(&d2gConfig)
+	d2gConfig.TaskclusterProxyUnixSocket = true
+	d2gConfigBytes, err := json.Marshal(d2gConfig)
+	if err != nil {
+		t.Fatalf("Cannot marshal D2GConfig: %v", err)
+	}
+	var d2gConfigMap map[string]any
+	err = json.Unmarshal(d2gConfigBytes, &d2gConfigMap)
+	if err != nil {
+		t.Fatalf("Cannot unmarshal D2GConfig %v: %v", string(d2gConfigBytes), err)
+	}
+
+	dwPayload := dockerworker.DockerWorkerPayload{}
+	defaults.SetDefaults(&dwPayload)
+	dwPayload.Command = []string{"echo", "hello"}
+	dwPayload.Image = json.RawMessage(`"ubuntu"`)
+	dwPayload.MaxRunTime = 3600
+
+	// without the taskcluster proxy, the task converts as usual
+	_, _, err = d2g.ConvertPayload(&dwPayload, d2gConfigMap, FakeReadDir)
+	if err != nil {
+		t.Fatalf("Cannot convert Docker Worker payload without taskclusterProxy feature: %v", err)
+	}
+
+	// with it, the task would not be able to reach the proxy at http://taskcluster
+	dwPayload.Features.TaskclusterProxy = true
+	_, _, err = d2g.ConvertPayload(&dwPayload, d2gConfigMap, FakeReadDir)
+	if err == nil {
+		t.Fatal("Was expecting conversion of Docker Worker payload with taskclusterProxy feature to fail")
+	}
+}
+
 // testSuite returns a go test the given testSuite
 func testSuite(schema string, path string) func(t *testing.T) {
 	return func(t *testing.T) {
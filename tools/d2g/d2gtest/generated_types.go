@@ -45,6 +45,9 @@ type (
 
 		// Default:    "all"
 		Gpus string `json:"gpus" default:"all"`
+
+		// Default:    false
+		TaskclusterProxyUnixSocket bool `json:"taskclusterProxyUnixSocket" default:"false"`
 	}
 
 	// Static d2g input/output test cases. Contains pairs of Docker Worker task def/payload
@@ -197,6 +200,10 @@ func JSONSchema() string {
         "gpus": {
           "default": "all",
           "type": "string"
+        },
+        "taskclusterProxyUnixSocket": {
+          "default": false,
+          "type": "boolean"
         }
       },
       "title": "d2g Configuration",
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry is the record written to the audit log for each request which
+// the proxy handles on behalf of the task.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Service   string    `json:"service"`
+	Endpoint  string    `json:"endpoint"`
+	Status    int       `json:"status"`
+	LatencyMS int64     `json:"latencyMs"`
+	Denied    bool      `json:"denied,omitempty"`
+}
+
+// AuditLog writes one JSON document per line to its underlying writer. Each
+// entry is written with a single Write call so that entries are not lost if
+// the proxy is killed at the end of a task.
+type AuditLog struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewAuditLog returns an AuditLog writing to out.
+func NewAuditLog(out io.Writer) *AuditLog {
+	return &AuditLog{out: out}
+}
+
+// OpenAuditLog returns an AuditLog appending to the file at path, creating
+// it if necessary.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuditLog(file), nil
+}
+
+// Record writes entry to the audit log. It is safe to call on a nil
+// *AuditLog, in which case nothing is recorded.
+func (a *AuditLog) Record(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		panic(err)
+	}
+	line = append(line, '\n')
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.out.Write(line)
+	if err != nil {
+		log.Printf("Could not write to audit log: %v", err)
+	}
+}
+
+// statusRecorder is an http.ResponseWriter that remembers the status code
+// sent to the client.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to flush and hijack the underlying
+// connection, for streamed requests.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// describeTarget returns the service and endpoint to record in the audit log
+// for the given target URL. Calls to the API of the RootURL deployment are
+// recorded by service name and API path; anything else by hostname and path.
+func (routes *Routes) describeTarget(target *url.URL) (service string, endpoint string) {
+	service, apiVersion, path, ok := routes.services.SplitAPIURL(target)
+	if ok {
+		return service, "/" + apiVersion + path
+	}
+	return target.Host, target.EscapedPath()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
+)
+
+// newAuditTestServer returns a server acting as a Taskcluster deployment with
+// "secrets" and "auth" services, counting the API calls which reach them.
+func newAuditTestServer(t *testing.T, calls *int) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/references/manifest.json":
+			fmt.Fprintf(w, `{"references": [%q, %q, %q, %q]}`,
+				"http://"+r.Host+"/references/auth/v1/api.json",
+				"http://"+r.Host+"/references/auth/v1/exchanges.json",
+				"http://"+r.Host+"/references/secrets/v1/api.json",
+				"http://"+r.Host+"/references/secrets/v2/api.json",
+			)
+			return
+		case "/references/secrets/v2/api.json":
+			fmt.Fprint(w, `{
+				"serviceName": "secrets",
+				"apiVersion": "v2",
+				"entries": [
+					{"type": "function", "name": "set", "method": "post", "route": "/secrets/<name>"}
+				]
+			}`)
+			return
+		case "/references/secrets/v1/api.json":
+			fmt.Fprint(w, `{
+				"serviceName": "secrets",
+				"apiVersion": "v1",
+				"entries": [
+					{"type": "function", "name": "set", "method": "put", "route": "/secret/<name>"},
+					{"type": "function", "name": "get", "method": "get", "route": "/secret/<name>"}
+				]
+			}`)
+			return
+		case "/references/auth/v1/api.json":
+			fmt.Fprint(w, `{
+				"serviceName": "auth",
+				"apiVersion": "v1",
+				"entries": [
+					{"type": "function", "name": "resetAccessToken", "method": "post", "route": "/clients/<clientId>/reset"}
+				]
+			}`)
+			return
+		}
+		*calls++
+		w.WriteHeader(200)
+		fmt.Fprint(w, "{}")
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newAuditTestRoutes(t *testing.T, rootURL string, audit *AuditLog, deny string) *Routes {
+	t.Helper()
+	routes := newTestRoutes(rootURL)
+	routes.audit = audit
+	var err error
+	routes.denied, err = LoadDenyList(routes.services, deny)
+	require.NoError(t, err)
+	return routes
+}
+
+func TestAuditLog(t *testing.T) {
+	calls := 0
+	ts := newAuditTestServer(t, &calls)
+	out := &bytes.Buffer{}
+	routes := newAuditTestRoutes(t, ts.URL, NewAuditLog(out), "")
+
+	req := httptest.NewRequest("GET", "/api/secrets/v1/secret/my-secret", nil)
+	res := httptest.NewRecorder()
+	routes.ServeHTTP(res, req)
+	assert.Equal(t, 200, res.Code)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 1)
+	var entry AuditEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "GET", entry.Method)
+	assert.Equal(t, "secrets", entry.Service)
+	assert.Equal(t, "/v1/secret/my-secret", entry.Endpoint)
+	assert.Equal(t, 200, entry.Status)
+	assert.False(t, entry.Denied)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDenyList(t *testing.T) {
+	calls := 0
+	ts := newAuditTestServer(t, &calls)
+	out := &bytes.Buffer{}
+	routes := newAuditTestRoutes(t, ts.URL, NewAuditLog(out), "secrets.set")
+
+	for _, path := range []string{
+		"/api/secrets/v1/secret/my-secret",
+		"/secrets/v1/secret/my-secret",
+		"/api/secrets/v1/secret/other%2Fsecret",
+	} {
+		req := httptest.NewRequest("PUT", path, strings.NewReader("{}"))
+		res := httptest.NewRecorder()
+		routes.ServeHTTP(res, req)
+		assert.Equal(t, 403, res.Code, "PUT %v should have been denied", path)
+	}
+	assert.Equal(t, 0, calls)
+
+	// other methods of the same service are still allowed
+	req := httptest.NewRequest("GET", "/api/secrets/v1/secret/my-secret", nil)
+	res := httptest.NewRecorder()
+	routes.ServeHTTP(res, req)
+	assert.Equal(t, 200, res.Code)
+	assert.Equal(t, 1, calls)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	var entry AuditEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.True(t, entry.Denied)
+	assert.Equal(t, 403, entry.Status)
+}
+
+// The services route paths with a trailing slash or repeated slashes to the
+// same API method, so the deny list must match them too, and it must match
+// the method in every version of the service's API.
+func TestDenyListPathVariants(t *testing.T) {
+	calls := 0
+	ts := newAuditTestServer(t, &calls)
+	routes := newAuditTestRoutes(t, ts.URL, nil, "secrets.set,auth.resetAccessToken")
+
+	for _, req := range []struct {
+		method string
+		path   string
+	}{
+		{"PUT", "/api/secrets/v1/secret/my-secret/"},
+		{"POST", "/api/secrets/v2/secrets/my-secret"},
+		{"PUT", "/api/secrets/v1//secret//my-secret"},
+		{"POST", "/api/auth/v1/clients/some-client/reset"},
+		{"POST", "/api/auth/v1/clients/some-client/reset/"},
+		{"POST", "/api/auth/v1/clients//some-client/reset//"},
+	} {
+		res := httptest.NewRecorder()
+		routes.ServeHTTP(res, httptest.NewRequest(req.method, req.path, strings.NewReader("{}")))
+		assert.Equal(t, 403, res.Code, "%v %v should have been denied", req.method, req.path)
+	}
+	assert.Equal(t, 0, calls)
+}
+
+func TestDenyListUnknownMethod(t *testing.T) {
+	calls := 0
+	ts := newAuditTestServer(t, &calls)
+	routes := NewRoutes(tcclient.Client{RootURL: ts.URL})
+	_, err := LoadDenyList(routes.services, "secrets.nosuch")
+	assert.Error(t, err)
+	_, err = LoadDenyList(routes.services, "secrets")
+	assert.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCache is a bounded, in-memory LRU cache of responses to GET
+// requests. Entries expire after the cache TTL, or sooner if the upstream
+// service sends a shorter Cache-Control max-age. Expired entries with an ETag
+// are revalidated with a conditional request rather than refetched.
+type ResponseCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	ttl      time.Duration
+	size     int
+	lru      *list.List
+	entries  map[string]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+// CachedResponse is a response held in a ResponseCache.
+type CachedResponse struct {
+	key        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	ETag       string
+	Expires    time.Time
+}
+
+// NewResponseCache returns a ResponseCache holding at most maxBytes of
+// response bodies, each for at most ttl.
+func NewResponseCache(maxBytes int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// Get returns the cached response for key, if any, and whether it is still
+// fresh. Stale responses are returned so that they can be revalidated.
+func (c *ResponseCache) Get(key string) (cached *CachedResponse, fresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	cached = elem.Value.(*CachedResponse)
+	return cached, time.Now().Before(cached.Expires)
+}
+
+// Store caches the given successful response for key, if the response allows
+// it. It returns true if the response was stored.
+func (c *ResponseCache) Store(key string, header http.Header, body []byte) bool {
+	ttl, ok := c.lifetime(header)
+	if !ok || len(body) > c.maxBytes {
+		c.Remove(key)
+		return false
+	}
+	cached := &CachedResponse{
+		key:        key,
+		StatusCode: http.StatusOK,
+		Header:     header.Clone(),
+		Body:       body,
+		ETag:       header.Get("ETag"),
+		Expires:    time.Now().Add(ttl),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, exists := c.entries[key]; exists {
+		c.removeElement(elem)
+	}
+	c.entries[key] = c.lru.PushFront(cached)
+	c.size += len(body)
+	for c.size > c.maxBytes {
+		c.removeElement(c.lru.Back())
+	}
+	return true
+}
+
+// Revalidated updates the cached response for key, after the upstream
+// service confirmed (with a 304 response carrying header) that it is still
+// current, and returns the updated response. As with any 304 response, the
+// headers it carries replace the stored ones, and the response lives on for
+// as long as the merged headers allow.
+func (c *ResponseCache) Revalidated(key string, cached *CachedResponse, header http.Header) *CachedResponse {
+	updated := *cached
+	updated.Header = cached.Header.Clone()
+	for name, values := range header {
+		// the 304 has no body, so says nothing about the length of ours
+		if name == "Content-Length" {
+			continue
+		}
+		updated.Header[name] = values
+	}
+	updated.ETag = updated.Header.Get("ETag")
+	ttl, ok := c.lifetime(updated.Header)
+	if !ok {
+		c.Remove(key)
+		return &updated
+	}
+	updated.Expires = time.Now().Add(ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// cached responses are never modified in place, since they may be being
+	// served concurrently; replace the entry, unless it has been replaced
+	// already
+	if elem, exists := c.entries[key]; exists && elem.Value == cached {
+		elem.Value = &updated
+	}
+	return &updated
+}
+
+// Remove drops any cached response for key.
+func (c *ResponseCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, exists := c.entries[key]; exists {
+		c.removeElement(elem)
+	}
+}
+
+// Count records a cache hit or miss, and returns the running totals.
+func (c *ResponseCache) Count(hit bool) (hits uint64, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return c.hits, c.misses
+}
+
+func (c *ResponseCache) removeElement(elem *list.Element) {
+	cached := c.lru.Remove(elem).(*CachedResponse)
+	delete(c.entries, cached.key)
+	c.size -= len(cached.Body)
+}
+
+// lifetime returns how long a response with the given headers may be cached
+// for, and false if it may not be cached at all. Responses marked no-cache
+// are stored, but are immediately stale so are always revalidated.
+func (c *ResponseCache) lifetime(header http.Header) (time.Duration, bool) {
+	ttl := c.ttl
+	for directive := range strings.SplitSeq(strings.ToLower(header.Get("Cache-Control")), ",") {
+		directive = strings.TrimSpace(directive)
+		switch {
+		case directive == "no-store":
+			return 0, false
+		case directive == "no-cache":
+			ttl = 0
+		case strings.HasPrefix(directive, "max-age="):
+			maxAge, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && time.Duration(maxAge)*time.Second < ttl {
+				ttl = time.Duration(maxAge) * time.Second
+			}
+		}
+	}
+	if ttl <= 0 && header.Get("ETag") == "" {
+		return 0, false
+	}
+	return ttl, true
+}
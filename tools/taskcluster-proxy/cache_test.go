@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cacheTestRequest(routes *Routes, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	res := httptest.NewRecorder()
+	routes.ServeHTTP(res, req)
+	return res
+}
+
+func TestResponseCache(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, "response %d", calls)
+	}))
+	defer ts.Close()
+	routes := newTestRoutes(ts.URL)
+	routes.cache = NewResponseCache(1024, time.Minute)
+
+	res := cacheTestRequest(routes, "GET", "/api/queue/v1/task/abc")
+	assert.Equal(t, "MISS", res.Header().Get("X-Taskcluster-Proxy-Cache"))
+	assert.Equal(t, "response 1", res.Body.String())
+
+	res = cacheTestRequest(routes, "GET", "/api/queue/v1/task/abc")
+	assert.Equal(t, "HIT", res.Header().Get("X-Taskcluster-Proxy-Cache"))
+	assert.Equal(t, "response 1", res.Body.String())
+
+	// different URL, and non-GET requests, are not served from the cache
+	res = cacheTestRequest(routes, "GET", "/api/queue/v1/task/def")
+	assert.Equal(t, "response 2", res.Body.String())
+	res = cacheTestRequest(routes, "POST", "/api/queue/v1/task/abc")
+	assert.Equal(t, "response 3", res.Body.String())
+	assert.Equal(t, "", res.Header().Get("X-Taskcluster-Proxy-Cache"))
+
+	// new credentials do not see responses cached for the old ones
+	routes.Credentials.ClientID = "other-client"
+	res = cacheTestRequest(routes, "GET", "/api/queue/v1/task/abc")
+	assert.Equal(t, "response 4", res.Body.String())
+	assert.Equal(t, 4, calls)
+
+	hits, misses := routes.cache.Count(false)
+	assert.Equal(t, uint64(1), hits)
+	assert.Equal(t, uint64(4), misses)
+}
+
+func TestResponseCacheRevalidation(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-Call", fmt.Sprint(calls))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, "the secret")
+	}))
+	defer ts.Close()
+	routes := newTestRoutes(ts.URL)
+	routes.cache = NewResponseCache(1024, time.Minute)
+
+	res := cacheTestRequest(routes, "GET", "/api/secrets/v1/secret/abc")
+	assert.Equal(t, "MISS", res.Header().Get("X-Taskcluster-Proxy-Cache"))
+	res = cacheTestRequest(routes, "GET", "/api/secrets/v1/secret/abc")
+	assert.Equal(t, "REVALIDATED", res.Header().Get("X-Taskcluster-Proxy-Cache"))
+	assert.Equal(t, 200, res.Code)
+	assert.Equal(t, "the secret", res.Body.String())
+	// headers of the 304 response replace the stored ones
+	assert.Equal(t, "2", res.Header().Get("X-Call"))
+	res = cacheTestRequest(routes, "GET", "/api/secrets/v1/secret/abc")
+	assert.Equal(t, "REVALIDATED", res.Header().Get("X-Taskcluster-Proxy-Cache"))
+	assert.Equal(t, "3", res.Header().Get("X-Call"))
+	assert.Equal(t, "the secret", res.Body.String())
+	assert.Equal(t, 3, calls)
+}
+
+func TestResponseCacheConditionalRequest(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "the task")
+	}))
+	defer ts.Close()
+	routes := newTestRoutes(ts.URL)
+	routes.cache = NewResponseCache(1024, time.Minute)
+
+	res := cacheTestRequest(routes, "GET", "/api/queue/v1/task/abc")
+	assert.Equal(t, "MISS", res.Header().Get("X-Taskcluster-Proxy-Cache"))
+
+	for _, test := range []struct {
+		ifNoneMatch string
+		code        int
+		body        string
+	}{
+		{`"v1"`, 304, ""},
+		{`"v0", W/"v1"`, 304, ""},
+		{"*", 304, ""},
+		{`"v0"`, 200, "the task"},
+	} {
+		req := httptest.NewRequest("GET", "/api/queue/v1/task/abc", nil)
+		req.Header.Set("If-None-Match", test.ifNoneMatch)
+		res := httptest.NewRecorder()
+		routes.ServeHTTP(res, req)
+		assert.Equal(t, "HIT", res.Header().Get("X-Taskcluster-Proxy-Cache"), "If-None-Match %s", test.ifNoneMatch)
+		assert.Equal(t, test.code, res.Code, "If-None-Match %s", test.ifNoneMatch)
+		assert.Equal(t, test.body, res.Body.String(), "If-None-Match %s", test.ifNoneMatch)
+		assert.Equal(t, `"v1"`, res.Header().Get("ETag"), "If-None-Match %s", test.ifNoneMatch)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestResponseCacheLifetime(t *testing.T) {
+	cache := NewResponseCache(1024, time.Minute)
+	for _, test := range []struct {
+		cacheControl string
+		etag         string
+		ttl          time.Duration
+		cacheable    bool
+	}{
+		{"", "", time.Minute, true},
+		{"max-age=10", "", 10 * time.Second, true},
+		{"public, max-age=3600", "", time.Minute, true},
+		{"no-store", `"x"`, 0, false},
+		{"no-cache", "", 0, false},
+		{"no-cache", `"x"`, 0, true},
+	} {
+		header := http.Header{}
+		header.Set("Cache-Control", test.cacheControl)
+		if test.etag != "" {
+			header.Set("ETag", test.etag)
+		}
+		ttl, cacheable := cache.lifetime(header)
+		assert.Equal(t, test.cacheable, cacheable, "Cache-Control %q", test.cacheControl)
+		if cacheable {
+			assert.Equal(t, test.ttl, ttl, "Cache-Control %q", test.cacheControl)
+		}
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	cache := NewResponseCache(10, time.Minute)
+	assert.True(t, cache.Store("a", http.Header{}, []byte("aaaa")))
+	assert.True(t, cache.Store("b", http.Header{}, []byte("bbbb")))
+	// use "a" so that "b" is least recently used
+	_, fresh := cache.Get("a")
+	assert.True(t, fresh)
+	assert.True(t, cache.Store("c", http.Header{}, []byte("cccc")))
+	cached, _ := cache.Get("b")
+	assert.Nil(t, cached)
+	cached, _ = cache.Get("a")
+	assert.NotNil(t, cached)
+	// too big to cache at all
+	assert.False(t, cache.Store("d", http.Header{}, []byte("ddddddddddd")))
+}
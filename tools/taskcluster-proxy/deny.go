@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	tc "github.com/taskcluster/taskcluster/v84/tools/taskcluster-proxy/taskcluster"
+)
+
+// LoadDenyList resolves a comma-separated list of API methods, each of the
+// form <service>.<method> (e.g. auth.resetAccessToken), into the API entries
+// that the proxy should refuse to sign requests for.
+func LoadDenyList(services tc.Services, methods string) ([]*tc.APIEntry, error) {
+	denied := []*tc.APIEntry{}
+	for method := range strings.SplitSeq(methods, ",") {
+		method = strings.TrimSpace(method)
+		if method == "" {
+			continue
+		}
+		service, name, found := strings.Cut(method, ".")
+		if !found || service == "" || name == "" {
+			return nil, fmt.Errorf("invalid API method %q - must be of the form <service>.<method>", method)
+		}
+		entries, err := services.APIEntries(service, name)
+		if err != nil {
+			return nil, err
+		}
+		denied = append(denied, entries...)
+	}
+	return denied, nil
+}
+
+// deniedEntry returns the entry of the deny list which matches a request
+// with the given method to the given target URL, or nil if the request is
+// allowed.
+func (routes *Routes) deniedEntry(method string, target *url.URL) *tc.APIEntry {
+	if len(routes.denied) == 0 {
+		return nil
+	}
+	service, apiVersion, path, ok := routes.services.SplitAPIURL(target)
+	if !ok {
+		return nil
+	}
+	for _, entry := range routes.denied {
+		if entry.Matches(method, service, apiVersion, path) {
+			return entry
+		}
+	}
+	return nil
+}
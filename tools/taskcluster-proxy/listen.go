@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// listen returns a listener for the proxy server on the given network
+// ("tcp" or "unix") and address. The file of a Unix domain socket is
+// restricted to its owner, so that the process which started the proxy can
+// control who may use it, by changing the owner of the socket file, or the
+// permissions of the directory containing it.
+func listen(network, address string) (net.Listener, error) {
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+	// remove any socket left behind by a previous proxy process
+	err := os.Remove(address)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(address, 0600)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
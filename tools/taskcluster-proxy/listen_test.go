@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "proxy.sock")
+	_, network, address, err := ParseCommandArgs(
+		[]string{
+			"--root-url", "https://tc-tests.example.com",
+			"--client-id", "abc",
+			"--access-token", "ghi",
+			"--unix-socket", socket,
+		},
+		false,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "unix", network)
+	assert.Equal(t, socket, address)
+
+	// a stale socket file from an earlier run should not prevent listening
+	require.NoError(t, os.WriteFile(socket, []byte{}, 0644))
+
+	listener, err := listen(network, address)
+	require.NoError(t, err)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "hello")
+		}),
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(socket)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	res, err := client.Get("http://localhost/")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	docopt "github.com/docopt/docopt-go"
 	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
@@ -36,29 +37,56 @@ task id.
     -i --ip-address <address>       IPv4 or IPv6 address of network interface to bind listener to.
                                     If not provided, will bind listener to all available network
                                     interfaces [default: ].
+    -u --unix-socket <path>         Listen on a Unix domain socket at the given path, instead of
+                                    on a TCP port. The socket is only accessible to the owner
+                                    of the socket file [default: ].
     -t --task-id <taskId>           Restrict given scopes to those defined in taskId.
     --root-url <rootUrl>            The rootUrl for the TC deployment to access
     --client-id <clientId>          Use a specific auth.taskcluster hawk client id [default: ].
     --access-token <accessToken>    Use a specific auth.taskcluster hawk access token [default: ].
     --certificate <certificate>     Use a specific auth.taskcluster hawk certificate [default: ].
+    --audit-log <file>              Append a JSON record of every proxied request (method,
+                                    service, endpoint, status and latency) to the given
+                                    file [default: ].
+    --deny <methods>                Comma-separated list of API methods, of the form
+                                    <service>.<method> (e.g. auth.resetAccessToken), which
+                                    may not be called through the proxy [default: ].
+    --cache-size <megabytes>        Cache responses to GET requests in memory, using at most
+                                    this many megabytes. A value of 0 disables the
+                                    cache [default: 0].
+    --cache-ttl <seconds>           Maximum time to cache a response for. Shorter lifetimes
+                                    given by the Cache-Control header of the response are
+                                    respected [default: 60].
+    --expiry-margin <seconds>       Requests made when the temporary credentials expire within
+                                    this many seconds wait for new credentials to be provided
+                                    via the /credentials endpoint [default: 60].
+    --refresh-wait <seconds>        Maximum time a request waits for new credentials before
+                                    being sent with the current ones. A value of 0 disables
+                                    waiting [default: 30].
 `
 )
 
 func main() {
-	routes, address, err := ParseCommandArgs(os.Args[1:], true)
+	routes, network, address, err := ParseCommandArgs(os.Args[1:], true)
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
 
+	listener, err := listen(network, address)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	server := &http.Server{
 		Handler: &routes,
-		// Only listen on loopback interface to reduce attack surface. If we later
-		// wish to make this service available over the network, we could add
-		// configuration settings for this, but for now, let's lock it down.
-		Addr: address,
+		// Accept HTTP/2 over cleartext (h2c) from clients which use it, so
+		// that their streams can be passed through.
+		Protocols: new(http.Protocols),
 	}
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetUnencryptedHTTP2(true)
 
-	startError := server.ListenAndServe()
+	startError := server.Serve(listener)
 	if startError != nil {
 		log.Fatal(startError)
 	}
@@ -74,8 +102,8 @@ var getTask = func(rootURL string, taskID string) (task *tcqueue.TaskDefinitionR
 }
 
 // ParseCommandArgs converts command line arguments into a configured Routes
-// and port.
-func ParseCommandArgs(argv []string, exit bool) (routes Routes, address string, err error) {
+// and the network ("tcp" or "unix") and address to listen on.
+func ParseCommandArgs(argv []string, exit bool) (routes Routes, network string, address string, err error) {
 	fullversion := "Taskcluster proxy " + version
 	if revision != "" {
 		fullversion += " (git revision " + revision + ")"
@@ -112,7 +140,10 @@ func ParseCommandArgs(argv []string, exit bool) (routes Routes, address string,
 			return
 		}
 	}
-	address = ipAddress + ":" + portStr
+	network, address = "tcp", ipAddress+":"+portStr
+	if unixSocket := arguments["--unix-socket"].(string); unixSocket != "" {
+		network, address = "unix", unixSocket
+	}
 	log.Printf("Listening on: %v", address)
 
 	rootURL := arguments["--root-url"]
@@ -200,5 +231,53 @@ func ParseCommandArgs(argv []string, exit bool) (routes Routes, address string,
 			Credentials:  creds,
 		},
 	)
+
+	if auditLog := arguments["--audit-log"].(string); auditLog != "" {
+		routes.audit, err = OpenAuditLog(auditLog)
+		if err != nil {
+			err = fmt.Errorf("could not open audit log %v: %v", auditLog, err)
+			return
+		}
+		log.Printf("Audit log: '%v'", auditLog)
+	}
+
+	if deny := arguments["--deny"].(string); deny != "" {
+		routes.denied, err = LoadDenyList(routes.services, deny)
+		if err != nil {
+			err = fmt.Errorf("could not load deny list: %v", err)
+			return
+		}
+		log.Printf("Denied API methods: %v", routes.denied)
+	}
+
+	var cacheSize, cacheTTL int
+	cacheSize, err = strconv.Atoi(arguments["--cache-size"].(string))
+	if err != nil || cacheSize < 0 {
+		err = fmt.Errorf("invalid --cache-size %q - must be a non-negative integer", arguments["--cache-size"])
+		return
+	}
+	cacheTTL, err = strconv.Atoi(arguments["--cache-ttl"].(string))
+	if err != nil || cacheTTL < 0 {
+		err = fmt.Errorf("invalid --cache-ttl %q - must be a non-negative integer", arguments["--cache-ttl"])
+		return
+	}
+	if cacheSize > 0 {
+		routes.cache = NewResponseCache(cacheSize*1024*1024, time.Duration(cacheTTL)*time.Second)
+		log.Printf("Caching GET responses (max %v MB, max %vs)", cacheSize, cacheTTL)
+	}
+
+	var expiryMargin, refreshWait int
+	expiryMargin, err = strconv.Atoi(arguments["--expiry-margin"].(string))
+	if err != nil || expiryMargin < 0 {
+		err = fmt.Errorf("invalid --expiry-margin %q - must be a non-negative integer", arguments["--expiry-margin"])
+		return
+	}
+	refreshWait, err = strconv.Atoi(arguments["--refresh-wait"].(string))
+	if err != nil || refreshWait < 0 {
+		err = fmt.Errorf("invalid --refresh-wait %q - must be a non-negative integer", arguments["--refresh-wait"])
+		return
+	}
+	routes.expiryMargin = time.Duration(expiryMargin) * time.Second
+	routes.refreshWait = time.Duration(refreshWait) * time.Second
 	return
 }
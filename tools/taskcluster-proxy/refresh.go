@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// CredentialsStatus is the json body served by the /status endpoint,
+// describing how fresh the credentials of the proxy are.
+type CredentialsStatus struct {
+	ClientID string `json:"clientId"`
+	// One of "permanent", "fresh", "expiring" or "expired". Credentials are
+	// "expiring" once they are within the expiry margin of their expiry time,
+	// at which point requests wait for new credentials before being proxied.
+	State            string     `json:"state"`
+	Expires          *time.Time `json:"expires,omitempty"`
+	SecondsRemaining *int64     `json:"secondsRemaining,omitempty"`
+	LastUpdated      *time.Time `json:"lastUpdated,omitempty"`
+}
+
+// credentialsExpiry returns the expiry time of the certificate of the current
+// (temporary) credentials, and false if the credentials are permanent or the
+// certificate cannot be parsed. The caller must hold routes.lock.
+func (routes *Routes) credentialsExpiry() (time.Time, bool) {
+	cert, err := routes.Credentials.Cert()
+	if err != nil || cert == nil || cert.Expiry == 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(cert.Expiry), true
+}
+
+// credentialsRefreshed returns a channel which is closed the next time the
+// credentials are updated via the /credentials endpoint.
+func (routes *Routes) credentialsRefreshed() <-chan struct{} {
+	routes.refreshLock.Lock()
+	defer routes.refreshLock.Unlock()
+	if routes.refreshed == nil {
+		routes.refreshed = make(chan struct{})
+	}
+	return routes.refreshed
+}
+
+// notifyCredentialsRefreshed releases any requests waiting for new
+// credentials.
+func (routes *Routes) notifyCredentialsRefreshed() {
+	routes.refreshLock.Lock()
+	defer routes.refreshLock.Unlock()
+	if routes.refreshed != nil {
+		close(routes.refreshed)
+		routes.refreshed = nil
+	}
+	routes.lastUpdated = time.Now()
+}
+
+// awaitFreshCredentials blocks for up to routes.refreshWait if the current
+// credentials expire within routes.expiryMargin, so that requests are not
+// signed with credentials that are about to be rejected. If no new
+// credentials arrive in time, the request goes ahead with the current ones.
+// It must be called without holding routes.lock, since updating the
+// credentials requires the write lock.
+func (routes *Routes) awaitFreshCredentials() {
+	if routes.refreshWait <= 0 {
+		return
+	}
+	refreshed := routes.credentialsRefreshed()
+	routes.lock.RLock()
+	expires, temporary := routes.credentialsExpiry()
+	routes.lock.RUnlock()
+	if !temporary || time.Until(expires) > routes.expiryMargin {
+		return
+	}
+	log.Printf("Credentials expire at %v - waiting up to %v for new credentials", expires.UTC(), routes.refreshWait)
+	timer := time.NewTimer(routes.refreshWait)
+	defer timer.Stop()
+	select {
+	case <-refreshed:
+		log.Print("Received new credentials")
+	case <-timer.C:
+		log.Print("No new credentials received - continuing with current credentials")
+	}
+}
+
+// StatusHandler is the HTTP Handler for serving the /status endpoint
+func (routes *Routes) StatusHandler(res http.ResponseWriter, req *http.Request) {
+	routes.setHeaders(res)
+	if req.Method != "GET" {
+		log.Printf("Invalid method %s\n", req.Method)
+		res.WriteHeader(405)
+		return
+	}
+
+	routes.lock.RLock()
+	status := CredentialsStatus{
+		ClientID: routes.Credentials.ClientID,
+		State:    "permanent",
+	}
+	expires, temporary := routes.credentialsExpiry()
+	routes.lock.RUnlock()
+
+	if temporary {
+		remaining := int64(time.Until(expires) / time.Second)
+		status.Expires = &expires
+		status.SecondsRemaining = &remaining
+		switch until := time.Until(expires); {
+		case until <= 0:
+			status.State = "expired"
+		case until <= routes.expiryMargin:
+			status.State = "expiring"
+		default:
+			status.State = "fresh"
+		}
+	}
+	routes.refreshLock.Lock()
+	if !routes.lastUpdated.IsZero() {
+		lastUpdated := routes.lastUpdated
+		status.LastUpdated = &lastUpdated
+	}
+	routes.refreshLock.Unlock()
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(200)
+	err := json.NewEncoder(res).Encode(&status)
+	if err != nil {
+		log.Printf("Error writing status: %s", err)
+	}
+}
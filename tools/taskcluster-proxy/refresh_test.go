@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// certExpiringIn returns a (fake) temporary credentials certificate which
+// expires after the given duration.
+func certExpiringIn(d time.Duration) string {
+	now := time.Now()
+	return fmt.Sprintf(
+		`{"version":1,"scopes":["scope1"],"start":%d,"expiry":%d,"seed":"seed","signature":"sig"}`,
+		now.Add(-time.Minute).UnixMilli(),
+		now.Add(d).UnixMilli(),
+	)
+}
+
+func newRefreshTestRoutes(rootURL string, clientID string, certificate string) *Routes {
+	routes := newTestRoutes(rootURL)
+	routes.Credentials.ClientID = clientID
+	routes.Credentials.Certificate = certificate
+	routes.expiryMargin = time.Minute
+	routes.refreshWait = 10 * time.Second
+	return routes
+}
+
+func getStatus(t *testing.T, routes *Routes) CredentialsStatus {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/status", nil)
+	res := httptest.NewRecorder()
+	routes.ServeHTTP(res, req)
+	require.Equal(t, 200, res.Code)
+	var status CredentialsStatus
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &status))
+	return status
+}
+
+func TestStatus(t *testing.T) {
+	for _, test := range []struct {
+		certificate string
+		state       string
+	}{
+		{"", "permanent"},
+		{certExpiringIn(time.Hour), "fresh"},
+		{certExpiringIn(30 * time.Second), "expiring"},
+		{certExpiringIn(-time.Second), "expired"},
+	} {
+		routes := newRefreshTestRoutes("https://tc.example.com", "some-client", test.certificate)
+		status := getStatus(t, routes)
+		assert.Equal(t, test.state, status.State)
+		assert.Equal(t, "some-client", status.ClientID)
+		assert.Nil(t, status.LastUpdated)
+		if test.state == "permanent" {
+			assert.Nil(t, status.Expires)
+			assert.Nil(t, status.SecondsRemaining)
+		} else {
+			assert.NotNil(t, status.Expires)
+			assert.NotNil(t, status.SecondsRemaining)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/status", nil)
+	res := httptest.NewRecorder()
+	newRefreshTestRoutes("https://tc.example.com", "some-client", "").ServeHTTP(res, req)
+	assert.Equal(t, 405, res.Code)
+}
+
+func TestRequestWaitsForNewCredentials(t *testing.T) {
+	var mu sync.Mutex
+	authorizations := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+	routes := newRefreshTestRoutes(ts.URL, "old-client", certExpiringIn(10*time.Second))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req := httptest.NewRequest("GET", "/api/queue/v1/task/abc", nil)
+		res := httptest.NewRecorder()
+		routes.ServeHTTP(res, req)
+		done <- res
+	}()
+
+	// give the request a chance to start waiting
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	assert.Empty(t, authorizations)
+	mu.Unlock()
+
+	body, err := json.Marshal(&CredentialsUpdate{
+		ClientID:    "new-client",
+		AccessToken: "doesn't-matter",
+		Certificate: certExpiringIn(time.Hour),
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest("PUT", "/credentials", bytes.NewReader(body))
+	res := httptest.NewRecorder()
+	routes.ServeHTTP(res, req)
+	require.Equal(t, 200, res.Code)
+
+	select {
+	case res := <-done:
+		assert.Equal(t, 200, res.Code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request was not released by credentials update")
+	}
+	mu.Lock()
+	require.Len(t, authorizations, 1)
+	assert.True(t, strings.Contains(authorizations[0], `id="new-client"`), "request signed with %q", authorizations[0])
+	mu.Unlock()
+
+	status := getStatus(t, routes)
+	assert.Equal(t, "fresh", status.State)
+	assert.NotNil(t, status.LastUpdated)
+}
+
+func TestRequestProceedsWithoutNewCredentials(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+	routes := newRefreshTestRoutes(ts.URL, "old-client", certExpiringIn(10*time.Second))
+	routes.refreshWait = 100 * time.Millisecond
+
+	start := time.Now()
+	req := httptest.NewRequest("GET", "/api/queue/v1/task/abc", nil)
+	res := httptest.NewRecorder()
+	routes.ServeHTTP(res, req)
+	assert.Equal(t, 200, res.Code)
+	assert.Equal(t, 1, calls)
+	assert.GreaterOrEqual(t, time.Since(start), routes.refreshWait)
+
+	// fresh credentials do not wait at all
+	routes.refreshWait = time.Hour
+	routes.Credentials.Certificate = certExpiringIn(time.Hour)
+	res = httptest.NewRecorder()
+	routes.ServeHTTP(res, httptest.NewRequest("GET", "/api/queue/v1/task/abc", nil))
+	assert.Equal(t, 200, res.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestCredentialsUpdateNotBlockedBySlowRequest(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+	defer close(release)
+	routes := newRefreshTestRoutes(ts.URL, "old-client", "")
+
+	go func() {
+		req := httptest.NewRequest("GET", "/api/queue/v1/task/abc", nil)
+		routes.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	body, err := json.Marshal(&CredentialsUpdate{ClientID: "new-client", AccessToken: "doesn't-matter"})
+	require.NoError(t, err)
+	updated := make(chan int)
+	go func() {
+		res := httptest.NewRecorder()
+		routes.ServeHTTP(res, httptest.NewRequest("PUT", "/credentials", bytes.NewReader(body)))
+		updated <- res.Code
+	}()
+	select {
+	case code := <-updated:
+		assert.Equal(t, 200, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Credentials update blocked by in-flight request")
+	}
+}
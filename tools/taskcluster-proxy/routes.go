@@ -26,6 +26,17 @@ type Routes struct {
 	tcclient.Client
 	services tc.Services
 	lock     sync.RWMutex
+	audit    *AuditLog
+	denied   []*tc.APIEntry
+	cache    *ResponseCache
+
+	// Requests made within expiryMargin of the credentials expiring wait up
+	// to refreshWait for new credentials before being proxied.
+	expiryMargin time.Duration
+	refreshWait  time.Duration
+	refreshLock  sync.Mutex
+	refreshed    chan struct{}
+	lastUpdated  time.Time
 }
 
 // CredentialsUpdate is the internal representation of the json body which is
@@ -90,6 +101,8 @@ func (routes *Routes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		routes.BewitHandler(w, r)
 	} else if strings.HasPrefix(url, "/credentials") {
 		routes.CredentialsHandler(w, r)
+	} else if strings.HasPrefix(url, "/status") {
+		routes.StatusHandler(w, r)
 	} else if strings.HasPrefix(url, "/api") {
 		routes.APIHandler(w, r)
 	} else {
@@ -101,6 +114,7 @@ func (routes *Routes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (routes *Routes) BewitHandler(res http.ResponseWriter, req *http.Request) {
 	// Using ReadAll could be sketchy here since we are reading unbounded data
 	// into memory...
+	routes.awaitFreshCredentials()
 	routes.setHeaders(res)
 	body, err := io.ReadAll(req.Body)
 
@@ -119,6 +133,13 @@ func (routes *Routes) BewitHandler(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if entry := routes.deniedEntry("GET", urlObject); entry != nil {
+		log.Printf("Refusing to create bewit url for denied API method %v: %s", entry, urlString)
+		res.WriteHeader(403)
+		fmt.Fprintf(res, "API method %v may not be called via the taskcluster proxy", entry)
+		return
+	}
+
 	bewitURL, err := routes.SignedURL(urlString, urlObject.Query(), time.Hour*1)
 
 	if err != nil {
@@ -154,19 +175,19 @@ func (routes *Routes) CredentialsHandler(res http.ResponseWriter, req *http.Requ
 	}
 
 	routes.lock.Lock()
-	defer routes.lock.Unlock()
 	routes.Credentials.ClientID = credentials.ClientID
 	routes.Credentials.AccessToken = credentials.AccessToken
 	routes.Credentials.Certificate = credentials.Certificate
+	routes.lock.Unlock()
+	routes.notifyCredentialsRefreshed()
 
 	res.WriteHeader(200)
 }
 
 // RootHandler is the HTTP Handler for / endpoint
 func (routes *Routes) RootHandler(res http.ResponseWriter, req *http.Request) {
+	routes.awaitFreshCredentials()
 	routes.setHeaders(res)
-	routes.lock.RLock()
-	defer routes.lock.RUnlock()
 
 	targetPath, err := routes.services.ConvertPath(req.URL)
 
@@ -177,16 +198,15 @@ func (routes *Routes) RootHandler(res http.ResponseWriter, req *http.Request) {
 		fmt.Fprintf(res, "Unkown taskcluster service: %s", err)
 		return
 	}
-	routes.commonHandler(res, req, targetPath)
+	routes.proxyHandler(res, req, targetPath)
 }
 
 var apiPath = regexp.MustCompile("^/api/(?P<service>[^/]*)/(?P<apiVersion>[^/]*)/(?P<path>.*)$")
 
 // APIHandler is the HTTP Handler for /api endpoint
 func (routes *Routes) APIHandler(res http.ResponseWriter, req *http.Request) {
+	routes.awaitFreshCredentials()
 	routes.setHeaders(res)
-	routes.lock.RLock()
-	defer routes.lock.RUnlock()
 
 	rawPath := req.URL.EscapedPath()
 
@@ -213,14 +233,63 @@ func (routes *Routes) APIHandler(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	routes.commonHandler(res, req, targetPath)
+	routes.proxyHandler(res, req, targetPath)
 }
 
 // Common code for RootHandler and APIHandler
-func (routes *Routes) commonHandler(res http.ResponseWriter, req *http.Request, targetPath *url.URL) {
-	res.Header().Set("X-Taskcluster-Endpoint", targetPath.String())
+func (routes *Routes) proxyHandler(w http.ResponseWriter, req *http.Request, targetPath *url.URL) {
+	w.Header().Set("X-Taskcluster-Endpoint", targetPath.String())
 	log.Printf("Proxying %s | %s | %s", req.URL, req.Method, targetPath)
 
+	res := &statusRecorder{ResponseWriter: w}
+	service, endpoint := routes.describeTarget(targetPath)
+	start := time.Now()
+	denied := false
+	defer func() {
+		routes.audit.Record(AuditEntry{
+			Time:      start.UTC(),
+			Method:    req.Method,
+			Service:   service,
+			Endpoint:  endpoint,
+			Status:    res.status,
+			LatencyMS: time.Since(start).Milliseconds(),
+			Denied:    denied,
+		})
+	}()
+
+	// Denied API methods are rejected before the request is signed, so that
+	// the task's credentials are never used for them.
+	if entry := routes.deniedEntry(req.Method, targetPath); entry != nil {
+		denied = true
+		log.Printf("Refusing to proxy denied API method %v: %s", entry, targetPath)
+		res.WriteHeader(403)
+		fmt.Fprintf(res, "API method %v may not be called via the taskcluster proxy", entry)
+		return
+	}
+
+	// Streams are signed once, and then may stay open indefinitely, so are
+	// neither buffered nor retried.
+	if isStreamingRequest(req) {
+		routes.streamHandler(res, req, targetPath)
+		return
+	}
+
+	routes.commonHandler(res, req, targetPath, routes.credentials())
+}
+
+// credentials returns a copy of the current credentials, so that they can be
+// used without holding routes.lock, which would block credentials updates.
+func (routes *Routes) credentials() *tcclient.Credentials {
+	routes.lock.RLock()
+	defer routes.lock.RUnlock()
+	creds := *routes.Credentials
+	return &creds
+}
+
+// commonHandler proxies a request/response exchange with the given
+// credentials, buffering the request and response bodies so that the request
+// can be retried.
+func (routes *Routes) commonHandler(res http.ResponseWriter, req *http.Request, targetPath *url.URL, creds *tcclient.Credentials) {
 	// In theory, req.Body should never be nil when running as a server, but
 	// during testing, with a direct call to the method rather than a real http
 	// request coming in from outside, it could be. For example see:
@@ -245,6 +314,24 @@ func (routes *Routes) commonHandler(res http.ResponseWriter, req *http.Request,
 		}
 	}
 
+	// Only GET requests are served from the cache, and only if the caller has
+	// not asked to bypass caches. A stale cached response is revalidated with
+	// its ETag, unless the caller is making its own conditional request.
+	var cacheKey string
+	var cached *CachedResponse
+	if routes.cache != nil && req.Method == "GET" && !strings.Contains(req.Header.Get("Cache-Control"), "no-cache") {
+		cacheKey = routes.cacheKey(creds, targetPath)
+		var fresh bool
+		cached, fresh = routes.cache.Get(cacheKey)
+		if fresh {
+			routes.writeCachedResponse(res, req, targetPath, cached, "HIT")
+			return
+		}
+		if cached != nil && (cached.ETag == "" || req.Header.Get("If-None-Match") != "") {
+			cached = nil
+		}
+	}
+
 	// function to perform http request - we call this using backoff library to
 	// have exponential backoff in case of intermittent failures (e.g. network
 	// blips or HTTP 5xx errors)
@@ -254,6 +341,9 @@ func (routes *Routes) commonHandler(res http.ResponseWriter, req *http.Request,
 			return nil, nil, fmt.Errorf("error constructing request: %s", err)
 		}
 		maps.Copy(proxyreq.Header, req.Header)
+		if cached != nil {
+			proxyreq.Header.Set("If-None-Match", cached.ETag)
+		}
 
 		// for compatibility, if there is no request Content-Type and the body
 		// has nonzero length, we add a Content-Type header.  See #3521.
@@ -263,7 +353,7 @@ func (routes *Routes) commonHandler(res http.ResponseWriter, req *http.Request,
 		}
 
 		// Refresh Authorization header with each call...
-		err = routes.Credentials.SignRequest(proxyreq)
+		err = creds.SignRequest(proxyreq)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -297,6 +387,20 @@ func (routes *Routes) commonHandler(res http.ResponseWriter, req *http.Request,
 		}
 	}
 
+	if cacheKey != "" {
+		switch {
+		case cached != nil && proxyres.StatusCode == http.StatusNotModified:
+			cached = routes.cache.Revalidated(cacheKey, cached, proxyres.Header)
+			routes.writeCachedResponse(res, req, targetPath, cached, "REVALIDATED")
+			return
+		case proxyres.StatusCode == http.StatusOK:
+			routes.cache.Store(cacheKey, proxyres.Header, resbody)
+		}
+		hits, misses := routes.cache.Count(false)
+		log.Printf("Cache miss for %s (%d hits, %d misses)", targetPath, hits, misses)
+		res.Header().Set("X-Taskcluster-Proxy-Cache", "MISS")
+	}
+
 	// Map the headers from the proxy back into our proxyResponse
 	for key := range proxyres.Header {
 		res.Header().Set(key, proxyres.Header.Get(key))
@@ -312,3 +416,56 @@ func (routes *Routes) commonHandler(res http.ResponseWriter, req *http.Request,
 		return
 	}
 }
+
+// cacheKey identifies cached responses by the credentials used to fetch them
+// as well as the target URL, so that a response is never served to a caller
+// with different scopes.
+func (routes *Routes) cacheKey(creds *tcclient.Credentials, targetPath *url.URL) string {
+	return strings.Join([]string{
+		creds.ClientID,
+		strings.Join(creds.AuthorizedScopes, " "),
+		targetPath.String(),
+	}, "\n")
+}
+
+// writeCachedResponse serves a response from the cache, logging the result
+// (HIT or REVALIDATED) and running totals. If the caller already holds the
+// cached version, according to its If-None-Match header, it gets a 304
+// response without a body.
+func (routes *Routes) writeCachedResponse(res http.ResponseWriter, req *http.Request, targetPath *url.URL, cached *CachedResponse, result string) {
+	hits, misses := routes.cache.Count(true)
+	log.Printf("Cache %s for %s (%d hits, %d misses)", strings.ToLower(result), targetPath, hits, misses)
+	for key := range cached.Header {
+		res.Header().Set(key, cached.Header.Get(key))
+	}
+	res.Header().Set("X-Taskcluster-Proxy-Cache", result)
+	if etagMatches(req.Header.Get("If-None-Match"), cached.ETag) {
+		res.Header().Del("Content-Length")
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+	res.WriteHeader(cached.StatusCode)
+	_, err := res.Write(cached.Body)
+	if err != nil {
+		log.Printf("Error writing cached response: %s", err)
+	}
+}
+
+// etagMatches reports whether the If-None-Match header value ifNoneMatch
+// (either "*" or a comma-separated list of entity tags) matches etag. As
+// required for If-None-Match, the comparison is weak, i.e. it ignores any
+// W/ prefix.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
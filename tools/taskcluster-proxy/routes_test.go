@@ -12,6 +12,22 @@ import (
 	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
 )
 
+// newTestRoutes returns Routes which proxy requests to rootURL, with
+// credentials that the upstream servers in these tests do not check.
+func newTestRoutes(rootURL string) *Routes {
+	routes := NewRoutes(
+		tcclient.Client{
+			Authenticate: true,
+			RootURL:      rootURL,
+			Credentials: &tcclient.Credentials{
+				ClientID:    "some-client",
+				AccessToken: "doesn't-matter",
+			},
+		},
+	)
+	return &routes
+}
+
 func TestHttpRedirects(t *testing.T) {
 	// set up an upstream server that will return a redirect
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// upgradeTransport is used for requests which upgrade the connection (e.g.
+// to a WebSocket), which is only possible over HTTP/1.1.
+var upgradeTransport = func() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Protocols = new(http.Protocols)
+	transport.Protocols.SetHTTP1(true)
+	return transport
+}()
+
+// streamTransport is used for other streamed requests, and uses HTTP/2
+// to the target where available.
+var streamTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+// isStreamingRequest returns true for requests which are passed through to
+// the target as a stream, rather than being buffered: those upgrading the
+// connection (e.g. WebSockets), gRPC calls, requests for server-sent events,
+// and HTTP/2 requests with a body of unknown length, which the client may
+// keep sending for as long as the stream is open. Other HTTP/2 requests are
+// buffered like HTTP/1.1 requests, so that they can be retried and cached.
+func isStreamingRequest(req *http.Request) bool {
+	switch {
+	case httpguts.HeaderValuesContainsToken(req.Header["Connection"], "Upgrade"):
+		return true
+	case strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc"):
+		return true
+	case strings.Contains(req.Header.Get("Accept"), "text/event-stream"):
+		return true
+	case req.ProtoMajor == 2 && req.ContentLength < 0 && req.Body != nil && req.Body != http.NoBody:
+		return true
+	}
+	return false
+}
+
+// streamHandler passes a request through to targetPath without buffering,
+// copying data in both directions for as long as the connection stays open.
+// The request is signed once, when the connection is established, so it is
+// not retried, and is not affected by later credentials updates.
+func (routes *Routes) streamHandler(res http.ResponseWriter, req *http.Request, targetPath *url.URL) {
+	signed, err := http.NewRequest(req.Method, targetPath.String(), nil)
+	if err == nil {
+		err = routes.credentials().SignRequest(signed)
+	}
+	if err != nil {
+		res.WriteHeader(500)
+		fmt.Fprintf(res, "Failed to generate proxy request - %s", err)
+		return
+	}
+
+	transport := streamTransport
+	if httpguts.HeaderValuesContainsToken(req.Header["Connection"], "Upgrade") {
+		log.Printf("Upgrading connection to %s for %s", req.Header.Get("Upgrade"), targetPath)
+		transport = upgradeTransport
+	}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = targetPath
+			pr.Out.Host = ""
+			pr.Out.Header.Set("Authorization", signed.Header.Get("Authorization"))
+		},
+		Transport: transport,
+		// flush immediately, since responses may be streamed indefinitely
+		FlushInterval: -1,
+		ErrorHandler: func(res http.ResponseWriter, req *http.Request, err error) {
+			log.Printf("Failed during proxy stream to %s: %s", targetPath, err)
+			res.WriteHeader(502)
+			fmt.Fprintf(res, "Failed during proxy request: %s", err)
+		},
+	}
+	proxy.ServeHTTP(res, req)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradePassthrough(t *testing.T) {
+	authorization := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization <- r.Header.Get("Authorization")
+		if r.Header.Get("Upgrade") != "echo" {
+			w.WriteHeader(400)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Could not hijack connection: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(brw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_ = brw.Flush()
+		// echo lines back until the client closes the connection
+		for {
+			line, err := brw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fmt.Fprint(brw, "echo: "+line)
+			_ = brw.Flush()
+		}
+	}))
+	defer upstream.Close()
+	routes := newTestRoutes(upstream.URL)
+	proxy := httptest.NewServer(routes)
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprint(conn, "GET /api/notify/v1/socket HTTP/1.1\r\nHost: proxy\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, 101, res.StatusCode)
+	assert.Contains(t, <-authorization, `id="some-client"`)
+
+	// credentials can be updated while the connection is open
+	body, err := json.Marshal(&CredentialsUpdate{ClientID: "new-client", AccessToken: "doesn't-matter"})
+	require.NoError(t, err)
+	updated := make(chan int)
+	go func() {
+		res := httptest.NewRecorder()
+		routes.ServeHTTP(res, httptest.NewRequest("PUT", "/credentials", bytes.NewReader(body)))
+		updated <- res.Code
+	}()
+	select {
+	case code := <-updated:
+		assert.Equal(t, 200, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Credentials update blocked by open connection")
+	}
+
+	for _, msg := range []string{"hello\n", "world\n"} {
+		fmt.Fprint(conn, msg)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "echo: "+msg, line)
+	}
+}
+
+func TestH2CStreamPassthrough(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Authorization"), `id="some-client"`)
+		fmt.Fprintln(w, "first")
+		_ = http.NewResponseController(w).Flush()
+		<-release
+		fmt.Fprintln(w, "second")
+	}))
+	defer upstream.Close()
+	proxy := httptest.NewUnstartedServer(newTestRoutes(upstream.URL))
+	proxy.Config.Protocols = new(http.Protocols)
+	proxy.Config.Protocols.SetHTTP1(true)
+	proxy.Config.Protocols.SetUnencryptedHTTP2(true)
+	proxy.Start()
+	defer proxy.Close()
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport}
+	req, err := http.NewRequest("GET", proxy.URL+"/api/queue/v1/stream", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, 2, res.ProtoMajor)
+	assert.Equal(t, 200, res.StatusCode)
+
+	// the first line arrives before the upstream response is complete
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", line)
+	close(release)
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(rest))
+}
+
+func TestIsStreamingRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/queue/v1/task/abc", nil)
+	assert.False(t, isStreamingRequest(req))
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	assert.True(t, isStreamingRequest(req))
+
+	req = httptest.NewRequest("GET", "/api/queue/v1/task/abc", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	assert.True(t, isStreamingRequest(req))
+
+	req = httptest.NewRequest("POST", "/api/queue/v1/task/abc", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	assert.True(t, isStreamingRequest(req))
+
+	// HTTP/2 requests are only streamed if their body is of unknown length
+	req = httptest.NewRequest("POST", "/api/queue/v1/task/abc", strings.NewReader("{}"))
+	req.ProtoMajor = 2
+	assert.False(t, isStreamingRequest(req))
+	req.ContentLength = -1
+	assert.True(t, isStreamingRequest(req))
+	req = httptest.NewRequest("GET", "/api/queue/v1/task/abc", nil)
+	req.ProtoMajor = 2
+	req.ContentLength = -1
+	assert.False(t, isStreamingRequest(req))
+}
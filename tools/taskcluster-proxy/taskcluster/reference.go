@@ -0,0 +1,165 @@
+package taskcluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/taskcluster/httpbackoff/v3"
+	tcUrls "github.com/taskcluster/taskcluster-lib-urls"
+)
+
+// APIEntry is a single API method of a Taskcluster service, as described in
+// the service's API reference.
+type APIEntry struct {
+	Service    string
+	APIVersion string
+	Name       string
+	Method     string
+	Route      string
+	pattern    *regexp.Regexp
+}
+
+type apiReference struct {
+	ServiceName string `json:"serviceName"`
+	APIVersion  string `json:"apiVersion"`
+	Entries     []struct {
+		Type   string `json:"type"`
+		Name   string `json:"name"`
+		Method string `json:"method"`
+		Route  string `json:"route"`
+	} `json:"entries"`
+}
+
+var routeParam = regexp.MustCompile(`<[^<>]+>`)
+
+// String returns the entry in the form <service>.<method>, e.g.
+// auth.resetAccessToken.
+func (e *APIEntry) String() string {
+	return e.Service + "." + e.Name
+}
+
+var repeatedSlashes = regexp.MustCompile(`//+`)
+
+// Matches returns true if an HTTP request with the given method, for the
+// given (escaped) path relative to the service's API base URL, would be
+// handled by this API entry.  Repeated slashes in the path are treated as
+// one, so that requests cannot avoid a match by adding extra slashes.
+func (e *APIEntry) Matches(method string, service string, apiVersion string, path string) bool {
+	return e.Service == service &&
+		e.APIVersion == apiVersion &&
+		strings.EqualFold(e.Method, method) &&
+		e.pattern.MatchString(repeatedSlashes.ReplaceAllString(path, "/"))
+}
+
+type apiManifest struct {
+	References []string `json:"references"`
+}
+
+// getJSON fetches the given URL and decodes its JSON body into out.
+func getJSON(u string, out any) error {
+	resp, _, err := httpbackoff.Get(u)
+	if err != nil {
+		return fmt.Errorf("could not fetch %v: %v", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read %v: %v", u, err)
+	}
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		return fmt.Errorf("could not parse %v: %v", u, err)
+	}
+	return nil
+}
+
+// APIEntries fetches the API references of every version of the given
+// service listed in the RootURL's API manifest, and returns the entries with
+// the given name, one for each version that has such a method.
+func (s *Services) APIEntries(service string, name string) ([]*APIEntry, error) {
+	var manifest apiManifest
+	err := getJSON(tcUrls.APIManifest(s.RootURL), &manifest)
+	if err != nil {
+		return nil, err
+	}
+	entries := []*APIEntry{}
+	for _, refURL := range manifest.References {
+		u, err := url.Parse(refURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API reference URL %v in manifest: %v", refURL, err)
+		}
+		// references are named .../references/<service>/<version>/api.json
+		parts := strings.Split(u.Path, "/")
+		n := len(parts)
+		if n < 4 || parts[n-4] != "references" || parts[n-3] != service || parts[n-1] != "api.json" {
+			continue
+		}
+		var ref apiReference
+		err = getJSON(tcUrls.APIReference(s.RootURL, service, parts[n-2]), &ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range ref.Entries {
+			if entry.Type != "function" || entry.Name != name {
+				continue
+			}
+			entries = append(entries, &APIEntry{
+				Service:    service,
+				APIVersion: ref.APIVersion,
+				Name:       entry.Name,
+				Method:     entry.Method,
+				Route:      entry.Route,
+				pattern:    routePattern(entry.Route),
+			})
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("service %v has no API method %v", service, name)
+	}
+	return entries, nil
+}
+
+// routePattern converts a route such as /clients/<clientId>/reset into a
+// regular expression that matches escaped request paths. Route parameters
+// never span path segments.  The services route requests with a trailing
+// slash just like those without, so the pattern allows one.
+func routePattern(route string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range routeParam.FindAllStringIndex(route, -1) {
+		pattern.WriteString(regexp.QuoteMeta(route[last:loc[0]]))
+		pattern.WriteString("[^/]+")
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(strings.TrimSuffix(route[last:], "/")))
+	pattern.WriteString("/?$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// SplitAPIURL splits a URL targeting an API of the RootURL deployment into
+// its service name, API version and path (including leading slash). If the
+// URL targets some other host, ok is false.
+func (s *Services) SplitAPIURL(u *url.URL) (service string, apiVersion string, path string, ok bool) {
+	root, err := url.Parse(s.RootURL)
+	if err != nil || !strings.EqualFold(root.Host, u.Host) {
+		return
+	}
+	rest, found := strings.CutPrefix(u.EscapedPath(), strings.TrimRight(root.EscapedPath(), "/")+"/api/")
+	if !found {
+		return
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 2 {
+		return
+	}
+	service, apiVersion, path, ok = parts[0], parts[1], "/", true
+	if len(parts) == 3 {
+		path += parts[2]
+	}
+	return
+}
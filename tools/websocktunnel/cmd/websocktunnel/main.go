@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"log/syslog"
+	"net/http"
+	"os"
+
+	docopt "github.com/docopt/docopt-go"
+	"github.com/gorilla/websocket"
+	mozlog "github.com/mozilla-services/go-mozlogrus"
+	log "github.com/sirupsen/logrus"
+	lSyslog "github.com/sirupsen/logrus/hooks/syslog"
+	"github.com/taskcluster/taskcluster/v84/internal"
+	"github.com/taskcluster/taskcluster/v84/tools/websocktunnel/relay"
+	"github.com/taskcluster/taskcluster/v84/tools/websocktunnel/wsproxy"
+)
+
+const usage = `Websocketunnel Server
+
+Usage:
+  websocktunnel
+  websocktunnel relay
+  websocktunnel -h | --help
+  websocktunnel --short-version
+  websocktunnel --version
+
+The relay command runs a minimal websocktunnel server for local or stand-in
+deployments, which authenticates clients with RELAY_SECRET rather than with
+tokens issued by the Taskcluster auth service.
+
+Environment:
+ URL_PREFIX (required)								URL prefix (http(s)://hostname(:port)) at which
+													this service is publicly exposed
+ PORT (optional; defaults to 80 or 443)				port on which to listen
+ TLS_CERTIFICATE (optional; no TLS if not provided) base64-encoded TLS certificate
+ TLS_KEY											corresponding base64-encoded TLS key
+ TASKCLUSTER_PROXY_SECRET_A							JWT secret
+ TASKCLUSTER_PROXY_SECRET_B							alternate JWT secret
+ SYSLOG_ADDR										address to which to send syslog output
+ AUDIENCE											JWT 'audience' claim
+ RELAY_SECRET (required for relay)					secret shared with relay clients
+
+Options:
+-h --help       Show help
+--short-version Show only the semantic version`
+
+func main() {
+	opts, _ := docopt.ParseArgs(usage, nil, "websocktunnel "+internal.Version)
+
+	if opts["--short-version"].(bool) {
+		fmt.Printf("%s\n", internal.Version)
+		os.Exit(0)
+	}
+
+	urlPrefix := os.Getenv("URL_PREFIX")
+	if urlPrefix == "" {
+		panic("URL_PREFIX is required")
+	}
+
+	logger := log.New()
+
+	if env := os.Getenv("ENV"); env == "production" {
+		// add mozlog formatter
+		logger.Formatter = &mozlog.MozLogFormatter{
+			LoggerName: "websocktunnel",
+		}
+
+		// add syslog hook if addr is provided
+		syslogAddr := os.Getenv("SYSLOG_ADDR")
+		if syslogAddr != "" {
+			hook, err := lSyslog.NewSyslogHook("udp", syslogAddr, syslog.LOG_DEBUG, "websocktunnel")
+			if err != nil {
+				panic(err)
+			}
+			logger.Hooks.Add(hook)
+		}
+	}
+
+	// Load secrets
+	signingSecretA := os.Getenv("TASKCLUSTER_PROXY_SECRET_A")
+	signingSecretB := os.Getenv("TASKCLUSTER_PROXY_SECRET_B")
+
+	// Load TLS certificates
+	useTLS := true
+	tlsKeyEnc := os.Getenv("TLS_KEY")
+	tlsCertEnc := os.Getenv("TLS_CERTIFICATE")
+
+	tlsKey, _ := base64.StdEncoding.DecodeString(tlsKeyEnc)
+	tlsCert, _ := base64.StdEncoding.DecodeString(tlsCertEnc)
+	cert, err := tls.X509KeyPair([]byte(tlsCert), []byte(tlsKey))
+	if err != nil {
+		logger.Error(err.Error())
+		useTLS = false
+	}
+
+	//load port
+	port := os.Getenv("PORT")
+	if port == "" {
+		if useTLS {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+
+	// load audience value
+	audience := os.Getenv("AUDIENCE")
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+
+	var proxy http.Handler
+	if opts["relay"].(bool) {
+		proxy, err = relay.New(relay.Config{
+			Logger:    logger,
+			Secret:    []byte(os.Getenv("RELAY_SECRET")),
+			URLPrefix: urlPrefix,
+		})
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		// will panic if secrets are not loaded
+		proxy, _ = wsproxy.New(wsproxy.Config{
+			Logger:     logger,
+			Upgrader:   upgrader,
+			JWTSecretA: []byte(signingSecretA),
+			JWTSecretB: []byte(signingSecretB),
+			URLPrefix:  urlPrefix,
+			Audience:   audience,
+		})
+	}
+
+	server := &http.Server{Addr: ":" + port, Handler: proxy}
+	defer func() {
+		_ = server.Close()
+	}()
+	logger.WithFields(log.Fields{
+		"server-addr": server.Addr,
+	}).Info("starting server")
+
+	// create tls config and serve
+	if useTLS {
+		config := &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}
+		listener, err := tls.Listen("tcp", ":"+port, config)
+		if err != nil {
+			panic(err)
+		}
+		_ = server.Serve(listener)
+	} else {
+		err = server.ListenAndServe()
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package relay implements a minimal websocktunnel server, for local or
+// stand-in deployments that do not run a websocktunnel service of their own.
+//
+// A relay speaks the same protocol as a websocktunnel server, so clients
+// connect to it with the websocktunnel client, making a single outbound
+// connection over which viewer requests are multiplexed.  However, instead of
+// validating tokens issued by the Taskcluster auth service, a relay shares a
+// secret with its clients, and clients sign their own tokens with that secret
+// (see Token).  So no Taskcluster credentials or scopes are required.
+//
+//	viewer ----> [ relay ] <--- websocket --- client
+package relay
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/taskcluster/taskcluster/v84/tools/websocktunnel/wsproxy"
+)
+
+// Audience is the value of the aud claim of relay tokens.
+const Audience = "websocktunnel-relay"
+
+// tokenValidity is how long before and after its creation a token is valid,
+// allowing for clock drift between relay and client.  Clients generate a
+// fresh token each time they (re)connect, so it needn't be valid for long.
+const tokenValidity = time.Hour
+
+// Config contains the run time parameters for a relay
+type Config struct {
+	// Secret shared with clients, which they use to sign their tokens.
+	Secret []byte
+
+	// The prefix for publicly accessible URLs (used to generate the URLs sent
+	// to clients)
+	URLPrefix string
+
+	// Logger is used to log relay events; default is no logging.
+	Logger *logrus.Logger
+}
+
+// New creates a new relay, wrapped as an http.Handler.
+func New(conf Config) (http.Handler, error) {
+	if len(conf.Secret) == 0 {
+		return nil, errors.New("relay: missing secret")
+	}
+	return wsproxy.New(wsproxy.Config{
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+		Logger:     conf.Logger,
+		JWTSecretA: conf.Secret,
+		JWTSecretB: conf.Secret,
+		URLPrefix:  conf.URLPrefix,
+		Audience:   Audience,
+	})
+}
+
+// Token generates a token for the client with the given ID, signed with the
+// secret shared with the relay.
+func Token(secret []byte, id string) (string, error) {
+	now := time.Now()
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"tid": id,
+		"iat": now.Unix(),
+		"nbf": now.Add(-tokenValidity).Unix(),
+		"exp": now.Add(tokenValidity).Unix(),
+		"aud": Audience,
+	}).SignedString(secret)
+}
@@ -0,0 +1,97 @@
+package relay
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/websocktunnel/client"
+)
+
+var secret = []byte("relay-secret")
+
+func startRelay(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(nil)
+	handler, err := New(Config{
+		Secret:    secret,
+		URLPrefix: "http://" + server.Listener.Addr().String(),
+	})
+	require.NoError(t, err)
+	server.Config.Handler = handler
+	server.Start()
+	return server
+}
+
+func configurer(id, addr string, secret []byte) client.Configurer {
+	return func() (client.Config, error) {
+		token, err := Token(secret, id)
+		if err != nil {
+			return client.Config{}, err
+		}
+		return client.Config{
+			ID:         id,
+			TunnelAddr: addr,
+			Token:      token,
+			Retry: client.RetryConfig{
+				MaxElapsedTime: 5 * time.Second,
+			},
+			ConnectHook: func(cl *client.Client) {
+				server := &http.Server{
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						fmt.Fprintf(w, "Hello from %s @ %s", id, r.URL.Path)
+					}),
+				}
+				go func() {
+					_ = server.Serve(cl)
+				}()
+			},
+		}, nil
+	}
+}
+
+func TestNewWithoutSecret(t *testing.T) {
+	_, err := New(Config{URLPrefix: "http://localhost"})
+	require.Error(t, err)
+}
+
+func TestRelay(t *testing.T) {
+	relay := startRelay(t)
+	defer relay.Close()
+
+	cl, err := client.New(configurer("worker.one", relay.URL, secret))
+	require.NoError(t, err)
+	defer cl.Close()
+
+	require.Equal(t, relay.URL+"/worker.one", cl.URL())
+
+	res, err := http.Get(cl.URL() + "/abc/def")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "Hello from worker.one @ /abc/def", string(body))
+}
+
+func TestRelayWrongSecret(t *testing.T) {
+	relay := startRelay(t)
+	defer relay.Close()
+
+	_, err := client.New(configurer("worker.two", relay.URL, []byte("not-the-secret")))
+	require.ErrorIs(t, err, client.ErrAuthFailed)
+}
+
+func TestRelayUnknownClient(t *testing.T) {
+	relay := startRelay(t)
+	defer relay.Close()
+
+	res, err := http.Get(relay.URL + "/worker.three/abc")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+}
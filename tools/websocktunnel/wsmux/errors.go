@@ -13,10 +13,10 @@ var (
 	ErrBrokenPipe = errors.New("broken pipe")
 
 	// ErrWriteTimeout if the write operation on a stream times out
-	ErrWriteTimeout = errors.New("wsmux: write operation timed out")
+	ErrWriteTimeout error = timeoutError("wsmux: write operation timed out")
 
 	// ErrReadTimeout if the read operation on a stream times out
-	ErrReadTimeout = errors.New("wsmux: read operation timed out")
+	ErrReadTimeout error = timeoutError("wsmux: read operation timed out")
 
 	// ErrNoCapacity is returns if the read buffer is full and a session attempts to load
 	// more data into the buffer
@@ -40,3 +40,12 @@ var (
 	// ErrTooManySyns indicates too many un-accepted new incoming streams
 	ErrTooManySyns = errors.New("too many un-accepted new incoming streams")
 )
+
+// timeoutError is returned when a stream deadline is exceeded.  It implements
+// net.Error, so that users of a stream as a net.Conn, such as http.Server,
+// recognize it as a timeout.
+type timeoutError string
+
+func (e timeoutError) Error() string   { return string(e) }
+func (e timeoutError) Timeout() bool   { return true }
+func (e timeoutError) Temporary() bool { return true }
@@ -0,0 +1,8 @@
+package cfg
+
+// CacheEncryptionConfig configures encryption of the cacheOverRestarts file.
+// See the usage string for field descriptions.
+type CacheEncryptionConfig struct {
+	KeyFile      string `yaml:"keyFile"`
+	TPMSealedKey string `yaml:"tpmSealedKey"`
+}
@@ -32,3 +32,13 @@ func (lc *LoggingConfig) UnmarshalYAML(node *yaml.Node) error {
 
 	return nil
 }
+
+// Unpack this LoggingConfig to a logging implementation's configuration struct.  This will produce
+// an error for any missing properties.  Note that recursion is not supported.
+//
+// Structs should be tagged with `logging:"name"`, with the name defaulting to the
+// lowercased version of the field name.  Properties tagged `logging:"name,optional"`
+// may be omitted, in which case the field is not modified.
+func (lc *LoggingConfig) Unpack(out any) error {
+	return unpack(lc.Data, "logging", "logging", out)
+}
@@ -17,3 +17,63 @@ func TestUnmarshalLoggingYAML(t *testing.T) {
 	require.Equal(t, "stdio", lc.Implementation)
 	require.Equal(t, map[string]any{"foo": "bar"}, lc.Data)
 }
+
+func TestLoggingUnpack(t *testing.T) {
+	type mylc struct {
+		Value   int
+		Another string `logging:"anotherValue"`
+	}
+
+	var lc LoggingConfig
+	err := yaml.Unmarshal([]byte(`{"implementation": "x", "value": 10, "anotherValue": "hi"}`), &lc)
+	require.NoError(t, err)
+
+	var c mylc
+	err = lc.Unpack(&c)
+	require.NoError(t, err)
+	require.Equal(t, mylc{10, "hi"}, c)
+}
+
+func TestLoggingUnpackMissing(t *testing.T) {
+	type mylc struct {
+		Value int
+	}
+
+	var lc LoggingConfig
+	err := yaml.Unmarshal([]byte(`{"implementation": "x"}`), &lc)
+	require.NoError(t, err)
+
+	var c mylc
+	err = lc.Unpack(&c)
+	require.Error(t, err)
+}
+
+func TestLoggingUnpackOptional(t *testing.T) {
+	type mylc struct {
+		Value   int    `logging:",optional"`
+		Another string `logging:"anotherValue,optional"`
+	}
+
+	var lc LoggingConfig
+	err := yaml.Unmarshal([]byte(`{"implementation": "x", "anotherValue": "hi"}`), &lc)
+	require.NoError(t, err)
+
+	c := mylc{Value: 5}
+	err = lc.Unpack(&c)
+	require.NoError(t, err)
+	require.Equal(t, mylc{5, "hi"}, c)
+}
+
+func TestLoggingUnpackWrongType(t *testing.T) {
+	type mylc struct {
+		Value int
+	}
+
+	var lc LoggingConfig
+	err := yaml.Unmarshal([]byte(`{"implementation": "x", "value": "yo"}`), &lc)
+	require.NoError(t, err)
+
+	var c mylc
+	err = lc.Unpack(&c)
+	require.Error(t, err)
+}
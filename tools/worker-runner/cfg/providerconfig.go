@@ -2,8 +2,6 @@ package cfg
 
 import (
 	"fmt"
-	"reflect"
-	"strings"
 
 	yaml "gopkg.in/yaml.v3"
 )
@@ -39,42 +37,8 @@ func (pc *ProviderConfig) UnmarshalYAML(node *yaml.Node) error {
 // an error for any missing properties.  Note that recursion is not supported.
 //
 // Structs should be tagged with `provider:"name"`, with the name defaulting to the
-// lowercased version of the field name.
+// lowercased version of the field name.  Properties tagged `provider:"name,optional"`
+// may be omitted, in which case the field is not modified.
 func (pc *ProviderConfig) Unpack(out any) error {
-	outval := reflect.ValueOf(out)
-	if outval.Kind() != reflect.Ptr || outval.IsNil() {
-		return fmt.Errorf("expected a pointer, got %s", outval.Kind())
-	}
-	destval := reflect.Indirect(outval)
-	if destval.Kind() != reflect.Struct {
-		return fmt.Errorf("expected a pointer to struct, got &%s", destval.Kind())
-	}
-	desttype := destval.Type()
-	numfield := desttype.NumField()
-	for i := range numfield {
-		// get the expected property name
-		field := desttype.Field(i)
-		var name string
-		tag := field.Tag.Get("provider")
-		if tag == "" {
-			name = strings.ToLower(field.Name[:1]) + field.Name[1:]
-		} else {
-			name = tag
-		}
-
-		// get the value
-		val, ok := pc.Data[name]
-		if !ok {
-			return fmt.Errorf("configuration value `provider.%s` not found", name)
-		}
-
-		// check types and set the struct field
-		destfield := destval.Field(i)
-		gotval := reflect.ValueOf(val)
-		if destfield.Type() != gotval.Type() {
-			return fmt.Errorf("configuration value `provider.%s` should have type %s", name, destfield.Type())
-		}
-		destfield.Set(gotval)
-	}
-	return nil
+	return unpack(pc.Data, "provider", "provider", out)
 }
@@ -74,6 +74,24 @@ func TestProviderUnpackMissing(t *testing.T) {
 	}
 }
 
+func TestProviderUnpackOptional(t *testing.T) {
+	type mypc struct {
+		Value   int    `provider:"value,optional"`
+		Another string `provider:"anotherValue,optional"`
+	}
+
+	var pc ProviderConfig
+	err := yaml.Unmarshal([]byte(`{"providerType": "x", "value": 10}`), &pc)
+	assert.NoError(t, err, "should not fail")
+
+	c := mypc{Another: "default"}
+	err = pc.Unpack(&c)
+	if err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	assert.Equal(t, mypc{10, "default"}, c, "unpacked values correctly")
+}
+
 func TestProviderUnpackWrongType(t *testing.T) {
 	type mypc struct {
 		Value int
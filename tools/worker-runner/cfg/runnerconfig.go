@@ -9,12 +9,16 @@ import (
 // RunnerConfig defines the configuration for taskcluster-worker-starter.  See the usage
 // string for field descriptions
 type RunnerConfig struct {
-	Provider             ProviderConfig             `yaml:"provider"`
-	WorkerImplementation WorkerImplementationConfig `yaml:"worker"`
-	WorkerConfig         *WorkerConfig              `yaml:"workerConfig"`
-	Logging              *LoggingConfig             `yaml:"logging"`
-	GetSecrets           bool                       `yaml:"getSecrets"`
-	CacheOverRestarts    string                     `yaml:"cacheOverRestarts"`
+	Provider                    ProviderConfig             `yaml:"provider"`
+	WorkerImplementation        WorkerImplementationConfig `yaml:"worker"`
+	WorkerConfig                *WorkerConfig              `yaml:"workerConfig"`
+	Logging                     *LoggingConfig             `yaml:"logging"`
+	GetSecrets                  bool                       `yaml:"getSecrets"`
+	CacheOverRestarts           string                     `yaml:"cacheOverRestarts"`
+	CacheEncryption             *CacheEncryptionConfig     `yaml:"cacheEncryption"`
+	StatusEndpoint              string                     `yaml:"statusEndpoint"`
+	Supervise                   *SupervisionConfig         `yaml:"supervise"`
+	ConfigReloadIntervalSeconds int                        `yaml:"configReloadIntervalSeconds"`
 }
 
 // Load a configuration file
@@ -32,5 +36,8 @@ func LoadRunnerConfig(filename string) (*RunnerConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	if runnercfg.Supervise != nil {
+		runnercfg.Supervise.setDefaults()
+	}
 	return &runnercfg, nil
 }
@@ -1,11 +1,14 @@
 package cfg
 
 import (
+	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -21,3 +24,36 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, 10.0, runnercfg.WorkerConfig.MustGet("x"), "should read workerConfig correctly")
 	assert.Equal(t, true, runnercfg.GetSecrets, "getSecrets should default to true")
 }
+
+func TestLoadConfigSupervise(t *testing.T) {
+	load := func(t *testing.T, content string) *RunnerConfig {
+		t.Helper()
+		filename := filepath.Join(t.TempDir(), "runner.yml")
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+		runnercfg, err := LoadRunnerConfig(filename)
+		require.NoError(t, err)
+		return runnercfg
+	}
+
+	t.Run("not set", func(t *testing.T) {
+		runnercfg := load(t, "provider: {providerType: standalone}\n")
+		assert.Nil(t, runnercfg.Supervise)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		runnercfg := load(t, "provider: {providerType: standalone}\nsupervise: {}\n")
+		assert.Equal(t, &SupervisionConfig{
+			MaxRestarts:           5,
+			InitialBackoffSeconds: 10,
+			MaxBackoffSeconds:     600,
+			ResetAfterSeconds:     3600,
+		}, runnercfg.Supervise)
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		runnercfg := load(t, "provider: {providerType: standalone}\nsupervise: {maxRestarts: 2, initialBackoffSeconds: 1}\n")
+		assert.Equal(t, 2, runnercfg.Supervise.MaxRestarts)
+		assert.Equal(t, 1, runnercfg.Supervise.InitialBackoffSeconds)
+		assert.Equal(t, 600, runnercfg.Supervise.MaxBackoffSeconds)
+	})
+}
@@ -0,0 +1,26 @@
+package cfg
+
+// SupervisionConfig configures restarting the worker when it crashes.  See
+// the usage string for field descriptions.
+type SupervisionConfig struct {
+	MaxRestarts           int `yaml:"maxRestarts"`
+	InitialBackoffSeconds int `yaml:"initialBackoffSeconds"`
+	MaxBackoffSeconds     int `yaml:"maxBackoffSeconds"`
+	ResetAfterSeconds     int `yaml:"resetAfterSeconds"`
+}
+
+// set defaults for any unset (zero) values
+func (sc *SupervisionConfig) setDefaults() {
+	if sc.MaxRestarts == 0 {
+		sc.MaxRestarts = 5
+	}
+	if sc.InitialBackoffSeconds == 0 {
+		sc.InitialBackoffSeconds = 10
+	}
+	if sc.MaxBackoffSeconds == 0 {
+		sc.MaxBackoffSeconds = 600
+	}
+	if sc.ResetAfterSeconds == 0 {
+		sc.ResetAfterSeconds = 3600
+	}
+}
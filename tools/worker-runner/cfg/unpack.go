@@ -0,0 +1,61 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// unpack copies properties from data to the fields of the struct that out
+// points to, as described for ProviderConfig.Unpack, using the struct tag
+// tagName.  Properties are named `prefix.<name>` in error messages.
+func unpack(data map[string]any, tagName, prefix string, out any) error {
+	outval := reflect.ValueOf(out)
+	if outval.Kind() != reflect.Ptr || outval.IsNil() {
+		return fmt.Errorf("expected a pointer, got %s", outval.Kind())
+	}
+	destval := reflect.Indirect(outval)
+	if destval.Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to struct, got &%s", destval.Kind())
+	}
+	desttype := destval.Type()
+	numfield := desttype.NumField()
+	for i := range numfield {
+		// get the expected property name
+		field := desttype.Field(i)
+		var name string
+		optional := false
+		tag := field.Tag.Get(tagName)
+		tagBits := strings.Split(tag, ",")
+
+		if len(tagBits) == 0 || tagBits[0] == "" {
+			name = strings.ToLower(field.Name[:1]) + field.Name[1:]
+		} else {
+			name = tagBits[0]
+		}
+
+		for _, tagBit := range tagBits {
+			if tagBit == "optional" {
+				optional = true
+			}
+		}
+
+		// get the value
+		val, ok := data[name]
+		if !ok {
+			if optional {
+				continue
+			}
+			return fmt.Errorf("configuration value `%s.%s` not found", prefix, name)
+		}
+
+		// check types and set the struct field
+		destfield := destval.Field(i)
+		gotval := reflect.ValueOf(val)
+		if destfield.Type() != gotval.Type() {
+			return fmt.Errorf("configuration value `%s.%s` should have type %s, got %s", prefix, name, destfield.Type(), gotval.Type())
+		}
+		destfield.Set(gotval)
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"reflect"
+	"slices"
 	"strings"
 
 	"maps"
@@ -226,6 +228,33 @@ func (wc *WorkerConfig) Has(key string) bool {
 	return err == nil
 }
 
+// Diff returns the sorted top-level properties whose values differ between
+// this WorkerConfig and the other, including properties present in only one
+// of them.
+func (wc *WorkerConfig) Diff(other *WorkerConfig) []string {
+	var data1, data2 map[string]any
+	if wc != nil {
+		data1 = wc.data
+	}
+	if other != nil {
+		data2 = other.data
+	}
+
+	changed := []string{}
+	for key, value := range data1 {
+		if otherValue, ok := data2[key]; !ok || !reflect.DeepEqual(value, otherValue) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range data2 {
+		if _, ok := data1[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
 func NewWorkerConfig() *WorkerConfig {
 	return &WorkerConfig{
 		data: make(map[string]any),
@@ -236,3 +236,16 @@ func TestGet(t *testing.T) {
 	assert.NoError(t, err, "shouldn't fail")
 	assert.Equal(t, "z", res, "got correct value")
 }
+
+func TestDiff(t *testing.T) {
+	var wc1, wc2 WorkerConfig
+
+	err := json.Unmarshal([]byte(`{"same": {"y": [1]}, "changed": {"y": "z"}, "removed": 1}`), &wc1)
+	assert.NoError(t, err, "shouldn't fail")
+	err = json.Unmarshal([]byte(`{"same": {"y": [1]}, "changed": {"y": "zz"}, "added": 1}`), &wc2)
+	assert.NoError(t, err, "shouldn't fail")
+
+	assert.Equal(t, []string{"added", "changed", "removed"}, wc1.Diff(&wc2), "found differences")
+	assert.Equal(t, []string{}, wc1.Diff(&wc1), "no differences")
+	assert.Equal(t, []string{"added", "changed", "same"}, (*WorkerConfig)(nil).Diff(&wc2), "nil is empty")
+}
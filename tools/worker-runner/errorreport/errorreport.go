@@ -2,6 +2,7 @@ package errorreport
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"reflect"
 
@@ -78,6 +79,42 @@ func (er *ErrorReporter) HandleMessage(msg workerproto.Message) {
 	}
 }
 
+// ReportWorkerCrash reports to worker-manager that the worker process crashed
+// with the given error, after the given number of consecutive crashes.  If
+// restarting is true, worker-runner will restart the worker.
+func (er *ErrorReporter) ReportWorkerCrash(crashErr error, crashes int, restarting bool) {
+	er.state.Lock()
+	defer er.state.Unlock()
+
+	description := fmt.Sprintf("The worker process crashed: %v", crashErr)
+	if restarting {
+		description += "; it will be restarted"
+	} else {
+		description += "; giving up after repeated crashes"
+	}
+	extra, err := json.Marshal(map[string]any{
+		"error":              crashErr.Error(),
+		"consecutiveCrashes": crashes,
+		"restarting":         restarting,
+	})
+	if err != nil {
+		log.Printf("Error reporting worker crash, could not marshal extra: %v", err)
+		return
+	}
+	errorReport := tcworkermanager.WorkerErrorReport{
+		Description: description,
+		Kind:        "worker-crash",
+		Extra:       extra,
+		Title:       "Worker Crashed",
+		WorkerGroup: er.state.WorkerGroup,
+		WorkerID:    er.state.WorkerID,
+	}
+	err = ReportWorkerError(er.state, er.factory, &errorReport)
+	if err != nil {
+		log.Printf("Error reporting worker crash: %v\n", err)
+	}
+}
+
 // ReportWorkerError will send a worker error report to worker-manager
 func ReportWorkerError(state *run.State, factory tc.WorkerManagerClientFactory, payload *tcworkermanager.WorkerErrorReport) error {
 	wc, err := factory(state.RootURL, &state.Credentials)
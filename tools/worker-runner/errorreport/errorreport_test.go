@@ -2,6 +2,7 @@ package errorreport
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
@@ -75,3 +76,24 @@ func TestHandleMessage(t *testing.T) {
 
 	require.True(t, true)
 }
+
+func TestReportWorkerCrash(t *testing.T) {
+	state := run.State{
+		WorkerGroup: "hive-1",
+		WorkerID:    "workerbee-17",
+	}
+	er := new(&state, tc.FakeWorkerManagerClientFactory)
+
+	er.ReportWorkerCrash(errors.New("exit status 69"), 2, true)
+
+	reports, err := tc.FakeWorkerManagerWorkerErrorReports()
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+
+	require.Equal(t, "worker-crash", reports[0].Kind)
+	require.Equal(t, "Worker Crashed", reports[0].Title)
+	require.Equal(t, "The worker process crashed: exit status 69; it will be restarted", reports[0].Description)
+	require.Equal(t, "workerbee-17", reports[0].WorkerID)
+	require.Equal(t, "hive-1", reports[0].WorkerGroup)
+	require.JSONEq(t, `{"error": "exit status 69", "consecutiveCrashes": 2, "restarting": true}`, string(reports[0].Extra))
+}
@@ -0,0 +1,303 @@
+// Package file implements a logging destination that writes log messages to
+// a file, one JSON object per line, rotating the file when it reaches a
+// maximum size or age, and compressing rotated files with gzip.
+package file
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/logging"
+)
+
+// the layout of the timestamp appended to the names of rotated files, which
+// sorts lexically in time order
+const rotatedTimeLayout = "20060102T150405.000000000Z"
+
+type fileLoggingConfig struct {
+	Path                string `logging:"path"`
+	MaxSizeMB           int    `logging:"maxSizeMB,optional"`
+	RotateIntervalHours int    `logging:"rotateIntervalHours,optional"`
+	KeepFiles           int    `logging:"keepFiles,optional"`
+	Compress            bool   `logging:"compress,optional"`
+}
+
+type fileLogDestination struct {
+	path           string
+	maxSize        int64
+	rotateInterval time.Duration
+	keepFiles      int
+	compress       bool
+
+	// now returns the current time, and can be overridden in tests
+	now func() time.Time
+	// errors writes errors encountered while logging, which cannot be logged
+	// to the file itself
+	errors *log.Logger
+
+	// mutex protects the fields below
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// compressing tracks rotated files that are being compressed in the
+	// background
+	compressing sync.WaitGroup
+}
+
+func (dst *fileLogDestination) LogUnstructured(message string) {
+	dst.LogStructured(logging.ToStructured(message))
+}
+
+func (dst *fileLogDestination) LogStructured(message map[string]any) {
+	now := dst.now()
+	record := map[string]any{"time": now.UTC().Format(time.RFC3339Nano)}
+	maps.Copy(record, message)
+	line, err := json.Marshal(record)
+	if err != nil {
+		// fall back to a text representation of the message if it contains
+		// values that cannot be represented as JSON
+		line, _ = json.Marshal(map[string]any{
+			"time":        record["time"],
+			"textPayload": logging.ToUnstructured(message),
+		})
+	}
+	dst.write(append(line, '\n'), now)
+}
+
+// write writes the given line to the log file, first rotating the file if
+// the line would take it over the maximum size, or if it has reached its
+// maximum age.
+func (dst *fileLogDestination) write(line []byte, now time.Time) {
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+
+	if dst.file != nil && dst.size > 0 {
+		tooBig := dst.maxSize > 0 && dst.size+int64(len(line)) > dst.maxSize
+		tooOld := dst.rotateInterval > 0 && now.Sub(dst.opened) >= dst.rotateInterval
+		if tooBig || tooOld {
+			dst.rotate(now)
+		}
+	}
+
+	if dst.file == nil {
+		err := dst.open(now)
+		if err != nil {
+			dst.errors.Printf("could not open log file %s: %s", dst.path, err)
+			_, _ = os.Stderr.Write(line)
+			return
+		}
+	}
+
+	n, err := dst.file.Write(line)
+	dst.size += int64(n)
+	if err != nil {
+		dst.errors.Printf("could not write to log file %s: %s", dst.path, err)
+	}
+}
+
+// open opens the log file for appending, creating it (and its directory) if
+// necessary.  Must be called with the mutex held.
+func (dst *fileLogDestination) open(now time.Time) error {
+	err := os.MkdirAll(filepath.Dir(dst.path), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(dst.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	dst.file = file
+	dst.size = info.Size()
+	dst.opened = now
+	return nil
+}
+
+// rotate moves the current log file aside, so that the next write opens a
+// new file, and compresses and prunes rotated files.  Must be called with the
+// mutex held.
+func (dst *fileLogDestination) rotate(now time.Time) {
+	err := dst.file.Close()
+	if err != nil {
+		dst.errors.Printf("could not close log file %s: %s", dst.path, err)
+	}
+	dst.file = nil
+	dst.size = 0
+
+	rotated := dst.path + "." + now.UTC().Format(rotatedTimeLayout)
+	err = os.Rename(dst.path, rotated)
+	if err != nil {
+		dst.errors.Printf("could not rotate log file %s: %s", dst.path, err)
+		return
+	}
+
+	if !dst.compress {
+		dst.prune()
+		return
+	}
+	dst.compressing.Add(1)
+	go func() {
+		defer dst.compressing.Done()
+		err := compressFile(rotated)
+		if err != nil {
+			dst.errors.Printf("could not compress rotated log file %s: %s", rotated, err)
+		}
+		dst.prune()
+	}()
+}
+
+// compressFile replaces the given file with a gzipped copy, with the suffix
+// `.gz`.
+func compressFile(path string) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := out.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	gz.Name = filepath.Base(path)
+	_, err = io.Copy(gz, in)
+	if err != nil {
+		return err
+	}
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune removes the oldest rotated files, keeping at most keepFiles of them.
+func (dst *fileLogDestination) prune() {
+	if dst.keepFiles <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(dst.path + ".*")
+	if err != nil {
+		dst.errors.Printf("could not list rotated log files: %s", err)
+		return
+	}
+
+	// group the files by rotation, since a rotated file and its compressed
+	// copy may both exist while it is being compressed
+	files := map[string][]string{}
+	for _, match := range matches {
+		rotation := strings.TrimSuffix(match, ".gz")
+		_, err := time.Parse(rotatedTimeLayout, strings.TrimPrefix(rotation, dst.path+"."))
+		if err != nil {
+			// not a rotated log file
+			continue
+		}
+		files[rotation] = append(files[rotation], match)
+	}
+	rotations := make([]string, 0, len(files))
+	for rotation := range files {
+		rotations = append(rotations, rotation)
+	}
+	sort.Strings(rotations)
+
+	for len(rotations) > dst.keepFiles {
+		for _, file := range files[rotations[0]] {
+			err := os.Remove(file)
+			if err != nil && !os.IsNotExist(err) {
+				dst.errors.Printf("could not remove rotated log file: %s", err)
+			}
+		}
+		rotations = rotations[1:]
+	}
+}
+
+func New(runnercfg *cfg.RunnerConfig) (logging.Logger, error) {
+	lc := fileLoggingConfig{
+		MaxSizeMB:           100,
+		RotateIntervalHours: 24,
+		KeepFiles:           10,
+		Compress:            true,
+	}
+	err := runnercfg.Logging.Unpack(&lc)
+	if err != nil {
+		return nil, err
+	}
+	if lc.MaxSizeMB < 0 || lc.RotateIntervalHours < 0 || lc.KeepFiles < 0 {
+		return nil, fmt.Errorf("file logging `maxSizeMB`, `rotateIntervalHours` and `keepFiles` must not be negative")
+	}
+	return newFileLogDestination(
+		lc.Path,
+		int64(lc.MaxSizeMB)*1024*1024,
+		time.Duration(lc.RotateIntervalHours)*time.Hour,
+		lc.KeepFiles,
+		lc.Compress,
+	), nil
+}
+
+func newFileLogDestination(path string, maxSize int64, rotateInterval time.Duration, keepFiles int, compress bool) *fileLogDestination {
+	return &fileLogDestination{
+		path:           path,
+		maxSize:        maxSize,
+		rotateInterval: rotateInterval,
+		keepFiles:      keepFiles,
+		compress:       compress,
+		now:            time.Now,
+		errors:         log.New(os.Stderr, "file logging: ", log.LstdFlags),
+	}
+}
+
+func Usage() string {
+	return `
+
+The "file" logging writes log messages to a file, one JSON object per line,
+with the fields of structured messages preserved and a "time" field added.
+Unstructured messages are logged as a "textPayload" field.
+
+The file is rotated when writing a message would make it larger than
+"maxSizeMB" (default 100), or when it has been open for "rotateIntervalHours"
+(default 24).  Either limit can be disabled by setting it to 0.  Rotated files
+have a timestamp appended to their name, and are compressed with gzip unless
+"compress" is false.  The newest "keepFiles" (default 10) rotated files are
+kept, and older ones removed; set "keepFiles" to 0 to keep all rotated files.
+
+` + "```yaml" + `
+logging:
+    implementation: file
+    path: /var/log/worker-runner.log
+    # (optional) rotation settings
+    maxSizeMB: 100
+    rotateIntervalHours: 24
+    keepFiles: 10
+    compress: true
+` + "```" + `
+
+`
+}
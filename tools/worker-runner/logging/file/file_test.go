@@ -0,0 +1,199 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// fakeClock returns a time that only changes when advanced
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func makeLogger(t *testing.T, maxSize int64, rotateInterval time.Duration, keepFiles int, compress bool) (*fileLogDestination, *fakeClock) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "logs", "worker-runner.log")
+	dst := newFileLogDestination(path, maxSize, rotateInterval, keepFiles, compress)
+	clock := &fakeClock{time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	dst.now = clock.now
+	t.Cleanup(func() {
+		dst.compressing.Wait()
+		if dst.file != nil {
+			_ = dst.file.Close()
+		}
+	})
+	return dst, clock
+}
+
+func readLines(t *testing.T, r io.Reader) []map[string]any {
+	t.Helper()
+	lines := []map[string]any{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func readFile(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	return readLines(t, f)
+}
+
+func readGzipFile(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	defer gz.Close()
+	return readLines(t, gz)
+}
+
+func rotatedFiles(t *testing.T, dst *fileLogDestination) []string {
+	t.Helper()
+	dst.compressing.Wait()
+	matches, err := filepath.Glob(dst.path + ".*")
+	require.NoError(t, err)
+	sort.Strings(matches)
+	return matches
+}
+
+func TestLogStructuredAndUnstructured(t *testing.T) {
+	dst, _ := makeLogger(t, 0, 0, 0, true)
+
+	dst.LogUnstructured("uhoh!")
+	dst.LogStructured(map[string]any{"textPayload": "hello", "level": "bad", "count": 3})
+
+	require.Equal(t, []map[string]any{
+		{"time": "2026-01-02T03:04:05Z", "textPayload": "uhoh!"},
+		{"time": "2026-01-02T03:04:05Z", "textPayload": "hello", "level": "bad", "count": float64(3)},
+	}, readFile(t, dst.path))
+}
+
+func TestLogNonJSONValue(t *testing.T) {
+	dst, _ := makeLogger(t, 0, 0, 0, true)
+
+	dst.LogStructured(map[string]any{"level": "bad", "wat": func() {}})
+
+	lines := readFile(t, dst.path)
+	require.Len(t, lines, 1)
+	require.True(t, strings.HasPrefix(lines[0]["textPayload"].(string), "level: bad; wat: "))
+}
+
+func TestAppendsToExistingFile(t *testing.T) {
+	dst, _ := makeLogger(t, 0, 0, 0, true)
+	require.NoError(t, os.MkdirAll(filepath.Dir(dst.path), 0755))
+	require.NoError(t, os.WriteFile(dst.path, []byte(`{"textPayload":"before"}`+"\n"), 0644))
+
+	dst.LogUnstructured("after")
+
+	lines := readFile(t, dst.path)
+	require.Len(t, lines, 2)
+	require.Equal(t, "before", lines[0]["textPayload"])
+	require.Equal(t, "after", lines[1]["textPayload"])
+}
+
+func TestRotateOnSize(t *testing.T) {
+	dst, clock := makeLogger(t, 100, 0, 0, true)
+
+	// each line is about 60 bytes, so only one fits in each file
+	dst.LogUnstructured("first message")
+	clock.t = clock.t.Add(time.Second)
+	dst.LogUnstructured("second message")
+	clock.t = clock.t.Add(time.Second)
+	dst.LogUnstructured("third message")
+
+	rotated := rotatedFiles(t, dst)
+	require.Equal(t, []string{
+		dst.path + ".20260102T030406.000000000Z.gz",
+		dst.path + ".20260102T030407.000000000Z.gz",
+	}, rotated)
+	require.Equal(t, "first message", readGzipFile(t, rotated[0])[0]["textPayload"])
+	require.Equal(t, "second message", readGzipFile(t, rotated[1])[0]["textPayload"])
+	require.Equal(t, "third message", readFile(t, dst.path)[0]["textPayload"])
+}
+
+func TestRotateOnTime(t *testing.T) {
+	dst, clock := makeLogger(t, 0, time.Hour, 0, false)
+
+	dst.LogUnstructured("first message")
+	clock.t = clock.t.Add(59 * time.Minute)
+	dst.LogUnstructured("second message")
+	clock.t = clock.t.Add(time.Minute)
+	dst.LogUnstructured("third message")
+
+	rotated := rotatedFiles(t, dst)
+	require.Equal(t, []string{dst.path + ".20260102T040405.000000000Z"}, rotated)
+	require.Len(t, readFile(t, rotated[0]), 2)
+	require.Equal(t, "third message", readFile(t, dst.path)[0]["textPayload"])
+}
+
+func TestKeepFiles(t *testing.T) {
+	dst, clock := makeLogger(t, 1, 0, 2, true)
+
+	for range 5 {
+		dst.LogUnstructured("a message")
+		clock.t = clock.t.Add(time.Second)
+		// wait for each compression to finish, so that the pruning is
+		// deterministic
+		dst.compressing.Wait()
+	}
+
+	require.Equal(t, []string{
+		dst.path + ".20260102T030408.000000000Z.gz",
+		dst.path + ".20260102T030409.000000000Z.gz",
+	}, rotatedFiles(t, dst))
+}
+
+func TestNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker-runner.log")
+	var runnercfg cfg.RunnerConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+logging:
+  implementation: file
+  path: `+path+`
+  maxSizeMB: 5
+  keepFiles: 0
+`), &runnercfg))
+
+	logger, err := New(&runnercfg)
+	require.NoError(t, err)
+	dst := logger.(*fileLogDestination)
+	require.Equal(t, path, dst.path)
+	require.Equal(t, int64(5*1024*1024), dst.maxSize)
+	require.Equal(t, 24*time.Hour, dst.rotateInterval)
+	require.Equal(t, 0, dst.keepFiles)
+	require.True(t, dst.compress)
+}
+
+func TestNewMissingPath(t *testing.T) {
+	var runnercfg cfg.RunnerConfig
+	require.NoError(t, yaml.Unmarshal([]byte("logging:\n  implementation: file\n"), &runnercfg))
+
+	_, err := New(&runnercfg)
+	require.Error(t, err)
+}
@@ -9,8 +9,13 @@ import (
 	"strings"
 
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/file"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/logging"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/otlp"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/stdio"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/syslog"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/status"
 )
 
 var Destination logging.Logger
@@ -22,12 +27,19 @@ func init() {
 }
 
 type implInfo struct {
-	constructor func(*cfg.RunnerConfig) logging.Logger
+	constructor func(*cfg.RunnerConfig) (logging.Logger, error)
 	usage       func() string
 }
 
 var implementations map[string]implInfo = map[string]implInfo{
-	"stdio": implInfo{stdio.New, stdio.Usage},
+	"stdio": implInfo{
+		func(runnercfg *cfg.RunnerConfig) (logging.Logger, error) { return stdio.New(runnercfg), nil },
+		stdio.Usage,
+	},
+	"file":     implInfo{file.New, file.Usage},
+	"syslog":   implInfo{syslog.New, syslog.Usage},
+	"journald": implInfo{syslog.NewJournald, syslog.JournaldUsage},
+	"otlp":     implInfo{otlp.New, otlp.Usage},
 }
 
 func Configure(runnercfg *cfg.RunnerConfig) {
@@ -42,7 +54,37 @@ func Configure(runnercfg *cfg.RunnerConfig) {
 		log.Printf("Unrecognized logging implementation %s (falling back to stdio)", impl)
 		return
 	}
-	Destination = li.constructor(runnercfg)
+	dst, err := li.constructor(runnercfg)
+	if err != nil {
+		log.Printf("Could not configure %s logging: %s (falling back to stdio)", impl, err)
+		return
+	}
+	Destination = dst
+}
+
+// SetRunState provides the state of the worker run to the logging
+// destination, for destinations that include the worker's identity in log
+// messages.
+func SetRunState(state *run.State) {
+	if dst, ok := Destination.(interface{ SetRunState(*run.State) }); ok {
+		dst.SetRunState(state)
+	}
+}
+
+// SetStatus provides the status of the worker to the logging destination,
+// for destinations that export metrics derived from it.
+func SetStatus(sm *status.StatusManager) {
+	if dst, ok := Destination.(interface{ SetStatus(func() status.Status) }); ok {
+		dst.SetStatus(sm.Status)
+	}
+}
+
+// Flush flushes any log messages buffered by the logging destination.  This
+// should be called before worker-runner exits.
+func Flush() {
+	if dst, ok := Destination.(interface{ Flush() }); ok {
+		dst.Flush()
+	}
 }
 
 func Usage() string {
@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func configure(t *testing.T, config string) {
+	t.Helper()
+	var runnercfg cfg.RunnerConfig
+	require.NoError(t, yaml.Unmarshal([]byte(config), &runnercfg))
+	Configure(&runnercfg)
+}
+
+func TestConfigure(t *testing.T) {
+	oldLogDestination := Destination
+	defer func() { Destination = oldLogDestination }()
+
+	t.Run("no logging config", func(t *testing.T) {
+		Destination = &TestLogDestination{}
+		configure(t, "getSecrets: false")
+		require.IsType(t, &TestLogDestination{}, Destination)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		Destination = &TestLogDestination{}
+		configure(t, "logging:\n  implementation: file\n  path: "+filepath.Join(t.TempDir(), "worker-runner.log"))
+		require.Equal(t, "*file.fileLogDestination", fmt.Sprintf("%T", Destination))
+	})
+
+	t.Run("invalid config falls back", func(t *testing.T) {
+		Destination = &TestLogDestination{}
+		// file logging requires a path
+		configure(t, "logging:\n  implementation: file")
+		require.IsType(t, &TestLogDestination{}, Destination)
+	})
+
+	t.Run("unknown implementation falls back", func(t *testing.T) {
+		Destination = &TestLogDestination{}
+		configure(t, "logging:\n  implementation: carrier-pigeon")
+		require.IsType(t, &TestLogDestination{}, Destination)
+	})
+}
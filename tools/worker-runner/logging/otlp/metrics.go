@@ -0,0 +1,126 @@
+package otlp
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/status"
+)
+
+// aggregationTemporalityCumulative is the OTLP AggregationTemporality of sums
+// which count from a fixed start time
+const aggregationTemporalityCumulative = 2
+
+// exportMetrics exports the current metrics, if metrics are enabled and the
+// status of the worker is known.  Metrics that cannot be exported are not
+// retried, since the next export contains their current values.
+func (dst *otlpLogDestination) exportMetrics() {
+	if dst.metricsInterval <= 0 {
+		return
+	}
+	dst.mutex.Lock()
+	source := dst.status
+	dst.mutex.Unlock()
+	if source == nil {
+		return
+	}
+	_, _ = dst.export(dst.metricsURL, dst.encodeMetrics(source()))
+}
+
+// encodeMetrics encodes the metrics derived from the given status as an OTLP
+// ExportMetricsServiceRequest.
+func (dst *otlpLogDestination) encodeMetrics(st status.Status) []byte {
+	now := strconv.FormatInt(dst.now().UnixNano(), 10)
+	// cumulative sums count from the start of this worker-runner process
+	start := strconv.FormatInt(dst.startTime.UnixNano(), 10)
+	newGauge := func(name, description, unit string, value int64) metric {
+		return metric{
+			Name:        name,
+			Description: description,
+			Unit:        unit,
+			Gauge: &gauge{
+				DataPoints: []numberDataPoint{{TimeUnixNano: now, AsInt: strconv.FormatInt(value, 10)}},
+			},
+		}
+	}
+	newCounter := func(name, description, unit string, value int64) metric {
+		return metric{
+			Name:        name,
+			Description: description,
+			Unit:        unit,
+			Sum: &sum{
+				DataPoints:             []numberDataPoint{{StartTimeUnixNano: start, TimeUnixNano: now, AsInt: strconv.FormatInt(value, 10)}},
+				AggregationTemporality: aggregationTemporalityCumulative,
+				IsMonotonic:            true,
+			},
+		}
+	}
+
+	metrics := []metric{}
+	if st.Credentials.SecondsUntilExpiry != nil {
+		metrics = append(metrics, newGauge("taskcluster.worker.credentials.time_until_expiry", "Time until the worker's Taskcluster credentials expire", "s", *st.Credentials.SecondsUntilExpiry))
+	}
+	if st.Tasks.Reported {
+		metrics = append(metrics,
+			newGauge("taskcluster.worker.tasks.running", "Number of tasks the worker is running", "{task}", int64(len(st.Tasks.Running))),
+			newCounter("taskcluster.worker.tasks.resolved", "Number of tasks the worker has resolved", "{task}", int64(st.Tasks.ResolvedCount)),
+		)
+	}
+	metrics = append(metrics, newCounter("taskcluster.worker.restarts", "Number of times the worker has been restarted after crashing", "{restart}", int64(st.Worker.Restarts)))
+
+	body, err := json.Marshal(exportMetricsServiceRequest{
+		ResourceMetrics: []resourceMetrics{{
+			Resource: dst.resource(),
+			ScopeMetrics: []scopeMetrics{{
+				Scope:   instrumentationScope{Name: "worker-runner"},
+				Metrics: metrics,
+			}},
+		}},
+	})
+	if err != nil {
+		// metrics only contain JSON-able values, so this cannot happen
+		panic(err)
+	}
+	return body
+}
+
+// The following types are the JSON encoding of the OTLP metrics data model;
+// see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type exportMetricsServiceRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   instrumentationScope `json:"scope"`
+	Metrics []metric             `json:"metrics"`
+}
+
+type metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	Gauge       *gauge `json:"gauge,omitempty"`
+	Sum         *sum   `json:"sum,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type numberDataPoint struct {
+	StartTimeUnixNano string `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string `json:"timeUnixNano"`
+	AsInt             string `json:"asInt"`
+}
@@ -0,0 +1,129 @@
+package otlp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/status"
+)
+
+// latestMetrics returns the metrics of the most recent metrics export, by name
+func (rcv *receiver) latestMetrics() map[string]metric {
+	rcv.mutex.Lock()
+	defer rcv.mutex.Unlock()
+	rv := map[string]metric{}
+	if len(rcv.metrics) == 0 {
+		return rv
+	}
+	for _, m := range rcv.metrics[len(rcv.metrics)-1].ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		rv[m.Name] = m
+	}
+	return rv
+}
+
+func TestExportMetrics(t *testing.T) {
+	rcv := newReceiver(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+`, rcv.URL))
+
+	dst.SetRunState(&run.State{WorkerPoolID: "pp/wt", WorkerID: "wi"})
+	seconds := int64(3600)
+	dst.SetStatus(func() status.Status {
+		return status.Status{
+			Credentials: status.Credentials{SecondsUntilExpiry: &seconds},
+			Worker:      status.Worker{State: status.WorkerRunning, Restarts: 2},
+			Tasks: status.Tasks{
+				Reported:      true,
+				Running:       []status.Task{{TaskID: "abc"}},
+				ResolvedCount: 5,
+			},
+		}
+	})
+	dst.Flush()
+
+	require.Len(t, rcv.metrics, 1)
+	resourceAttrs := attributes(rcv.metrics[0].ResourceMetrics[0].Resource.Attributes)
+	require.Equal(t, "pp/wt", *resourceAttrs["taskcluster.worker_pool_id"].StringValue)
+	require.Equal(t, "wi", *resourceAttrs["taskcluster.worker_id"].StringValue)
+
+	metrics := rcv.latestMetrics()
+	require.Len(t, metrics, 4)
+
+	expiry := metrics["taskcluster.worker.credentials.time_until_expiry"]
+	require.Equal(t, "s", expiry.Unit)
+	require.NotNil(t, expiry.Gauge)
+	require.Equal(t, "3600", expiry.Gauge.DataPoints[0].AsInt)
+
+	require.Equal(t, "1", metrics["taskcluster.worker.tasks.running"].Gauge.DataPoints[0].AsInt)
+
+	for name, value := range map[string]string{
+		"taskcluster.worker.tasks.resolved": "5",
+		"taskcluster.worker.restarts":       "2",
+	} {
+		m := metrics[name]
+		require.NotNil(t, m.Sum, name)
+		require.Equal(t, aggregationTemporalityCumulative, m.Sum.AggregationTemporality, name)
+		require.True(t, m.Sum.IsMonotonic, name)
+		require.Equal(t, value, m.Sum.DataPoints[0].AsInt, name)
+		require.NotEmpty(t, m.Sum.DataPoints[0].StartTimeUnixNano, name)
+	}
+}
+
+func TestExportMetricsWithoutTasksReported(t *testing.T) {
+	rcv := newReceiver(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+`, rcv.URL))
+
+	dst.SetStatus(func() status.Status {
+		return status.Status{Worker: status.Worker{State: status.WorkerRunning}}
+	})
+	dst.Flush()
+
+	metrics := rcv.latestMetrics()
+	require.Len(t, metrics, 1)
+	require.Contains(t, metrics, "taskcluster.worker.restarts")
+}
+
+func TestExportMetricsPeriodically(t *testing.T) {
+	rcv := newReceiver(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  metricsIntervalSeconds: 1
+`, rcv.URL))
+
+	dst.SetStatus(func() status.Status { return status.Status{} })
+
+	require.Eventually(t, func() bool {
+		rcv.mutex.Lock()
+		defer rcv.mutex.Unlock()
+		return len(rcv.metrics) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	rcv := newReceiver(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  metricsIntervalSeconds: 0
+`, rcv.URL))
+
+	dst.SetStatus(func() status.Status { return status.Status{} })
+	dst.LogUnstructured("hello")
+	dst.Flush()
+
+	require.Equal(t, []string{"hello"}, rcv.bodies())
+	require.Empty(t, rcv.metrics)
+}
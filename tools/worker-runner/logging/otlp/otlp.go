@@ -0,0 +1,676 @@
+// Package otlp implements a logging destination that exports log messages,
+// and metrics about the worker, to an OpenTelemetry collector, using
+// OTLP/HTTP with JSON encoding.
+//
+// Messages are buffered in memory and exported in batches.  When the
+// collector cannot be reached, batches are written to an (optional) on-disk
+// spool, and exported once the collector is reachable again, oldest first.
+// Without a spool, or when the spool is full, the oldest messages are
+// dropped, so that memory and disk usage remain bounded.  Metrics are
+// exported periodically, and are not buffered, since every export contains
+// their current values.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/logging"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/status"
+)
+
+// the layout of the timestamp in the names of spool files, which sorts
+// lexically in time order
+const spoolTimeLayout = "20060102T150405.000000000Z"
+
+type otlpLoggingConfig struct {
+	Endpoint               string         `logging:"endpoint"`
+	Headers                map[string]any `logging:"headers,optional"`
+	ServiceName            string         `logging:"serviceName,optional"`
+	BatchSize              int            `logging:"batchSize,optional"`
+	FlushIntervalSeconds   int            `logging:"flushIntervalSeconds,optional"`
+	MaxBufferedRecords     int            `logging:"maxBufferedRecords,optional"`
+	SpoolDir               string         `logging:"spoolDir,optional"`
+	MaxSpoolMB             int            `logging:"maxSpoolMB,optional"`
+	MetricsIntervalSeconds int            `logging:"metricsIntervalSeconds,optional"`
+}
+
+type otlpLogDestination struct {
+	logsURL         string
+	metricsURL      string
+	headers         map[string]string
+	serviceName     string
+	batchSize       int
+	flushInterval   time.Duration
+	maxBuffered     int
+	spoolDir        string
+	maxSpoolSize    int64
+	metricsInterval time.Duration
+	// startTime is the start of the period over which cumulative metrics
+	// are counted
+	startTime time.Time
+
+	client *http.Client
+	// now returns the current time, and can be overridden in tests
+	now func() time.Time
+	// errors writes errors encountered while exporting, which cannot be
+	// exported themselves
+	errors *log.Logger
+
+	// mutex protects the fields below
+	mutex   sync.Mutex
+	records []logRecord
+	dropped int
+	state   *run.State
+	status  func() status.Status
+	spooled int
+
+	// flushMutex ensures that only one flush runs at a time
+	flushMutex sync.Mutex
+	flushNow   chan struct{}
+	stop       chan struct{}
+	stopOnce   sync.Once
+	stopped    sync.WaitGroup
+}
+
+func (dst *otlpLogDestination) LogUnstructured(message string) {
+	dst.LogStructured(logging.ToStructured(message))
+}
+
+func (dst *otlpLogDestination) LogStructured(message map[string]any) {
+	record := toLogRecord(message, dst.now())
+
+	dst.mutex.Lock()
+	dst.records = append(dst.records, record)
+	if excess := len(dst.records) - dst.maxBuffered; excess > 0 {
+		dst.records = dst.records[excess:]
+		dst.dropped += excess
+	}
+	full := len(dst.records) >= dst.batchSize
+	dst.mutex.Unlock()
+
+	if full {
+		select {
+		case dst.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SetRunState sets the state of the worker run, from which the identity of
+// the worker is included in exported messages.
+func (dst *otlpLogDestination) SetRunState(state *run.State) {
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+	dst.state = state
+}
+
+// SetStatus sets the function from which the current status of the worker
+// is read, to export metrics.  Until it is called, no metrics are exported.
+func (dst *otlpLogDestination) SetStatus(source func() status.Status) {
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+	dst.status = source
+}
+
+// Flush exports all buffered messages, spooling them if the collector cannot
+// be reached, and the current metrics.
+func (dst *otlpLogDestination) Flush() {
+	dst.flush()
+	dst.exportMetrics()
+}
+
+// start starts exporting messages in the background.
+func (dst *otlpLogDestination) start() {
+	dst.stopped.Add(1)
+	go func() {
+		defer dst.stopped.Done()
+		ticker := time.NewTicker(dst.flushInterval)
+		defer ticker.Stop()
+		var metricsTick <-chan time.Time
+		if dst.metricsInterval > 0 {
+			metricsTicker := time.NewTicker(dst.metricsInterval)
+			defer metricsTicker.Stop()
+			metricsTick = metricsTicker.C
+		}
+		for {
+			select {
+			case <-dst.stop:
+				return
+			case <-metricsTick:
+				dst.exportMetrics()
+				continue
+			case <-ticker.C:
+			case <-dst.flushNow:
+			}
+			dst.flush()
+		}
+	}()
+}
+
+// close stops exporting messages in the background.
+func (dst *otlpLogDestination) close() {
+	dst.stopOnce.Do(func() { close(dst.stop) })
+	dst.stopped.Wait()
+}
+
+func (dst *otlpLogDestination) flush() {
+	dst.flushMutex.Lock()
+	defer dst.flushMutex.Unlock()
+
+	// export any spooled batches first, to preserve the order of messages;
+	// if that fails, the collector is still unreachable, so spool (or keep)
+	// the buffered messages as well
+	reachable := dst.exportSpool()
+
+	for {
+		batch, dropped := dst.takeBatch()
+		if len(batch) == 0 {
+			return
+		}
+		body := dst.encode(batch, dropped)
+		retry := false
+		if reachable {
+			retry, reachable = dst.export(dst.logsURL, body)
+		} else {
+			retry = true
+		}
+		if !retry {
+			continue
+		}
+		if dst.spoolDir != "" && dst.spool(body) {
+			continue
+		}
+		// keep the batch for the next attempt
+		dst.requeue(batch, dropped)
+		return
+	}
+}
+
+// takeBatch removes up to batchSize buffered messages, returning them and
+// the number of messages dropped so far.
+func (dst *otlpLogDestination) takeBatch() ([]logRecord, int) {
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+	n := min(len(dst.records), dst.batchSize)
+	batch := dst.records[:n:n]
+	dst.records = dst.records[n:]
+	dropped := dst.dropped
+	if n > 0 {
+		dst.dropped = 0
+	}
+	return batch, dropped
+}
+
+// requeue returns a batch that could not be exported to the front of the
+// buffer, dropping the oldest messages if the buffer is full.
+func (dst *otlpLogDestination) requeue(batch []logRecord, dropped int) {
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+	dst.records = append(batch, dst.records...)
+	dst.dropped += dropped
+	if excess := len(dst.records) - dst.maxBuffered; excess > 0 {
+		dst.records = dst.records[excess:]
+		dst.dropped += excess
+	}
+}
+
+// export sends an encoded batch to the given URL of the collector.  It returns
+// whether the batch should be retried, and whether the collector is
+// reachable.  Batches rejected by the collector as invalid are not retried.
+func (dst *otlpLogDestination) export(url string, body []byte) (retry bool, reachable bool) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		dst.errors.Printf("could not create export request: %s", err)
+		return false, true
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range dst.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := dst.client.Do(req)
+	if err != nil {
+		dst.errors.Printf("could not export to %s: %s", url, err)
+		return true, false
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, true
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		dst.errors.Printf("could not export to %s: %s", url, resp.Status)
+		return true, false
+	default:
+		dst.errors.Printf("batch rejected by %s: %s (dropping batch)", url, resp.Status)
+		return false, true
+	}
+}
+
+// spool writes an encoded batch to the spool directory, removing the oldest
+// spooled batches if the spool is full.  It returns whether the batch was
+// spooled.
+func (dst *otlpLogDestination) spool(body []byte) bool {
+	err := os.MkdirAll(dst.spoolDir, 0700)
+	if err != nil {
+		dst.errors.Printf("could not create spool directory %s: %s", dst.spoolDir, err)
+		return false
+	}
+	dst.mutex.Lock()
+	dst.spooled++
+	name := fmt.Sprintf("%s-%06d.json", dst.now().UTC().Format(spoolTimeLayout), dst.spooled%1000000)
+	dst.mutex.Unlock()
+
+	path := filepath.Join(dst.spoolDir, name)
+	err = os.WriteFile(path+".tmp", body, 0600)
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		_ = os.Remove(path + ".tmp")
+		dst.errors.Printf("could not spool logs: %s", err)
+		return false
+	}
+
+	files := dst.spoolFiles()
+	var size int64
+	sizes := make([]int64, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err == nil {
+			sizes[i] = info.Size()
+			size += sizes[i]
+		}
+	}
+	for i := 0; size > dst.maxSpoolSize && i < len(files)-1; i++ {
+		dst.errors.Printf("spool directory %s is full; discarding %s", dst.spoolDir, filepath.Base(files[i]))
+		_ = os.Remove(files[i])
+		size -= sizes[i]
+	}
+	return true
+}
+
+// spoolFiles returns the spooled batches, oldest first.
+func (dst *otlpLogDestination) spoolFiles() []string {
+	if dst.spoolDir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(dst.spoolDir, "*.json"))
+	if err != nil {
+		dst.errors.Printf("could not list spool directory %s: %s", dst.spoolDir, err)
+		return nil
+	}
+	sort.Strings(files)
+	return files
+}
+
+// exportSpool exports spooled batches, oldest first, removing them from the
+// spool once exported.  It returns false if the collector could not be
+// reached.
+func (dst *otlpLogDestination) exportSpool() bool {
+	for _, file := range dst.spoolFiles() {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			dst.errors.Printf("could not read spooled logs %s: %s", file, err)
+			continue
+		}
+		retry, reachable := dst.export(dst.logsURL, body)
+		if retry {
+			return reachable
+		}
+		err = os.Remove(file)
+		if err != nil {
+			dst.errors.Printf("could not remove spooled logs %s: %s", file, err)
+		}
+	}
+	return true
+}
+
+// encode encodes a batch of messages as an OTLP ExportLogsServiceRequest.
+// If any messages were dropped, a message saying so is added to the batch.
+func (dst *otlpLogDestination) encode(batch []logRecord, dropped int) []byte {
+	if dropped > 0 {
+		batch = append(batch, toLogRecord(map[string]any{
+			"textPayload": fmt.Sprintf("%d log messages were dropped, since they could not be exported", dropped),
+			"level":       "warning",
+		}, dst.now()))
+	}
+
+	body, err := json.Marshal(exportLogsServiceRequest{
+		ResourceLogs: []resourceLogs{{
+			Resource: dst.resource(),
+			ScopeLogs: []scopeLogs{{
+				Scope:      instrumentationScope{Name: "worker-runner"},
+				LogRecords: batch,
+			}},
+		}},
+	})
+	if err != nil {
+		// log records only contain JSON-able values, so this cannot happen
+		panic(err)
+	}
+	return body
+}
+
+// resource returns the OTLP resource for exported logs and metrics, which
+// identifies the worker.
+func (dst *otlpLogDestination) resource() resource {
+	resourceAttributes := []keyValue{
+		{Key: "service.name", Value: stringValue(dst.serviceName)},
+	}
+	dst.mutex.Lock()
+	state := dst.state
+	dst.mutex.Unlock()
+	if state != nil {
+		state.RLock()
+		for _, attr := range []struct{ key, value string }{
+			{"taskcluster.worker_pool_id", state.WorkerPoolID},
+			{"taskcluster.worker_group", state.WorkerGroup},
+			{"taskcluster.worker_id", state.WorkerID},
+			{"taskcluster.provider_id", state.ProviderID},
+		} {
+			if attr.value != "" {
+				resourceAttributes = append(resourceAttributes, keyValue{Key: attr.key, Value: stringValue(attr.value)})
+			}
+		}
+		state.RUnlock()
+	}
+	return resource{Attributes: resourceAttributes}
+}
+
+// The following types are the JSON encoding of the OTLP logs data model; see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type exportLogsServiceRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeLogs struct {
+	Scope      instrumentationScope `json:"scope"`
+	LogRecords []logRecord          `json:"logRecords"`
+}
+
+type instrumentationScope struct {
+	Name string `json:"name"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber,omitempty"`
+	SeverityText         string     `json:"severityText,omitempty"`
+	Body                 *anyValue  `json:"body,omitempty"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string       `json:"stringValue,omitempty"`
+	BoolValue   *bool         `json:"boolValue,omitempty"`
+	IntValue    *string       `json:"intValue,omitempty"`
+	DoubleValue *float64      `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *keyValueList `json:"kvlistValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+type keyValueList struct {
+	Values []keyValue `json:"values"`
+}
+
+func stringValue(s string) anyValue {
+	return anyValue{StringValue: &s}
+}
+
+// toAnyValue converts a value from a structured message to an OTLP AnyValue.
+func toAnyValue(value any) anyValue {
+	switch v := value.(type) {
+	case nil:
+		return anyValue{}
+	case string:
+		return stringValue(v)
+	case bool:
+		return anyValue{BoolValue: &v}
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		return anyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return anyValue{IntValue: &s}
+	case float64:
+		return anyValue{DoubleValue: &v}
+	case []any:
+		values := make([]anyValue, len(v))
+		for i, item := range v {
+			values[i] = toAnyValue(item)
+		}
+		return anyValue{ArrayValue: &arrayValue{Values: values}}
+	case map[string]any:
+		return anyValue{KvlistValue: &keyValueList{Values: toKeyValues(v)}}
+	default:
+		// round-trip anything else through JSON, falling back to a string
+		j, err := json.Marshal(v)
+		if err == nil {
+			var decoded any
+			if json.Unmarshal(j, &decoded) == nil {
+				return toAnyValue(decoded)
+			}
+		}
+		return stringValue(fmt.Sprintf("%v", v))
+	}
+}
+
+func toKeyValues(m map[string]any) []keyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]keyValue, len(keys))
+	for i, k := range keys {
+		kvs[i] = keyValue{Key: k, Value: toAnyValue(m[k])}
+	}
+	return kvs
+}
+
+// severityNumbers maps values of the `level` or `severity` field of
+// structured messages to OTLP severity numbers
+var severityNumbers = map[string]int{
+	"trace":     1,
+	"debug":     5,
+	"info":      9,
+	"notice":    10,
+	"warn":      13,
+	"warning":   13,
+	"err":       17,
+	"error":     17,
+	"crit":      21,
+	"critical":  21,
+	"alert":     22,
+	"emerg":     23,
+	"emergency": 23,
+	"fatal":     21,
+}
+
+// toLogRecord converts a structured message to an OTLP log record.  A string
+// textPayload field is used as the body, and all other fields are included
+// as attributes.
+func toLogRecord(message map[string]any, now time.Time) logRecord {
+	timestamp := strconv.FormatInt(now.UnixNano(), 10)
+	record := logRecord{
+		TimeUnixNano:         timestamp,
+		ObservedTimeUnixNano: timestamp,
+	}
+	for _, field := range []string{"level", "severity"} {
+		if level, ok := message[field].(string); ok {
+			if n, ok := severityNumbers[strings.ToLower(level)]; ok {
+				record.SeverityNumber = n
+				record.SeverityText = strings.ToUpper(level)
+				break
+			}
+		}
+	}
+
+	attributes := message
+	if text, ok := message["textPayload"].(string); ok {
+		body := stringValue(text)
+		record.Body = &body
+		attributes = make(map[string]any, len(message))
+		for k, v := range message {
+			if k != "textPayload" {
+				attributes[k] = v
+			}
+		}
+	}
+	if len(attributes) > 0 {
+		record.Attributes = toKeyValues(attributes)
+	}
+	return record
+}
+
+func newOTLPLogDestination(lc otlpLoggingConfig) (*otlpLogDestination, error) {
+	if lc.BatchSize <= 0 || lc.FlushIntervalSeconds <= 0 || lc.MaxBufferedRecords <= 0 || lc.MaxSpoolMB <= 0 {
+		return nil, fmt.Errorf("otlp logging `batchSize`, `flushIntervalSeconds`, `maxBufferedRecords` and `maxSpoolMB` must be positive")
+	}
+	if lc.MetricsIntervalSeconds < 0 {
+		return nil, fmt.Errorf("otlp logging `metricsIntervalSeconds` must not be negative")
+	}
+	headers := map[string]string{}
+	for k, v := range lc.Headers {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("otlp logging header %s must be a string", k)
+		}
+		headers[k] = s
+	}
+	endpoint := strings.TrimSuffix(lc.Endpoint, "/")
+	return &otlpLogDestination{
+		logsURL:         endpoint + "/v1/logs",
+		metricsURL:      endpoint + "/v1/metrics",
+		headers:         headers,
+		serviceName:     lc.ServiceName,
+		batchSize:       lc.BatchSize,
+		flushInterval:   time.Duration(lc.FlushIntervalSeconds) * time.Second,
+		maxBuffered:     max(lc.MaxBufferedRecords, lc.BatchSize),
+		spoolDir:        lc.SpoolDir,
+		maxSpoolSize:    int64(lc.MaxSpoolMB) * 1024 * 1024,
+		metricsInterval: time.Duration(lc.MetricsIntervalSeconds) * time.Second,
+		startTime:       time.Now(),
+		client:          &http.Client{Timeout: 10 * time.Second},
+		now:             time.Now,
+		errors:          log.New(os.Stderr, "otlp logging: ", log.LstdFlags),
+		flushNow:        make(chan struct{}, 1),
+		stop:            make(chan struct{}),
+	}, nil
+}
+
+func New(runnercfg *cfg.RunnerConfig) (logging.Logger, error) {
+	lc := otlpLoggingConfig{
+		ServiceName:            "worker-runner",
+		BatchSize:              512,
+		FlushIntervalSeconds:   5,
+		MaxBufferedRecords:     10000,
+		MaxSpoolMB:             100,
+		MetricsIntervalSeconds: 60,
+	}
+	err := runnercfg.Logging.Unpack(&lc)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := newOTLPLogDestination(lc)
+	if err != nil {
+		return nil, err
+	}
+	dst.start()
+	return dst, nil
+}
+
+func Usage() string {
+	return `
+
+The "otlp" logging exports log messages to an OpenTelemetry collector, using
+OTLP/HTTP with JSON encoding.  Messages are sent to the "/v1/logs" path of the
+given "endpoint".  The "textPayload" field of structured messages is sent as
+the body of the log record, and all other fields as its attributes.  The
+severity of each message is taken from its "level" or "severity" field.  The
+resource of the log records has attribute "service.name" (from
+"serviceName", default "worker-runner"), and, once the worker is registered,
+the attributes "taskcluster.worker_pool_id", "taskcluster.worker_group",
+"taskcluster.worker_id" and "taskcluster.provider_id".
+
+Messages are exported in batches of up to "batchSize" (default 512) messages,
+at least every "flushIntervalSeconds" (default 5).  At most
+"maxBufferedRecords" (default 10000) messages are held in memory.  If
+"spoolDir" is set, batches that cannot be exported because the collector is
+unreachable are written to that directory, and exported once the collector is
+reachable again, including after worker-runner restarts.  The spool is limited
+to "maxSpoolMB" (default 100).  When the buffer or spool is full, the oldest
+messages are dropped.
+
+Every "metricsIntervalSeconds" (default 60), and when worker-runner exits,
+metrics about the worker are sent to the "/v1/metrics" path of the
+"endpoint", with the same resource attributes as the log records.  Setting
+"metricsIntervalSeconds" to 0 disables this.  Metrics are not buffered or
+spooled, since each export contains their current values.  The metrics are:
+
+ * "taskcluster.worker.credentials.time_until_expiry" (gauge, seconds): the
+   time until the worker's Taskcluster credentials expire, if they expire
+ * "taskcluster.worker.tasks.running" (gauge): the number of tasks the worker
+   is running
+ * "taskcluster.worker.tasks.resolved" (cumulative sum): the number of tasks
+   the worker has resolved
+ * "taskcluster.worker.restarts" (cumulative sum): the number of times the
+   worker has been restarted after crashing
+
+The task metrics are only exported for workers that report task claims and
+resolutions to worker-runner, such as generic-worker.
+
+` + "```yaml" + `
+logging:
+    implementation: otlp
+    endpoint: http://localhost:4318
+    # (optional) additional HTTP headers, e.g., for authentication
+    headers: {name: value, ..}
+    # (optional) batching and buffering settings
+    serviceName: worker-runner
+    batchSize: 512
+    flushIntervalSeconds: 5
+    maxBufferedRecords: 10000
+    spoolDir: /var/spool/worker-runner/otlp
+    maxSpoolMB: 100
+    # (optional) metrics export interval, or 0 to disable
+    metricsIntervalSeconds: 60
+` + "```" + `
+
+`
+}
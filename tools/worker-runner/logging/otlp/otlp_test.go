@@ -0,0 +1,393 @@
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// receiver is an OTLP/HTTP logs and metrics receiver, standing in for a
+// collector
+type receiver struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	status   int
+	requests []exportLogsServiceRequest
+	headers  []http.Header
+	metrics  []exportMetricsServiceRequest
+}
+
+func newReceiver(t *testing.T) *receiver {
+	t.Helper()
+	rcv := &receiver{status: http.StatusOK}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rcv.mutex.Lock()
+		defer rcv.mutex.Unlock()
+		if (r.URL.Path != "/v1/logs" && r.URL.Path != "/v1/metrics") || r.Method != "POST" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if rcv.status != http.StatusOK {
+			w.WriteHeader(rcv.status)
+			return
+		}
+		if r.URL.Path == "/v1/metrics" {
+			var req exportMetricsServiceRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rcv.metrics = append(rcv.metrics, req)
+			_, _ = w.Write([]byte("{}"))
+			return
+		}
+		var req exportLogsServiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rcv.requests = append(rcv.requests, req)
+		rcv.headers = append(rcv.headers, r.Header)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) setStatus(status int) {
+	rcv.mutex.Lock()
+	defer rcv.mutex.Unlock()
+	rcv.status = status
+}
+
+// bodies returns the bodies of all received log records, in order
+func (rcv *receiver) bodies() []string {
+	rcv.mutex.Lock()
+	defer rcv.mutex.Unlock()
+	bodies := []string{}
+	for _, req := range rcv.requests {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				for _, lr := range sl.LogRecords {
+					if lr.Body != nil && lr.Body.StringValue != nil {
+						bodies = append(bodies, *lr.Body.StringValue)
+					}
+				}
+			}
+		}
+	}
+	return bodies
+}
+
+func configure(t *testing.T, config string) *otlpLogDestination {
+	t.Helper()
+	var runnercfg cfg.RunnerConfig
+	require.NoError(t, yaml.Unmarshal([]byte(config), &runnercfg))
+	logger, err := New(&runnercfg)
+	require.NoError(t, err)
+	dst := logger.(*otlpLogDestination)
+	t.Cleanup(dst.close)
+	return dst
+}
+
+func attributes(kvs []keyValue) map[string]anyValue {
+	rv := map[string]anyValue{}
+	for _, kv := range kvs {
+		rv[kv.Key] = kv.Value
+	}
+	return rv
+}
+
+func TestExport(t *testing.T) {
+	rcv := newReceiver(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  headers:
+    Authorization: Bearer s3cr3t
+`, rcv.URL))
+
+	state := &run.State{
+		WorkerPoolID: "pp/wt",
+		WorkerGroup:  "wg",
+		WorkerID:     "wi",
+		ProviderID:   "prov",
+	}
+	dst.SetRunState(state)
+
+	dst.LogUnstructured("hello")
+	dst.LogStructured(map[string]any{
+		"textPayload": "oops",
+		"level":       "error",
+		"count":       float64(3),
+		"ok":          true,
+		"nested":      map[string]any{"a": "b"},
+	})
+	dst.Flush()
+
+	require.Equal(t, []string{"hello", "oops"}, rcv.bodies())
+	require.Len(t, rcv.requests, 1)
+	require.Equal(t, "Bearer s3cr3t", rcv.headers[0].Get("Authorization"))
+	require.Equal(t, "application/json", rcv.headers[0].Get("Content-Type"))
+
+	rl := rcv.requests[0].ResourceLogs[0]
+	resourceAttrs := attributes(rl.Resource.Attributes)
+	require.Equal(t, "worker-runner", *resourceAttrs["service.name"].StringValue)
+	require.Equal(t, "pp/wt", *resourceAttrs["taskcluster.worker_pool_id"].StringValue)
+	require.Equal(t, "wg", *resourceAttrs["taskcluster.worker_group"].StringValue)
+	require.Equal(t, "wi", *resourceAttrs["taskcluster.worker_id"].StringValue)
+	require.Equal(t, "prov", *resourceAttrs["taskcluster.provider_id"].StringValue)
+
+	records := rl.ScopeLogs[0].LogRecords
+	require.Equal(t, 0, records[0].SeverityNumber)
+	require.Nil(t, records[0].Attributes)
+	require.Equal(t, 17, records[1].SeverityNumber)
+	require.Equal(t, "ERROR", records[1].SeverityText)
+	attrs := attributes(records[1].Attributes)
+	require.NotContains(t, attrs, "textPayload")
+	require.Equal(t, "error", *attrs["level"].StringValue)
+	require.Equal(t, float64(3), *attrs["count"].DoubleValue)
+	require.Equal(t, true, *attrs["ok"].BoolValue)
+	require.Equal(t, "a", attrs["nested"].KvlistValue.Values[0].Key)
+}
+
+func TestExportWithoutRunState(t *testing.T) {
+	rcv := newReceiver(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s/
+  serviceName: my-runner
+`, rcv.URL))
+
+	dst.LogUnstructured("hello")
+	dst.Flush()
+
+	require.Equal(t, []string{"hello"}, rcv.bodies())
+	resourceAttrs := attributes(rcv.requests[0].ResourceLogs[0].Resource.Attributes)
+	require.Equal(t, "my-runner", *resourceAttrs["service.name"].StringValue)
+	require.NotContains(t, resourceAttrs, "taskcluster.worker_id")
+}
+
+func TestBatching(t *testing.T) {
+	rcv := newReceiver(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  batchSize: 2
+  flushIntervalSeconds: 3600
+`, rcv.URL))
+
+	dst.LogUnstructured("one")
+	dst.LogUnstructured("two")
+
+	// a full batch is exported without waiting for the flush interval
+	require.Eventually(t, func() bool {
+		return len(rcv.bodies()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	dst.LogUnstructured("three")
+	dst.LogUnstructured("four")
+	dst.LogUnstructured("five")
+	dst.Flush()
+
+	require.Equal(t, []string{"one", "two", "three", "four", "five"}, rcv.bodies())
+	for _, req := range rcv.requests {
+		require.LessOrEqual(t, len(req.ResourceLogs[0].ScopeLogs[0].LogRecords), 2)
+	}
+}
+
+func TestBoundedBuffer(t *testing.T) {
+	rcv := newReceiver(t)
+	rcv.setStatus(http.StatusServiceUnavailable)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  batchSize: 2
+  maxBufferedRecords: 3
+  flushIntervalSeconds: 3600
+`, rcv.URL))
+
+	for i := range 5 {
+		dst.LogUnstructured(fmt.Sprintf("msg %d", i))
+	}
+	dst.Flush()
+	require.Empty(t, rcv.bodies())
+
+	rcv.setStatus(http.StatusOK)
+	dst.Flush()
+
+	// the oldest messages were dropped, and that is reported
+	require.Equal(t, []string{
+		"msg 2",
+		"msg 3",
+		"2 log messages were dropped, since they could not be exported",
+		"msg 4",
+	}, rcv.bodies())
+}
+
+func TestSpool(t *testing.T) {
+	rcv := newReceiver(t)
+	rcv.setStatus(http.StatusServiceUnavailable)
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  batchSize: 2
+  flushIntervalSeconds: 3600
+  spoolDir: %s
+`, rcv.URL, spoolDir))
+
+	for i := range 5 {
+		dst.LogUnstructured(fmt.Sprintf("msg %d", i))
+	}
+	dst.Flush()
+	require.Empty(t, rcv.bodies())
+
+	spooled, err := filepath.Glob(filepath.Join(spoolDir, "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, spooled)
+
+	rcv.setStatus(http.StatusOK)
+	dst.LogUnstructured("msg 5")
+	dst.Flush()
+
+	// nothing was dropped, and spooled messages are exported first
+	require.Equal(t, []string{"msg 0", "msg 1", "msg 2", "msg 3", "msg 4", "msg 5"}, rcv.bodies())
+	spooled, err = filepath.Glob(filepath.Join(spoolDir, "*"))
+	require.NoError(t, err)
+	require.Empty(t, spooled)
+}
+
+func TestSpoolFromPreviousRun(t *testing.T) {
+	rcv := newReceiver(t)
+	rcv.setStatus(http.StatusServiceUnavailable)
+	spoolDir := t.TempDir()
+	config := fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  flushIntervalSeconds: 3600
+  spoolDir: %s
+`, rcv.URL, spoolDir)
+
+	dst := configure(t, config)
+	dst.LogUnstructured("before restart")
+	dst.Flush()
+	dst.close()
+
+	rcv.setStatus(http.StatusOK)
+	dst = configure(t, config)
+	dst.LogUnstructured("after restart")
+	dst.Flush()
+
+	require.Equal(t, []string{"before restart", "after restart"}, rcv.bodies())
+}
+
+func TestSpoolLimit(t *testing.T) {
+	rcv := newReceiver(t)
+	rcv.setStatus(http.StatusServiceUnavailable)
+	spoolDir := t.TempDir()
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  batchSize: 1
+  flushIntervalSeconds: 3600
+  spoolDir: %s
+`, rcv.URL, spoolDir))
+	// each spooled batch is a few hundred bytes
+	dst.maxSpoolSize = 1000
+
+	for i := range 10 {
+		dst.LogUnstructured(fmt.Sprintf("msg %d", i))
+	}
+	dst.Flush()
+
+	spooled, err := filepath.Glob(filepath.Join(spoolDir, "*.json"))
+	require.NoError(t, err)
+	require.Less(t, len(spooled), 10)
+
+	rcv.setStatus(http.StatusOK)
+	dst.Flush()
+
+	// the newest batches were kept
+	bodies := rcv.bodies()
+	require.Equal(t, len(spooled), len(bodies))
+	require.Equal(t, "msg 9", bodies[len(bodies)-1])
+}
+
+func TestRejectedBatchNotRetried(t *testing.T) {
+	rcv := newReceiver(t)
+	rcv.setStatus(http.StatusBadRequest)
+	spoolDir := t.TempDir()
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: otlp
+  endpoint: %s
+  flushIntervalSeconds: 3600
+  spoolDir: %s
+`, rcv.URL, spoolDir))
+
+	dst.LogUnstructured("bad")
+	dst.Flush()
+
+	rcv.setStatus(http.StatusOK)
+	dst.LogUnstructured("good")
+	dst.Flush()
+
+	require.Equal(t, []string{"good"}, rcv.bodies())
+	spooled, err := filepath.Glob(filepath.Join(spoolDir, "*"))
+	require.NoError(t, err)
+	require.Empty(t, spooled)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for name, config := range map[string]string{
+		"missing endpoint": `
+logging:
+  implementation: otlp
+`,
+		"non-string header": `
+logging:
+  implementation: otlp
+  endpoint: http://localhost:4318
+  headers:
+    X-Count: 3
+`,
+		"zero batch size": `
+logging:
+  implementation: otlp
+  endpoint: http://localhost:4318
+  batchSize: 0
+`,
+		"negative metrics interval": `
+logging:
+  implementation: otlp
+  endpoint: http://localhost:4318
+  metricsIntervalSeconds: -1
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var runnercfg cfg.RunnerConfig
+			require.NoError(t, yaml.Unmarshal([]byte(config), &runnercfg))
+			_, err := New(&runnercfg)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Package syslog implements a logging destination that sends log messages to
+// a syslog daemon, or to journald's syslog socket, in the format defined by
+// RFC 5424.  The fields of structured messages are sent as RFC 5424
+// structured data.
+package syslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/logging"
+)
+
+const (
+	// the default address of the syslog socket
+	defaultSyslogAddress = "/dev/log"
+	// the default address of journald's syslog socket; /dev/log is normally
+	// a symlink to it on systems using journald
+	defaultJournaldAddress = "/run/systemd/journal/dev-log"
+
+	// the default SD-ID of the structured data element containing the
+	// fields of structured messages; 32473 is the private enterprise number
+	// reserved for documentation and examples (RFC 5612)
+	defaultStructuredDataID = "fields@32473"
+
+	// messages sent as datagrams are truncated to this length, since larger
+	// datagrams may be rejected by the socket
+	maxDatagramLength = 64 * 1024
+
+	nilValue = "-"
+)
+
+var facilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// severities maps values of the `level` or `severity` field of structured
+// messages to syslog severities
+var severities = map[string]int{
+	"emerg":     0,
+	"emergency": 0,
+	"alert":     1,
+	"crit":      2,
+	"critical":  2,
+	"err":       3,
+	"error":     3,
+	"warn":      4,
+	"warning":   4,
+	"notice":    5,
+	"info":      6,
+	"debug":     7,
+}
+
+const defaultSeverity = 6
+
+type syslogLoggingConfig struct {
+	Network          string `logging:"network,optional"`
+	Address          string `logging:"address,optional"`
+	Facility         string `logging:"facility,optional"`
+	AppName          string `logging:"appName,optional"`
+	Hostname         string `logging:"hostname,optional"`
+	StructuredDataID string `logging:"structuredDataID,optional"`
+}
+
+type syslogLogDestination struct {
+	network          string
+	address          string
+	facility         int
+	appName          string
+	hostname         string
+	procID           string
+	structuredDataID string
+
+	// now returns the current time, and can be overridden in tests
+	now func() time.Time
+	// errors writes errors encountered while logging, which cannot be logged
+	// to syslog itself
+	errors *log.Logger
+
+	// mutex protects conn
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+func (dst *syslogLogDestination) LogUnstructured(message string) {
+	dst.LogStructured(logging.ToStructured(message))
+}
+
+func (dst *syslogLogDestination) LogStructured(message map[string]any) {
+	dst.send(dst.format(message))
+}
+
+// format formats the given message according to RFC 5424.  A string
+// textPayload field is used as the MSG part, and all other fields are
+// included as parameters of a single structured data element.
+func (dst *syslogLogDestination) format(message map[string]any) string {
+	severity := defaultSeverity
+	for _, field := range []string{"level", "severity"} {
+		if level, ok := message[field].(string); ok {
+			if s, ok := severities[strings.ToLower(level)]; ok {
+				severity = s
+				break
+			}
+		}
+	}
+
+	msg, hasMsg := message["textPayload"].(string)
+
+	keys := make([]string, 0, len(message))
+	for k := range message {
+		if k == "textPayload" && hasMsg {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	structuredData := nilValue
+	if len(keys) > 0 {
+		var sd strings.Builder
+		sd.WriteString("[")
+		sd.WriteString(dst.structuredDataID)
+		for _, k := range keys {
+			sd.WriteString(" ")
+			sd.WriteString(paramName(k))
+			sd.WriteString(`="`)
+			sd.WriteString(paramValue(message[k]))
+			sd.WriteString(`"`)
+		}
+		sd.WriteString("]")
+		structuredData = sd.String()
+	}
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %s %s %s",
+		dst.facility*8+severity,
+		dst.now().UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		dst.hostname,
+		dst.appName,
+		dst.procID,
+		nilValue, // MSGID
+		structuredData,
+	)
+	if hasMsg && msg != "" {
+		line += " " + msg
+	}
+	return line
+}
+
+// paramName converts a field name to a valid SD-NAME: at most 32 printable
+// US-ASCII characters, excluding '=', ' ', ']' and '"'.
+func paramName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	result := b.String()
+	if len(result) > 32 {
+		result = result[:32]
+	}
+	if result == "" {
+		result = "_"
+	}
+	return result
+}
+
+// paramValue converts a field value to a PARAM-VALUE: strings are used as-is,
+// and other values are encoded as JSON; '"', '\' and ']' are escaped.
+func paramValue(value any) string {
+	str, ok := value.(string)
+	if !ok {
+		j, err := json.Marshal(value)
+		if err != nil {
+			str = fmt.Sprintf("%#v", value)
+		} else {
+			str = string(j)
+		}
+	}
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(str)
+}
+
+// send sends a formatted message, connecting (or reconnecting) to the syslog
+// socket as necessary.
+func (dst *syslogLogDestination) send(line string) {
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+
+	var frame []byte
+	switch dst.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		// stream transports use octet-counting framing (RFC 6587)
+		frame = fmt.Appendf(nil, "%d %s", len(line), line)
+	default:
+		if len(line) > maxDatagramLength {
+			line = line[:maxDatagramLength]
+		}
+		frame = []byte(line)
+	}
+
+	// try twice, in case the connection was closed since the last message
+	var err error
+	for range 2 {
+		if dst.conn == nil {
+			dst.conn, err = net.Dial(dst.network, dst.address)
+			if err != nil {
+				continue
+			}
+		}
+		_, err = dst.conn.Write(frame)
+		if err == nil {
+			return
+		}
+		_ = dst.conn.Close()
+		dst.conn = nil
+	}
+	dst.errors.Printf("could not send log message to %s %s: %s", dst.network, dst.address, err)
+	fmt.Fprintln(os.Stderr, line)
+}
+
+func newLogger(runnercfg *cfg.RunnerConfig, defaultAddress string) (logging.Logger, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = nilValue
+	}
+	lc := syslogLoggingConfig{
+		Network:          "unixgram",
+		Address:          defaultAddress,
+		Facility:         "daemon",
+		AppName:          "worker-runner",
+		Hostname:         hostname,
+		StructuredDataID: defaultStructuredDataID,
+	}
+	err = runnercfg.Logging.Unpack(&lc)
+	if err != nil {
+		return nil, err
+	}
+	facility, ok := facilities[lc.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", lc.Facility)
+	}
+	return &syslogLogDestination{
+		network:          lc.Network,
+		address:          lc.Address,
+		facility:         facility,
+		appName:          lc.AppName,
+		hostname:         lc.Hostname,
+		procID:           fmt.Sprintf("%d", os.Getpid()),
+		structuredDataID: lc.StructuredDataID,
+		now:              time.Now,
+		errors:           log.New(os.Stderr, "syslog logging: ", log.LstdFlags),
+	}, nil
+}
+
+// New creates a logger that sends messages to the syslog socket, /dev/log
+// by default.
+func New(runnercfg *cfg.RunnerConfig) (logging.Logger, error) {
+	return newLogger(runnercfg, defaultSyslogAddress)
+}
+
+// NewJournald creates a logger that sends messages to journald's syslog
+// socket.
+func NewJournald(runnercfg *cfg.RunnerConfig) (logging.Logger, error) {
+	return newLogger(runnercfg, defaultJournaldAddress)
+}
+
+func Usage() string {
+	return `
+
+The "syslog" logging sends log messages to a syslog daemon, in the format
+defined by RFC 5424.  The "textPayload" field of structured messages is sent
+as the message text, and all other fields are sent as parameters of a single
+structured data element, with SD-ID "structuredDataID" (default
+"fields@32473").  Non-string values are encoded as JSON.  The severity of each
+message is taken from its "level" or "severity" field, if that is a syslog
+severity name such as "error" or "warning", and defaults to "info".
+
+By default, messages are sent to the Unix datagram socket "/dev/log".  Set
+"network" to "udp" or "tcp" (or "unix", for a Unix stream socket) and
+"address" accordingly to send them elsewhere.  Messages sent over stream
+transports use octet-counting framing (RFC 6587).
+
+` + "```yaml" + `
+logging:
+    implementation: syslog
+    # (optional) where to send messages
+    network: unixgram
+    address: /dev/log
+    # (optional) message header values
+    facility: daemon
+    appName: worker-runner
+    hostname: .. # defaults to the hostname of the machine
+    structuredDataID: fields@32473
+` + "```" + `
+
+`
+}
+
+func JournaldUsage() string {
+	return `
+
+The "journald" logging is the same as the "syslog" logging, and takes the same
+properties, except that messages are sent to journald's syslog socket,
+"/run/systemd/journal/dev-log", by default.
+
+` + "```yaml" + `
+logging:
+    implementation: journald
+` + "```" + `
+
+`
+}
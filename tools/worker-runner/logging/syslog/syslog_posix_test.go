@@ -0,0 +1,118 @@
+//go:build linux || darwin || freebsd
+
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// listenUnixgram listens on a Unix datagram socket in a temporary directory,
+// standing in for /dev/log.
+func listenUnixgram(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	// socket paths are limited in length, so avoid the (long) test name
+	dir, err := os.MkdirTemp("", "syslog")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, path
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 128*1024)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestLogStructured(t *testing.T) {
+	conn, path := listenUnixgram(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: syslog
+  address: %s
+  hostname: test-host
+`, path))
+
+	dst.LogStructured(map[string]any{
+		"textPayload": "task finished",
+		"level":       "warning",
+		"taskId":      "abc",
+		"runId":       0,
+		"tricky key=": `say "hi" \o/ [x]`,
+	})
+
+	pid := strconv.Itoa(os.Getpid())
+	require.Equal(t,
+		`<28>1 2026-01-02T03:04:05.678000Z test-host worker-runner `+pid+` - [fields@32473 level="warning" runId="0" taskId="abc" tricky_key_="say \"hi\" \\o/ [x\]"] task finished`,
+		receive(t, conn))
+}
+
+func TestLogUnstructured(t *testing.T) {
+	conn, path := listenUnixgram(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: syslog
+  address: %s
+  hostname: test-host
+  facility: local3
+  appName: runner
+`, path))
+
+	dst.LogUnstructured("hello, world")
+
+	pid := strconv.Itoa(os.Getpid())
+	require.Equal(t, `<158>1 2026-01-02T03:04:05.678000Z test-host runner `+pid+` - - hello, world`, receive(t, conn))
+}
+
+func TestLogWithoutTextPayload(t *testing.T) {
+	conn, path := listenUnixgram(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: syslog
+  address: %s
+  hostname: test-host
+  structuredDataID: worker@12345
+`, path))
+
+	dst.LogStructured(map[string]any{"severity": "ERROR", "textPayload": []string{"a", "b"}})
+
+	pid := strconv.Itoa(os.Getpid())
+	require.Equal(t, `<27>1 2026-01-02T03:04:05.678000Z test-host worker-runner `+pid+` - [worker@12345 severity="ERROR" textPayload="[\"a\",\"b\"\]"]`, receive(t, conn))
+}
+
+func TestReconnect(t *testing.T) {
+	conn, path := listenUnixgram(t)
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: syslog
+  address: %s
+`, path))
+
+	dst.LogUnstructured("first")
+	require.True(t, strings.HasSuffix(receive(t, conn), " first"))
+
+	// simulate the syslog daemon restarting
+	require.NoError(t, conn.Close())
+	require.NoError(t, os.Remove(path))
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	dst.LogUnstructured("second")
+	require.True(t, strings.HasSuffix(receive(t, conn), " second"))
+}
@@ -0,0 +1,82 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func configure(t *testing.T, config string) *syslogLogDestination {
+	t.Helper()
+	var runnercfg cfg.RunnerConfig
+	require.NoError(t, yaml.Unmarshal([]byte(config), &runnercfg))
+	logger, err := New(&runnercfg)
+	require.NoError(t, err)
+	dst := logger.(*syslogLogDestination)
+	dst.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 678000000, time.UTC) }
+	t.Cleanup(func() {
+		if dst.conn != nil {
+			_ = dst.conn.Close()
+		}
+	})
+	return dst
+}
+
+func TestTCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	dst := configure(t, fmt.Sprintf(`
+logging:
+  implementation: syslog
+  network: tcp
+  address: %s
+  hostname: test-host
+`, listener.Addr()))
+
+	dst.LogUnstructured("one")
+	dst.LogUnstructured("two")
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	reader := bufio.NewReader(conn)
+	for _, expected := range []string{"one", "two"} {
+		length, err := reader.ReadString(' ')
+		require.NoError(t, err)
+		n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		require.NoError(t, err)
+		msg := make([]byte, n)
+		_, err = io.ReadFull(reader, msg)
+		require.NoError(t, err)
+		require.True(t, strings.HasSuffix(string(msg), " "+expected), "got %q", msg)
+	}
+}
+
+func TestUnknownFacility(t *testing.T) {
+	var runnercfg cfg.RunnerConfig
+	require.NoError(t, yaml.Unmarshal([]byte("logging:\n  implementation: syslog\n  facility: nope\n"), &runnercfg))
+	_, err := New(&runnercfg)
+	require.Error(t, err)
+}
+
+func TestJournaldDefaultAddress(t *testing.T) {
+	var runnercfg cfg.RunnerConfig
+	require.NoError(t, yaml.Unmarshal([]byte("logging:\n  implementation: journald\n"), &runnercfg))
+	logger, err := NewJournald(&runnercfg)
+	require.NoError(t, err)
+	dst := logger.(*syslogLogDestination)
+	require.Equal(t, "unixgram", dst.network)
+	require.Equal(t, "/run/systemd/journal/dev-log", dst.address)
+}
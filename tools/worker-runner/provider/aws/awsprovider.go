@@ -15,7 +15,16 @@ import (
 	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
 )
 
-const TERMINATION_PATH = "/meta-data/spot/termination-time"
+const (
+	// spot interruption notices; termination-time is the older form of
+	// instance-action, and both are present when an interruption is scheduled
+	TERMINATION_PATH     = "/meta-data/spot/termination-time"
+	INSTANCE_ACTION_PATH = "/meta-data/spot/instance-action"
+
+	// rebalance recommendation, which is issued when a spot instance is at
+	// elevated risk of interruption, usually some time before the interruption
+	REBALANCE_PATH = "/meta-data/events/recommendations/rebalance"
+)
 
 type AWSProvider struct {
 	runnercfg                  *cfg.RunnerConfig
@@ -23,7 +32,9 @@ type AWSProvider struct {
 	metadataService            MetadataService
 	proto                      *workerproto.Protocol
 	workerIdentityProof        map[string]any
-	terminationTicker          *time.Ticker
+	terminationInterval        time.Duration
+	stopPolling                chan struct{}
+	rebalanceMsgSent           bool
 }
 
 func (p *AWSProvider) ConfigureRun(state *run.State) error {
@@ -100,35 +111,59 @@ func (p *AWSProvider) SetProtocol(proto *workerproto.Protocol) {
 	p.proto = proto
 }
 
+func (p *AWSProvider) sendGracefulTermination(finishTasks bool) {
+	if p.proto != nil && p.proto.Capable("graceful-termination") {
+		p.proto.Send(workerproto.Message{
+			Type: "graceful-termination",
+			Properties: map[string]any{
+				"finish-tasks": finishTasks,
+			},
+		})
+	}
+}
+
+// checkTerminationTime checks for a spot interruption notice, returning true
+// if one is present, and for a rebalance recommendation.
 func (p *AWSProvider) checkTerminationTime() bool {
-	_, err := p.metadataService.queryMetadata(TERMINATION_PATH)
-	// if the file exists (so, no error), it's time to go away
-	if err == nil {
-		log.Println("EC2 Metadata Service says termination is imminent")
-		if p.proto != nil && p.proto.Capable("graceful-termination") {
-			p.proto.Send(workerproto.Message{
-				Type: "graceful-termination",
-				Properties: map[string]any{
-					// spot termination generally doesn't leave time to finish tasks
-					"finish-tasks": false,
-				},
-			})
+	for _, path := range []string{TERMINATION_PATH, INSTANCE_ACTION_PATH} {
+		notice, err := p.metadataService.queryMetadata(path)
+		// if the file exists (so, no error), it's time to go away
+		if err == nil {
+			log.Printf("EC2 Metadata Service says termination is imminent (%s: %s)", path, notice)
+			// spot termination generally doesn't leave time to finish tasks
+			p.sendGracefulTermination(false)
+			return true
 		}
-		return true
+	}
+
+	notice, err := p.metadataService.queryMetadata(REBALANCE_PATH)
+	if err == nil && !p.rebalanceMsgSent {
+		log.Printf("EC2 Metadata Service recommends rebalancing (%s)", notice)
+		// stop claiming new tasks, but finish the current ones; if an
+		// interruption follows, the worker is told not to finish them
+		p.sendGracefulTermination(true)
+		p.rebalanceMsgSent = true
 	}
 	return false
 }
 
 func (p *AWSProvider) WorkerStarted(state *run.State) error {
 	// start polling for graceful shutdown
-	p.terminationTicker = time.NewTicker(5 * time.Second)
 	p.proto.AddCapability("graceful-termination")
+	stop := make(chan struct{})
+	p.stopPolling = stop
+	ticker := time.NewTicker(p.terminationInterval)
 
 	go func() {
+		defer ticker.Stop()
 		for {
-			<-p.terminationTicker.C
-			log.Println("polling for termination-time")
-			p.checkTerminationTime()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				log.Println("polling for termination-time")
+				p.checkTerminationTime()
+			}
 		}
 	}()
 
@@ -136,7 +171,10 @@ func (p *AWSProvider) WorkerStarted(state *run.State) error {
 }
 
 func (p *AWSProvider) WorkerFinished(state *run.State) error {
-	p.terminationTicker.Stop()
+	if p.stopPolling != nil {
+		close(p.stopPolling)
+		p.stopPolling = nil
+	}
 	return nil
 }
 
@@ -165,6 +203,15 @@ defined by this provider has the following fields:
 * cloud: aws
 * region
 * availabilityZone
+
+While the worker is running, worker-runner polls the EC2 metadata service for
+spot interruption notices ("spot/instance-action" or "spot/termination-time")
+and rebalance recommendations ("events/recommendations/rebalance").  On an
+interruption notice, it sends a graceful-termination message with
+"finish-tasks" set to false, since interruptions generally do not leave time
+to finish tasks.  On a rebalance recommendation, it sends a
+graceful-termination message with "finish-tasks" set to true, so that the
+worker stops claiming new tasks but finishes its current ones.
 `
 }
 
@@ -182,8 +229,10 @@ func new(
 		metadataService = &realMetadataService{}
 	}
 
-	if _, err := metadataService.queryMetadata(TERMINATION_PATH); err == nil {
-		return nil, errors.New("instance is about to shutdown")
+	for _, path := range []string{TERMINATION_PATH, INSTANCE_ACTION_PATH} {
+		if _, err := metadataService.queryMetadata(path); err == nil {
+			return nil, errors.New("instance is about to shutdown")
+		}
 	}
 
 	return &AWSProvider{
@@ -191,5 +240,6 @@ func new(
 		workerManagerClientFactory: workerManagerClientFactory,
 		metadataService:            metadataService,
 		proto:                      nil,
+		terminationInterval:        5 * time.Second,
 	}, nil
 }
@@ -1,7 +1,9 @@
 package aws
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
@@ -93,7 +95,6 @@ func TestCheckTerminationTime(t *testing.T) {
 			workerManagerClientFactory: nil,
 			metadataService:            &fakeMetadataService{nil, nil, metaData, instanceIdentityDocument},
 			proto:                      proto,
-			terminationTicker:          nil,
 		}
 
 		proto.AddCapability("graceful-termination")
@@ -130,3 +131,123 @@ func TestCheckTerminationTime(t *testing.T) {
 		require.True(t, gotTerm())
 	})
 }
+
+func TestCheckInstanceAction(t *testing.T) {
+	wkr := ptesting.NewFakeWorkerWithCapabilities("graceful-termination")
+	defer wkr.Close()
+
+	gotTerm := wkr.MessageReceivedFunc("graceful-termination", func(msg workerproto.Message) bool {
+		return msg.Properties["finish-tasks"] == false
+	})
+
+	metaData := map[string]string{}
+	p := &AWSProvider{
+		metadataService: &fakeMetadataService{nil, nil, metaData, ""},
+		proto:           wkr.RunnerProtocol,
+	}
+	wkr.RunnerProtocol.AddCapability("graceful-termination")
+	wkr.RunnerProtocol.Start(false)
+
+	// not time yet..
+	require.False(t, p.checkTerminationTime())
+
+	metaData["/meta-data/spot/instance-action"] = `{"action": "terminate", "time": "2026-01-02T03:04:05Z"}`
+	require.True(t, p.checkTerminationTime())
+
+	require.True(t, gotTerm())
+}
+
+func TestCheckRebalanceRecommendation(t *testing.T) {
+	wkr := ptesting.NewFakeWorkerWithCapabilities("graceful-termination")
+	defer wkr.Close()
+
+	var finishTasks []bool
+	gotTerm := wkr.MessageReceivedFunc("graceful-termination", func(msg workerproto.Message) bool {
+		finishTasks = append(finishTasks, msg.Properties["finish-tasks"].(bool))
+		return true
+	})
+
+	metaData := map[string]string{}
+	p := &AWSProvider{
+		metadataService: &fakeMetadataService{nil, nil, metaData, ""},
+		proto:           wkr.RunnerProtocol,
+	}
+	wkr.RunnerProtocol.AddCapability("graceful-termination")
+	wkr.RunnerProtocol.Start(false)
+
+	// a rebalance recommendation is not an interruption, and tells the worker
+	// to finish its tasks, only once
+	metaData["/meta-data/events/recommendations/rebalance"] = `{"noticeTime": "2026-01-02T03:04:05Z"}`
+	require.False(t, p.checkTerminationTime())
+	require.False(t, p.checkTerminationTime())
+	require.True(t, gotTerm())
+	require.Equal(t, []bool{true}, finishTasks)
+
+	// a subsequent interruption tells the worker not to finish its tasks
+	metaData["/meta-data/spot/instance-action"] = `{"action": "stop", "time": "2026-01-02T03:06:05Z"}`
+	require.True(t, p.checkTerminationTime())
+	require.True(t, gotTerm())
+	require.Equal(t, []bool{true, false}, finishTasks)
+}
+
+func TestNewDuringInterruption(t *testing.T) {
+	for _, path := range []string{"/meta-data/spot/termination-time", "/meta-data/spot/instance-action"} {
+		t.Run(path, func(t *testing.T) {
+			mds := &fakeMetadataService{nil, nil, map[string]string{path: "soon"}, ""}
+			_, err := new(&cfg.RunnerConfig{}, tc.FakeWorkerManagerClientFactory, mds)
+			require.Error(t, err)
+		})
+	}
+
+	// a rebalance recommendation does not prevent the worker from starting
+	mds := &fakeMetadataService{nil, nil, map[string]string{"/meta-data/events/recommendations/rebalance": "{}"}, ""}
+	_, err := new(&cfg.RunnerConfig{}, tc.FakeWorkerManagerClientFactory, mds)
+	require.NoError(t, err)
+}
+
+// countingMetadataService counts the queries made when polling for
+// termination
+type countingMetadataService struct {
+	MetadataService
+	mutex   sync.Mutex
+	queries int
+}
+
+func (mds *countingMetadataService) queryMetadata(path string) (string, error) {
+	mds.mutex.Lock()
+	mds.queries++
+	mds.mutex.Unlock()
+	return mds.MetadataService.queryMetadata(path)
+}
+
+func (mds *countingMetadataService) count() int {
+	mds.mutex.Lock()
+	defer mds.mutex.Unlock()
+	return mds.queries
+}
+
+func TestWorkerRestart(t *testing.T) {
+	mds := &countingMetadataService{MetadataService: &fakeMetadataService{nil, nil, map[string]string{}, ""}}
+	p := &AWSProvider{
+		metadataService:     mds,
+		terminationInterval: 10 * time.Millisecond,
+	}
+	wkr := ptesting.NewFakeWorkerWithCapabilities("graceful-termination")
+	defer wkr.Close()
+	// the protocol is not started, as each worker would have its own
+	p.SetProtocol(wkr.RunnerProtocol)
+
+	for range 3 {
+		require.NoError(t, p.WorkerStarted(&run.State{}))
+		queries := mds.count()
+		require.Eventually(t, func() bool { return mds.count() > queries }, 5*time.Second, 10*time.Millisecond, "polling for termination")
+
+		require.NoError(t, p.WorkerFinished(&run.State{}))
+		// polling stops when the worker finishes, allowing for a query that
+		// was already in progress
+		time.Sleep(20 * time.Millisecond)
+		queries = mds.count()
+		time.Sleep(100 * time.Millisecond)
+		require.Equal(t, queries, mds.count(), "polling continued after the worker finished")
+	}
+}
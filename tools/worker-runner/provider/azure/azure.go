@@ -21,7 +21,8 @@ type AzureProvider struct {
 	metadataService            MetadataService
 	proto                      *workerproto.Protocol
 	workerIdentityProof        map[string]any
-	terminationTicker          *time.Ticker
+	terminationInterval        time.Duration
+	stopPolling                chan struct{}
 }
 
 type CustomData struct {
@@ -154,15 +155,22 @@ func (p *AzureProvider) WorkerStarted(state *run.State) error {
 	p.proto.AddCapability("graceful-termination")
 
 	// start polling for graceful shutdown
-	p.terminationTicker = time.NewTicker(15 * time.Second)
+	stop := make(chan struct{})
+	p.stopPolling = stop
+	ticker := time.NewTicker(p.terminationInterval)
 	go func() {
+		defer ticker.Stop()
 		for {
-			<-p.terminationTicker.C
-			log.Println("polling for termination-time")
-			// NOTE: the first call to this method may take up to 120s:
-			// https://docs.microsoft.com/en-us/azure/virtual-machines/linux/scheduled-events#enabling-and-disabling-scheduled-events
-			// that may lead to a "backlog" of checks, but that won't do any real harm.
-			p.checkTerminationTime()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				log.Println("polling for termination-time")
+				// NOTE: the first call to this method may take up to 120s:
+				// https://docs.microsoft.com/en-us/azure/virtual-machines/linux/scheduled-events#enabling-and-disabling-scheduled-events
+				// that may lead to a "backlog" of checks, but that won't do any real harm.
+				p.checkTerminationTime()
+			}
 		}
 	}()
 
@@ -170,7 +178,10 @@ func (p *AzureProvider) WorkerStarted(state *run.State) error {
 }
 
 func (p *AzureProvider) WorkerFinished(state *run.State) error {
-	p.terminationTicker.Stop()
+	if p.stopPolling != nil {
+		close(p.stopPolling)
+		p.stopPolling = nil
+	}
 	return nil
 }
 
@@ -255,5 +266,6 @@ func new(
 		workerManagerClientFactory: workerManagerClientFactory,
 		metadataService:            metadataService,
 		proto:                      nil,
+		terminationInterval:        15 * time.Second,
 	}, nil
 }
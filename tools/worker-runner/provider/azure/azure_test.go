@@ -4,7 +4,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
@@ -124,7 +126,6 @@ func TestCheckTerminationTime(t *testing.T) {
 			workerManagerClientFactory: nil,
 			metadataService:            mds,
 			proto:                      proto,
-			terminationTicker:          nil,
 		}
 
 		proto.AddCapability("graceful-termination")
@@ -175,3 +176,50 @@ func TestCheckTerminationTime(t *testing.T) {
 		require.True(t, gotTerm())
 	})
 }
+
+// countingMetadataService counts the queries made when polling for
+// termination
+type countingMetadataService struct {
+	MetadataService
+	mutex   sync.Mutex
+	queries int
+}
+
+func (mds *countingMetadataService) queryScheduledEvents() (*ScheduledEvents, error) {
+	mds.mutex.Lock()
+	mds.queries++
+	mds.mutex.Unlock()
+	return mds.MetadataService.queryScheduledEvents()
+}
+
+func (mds *countingMetadataService) count() int {
+	mds.mutex.Lock()
+	defer mds.mutex.Unlock()
+	return mds.queries
+}
+
+func TestWorkerRestart(t *testing.T) {
+	mds := &countingMetadataService{MetadataService: &fakeMetadataService{ScheduledEvents: &ScheduledEvents{}}}
+	p := &AzureProvider{
+		metadataService:     mds,
+		terminationInterval: 10 * time.Millisecond,
+	}
+	wkr := ptesting.NewFakeWorkerWithCapabilities("graceful-termination")
+	defer wkr.Close()
+	// the protocol is not started, as each worker would have its own
+	p.SetProtocol(wkr.RunnerProtocol)
+
+	for range 3 {
+		require.NoError(t, p.WorkerStarted(&run.State{}))
+		queries := mds.count()
+		require.Eventually(t, func() bool { return mds.count() > queries }, 5*time.Second, 10*time.Millisecond, "polling for termination")
+
+		require.NoError(t, p.WorkerFinished(&run.State{}))
+		// polling stops when the worker finishes, allowing for a query that
+		// was already in progress
+		time.Sleep(20 * time.Millisecond)
+		queries = mds.count()
+		time.Sleep(100 * time.Millisecond)
+		require.Equal(t, queries, mds.count(), "polling continued after the worker finished")
+	}
+}
@@ -0,0 +1,334 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tcurls "github.com/taskcluster/taskcluster-lib-urls"
+	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/provider"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
+)
+
+type commandProviderConfig struct {
+	IdentityCommand            []any `provider:"identityCommand"`
+	TerminationCommand         []any `provider:"terminationCommand,optional"`
+	TerminationIntervalSeconds int   `provider:"terminationIntervalSeconds,optional"`
+	CommandTimeoutSeconds      int   `provider:"commandTimeoutSeconds,optional"`
+}
+
+// Identity is the output of the identity command
+type Identity struct {
+	RootURL             string                `json:"rootURL"`
+	ProviderID          string                `json:"providerId"`
+	WorkerPoolID        string                `json:"workerPoolId"`
+	WorkerGroup         string                `json:"workerGroup"`
+	WorkerID            string                `json:"workerId"`
+	ProviderMetadata    map[string]any        `json:"providerMetadata"`
+	WorkerLocation      map[string]string     `json:"workerLocation"`
+	WorkerIdentityProof map[string]any        `json:"workerIdentityProof"`
+	Credentials         *tcclient.Credentials `json:"credentials"`
+}
+
+// Termination is the output of the termination command
+type Termination struct {
+	Terminate   bool `json:"terminate"`
+	FinishTasks bool `json:"finishTasks"`
+}
+
+type CommandProvider struct {
+	runnercfg           *cfg.RunnerConfig
+	proto               *workerproto.Protocol
+	identityCommand     []string
+	terminationCommand  []string
+	terminationInterval time.Duration
+	commandTimeout      time.Duration
+	workerIdentityProof map[string]any
+	terminationMsgSent  bool
+	stopPolling         chan struct{}
+}
+
+func (p *CommandProvider) ConfigureRun(state *run.State) error {
+	state.Lock()
+	defer state.Unlock()
+
+	var identity Identity
+	err := p.runCommand(p.identityCommand, nil, &identity)
+	if err != nil {
+		return fmt.Errorf("could not get worker identity: %v", err)
+	}
+
+	for name, value := range map[string]string{
+		"rootURL":      identity.RootURL,
+		"workerPoolId": identity.WorkerPoolID,
+		"workerGroup":  identity.WorkerGroup,
+		"workerId":     identity.WorkerID,
+	} {
+		if value == "" {
+			return fmt.Errorf("identity command output does not contain %s", name)
+		}
+	}
+
+	if identity.WorkerIdentityProof == nil && identity.Credentials == nil {
+		return errors.New("identity command output must contain workerIdentityProof or credentials")
+	}
+
+	state.RootURL = tcurls.NormalizeRootURL(identity.RootURL)
+	state.ProviderID = identity.ProviderID
+	if state.ProviderID == "" {
+		state.ProviderID = "command"
+	}
+	state.WorkerPoolID = identity.WorkerPoolID
+	state.WorkerGroup = identity.WorkerGroup
+	state.WorkerID = identity.WorkerID
+
+	state.WorkerLocation = map[string]string{
+		"cloud": "command",
+	}
+	maps.Copy(state.WorkerLocation, identity.WorkerLocation)
+
+	state.ProviderMetadata = map[string]any{}
+	maps.Copy(state.ProviderMetadata, identity.ProviderMetadata)
+
+	if identity.WorkerIdentityProof != nil {
+		p.workerIdentityProof = identity.WorkerIdentityProof
+	} else {
+		// without an identity proof, the worker does not register with
+		// worker-manager, and uses the given credentials instead
+		state.Credentials = *identity.Credentials
+	}
+
+	return nil
+}
+
+func (p *CommandProvider) GetWorkerIdentityProof() (map[string]any, error) {
+	return p.workerIdentityProof, nil
+}
+
+func (p *CommandProvider) UseCachedRun(run *run.State) error {
+	return nil
+}
+
+func (p *CommandProvider) SetProtocol(proto *workerproto.Protocol) {
+	p.proto = proto
+}
+
+// runCommand runs the given command, with the given additional environment
+// variables, and decodes its output, which must be JSON, into out.
+func (p *CommandProvider) runCommand(command []string, env []string, out any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("command %s timed out after %s", command[0], p.commandTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("command %s failed: %v; stderr: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		// no output is treated as an empty object
+		return nil
+	}
+	err = json.Unmarshal(stdout.Bytes(), out)
+	if err != nil {
+		return fmt.Errorf("could not parse output of command %s as JSON: %v", command[0], err)
+	}
+	return nil
+}
+
+func (p *CommandProvider) checkTermination(state *run.State) bool {
+	state.RLock()
+	env := []string{
+		"TASKCLUSTER_WORKER_POOL_ID=" + state.WorkerPoolID,
+		"TASKCLUSTER_WORKER_GROUP=" + state.WorkerGroup,
+		"TASKCLUSTER_WORKER_ID=" + state.WorkerID,
+	}
+	state.RUnlock()
+
+	var termination Termination
+	err := p.runCommand(p.terminationCommand, env, &termination)
+	if err != nil {
+		log.Printf("Error checking for termination: %v", err)
+		return false
+	}
+	if !termination.Terminate {
+		return false
+	}
+
+	log.Println("Termination command says termination is imminent")
+	if p.proto != nil && p.proto.Capable("graceful-termination") && !p.terminationMsgSent {
+		p.proto.Send(workerproto.Message{
+			Type: "graceful-termination",
+			Properties: map[string]any{
+				"finish-tasks": termination.FinishTasks,
+			},
+		})
+		p.terminationMsgSent = true
+	}
+	return true
+}
+
+func (p *CommandProvider) WorkerStarted(state *run.State) error {
+	if p.terminationCommand == nil {
+		return nil
+	}
+
+	// start polling for graceful shutdown
+	p.proto.AddCapability("graceful-termination")
+	stop := make(chan struct{})
+	p.stopPolling = stop
+	ticker := time.NewTicker(p.terminationInterval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.checkTermination(state)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (p *CommandProvider) WorkerFinished(state *run.State) error {
+	if p.stopPolling != nil {
+		close(p.stopPolling)
+		p.stopPolling = nil
+	}
+	return nil
+}
+
+// toCommand converts a command from the provider configuration to a list of
+// strings.
+func toCommand(name string, value []any) ([]string, error) {
+	if len(value) == 0 {
+		return nil, fmt.Errorf("provider.%s must not be empty", name)
+	}
+	command := make([]string, len(value))
+	for i, v := range value {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("provider.%s must be a list of strings", name)
+		}
+		command[i] = s
+	}
+	return command, nil
+}
+
+func New(runnercfg *cfg.RunnerConfig) (provider.Provider, error) {
+	pc := commandProviderConfig{
+		TerminationIntervalSeconds: 30,
+		CommandTimeoutSeconds:      60,
+	}
+	err := runnercfg.Provider.Unpack(&pc)
+	if err != nil {
+		return nil, err
+	}
+	if pc.TerminationIntervalSeconds <= 0 || pc.CommandTimeoutSeconds <= 0 {
+		return nil, errors.New("provider.terminationIntervalSeconds and provider.commandTimeoutSeconds must be positive")
+	}
+
+	p := &CommandProvider{
+		runnercfg:           runnercfg,
+		proto:               nil,
+		terminationInterval: time.Duration(pc.TerminationIntervalSeconds) * time.Second,
+		commandTimeout:      time.Duration(pc.CommandTimeoutSeconds) * time.Second,
+	}
+	p.identityCommand, err = toCommand("identityCommand", pc.IdentityCommand)
+	if err != nil {
+		return nil, err
+	}
+	if pc.TerminationCommand != nil {
+		p.terminationCommand, err = toCommand("terminationCommand", pc.TerminationCommand)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func Usage() string {
+	return `
+The providerType "command" is intended for workers whose identity is managed by
+an external system, such as a bare-metal inventory.  It runs an external
+program to determine the worker's identity.
+
+` + "```yaml" + `
+provider:
+    providerType: command
+    # command (and arguments) that prints the worker identity as JSON
+    identityCommand: [/usr/local/bin/inventory, identity]
+    # (optional) command (and arguments) to check for termination
+    terminationCommand: [/usr/local/bin/inventory, check-termination]
+    # (optional) how often to run terminationCommand (default 30)
+    terminationIntervalSeconds: 30
+    # (optional) how long commands may run before they are killed (default 60)
+    commandTimeoutSeconds: 60
+` + "```" + `
+
+The identity command must exit successfully, and print a JSON object of the
+form
+
+` + "```json" + `
+{
+    "rootURL": "https://tc.example.com",
+    "providerId": "...",            // optional, default "command"
+    "workerPoolId": "...",
+    "workerGroup": "...",
+    "workerId": "...",
+    "providerMetadata": {...},      // optional
+    "workerLocation": {...},        // optional; values must be strings
+    "workerIdentityProof": {...},   // passed to worker-manager's registerWorker
+    "credentials": {                // used instead, if there is no workerIdentityProof
+        "clientId": "...",
+        "accessToken": "...",
+        "certificate": "..."        // optional
+    }
+}
+` + "```" + `
+
+One of "workerIdentityProof" and "credentials" must be given; if both are,
+"credentials" is ignored.  With a "workerIdentityProof", the worker registers with worker-manager, for
+example using a "static" worker-manager provider with
+"{\"staticSecret\": \"...\"}".  With "credentials", registration is skipped,
+as for the "standalone" provider.
+
+If "terminationCommand" is given, it is run every "terminationIntervalSeconds"
+while the worker is running, with the environment variables
+"TASKCLUSTER_WORKER_POOL_ID", "TASKCLUSTER_WORKER_GROUP" and
+"TASKCLUSTER_WORKER_ID" set.  If it prints a JSON object of the form
+"{\"terminate\": true, \"finishTasks\": ..}", a graceful-termination message
+is sent to the worker, with "finish-tasks" set from "finishTasks".  Empty
+output means the worker should keep running.
+
+The [$TASKCLUSTER_WORKER_LOCATION](https://docs.taskcluster.net/docs/manual/design/env-vars#taskcluster_worker_location)
+defined by this provider has the following fields:
+
+* cloud: command
+
+as well as any worker location values from the identity command.
+`
+}
@@ -0,0 +1,195 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
+	ptesting "github.com/taskcluster/taskcluster/v84/tools/workerproto/testing"
+)
+
+// TestMain allows the test binary to act as the external program run by the
+// provider: when FAKE_INVENTORY_OUTPUT is set, it prints that and exits.
+func TestMain(m *testing.M) {
+	if output, ok := os.LookupEnv("FAKE_INVENTORY_OUTPUT"); ok {
+		if os.Getenv("FAKE_INVENTORY_REQUIRE_WORKER_ID") != "" && os.Getenv("TASKCLUSTER_WORKER_ID") != os.Getenv("FAKE_INVENTORY_REQUIRE_WORKER_ID") {
+			fmt.Fprintln(os.Stderr, "wrong worker ID")
+			os.Exit(1)
+		}
+		fmt.Print(output)
+		if os.Getenv("FAKE_INVENTORY_FAIL") != "" {
+			fmt.Fprintln(os.Stderr, "inventory unavailable")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func makeProvider(t *testing.T, data map[string]any) *CommandProvider {
+	t.Helper()
+	data["identityCommand"] = []any{os.Args[0], "identity"}
+	runnercfg := &cfg.RunnerConfig{
+		Provider: cfg.ProviderConfig{
+			ProviderType: "command",
+			Data:         data,
+		},
+	}
+	p, err := New(runnercfg)
+	require.NoError(t, err, "creating provider")
+	return p.(*CommandProvider)
+}
+
+func TestConfigureRun(t *testing.T) {
+	t.Setenv("FAKE_INVENTORY_OUTPUT", `{
+		"rootURL": "https://tc.example.com/",
+		"providerId": "inventory",
+		"workerPoolId": "w/p",
+		"workerGroup": "rack-7",
+		"workerId": "host-123",
+		"providerMetadata": {"public-ipv4": "1.2.3.4", "cores": 64},
+		"workerLocation": {"datacenter": "mdc1"},
+		"workerIdentityProof": {"staticSecret": "quiet"}
+	}`)
+	p := makeProvider(t, map[string]any{})
+
+	state := run.State{}
+	require.NoError(t, p.ConfigureRun(&state))
+
+	require.Equal(t, "https://tc.example.com", state.RootURL, "rootURL is correct")
+	require.Equal(t, "inventory", state.ProviderID, "providerID is correct")
+	require.Equal(t, "w/p", state.WorkerPoolID, "workerPoolID is correct")
+	require.Equal(t, "rack-7", state.WorkerGroup, "workerGroup is correct")
+	require.Equal(t, "host-123", state.WorkerID, "workerID is correct")
+	require.Equal(t, map[string]any{"public-ipv4": "1.2.3.4", "cores": float64(64)}, state.ProviderMetadata, "providerMetadata is correct")
+	require.Equal(t, map[string]string{"cloud": "command", "datacenter": "mdc1"}, state.WorkerLocation)
+
+	proof, err := p.GetWorkerIdentityProof()
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"staticSecret": "quiet"}, proof)
+}
+
+func TestConfigureRunCredentials(t *testing.T) {
+	t.Setenv("FAKE_INVENTORY_OUTPUT", `{
+		"rootURL": "https://tc.example.com",
+		"workerPoolId": "w/p",
+		"workerGroup": "rack-7",
+		"workerId": "host-123",
+		"credentials": {"clientId": "cli", "accessToken": "tok"}
+	}`)
+	p := makeProvider(t, map[string]any{})
+
+	state := run.State{}
+	require.NoError(t, p.ConfigureRun(&state))
+
+	require.Equal(t, "command", state.ProviderID, "providerID defaults to command")
+	require.Equal(t, "cli", state.Credentials.ClientID)
+	require.Equal(t, "tok", state.Credentials.AccessToken)
+
+	proof, err := p.GetWorkerIdentityProof()
+	require.NoError(t, err)
+	require.Nil(t, proof, "registration is skipped")
+}
+
+func TestConfigureRunErrors(t *testing.T) {
+	for name, test := range map[string]struct {
+		output string
+		fail   bool
+	}{
+		"command fails":           {`{}`, true},
+		"invalid JSON":            {`not json`, false},
+		"missing workerId":        {`{"rootURL": "https://tc.example.com", "workerPoolId": "w/p", "workerGroup": "wg", "credentials": {"clientId": "c"}}`, false},
+		"no proof or credentials": {`{"rootURL": "https://tc.example.com", "workerPoolId": "w/p", "workerGroup": "wg", "workerId": "wi"}`, false},
+		"non-string location":     {`{"rootURL": "https://tc.example.com", "workerPoolId": "w/p", "workerGroup": "wg", "workerId": "wi", "workerLocation": {"rack": 7}}`, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("FAKE_INVENTORY_OUTPUT", test.output)
+			if test.fail {
+				t.Setenv("FAKE_INVENTORY_FAIL", "1")
+			}
+			p := makeProvider(t, map[string]any{})
+			require.Error(t, p.ConfigureRun(&run.State{}))
+		})
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for name, data := range map[string]map[string]any{
+		"missing identityCommand": {},
+		"empty identityCommand":   {"identityCommand": []any{}},
+		"non-string argument":     {"identityCommand": []any{"inventory", 7}},
+		"bad terminationCommand":  {"identityCommand": []any{"inventory"}, "terminationCommand": []any{}},
+		"zero interval":           {"identityCommand": []any{"inventory"}, "terminationIntervalSeconds": 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(&cfg.RunnerConfig{
+				Provider: cfg.ProviderConfig{
+					ProviderType: "command",
+					Data:         data,
+				},
+			})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCheckTermination(t *testing.T) {
+	state := &run.State{
+		WorkerPoolID: "w/p",
+		WorkerGroup:  "rack-7",
+		WorkerID:     "host-123",
+	}
+	// the termination command is given the worker's identity
+	t.Setenv("FAKE_INVENTORY_REQUIRE_WORKER_ID", "host-123")
+
+	test := func(t *testing.T, wkr *ptesting.FakeWorker) {
+		t.Helper()
+		p := makeProvider(t, map[string]any{
+			"terminationCommand": []any{os.Args[0], "check-termination"},
+		})
+		p.SetProtocol(wkr.RunnerProtocol)
+		require.NoError(t, p.WorkerStarted(state))
+		defer func() {
+			require.NoError(t, p.WorkerFinished(state))
+		}()
+		wkr.RunnerProtocol.Start(false)
+		wkr.RunnerProtocol.WaitUntilInitialized()
+
+		// not time yet..
+		t.Setenv("FAKE_INVENTORY_OUTPUT", "")
+		require.False(t, p.checkTermination(state))
+		t.Setenv("FAKE_INVENTORY_OUTPUT", `{"terminate": false}`)
+		require.False(t, p.checkTermination(state))
+
+		t.Setenv("FAKE_INVENTORY_OUTPUT", `{"terminate": true, "finishTasks": true}`)
+		require.True(t, p.checkTermination(state))
+	}
+
+	t.Run("without capability", func(t *testing.T) {
+		wkr := ptesting.NewFakeWorkerWithCapabilities()
+		defer wkr.Close()
+
+		gotTerm := wkr.MessageReceivedFunc("graceful-termination", nil)
+
+		test(t, wkr)
+
+		require.False(t, gotTerm())
+	})
+
+	t.Run("with capability", func(t *testing.T) {
+		wkr := ptesting.NewFakeWorkerWithCapabilities("graceful-termination")
+		defer wkr.Close()
+
+		gotTerm := wkr.MessageReceivedFunc("graceful-termination", func(msg workerproto.Message) bool {
+			return msg.Properties["finish-tasks"] == true
+		})
+
+		test(t, wkr)
+
+		require.True(t, gotTerm())
+	})
+}
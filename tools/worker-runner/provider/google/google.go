@@ -24,6 +24,9 @@ type GoogleProvider struct {
 	proto                      *workerproto.Protocol
 	workerIdentityProof        map[string]any
 	terminationMsgSent         bool
+	// true once polling for termination has started; polling continues even
+	// if the worker is restarted
+	polling bool
 }
 
 func (p *GoogleProvider) ConfigureRun(state *run.State) error {
@@ -132,6 +135,10 @@ func (p *GoogleProvider) checkTerminationTime() bool {
 func (p *GoogleProvider) WorkerStarted(state *run.State) error {
 	p.proto.AddCapability("graceful-termination")
 
+	if p.polling {
+		return nil
+	}
+	p.polling = true
 	go func() {
 		for {
 			log.Println("polling for termination-time")
@@ -0,0 +1,103 @@
+package kubernetes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// KubeAPI is the subset of the Kubernetes API used by this provider
+type KubeAPI interface {
+	// get the keys of the taints on the given node
+	getNodeTaints(node string) ([]string, error)
+}
+
+// realKubeAPI accesses the Kubernetes API from within the cluster, using the
+// pod's service account
+type realKubeAPI struct {
+	baseURL           string
+	serviceAccountDir string
+	client            *http.Client
+}
+
+// newRealKubeAPI creates a KubeAPI using the in-cluster configuration: the
+// API server address from $KUBERNETES_SERVICE_HOST and
+// $KUBERNETES_SERVICE_PORT, and the CA certificate and token in the given
+// service account directory.
+func newRealKubeAPI(serviceAccountDir string) (*realKubeAPI, error) {
+	host := os.Getenv("KUBERNETES_SERVICE_HOST")
+	port := os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster ($KUBERNETES_SERVICE_HOST or $KUBERNETES_SERVICE_PORT not set)")
+	}
+
+	caCert, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read Kubernetes CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("could not parse Kubernetes CA certificate")
+	}
+
+	return &realKubeAPI{
+		baseURL:           "https://" + net.JoinHostPort(host, port),
+		serviceAccountDir: serviceAccountDir,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+func (api *realKubeAPI) getNodeTaints(node string) ([]string, error) {
+	// the token is re-read for each request, since kubelet rotates it
+	token, err := os.ReadFile(filepath.Join(api.serviceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account token: %v", err)
+	}
+
+	req, err := http.NewRequest("GET", api.baseURL+"/api/v1/nodes/"+url.PathEscape(node), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get node %s: %s", node, resp.Status)
+	}
+
+	var nodeInfo struct {
+		Spec struct {
+			Taints []struct {
+				Key string `json:"key"`
+			} `json:"taints"`
+		} `json:"spec"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&nodeInfo)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode node %s: %v", node, err)
+	}
+
+	taints := make([]string, len(nodeInfo.Spec.Taints))
+	for i, taint := range nodeInfo.Spec.Taints {
+		taints[i] = taint.Key
+	}
+	return taints, nil
+}
@@ -0,0 +1,372 @@
+package kubernetes
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tcurls "github.com/taskcluster/taskcluster-lib-urls"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/provider"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
+)
+
+type kubernetesProviderConfig struct {
+	RootURL                        string
+	ProviderID                     string
+	WorkerPoolID                   string
+	WorkerGroup                    string `provider:"workerGroup,optional"`
+	PodInfoDir                     string `provider:"podInfoDir,optional"`
+	TokenPath                      string `provider:"tokenPath,optional"`
+	ServiceAccountDir              string `provider:"serviceAccountDir,optional"`
+	PreemptionTaints               []any  `provider:"preemptionTaints,optional"`
+	TerminationPollIntervalSeconds int    `provider:"terminationPollIntervalSeconds,optional"`
+	FinishTasksOnSIGTERM           bool   `provider:"finishTasksOnSIGTERM,optional"`
+}
+
+type KubernetesProvider struct {
+	runnercfg           *cfg.RunnerConfig
+	kubeAPI             KubeAPI
+	proto               *workerproto.Protocol
+	workerIdentityProof map[string]any
+
+	serviceAccountDir    string
+	preemptionTaints     []string
+	pollInterval         time.Duration
+	finishTasksOnSIGTERM bool
+	nodeName             string
+
+	// sigterm receives SIGTERM while the worker is running
+	sigterm chan os.Signal
+	stop    chan struct{}
+
+	terminationMutex   sync.Mutex
+	terminationMsgSent bool
+}
+
+// readPodInfo reads a value from the given file in the downward API volume,
+// falling back to the given environment variable.
+func readPodInfo(dir, file, envVar string) string {
+	if dir != "" {
+		value, err := os.ReadFile(filepath.Join(dir, file))
+		if err == nil && strings.TrimSpace(string(value)) != "" {
+			return strings.TrimSpace(string(value))
+		}
+	}
+	return os.Getenv(envVar)
+}
+
+func (p *KubernetesProvider) ConfigureRun(state *run.State) error {
+	state.Lock()
+	defer state.Unlock()
+
+	pc, err := p.config()
+	if err != nil {
+		return err
+	}
+
+	podName := readPodInfo(pc.PodInfoDir, "name", "POD_NAME")
+	namespace := readPodInfo(pc.PodInfoDir, "namespace", "POD_NAMESPACE")
+	if namespace == "" {
+		// every pod with a service account has its namespace available
+		namespace = readPodInfo(pc.ServiceAccountDir, "namespace", "")
+	}
+	podUID := readPodInfo(pc.PodInfoDir, "uid", "POD_UID")
+	p.nodeName = readPodInfo(pc.PodInfoDir, "nodeName", "NODE_NAME")
+
+	if podName == "" {
+		return fmt.Errorf("could not determine pod name from %s or $POD_NAME", filepath.Join(pc.PodInfoDir, "name"))
+	}
+	if namespace == "" {
+		return fmt.Errorf("could not determine pod namespace from %s or $POD_NAMESPACE", filepath.Join(pc.PodInfoDir, "namespace"))
+	}
+
+	token, err := os.ReadFile(pc.TokenPath)
+	if err != nil {
+		return fmt.Errorf("could not read service account token: %v", err)
+	}
+
+	state.RootURL = tcurls.NormalizeRootURL(pc.RootURL)
+	state.ProviderID = pc.ProviderID
+	state.WorkerPoolID = pc.WorkerPoolID
+	state.WorkerGroup = pc.WorkerGroup
+	if state.WorkerGroup == "" {
+		state.WorkerGroup = namespace
+	}
+	state.WorkerID = podName
+
+	state.WorkerLocation = map[string]string{
+		"cloud":     "kubernetes",
+		"namespace": namespace,
+	}
+
+	providerMetadata := map[string]any{
+		"namespace": namespace,
+		"pod-name":  podName,
+	}
+	if podUID != "" {
+		providerMetadata["pod-uid"] = podUID
+	}
+	if p.nodeName != "" {
+		providerMetadata["node-name"] = p.nodeName
+	}
+	state.ProviderMetadata = providerMetadata
+
+	p.workerIdentityProof = map[string]any{
+		"token": strings.TrimSpace(string(token)),
+	}
+
+	return nil
+}
+
+func (p *KubernetesProvider) GetWorkerIdentityProof() (map[string]any, error) {
+	return p.workerIdentityProof, nil
+}
+
+func (p *KubernetesProvider) UseCachedRun(state *run.State) error {
+	state.Lock()
+	defer state.Unlock()
+
+	if nodeName, ok := state.ProviderMetadata["node-name"].(string); ok {
+		p.nodeName = nodeName
+	}
+	return nil
+}
+
+func (p *KubernetesProvider) SetProtocol(proto *workerproto.Protocol) {
+	p.proto = proto
+}
+
+// sendTermination sends a graceful-termination message to the worker, at
+// most once.
+func (p *KubernetesProvider) sendTermination(finishTasks bool) {
+	p.terminationMutex.Lock()
+	defer p.terminationMutex.Unlock()
+
+	if p.proto != nil && p.proto.Capable("graceful-termination") && !p.terminationMsgSent {
+		p.proto.Send(workerproto.Message{
+			Type: "graceful-termination",
+			Properties: map[string]any{
+				"finish-tasks": finishTasks,
+			},
+		})
+		p.terminationMsgSent = true
+	}
+}
+
+// checkPreemption checks whether the node has one of the preemption taints.
+func (p *KubernetesProvider) checkPreemption() bool {
+	taints, err := p.kubeAPI.getNodeTaints(p.nodeName)
+	if err != nil {
+		log.Printf("Error checking node %s for preemption: %v", p.nodeName, err)
+		return false
+	}
+	for _, taint := range taints {
+		if slices.Contains(p.preemptionTaints, taint) {
+			log.Printf("Node %s has taint %s; termination is imminent", p.nodeName, taint)
+			// preemption generally doesn't leave time to finish tasks
+			p.sendTermination(false)
+			return true
+		}
+	}
+	return false
+}
+
+// Relay SIGTERM to the given channel, or stop doing so.  These can be replaced
+// in testing.
+var notifySIGTERM = func(c chan os.Signal) { signal.Notify(c, syscall.SIGTERM) }
+var stopSIGTERM = func(c chan os.Signal) { signal.Stop(c) }
+
+func (p *KubernetesProvider) WorkerStarted(state *run.State) error {
+	p.proto.AddCapability("graceful-termination")
+	p.stop = make(chan struct{})
+	stop := p.stop
+
+	// a restarted worker has not been told about any earlier termination
+	p.terminationMutex.Lock()
+	p.terminationMsgSent = false
+	p.terminationMutex.Unlock()
+
+	// kubelet sends SIGTERM to the pod when it is deleted.  WorkerFinished
+	// stops relaying it, so relay it again each time the worker starts.
+	if p.sigterm == nil {
+		p.sigterm = make(chan os.Signal, 1)
+	}
+	sigterm := p.sigterm
+	notifySIGTERM(sigterm)
+	go func() {
+		select {
+		case <-stop:
+		case <-sigterm:
+			log.Println("Received SIGTERM; termination is imminent")
+			p.sendTermination(p.finishTasksOnSIGTERM)
+		}
+	}()
+
+	if p.nodeName == "" || len(p.preemptionTaints) == 0 {
+		return nil
+	}
+	if p.kubeAPI == nil {
+		kubeAPI, err := newRealKubeAPI(p.serviceAccountDir)
+		if err != nil {
+			log.Printf("Not watching node %s for preemption: %v", p.nodeName, err)
+			return nil
+		}
+		p.kubeAPI = kubeAPI
+	}
+
+	// start polling for preemption taints
+	ticker := time.NewTicker(p.pollInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if p.checkPreemption() {
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (p *KubernetesProvider) WorkerFinished(state *run.State) error {
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	if p.sigterm != nil {
+		stopSIGTERM(p.sigterm)
+	}
+	return nil
+}
+
+// config unpacks the provider configuration, applying defaults
+func (p *KubernetesProvider) config() (kubernetesProviderConfig, error) {
+	pc := kubernetesProviderConfig{
+		PodInfoDir:        "/etc/podinfo",
+		TokenPath:         "/var/run/secrets/taskcluster/token",
+		ServiceAccountDir: "/var/run/secrets/kubernetes.io/serviceaccount",
+		PreemptionTaints: []any{
+			"cloud.google.com/impending-node-termination",
+			"ToBeDeletedByClusterAutoscaler",
+		},
+		TerminationPollIntervalSeconds: 10,
+	}
+	err := p.runnercfg.Provider.Unpack(&pc)
+	return pc, err
+}
+
+func New(runnercfg *cfg.RunnerConfig) (provider.Provider, error) {
+	return new(runnercfg, nil)
+}
+
+func Usage() string {
+	return `
+The providerType "kubernetes" is intended for workers running in Kubernetes
+pods.  It requires
+
+` + "```yaml" + `
+provider:
+    providerType: kubernetes
+    rootURL: ..    # note the Golang spelling with capitalized "URL"
+    providerID: .. # ..and similarly capitalized ID
+    workerPoolID: ...
+    # (optional) defaults to the pod's namespace
+    workerGroup: ...
+    # (optional) downward API volume containing files "name", "namespace"
+    # and (optionally) "uid" for the pod (default /etc/podinfo)
+    podInfoDir: /etc/podinfo
+    # (optional) projected service account token used as the worker identity
+    # proof (default /var/run/secrets/taskcluster/token)
+    tokenPath: /var/run/secrets/taskcluster/token
+    # (optional) the pod's service account directory
+    serviceAccountDir: /var/run/secrets/kubernetes.io/serviceaccount
+    # (optional) node taints that indicate the node is about to be preempted
+    preemptionTaints: [cloud.google.com/impending-node-termination, ToBeDeletedByClusterAutoscaler]
+    # (optional) how often to check the node's taints (default 10)
+    terminationPollIntervalSeconds: 10
+    # (optional) whether the worker should finish its tasks on SIGTERM
+    finishTasksOnSIGTERM: false
+` + "```" + `
+
+The pod name, namespace and UID are read from the downward API volume, or
+from the environment variables "POD_NAME", "POD_NAMESPACE" and "POD_UID".
+The namespace defaults to that of the pod's service account.  The node name
+is read from the environment variable "NODE_NAME", which should be set from
+the "spec.nodeName" field.  The worker ID is the pod name.
+
+The worker identity proof passed to worker-manager is
+"{\"token\": <token>}", where the token is the projected service account
+token at "tokenPath".  This should be a projected volume with the
+Taskcluster root URL as its audience.
+
+Note that none of the providers built into worker-manager accept this proof,
+so the worker pool's "providerId" must name a deployment-specific
+worker-manager provider that does.  Such a provider should validate the
+token with a Kubernetes TokenReview (or the cluster's JWKS), check that its
+audience is the root URL, and check that the pod name in its
+"kubernetes.io" claims is the worker ID.
+
+The provider metadata contains "namespace", "pod-name", "pod-uid" and
+"node-name", where available.
+
+When worker-runner receives SIGTERM, as it does when the pod is deleted, it
+sends a graceful-termination message to the worker, with "finish-tasks" set
+from "finishTasksOnSIGTERM".  The pod's "terminationGracePeriodSeconds"
+should allow for this.  If the node name is known, worker-runner also polls
+the node, and sends a graceful-termination message (without finishing tasks)
+if the node has any of the "preemptionTaints".  This requires the pod's
+service account to have permission to "get" its node.
+
+The [$TASKCLUSTER_WORKER_LOCATION](https://docs.taskcluster.net/docs/manual/design/env-vars#taskcluster_worker_location)
+defined by this provider has the following fields:
+
+* cloud: kubernetes
+* namespace
+`
+}
+
+// New takes its dependencies as optional arguments, allowing injection of fake dependencies for testing.
+func new(
+	runnercfg *cfg.RunnerConfig,
+	kubeAPI KubeAPI) (*KubernetesProvider, error) {
+
+	p := &KubernetesProvider{
+		runnercfg: runnercfg,
+		kubeAPI:   kubeAPI,
+		proto:     nil,
+	}
+
+	pc, err := p.config()
+	if err != nil {
+		return nil, err
+	}
+	if pc.TerminationPollIntervalSeconds <= 0 {
+		return nil, fmt.Errorf("provider.terminationPollIntervalSeconds must be positive")
+	}
+	for _, taint := range pc.PreemptionTaints {
+		s, ok := taint.(string)
+		if !ok {
+			return nil, fmt.Errorf("provider.preemptionTaints must be a list of strings")
+		}
+		p.preemptionTaints = append(p.preemptionTaints, s)
+	}
+	p.serviceAccountDir = pc.ServiceAccountDir
+	p.pollInterval = time.Duration(pc.TerminationPollIntervalSeconds) * time.Second
+	p.finishTasksOnSIGTERM = pc.FinishTasksOnSIGTERM
+
+	return p, nil
+}
@@ -0,0 +1,322 @@
+package kubernetes
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
+	ptesting "github.com/taskcluster/taskcluster/v84/tools/workerproto/testing"
+)
+
+// fakeKubeAPI returns the taints set for each node
+type fakeKubeAPI struct {
+	mutex  sync.Mutex
+	taints map[string][]string
+}
+
+func (api *fakeKubeAPI) getNodeTaints(node string) ([]string, error) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	taints, ok := api.taints[node]
+	if !ok {
+		return nil, fmt.Errorf("no such node %s", node)
+	}
+	return taints, nil
+}
+
+// makeFiles creates the files provided to a pod by Kubernetes in a temporary
+// directory, returning the provider configuration to use them.
+func makeFiles(t *testing.T, podInfo map[string]string) map[string]any {
+	t.Helper()
+	dir := t.TempDir()
+	podInfoDir := filepath.Join(dir, "podinfo")
+	serviceAccountDir := filepath.Join(dir, "serviceaccount")
+	require.NoError(t, os.MkdirAll(podInfoDir, 0755))
+	require.NoError(t, os.MkdirAll(serviceAccountDir, 0755))
+	for name, value := range podInfo {
+		require.NoError(t, os.WriteFile(filepath.Join(podInfoDir, name), []byte(value), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(serviceAccountDir, "namespace"), []byte("sa-namespace"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(serviceAccountDir, "token"), []byte("sa-token\n"), 0644))
+	tokenPath := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("i-am-a-jwt\n"), 0644))
+
+	return map[string]any{
+		"rootURL":           "https://tc.example.com",
+		"providerID":        "k8s",
+		"workerPoolID":      "w/p",
+		"podInfoDir":        podInfoDir,
+		"tokenPath":         tokenPath,
+		"serviceAccountDir": serviceAccountDir,
+	}
+}
+
+func makeRunnerConfig(data map[string]any) *cfg.RunnerConfig {
+	return &cfg.RunnerConfig{
+		Provider: cfg.ProviderConfig{
+			ProviderType: "kubernetes",
+			Data:         data,
+		},
+		WorkerImplementation: cfg.WorkerImplementationConfig{
+			Implementation: "whatever-worker",
+		},
+	}
+}
+
+func TestConfigureRun(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+	data := makeFiles(t, map[string]string{
+		"name":      "worker-abc12",
+		"namespace": "ci",
+		"uid":       "1234-5678",
+	})
+
+	p, err := new(makeRunnerConfig(data), nil)
+	require.NoError(t, err, "creating provider")
+
+	state := run.State{}
+	require.NoError(t, p.ConfigureRun(&state))
+
+	require.Equal(t, "https://tc.example.com", state.RootURL, "rootURL is correct")
+	require.Equal(t, "k8s", state.ProviderID, "providerID is correct")
+	require.Equal(t, "w/p", state.WorkerPoolID, "workerPoolID is correct")
+	require.Equal(t, "ci", state.WorkerGroup, "workerGroup is correct")
+	require.Equal(t, "worker-abc12", state.WorkerID, "workerID is correct")
+
+	require.Equal(t, map[string]any{
+		"namespace": "ci",
+		"pod-name":  "worker-abc12",
+		"pod-uid":   "1234-5678",
+		"node-name": "node-1",
+	}, state.ProviderMetadata, "providerMetadata is correct")
+
+	require.Equal(t, "kubernetes", state.WorkerLocation["cloud"])
+	require.Equal(t, "ci", state.WorkerLocation["namespace"])
+
+	proof, err := p.GetWorkerIdentityProof()
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"token": "i-am-a-jwt",
+	}, proof)
+}
+
+func TestConfigureRunFallbacks(t *testing.T) {
+	t.Setenv("POD_NAME", "worker-from-env")
+	t.Setenv("NODE_NAME", "")
+	data := makeFiles(t, map[string]string{})
+	data["workerGroup"] = "my-cluster"
+
+	p, err := new(makeRunnerConfig(data), nil)
+	require.NoError(t, err, "creating provider")
+
+	state := run.State{}
+	require.NoError(t, p.ConfigureRun(&state))
+
+	require.Equal(t, "my-cluster", state.WorkerGroup, "workerGroup is from config")
+	require.Equal(t, "worker-from-env", state.WorkerID, "workerID is from environment")
+	require.Equal(t, map[string]any{
+		"namespace": "sa-namespace",
+		"pod-name":  "worker-from-env",
+	}, state.ProviderMetadata, "providerMetadata is correct")
+}
+
+func TestConfigureRunMissingPodName(t *testing.T) {
+	t.Setenv("POD_NAME", "")
+	data := makeFiles(t, map[string]string{"namespace": "ci"})
+
+	p, err := new(makeRunnerConfig(data), nil)
+	require.NoError(t, err, "creating provider")
+
+	require.Error(t, p.ConfigureRun(&run.State{}))
+}
+
+func TestConfigureRunMissingToken(t *testing.T) {
+	data := makeFiles(t, map[string]string{"name": "worker-abc12", "namespace": "ci"})
+	data["tokenPath"] = filepath.Join(t.TempDir(), "nonexistent")
+
+	p, err := new(makeRunnerConfig(data), nil)
+	require.NoError(t, err, "creating provider")
+
+	require.Error(t, p.ConfigureRun(&run.State{}))
+}
+
+func TestUseCachedRun(t *testing.T) {
+	data := makeFiles(t, map[string]string{})
+	p, err := new(makeRunnerConfig(data), nil)
+	require.NoError(t, err, "creating provider")
+
+	state := run.State{ProviderMetadata: map[string]any{"node-name": "node-1"}}
+	require.NoError(t, p.UseCachedRun(&state))
+	require.Equal(t, "node-1", p.nodeName)
+}
+
+// fakeSignals replaces the relaying of SIGTERM for the duration of the test,
+// returning a function which reports whether SIGTERM is relayed to a channel.
+func fakeSignals(t *testing.T) func(c chan os.Signal) bool {
+	t.Helper()
+	var mutex sync.Mutex
+	relayed := map[chan os.Signal]bool{}
+	oldNotify, oldStop := notifySIGTERM, stopSIGTERM
+	t.Cleanup(func() { notifySIGTERM, stopSIGTERM = oldNotify, oldStop })
+	notifySIGTERM = func(c chan os.Signal) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		relayed[c] = true
+	}
+	stopSIGTERM = func(c chan os.Signal) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		relayed[c] = false
+	}
+	return func(c chan os.Signal) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return relayed[c]
+	}
+}
+
+func TestTermination(t *testing.T) {
+	setup := func(t *testing.T, capabilities ...string) (*KubernetesProvider, *fakeKubeAPI, *ptesting.FakeWorker) {
+		t.Helper()
+		t.Setenv("NODE_NAME", "node-1")
+		data := makeFiles(t, map[string]string{"name": "worker-abc12", "namespace": "ci"})
+		data["finishTasksOnSIGTERM"] = true
+		api := &fakeKubeAPI{taints: map[string][]string{"node-1": {"node.kubernetes.io/not-ready"}}}
+		p, err := new(makeRunnerConfig(data), api)
+		require.NoError(t, err, "creating provider")
+		require.NoError(t, p.ConfigureRun(&run.State{}))
+
+		wkr := ptesting.NewFakeWorkerWithCapabilities(capabilities...)
+		t.Cleanup(wkr.Close)
+		p.SetProtocol(wkr.RunnerProtocol)
+		// simulate signals with a channel that is not registered for signals
+		fakeSignals(t)
+		require.NoError(t, p.WorkerStarted(&run.State{}))
+		t.Cleanup(func() { require.NoError(t, p.WorkerFinished(&run.State{})) })
+		wkr.RunnerProtocol.Start(false)
+		wkr.RunnerProtocol.WaitUntilInitialized()
+		return p, api, wkr
+	}
+
+	finishTasks := func(expected bool) func(msg workerproto.Message) bool {
+		return func(msg workerproto.Message) bool {
+			return msg.Properties["finish-tasks"] == expected
+		}
+	}
+
+	t.Run("preemption taint", func(t *testing.T) {
+		p, api, wkr := setup(t, "graceful-termination")
+		gotTerm := wkr.MessageReceivedFunc("graceful-termination", finishTasks(false))
+
+		// not time yet..
+		require.False(t, p.checkPreemption())
+		require.False(t, gotTerm())
+
+		api.mutex.Lock()
+		api.taints["node-1"] = append(api.taints["node-1"], "cloud.google.com/impending-node-termination")
+		api.mutex.Unlock()
+		require.True(t, p.checkPreemption())
+		require.True(t, gotTerm())
+	})
+
+	t.Run("SIGTERM", func(t *testing.T) {
+		p, _, wkr := setup(t, "graceful-termination")
+		gotTerm := wkr.MessageReceivedFunc("graceful-termination", finishTasks(true))
+
+		p.sigterm <- syscall.SIGTERM
+		require.Eventually(t, gotTerm, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("without capability", func(t *testing.T) {
+		p, api, wkr := setup(t)
+		gotTerm := wkr.MessageReceivedFunc("graceful-termination", nil)
+
+		api.mutex.Lock()
+		api.taints["node-1"] = []string{"ToBeDeletedByClusterAutoscaler"}
+		api.mutex.Unlock()
+		require.True(t, p.checkPreemption())
+		require.False(t, gotTerm())
+	})
+}
+
+func TestRealKubeAPI(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sa-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/nodes/node-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"kind": "Node", "spec": {"taints": [{"key": "ToBeDeletedByClusterAutoscaler", "effect": "NoSchedule"}]}}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	t.Setenv("KUBERNETES_SERVICE_HOST", host)
+	t.Setenv("KUBERNETES_SERVICE_PORT", port)
+
+	data := makeFiles(t, map[string]string{})
+	serviceAccountDir := data["serviceAccountDir"].(string)
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(filepath.Join(serviceAccountDir, "ca.crt"), caCert, 0644))
+
+	api, err := newRealKubeAPI(serviceAccountDir)
+	require.NoError(t, err)
+
+	taints, err := api.getNodeTaints("node-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ToBeDeletedByClusterAutoscaler"}, taints)
+
+	_, err = api.getNodeTaints("node-2")
+	require.Error(t, err)
+}
+
+func TestRealKubeAPIOutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	_, err := newRealKubeAPI(t.TempDir())
+	require.Error(t, err)
+}
+
+func TestWorkerRestart(t *testing.T) {
+	relayed := fakeSignals(t)
+	data := makeFiles(t, map[string]string{"name": "worker-abc12", "namespace": "ci"})
+	p, err := new(makeRunnerConfig(data), nil)
+	require.NoError(t, err, "creating provider")
+	require.NoError(t, p.ConfigureRun(&run.State{}))
+
+	// each time the worker is (re)started, SIGTERM is relayed to it
+	for range 3 {
+		wkr := ptesting.NewFakeWorkerWithCapabilities("graceful-termination")
+		p.SetProtocol(wkr.RunnerProtocol)
+		gotTerm := wkr.MessageReceivedFunc("graceful-termination", nil)
+		require.NoError(t, p.WorkerStarted(&run.State{}))
+		wkr.RunnerProtocol.Start(false)
+		wkr.RunnerProtocol.WaitUntilInitialized()
+		require.True(t, relayed(p.sigterm))
+
+		p.sigterm <- syscall.SIGTERM
+		require.Eventually(t, gotTerm, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, p.WorkerFinished(&run.State{}))
+		require.False(t, relayed(p.sigterm))
+		wkr.Close()
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/aws"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/azure"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/command"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/google"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/kubernetes"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/provider"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/standalone"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider/static"
@@ -17,14 +19,21 @@ import (
 type providerInfo struct {
 	constructor func(*cfg.RunnerConfig) (provider.Provider, error)
 	usage       func() string
+	// true if the worker can register with worker-manager again after a
+	// restart.  Worker-manager's aws, azure and google providers only allow
+	// a worker to register once, and the command and kubernetes providers
+	// depend on a worker-manager provider that may do the same.
+	registersAgain bool
 }
 
 var providers map[string]providerInfo = map[string]providerInfo{
-	"standalone": providerInfo{standalone.New, standalone.Usage},
-	"google":     providerInfo{google.New, google.Usage},
-	"static":     providerInfo{static.New, static.Usage},
-	"aws":        providerInfo{aws.New, aws.Usage},
-	"azure":      providerInfo{azure.New, azure.Usage},
+	"standalone": providerInfo{standalone.New, standalone.Usage, true},
+	"google":     providerInfo{google.New, google.Usage, false},
+	"static":     providerInfo{static.New, static.Usage, true},
+	"aws":        providerInfo{aws.New, aws.Usage, false},
+	"azure":      providerInfo{azure.New, azure.Usage, false},
+	"command":    providerInfo{command.New, command.Usage, false},
+	"kubernetes": providerInfo{kubernetes.New, kubernetes.Usage, false},
 }
 
 func New(runnercfg *cfg.RunnerConfig) (provider.Provider, error) {
@@ -39,6 +48,12 @@ func New(runnercfg *cfg.RunnerConfig) (provider.Provider, error) {
 	return pi.constructor(runnercfg)
 }
 
+// RegistersAgain determines whether the configured provider can register the
+// worker again after a restart, rather than using a cached run.
+func RegistersAgain(runnercfg *cfg.RunnerConfig) bool {
+	return providers[runnercfg.Provider.ProviderType].registersAgain
+}
+
 func Usage() string {
 	rv := []string{strings.ReplaceAll(
 		`Providers are configured in the |provider| portion of the runner configuration.  The |providerType| property
@@ -34,6 +34,7 @@ type Provider interface {
 
 	// The worker has exited.  Handle any necessary communication with the provider.
 	// Note that this method may not always be called, e.g., in the event of a system
-	// failure.
+	// failure.  If the worker crashed and supervision is enabled, the worker
+	// is then restarted, calling SetProtocol and WorkerStarted again.
 	WorkerFinished(state *run.State) error
 }
@@ -33,6 +33,9 @@ type RegistrationManager struct {
 	// expire
 	credsExpireCancel context.CancelFunc
 
+	// the time at which the timer will fire; protected by the state mutex
+	renewAt time.Time
+
 	// for testing
 	credsExpireCond *sync.Cond
 }
@@ -79,6 +82,7 @@ func (reg *RegistrationManager) RegisterWorker(workerIdentityProofMap map[string
 		}
 
 		reg.state.WorkerConfig = reg.state.WorkerConfig.Merge(pwc.Config)
+		reg.state.RegistrationWorkerConfig = pwc.Config
 		reg.state.Files = append(reg.state.Files, pwc.Files...)
 	}
 
@@ -125,6 +129,7 @@ func (reg *RegistrationManager) setTimer() error {
 	// timers, which stop ticking during system hibernation.
 	renewAt := time.Now().Add(untilRenew)
 	renewAt = renewAt.Round(0) // remove monotonic clock value
+	reg.renewAt = renewAt
 
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
@@ -233,6 +238,32 @@ func (reg *RegistrationManager) WorkerFinished() error {
 	return nil
 }
 
+// RegistrationStatus describes the expiration of the worker's credentials
+type RegistrationStatus struct {
+	// when the credentials expire; zero if they do not expire
+	CredentialsExpire time.Time
+	// the time until the credentials expire
+	UntilExpires time.Duration
+	// when the credentials are scheduled to be renewed (or the worker
+	// stopped); zero if no renewal has been scheduled
+	RenewAt time.Time
+}
+
+// Get the status of the worker's credentials
+func (reg *RegistrationManager) Status() RegistrationStatus {
+	reg.state.RLock()
+	defer reg.state.RUnlock()
+
+	status := RegistrationStatus{
+		CredentialsExpire: time.Time(reg.state.CredentialsExpire),
+	}
+	if !status.CredentialsExpire.IsZero() {
+		status.UntilExpires = reg.untilExpires()
+	}
+	status.RenewAt = reg.renewAt
+	return status
+}
+
 // Calculate the time until the credentials expire, rounding to the nearest second
 func (reg *RegistrationManager) untilExpires() time.Duration {
 	untilExpires := time.Until(time.Time(reg.state.CredentialsExpire))
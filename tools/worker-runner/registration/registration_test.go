@@ -47,6 +47,7 @@ func TestRegisterWorker(t *testing.T) {
 	require.Equal(t, tc.GetFakeWorkerManagerWorkerSecret(), state.RegistrationSecret)
 
 	require.Equal(t, true, state.WorkerConfig.MustGet("from-register-worker"), "value for from-register-worker")
+	require.Equal(t, true, state.RegistrationWorkerConfig.MustGet("from-register-worker"), "registration config is kept")
 	require.Equal(t, "a file.", state.Files[0].Description)
 
 	call, err := tc.FakeWorkerManagerRegistration()
@@ -212,3 +213,30 @@ func TestUseCachedRun_Expired(t *testing.T) {
 	err := reg.UseCachedRun()
 	require.Error(t, err)
 }
+
+func TestStatus(t *testing.T) {
+	runnercfg := cfg.RunnerConfig{}
+	state := run.State{}
+	reg := new(&runnercfg, &state, tc.FakeWorkerManagerClientFactory)
+
+	t.Run("no expiration", func(t *testing.T) {
+		require.Equal(t, RegistrationStatus{}, reg.Status())
+	})
+
+	t.Run("with timer", func(t *testing.T) {
+		state.CredentialsExpire = tcclient.Time(time.Now().Add(90 * time.Minute))
+
+		wkr := ptesting.NewFakeWorkerWithCapabilities("graceful-termination")
+		defer wkr.Close()
+		reg.SetProtocol(wkr.RunnerProtocol)
+
+		require.NoError(t, reg.WorkerStarted())
+		defer func() { require.NoError(t, reg.WorkerFinished()) }()
+
+		status := reg.Status()
+		require.Equal(t, time.Time(state.CredentialsExpire), status.CredentialsExpire)
+		require.Equal(t, 90*time.Minute, status.UntilExpires)
+		// renewal is 30 minutes before expiration
+		require.WithinDuration(t, time.Now().Add(60*time.Minute), status.RenewAt, time.Minute)
+	})
+}
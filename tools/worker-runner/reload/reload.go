@@ -0,0 +1,280 @@
+package reload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	taskcluster "github.com/taskcluster/taskcluster/v84/clients/client-go"
+	"github.com/taskcluster/taskcluster/v84/clients/client-go/tcworkermanager"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/secrets"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/tc"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/worker/worker"
+	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
+)
+
+// A function to fetch the worker config from the worker pool's secrets
+type secretsFetcher func(rootURL string, credentials *taskcluster.Credentials, workerPoolID string) (*cfg.WorkerConfig, error)
+
+// ReloadManager periodically re-fetches the worker config from the worker
+// pool's secrets and, for static workers, the worker pool definition.  When
+// that config changes, it sends the changes to the worker in a config-update
+// message or, if the worker cannot apply them while running, gracefully stops
+// the worker so that it can be restarted with the new config.
+type ReloadManager struct {
+	runnercfg *cfg.RunnerConfig
+	state     *run.State
+	worker    worker.Worker
+
+	// Factory for worker-manager clients
+	factory tc.WorkerManagerClientFactory
+
+	// Function to fetch secrets
+	fetchSecrets secretsFetcher
+
+	// the protocol (set in SetProtocol)
+	proto *workerproto.Protocol
+
+	// calling cancel stops the reload loop, which closes done when it has
+	// finished
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// set when the worker has been asked to stop so that it can be restarted
+	// with new config
+	restartRequested bool
+	mux              sync.Mutex
+}
+
+func (rm *ReloadManager) SetProtocol(proto *workerproto.Protocol) {
+	rm.proto = proto
+	proto.AddCapability("config-update")
+}
+
+// The worker has started, so begin checking for config changes.
+func (rm *ReloadManager) WorkerStarted() {
+	rm.mux.Lock()
+	rm.restartRequested = false
+	rm.mux.Unlock()
+
+	if rm.runnercfg.ConfigReloadIntervalSeconds <= 0 {
+		return
+	}
+	interval := time.Duration(rm.runnercfg.ConfigReloadIntervalSeconds) * time.Second
+
+	rm.state.Lock()
+	cachedWithoutBase := rm.state.BaseWorkerConfig == nil
+	rm.state.Unlock()
+	if cachedWithoutBase {
+		log.Printf("WARNING: the cached state does not support reloading config, so it will not be reloaded; restart worker-runner without the cache to enable reloading")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rm.cancel = cancel
+	rm.done = make(chan struct{})
+	go func() {
+		defer close(rm.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if rm.RestartRequested() {
+					continue
+				}
+				err := rm.reload()
+				if err != nil {
+					log.Printf("Error reloading worker config: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// The worker has exited, so stop checking for config changes.
+func (rm *ReloadManager) WorkerFinished() {
+	if rm.cancel != nil {
+		rm.cancel()
+		<-rm.done
+		rm.cancel = nil
+	}
+}
+
+// Determine whether the worker was stopped in order to apply new config, in
+// which case it should be restarted.
+func (rm *ReloadManager) RestartRequested() bool {
+	rm.mux.Lock()
+	defer rm.mux.Unlock()
+	return rm.restartRequested
+}
+
+// Fetch the worker config, rebuild the state's WorkerConfig with it, and
+// apply any changes to the worker.
+func (rm *ReloadManager) reload() error {
+	current := rm.state.Clone()
+	if current.BaseWorkerConfig == nil {
+		return errors.New("the cached state does not support reloading config; restart worker-runner without the cache")
+	}
+
+	registrationConfig := current.RegistrationWorkerConfig
+	// only the static provider registers with the worker pool's current
+	// config; other providers supply the config with which the worker was
+	// launched, which does not change.
+	if rm.runnercfg.Provider.ProviderType == "static" {
+		var err error
+		registrationConfig, err = rm.fetchWorkerPoolConfig(current)
+		if err != nil {
+			return err
+		}
+	}
+
+	secretConfig := current.SecretWorkerConfig
+	if rm.runnercfg.GetSecrets {
+		var err error
+		secretConfig, err = rm.fetchSecrets(current.RootURL, &current.Credentials, current.WorkerPoolID)
+		if err != nil {
+			return fmt.Errorf("could not fetch secrets: %w", err)
+		}
+	}
+
+	oldConfig := current.BaseWorkerConfig.Merge(current.RegistrationWorkerConfig).Merge(current.SecretWorkerConfig)
+	newConfig := current.BaseWorkerConfig.Merge(registrationConfig).Merge(secretConfig)
+	changed := oldConfig.Diff(newConfig)
+	if len(changed) == 0 {
+		return nil
+	}
+	log.Printf("Worker config has changed: %v", changed)
+
+	// let the worker implementation configure the new config, just as it
+	// did the original config
+	current.WorkerConfig = newConfig
+	err := rm.worker.ConfigureRun(current)
+	if err != nil {
+		return err
+	}
+
+	rm.state.Lock()
+	rm.state.WorkerConfig = current.WorkerConfig
+	rm.state.RegistrationWorkerConfig = registrationConfig
+	rm.state.SecretWorkerConfig = secretConfig
+	if rm.runnercfg.CacheOverRestarts != "" {
+		err = rm.state.WriteCacheFile(rm.runnercfg.CacheOverRestarts)
+	}
+	rm.state.Unlock()
+	if err != nil {
+		return err
+	}
+
+	rm.apply(changed, current.WorkerConfig)
+	return nil
+}
+
+// Get the worker config from the worker pool definition
+func (rm *ReloadManager) fetchWorkerPoolConfig(current *run.State) (*cfg.WorkerConfig, error) {
+	wm, err := rm.factory(current.RootURL, &current.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	wp, err := wm.WorkerPool(current.WorkerPoolID)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch worker pool: %w", err)
+	}
+
+	var poolConfig struct {
+		WorkerConfig json.RawMessage `json:"workerConfig"`
+	}
+	err = json.Unmarshal(wp.Config, &poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing worker pool config: %w", err)
+	}
+	if poolConfig.WorkerConfig == nil {
+		return nil, nil
+	}
+
+	pwc, err := cfg.ParseProviderWorkerConfig(rm.runnercfg, &poolConfig.WorkerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return pwc.Config, nil
+}
+
+// Apply changes to the given top-level properties of the worker config,
+// either by sending them to the worker or by restarting it.
+func (rm *ReloadManager) apply(changed []string, config *cfg.WorkerConfig) {
+	update := make(map[string]any)
+	hot := rm.proto.Capable("config-update")
+	reloader, ok := rm.worker.(worker.ConfigReloader)
+	for _, key := range changed {
+		value, err := config.Get(key)
+		// removed properties cannot be updated, as the worker would need to
+		// know their defaults
+		if !hot || !ok || err != nil || !reloader.HotReloadable(key) {
+			hot = false
+			break
+		}
+		update[key] = value
+	}
+
+	if hot {
+		log.Printf("Sending updated config to worker")
+		rm.proto.Send(workerproto.Message{
+			Type: "config-update",
+			Properties: map[string]any{
+				"config": update,
+			},
+		})
+		return
+	}
+
+	if !rm.proto.Capable("graceful-termination") {
+		log.Printf("Worker does not support graceful termination; new config will apply when it is next started")
+		return
+	}
+
+	log.Printf("Stopping worker after its current tasks, to restart it with the new config")
+	rm.mux.Lock()
+	rm.restartRequested = true
+	rm.mux.Unlock()
+	rm.proto.Send(workerproto.Message{
+		Type: "graceful-termination",
+		Properties: map[string]any{
+			"finish-tasks": true,
+		},
+	})
+}
+
+// Make a new ReloadManager object
+func New(runnercfg *cfg.RunnerConfig, state *run.State, worker worker.Worker) *ReloadManager {
+	return new(runnercfg, state, worker, nil, nil)
+}
+
+// Private constructor allowing injection of fakes
+func new(runnercfg *cfg.RunnerConfig, state *run.State, worker worker.Worker, factory tc.WorkerManagerClientFactory, fetchSecrets secretsFetcher) *ReloadManager {
+	if factory == nil {
+		factory = func(rootURL string, credentials *taskcluster.Credentials) (tc.WorkerManager, error) {
+			prov := tcworkermanager.New(credentials, rootURL)
+			return prov, nil
+		}
+	}
+	if fetchSecrets == nil {
+		fetchSecrets = secrets.FetchWorkerConfig
+	}
+
+	return &ReloadManager{
+		runnercfg:    runnercfg,
+		state:        state,
+		worker:       worker,
+		factory:      factory,
+		fetchSecrets: fetchSecrets,
+	}
+}
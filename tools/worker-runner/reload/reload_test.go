@@ -0,0 +1,218 @@
+package reload
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	taskcluster "github.com/taskcluster/taskcluster/v84/clients/client-go"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/tc"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/worker/dummy"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/worker/worker"
+	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
+	ptesting "github.com/taskcluster/taskcluster/v84/tools/workerproto/testing"
+)
+
+// reloadingWorker can apply changes to the "hot" property while running, and
+// sets "configured" in ConfigureRun
+type reloadingWorker struct {
+	worker.Worker
+}
+
+func (w *reloadingWorker) ConfigureRun(state *run.State) error {
+	var err error
+	state.WorkerConfig, err = state.WorkerConfig.Set("configured", true)
+	return err
+}
+
+func (w *reloadingWorker) HotReloadable(key string) bool {
+	return key == "hot"
+}
+
+func mustParse(t *testing.T, data string) *cfg.WorkerConfig {
+	t.Helper()
+	var wc cfg.WorkerConfig
+	require.NoError(t, json.Unmarshal([]byte(data), &wc))
+	return &wc
+}
+
+type reloadTest struct {
+	// the worker pool's workerConfig and secret, after the change
+	poolConfig   string
+	secretConfig string
+	// worker capabilities
+	capabilities []string
+}
+
+func setup(t *testing.T, test reloadTest) (*ReloadManager, *run.State, *ptesting.FakeWorker) {
+	t.Helper()
+
+	runnercfg := &cfg.RunnerConfig{
+		Provider:             cfg.ProviderConfig{ProviderType: "static"},
+		WorkerImplementation: cfg.WorkerImplementationConfig{Implementation: "whatever-worker"},
+		GetSecrets:           true,
+	}
+	base := mustParse(t, `{"base": true}`)
+	registration := mustParse(t, `{"hot": 1, "cold": 1}`)
+	secret := mustParse(t, `{"secret": 1}`)
+	state := &run.State{
+		RootURL:                  "https://tc.example.com",
+		Credentials:              taskcluster.Credentials{ClientID: "cli"},
+		WorkerPoolID:             "w/p",
+		BaseWorkerConfig:         base,
+		RegistrationWorkerConfig: registration,
+		SecretWorkerConfig:       secret,
+		WorkerConfig:             base.Merge(registration).Merge(secret),
+	}
+
+	tc.SetFakeWorkerManagerWorkerPoolConfig(json.RawMessage(`{"workerConfig": {"whateverWorker": {"config": ` + test.poolConfig + `}}}`))
+	t.Cleanup(func() { tc.SetFakeWorkerManagerWorkerPoolConfig(nil) })
+	fetchSecrets := func(rootURL string, credentials *taskcluster.Credentials, workerPoolID string) (*cfg.WorkerConfig, error) {
+		return mustParse(t, test.secretConfig), nil
+	}
+
+	w, err := dummy.New(runnercfg)
+	require.NoError(t, err)
+	rm := new(runnercfg, state, &reloadingWorker{w}, tc.FakeWorkerManagerClientFactory, fetchSecrets)
+
+	wkr := ptesting.NewFakeWorkerWithCapabilities(test.capabilities...)
+	t.Cleanup(wkr.Close)
+	rm.SetProtocol(wkr.RunnerProtocol)
+	wkr.RunnerProtocol.AddCapability("graceful-termination")
+	wkr.RunnerProtocol.Start(false)
+	wkr.RunnerProtocol.WaitUntilInitialized()
+
+	return rm, state, wkr
+}
+
+func TestReloadNoChanges(t *testing.T) {
+	rm, state, wkr := setup(t, reloadTest{
+		poolConfig:   `{"hot": 1, "cold": 1}`,
+		secretConfig: `{"secret": 1}`,
+		capabilities: []string{"config-update", "graceful-termination"},
+	})
+	gotUpdate := wkr.MessageReceivedFunc("config-update", nil)
+	gotTerminated := wkr.MessageReceivedFunc("graceful-termination", nil)
+
+	require.NoError(t, rm.reload())
+	require.False(t, gotUpdate())
+	require.False(t, gotTerminated())
+	require.False(t, state.WorkerConfig.Has("configured"), "config not rebuilt")
+}
+
+func TestReloadConfigUpdate(t *testing.T) {
+	rm, state, wkr := setup(t, reloadTest{
+		poolConfig:   `{"hot": 2, "cold": 1}`,
+		secretConfig: `{"secret": 1}`,
+		capabilities: []string{"config-update", "graceful-termination"},
+	})
+	gotUpdate := wkr.MessageReceivedFunc("config-update", func(msg workerproto.Message) bool {
+		update, ok := msg.Properties["config"].(map[string]any)
+		return ok && len(update) == 1 && update["hot"] == float64(2)
+	})
+	gotTerminated := wkr.MessageReceivedFunc("graceful-termination", nil)
+
+	require.NoError(t, rm.reload())
+	require.True(t, gotUpdate())
+	require.False(t, gotTerminated())
+	require.False(t, rm.RestartRequested())
+
+	require.Equal(t, float64(2), state.WorkerConfig.MustGet("hot"))
+	require.Equal(t, true, state.WorkerConfig.MustGet("base"))
+	require.Equal(t, true, state.WorkerConfig.MustGet("configured"))
+	require.Equal(t, float64(2), state.RegistrationWorkerConfig.MustGet("hot"))
+}
+
+func TestReloadRestart(t *testing.T) {
+	rm, state, wkr := setup(t, reloadTest{
+		poolConfig:   `{"hot": 2, "cold": 1}`,
+		secretConfig: `{"secret": 2}`,
+		capabilities: []string{"config-update", "graceful-termination"},
+	})
+	gotUpdate := wkr.MessageReceivedFunc("config-update", nil)
+	gotTerminated := wkr.MessageReceivedFunc("graceful-termination", func(msg workerproto.Message) bool {
+		return msg.Properties["finish-tasks"].(bool)
+	})
+
+	require.NoError(t, rm.reload())
+	require.False(t, gotUpdate())
+	require.True(t, gotTerminated())
+	require.True(t, rm.RestartRequested())
+
+	require.Equal(t, float64(2), state.WorkerConfig.MustGet("secret"))
+	require.Equal(t, float64(2), state.SecretWorkerConfig.MustGet("secret"))
+
+	// a restarted worker is no longer waiting to restart
+	rm.WorkerStarted()
+	require.False(t, rm.RestartRequested())
+	rm.WorkerFinished()
+}
+
+func TestReloadRemovedProperty(t *testing.T) {
+	rm, state, wkr := setup(t, reloadTest{
+		poolConfig:   `{"cold": 1}`,
+		secretConfig: `{"secret": 1}`,
+		capabilities: []string{"config-update", "graceful-termination"},
+	})
+	gotTerminated := wkr.MessageReceivedFunc("graceful-termination", nil)
+
+	require.NoError(t, rm.reload())
+	require.True(t, gotTerminated(), "removed properties require a restart")
+	require.False(t, state.WorkerConfig.Has("hot"))
+}
+
+func TestReloadWithoutConfigUpdate(t *testing.T) {
+	rm, _, wkr := setup(t, reloadTest{
+		poolConfig:   `{"hot": 2, "cold": 1}`,
+		secretConfig: `{"secret": 1}`,
+		capabilities: []string{"graceful-termination"},
+	})
+	gotTerminated := wkr.MessageReceivedFunc("graceful-termination", nil)
+
+	require.NoError(t, rm.reload())
+	require.True(t, gotTerminated(), "worker without config-update is restarted")
+	require.True(t, rm.RestartRequested())
+}
+
+func TestReloadWithoutGracefulTermination(t *testing.T) {
+	rm, state, wkr := setup(t, reloadTest{
+		poolConfig:   `{"hot": 1, "cold": 2}`,
+		secretConfig: `{"secret": 1}`,
+	})
+	gotTerminated := wkr.MessageReceivedFunc("graceful-termination", nil)
+
+	require.NoError(t, rm.reload())
+	require.False(t, gotTerminated())
+	require.False(t, rm.RestartRequested())
+	require.Equal(t, float64(2), state.WorkerConfig.MustGet("cold"), "new config applies at next start")
+}
+
+func TestReloadNonStaticProvider(t *testing.T) {
+	rm, state, _ := setup(t, reloadTest{
+		poolConfig:   `{"hot": 2}`,
+		secretConfig: `{"secret": 1}`,
+		capabilities: []string{"config-update", "graceful-termination"},
+	})
+	rm.runnercfg.Provider.ProviderType = "aws"
+
+	require.NoError(t, rm.reload())
+	require.Equal(t, float64(1), state.WorkerConfig.MustGet("hot"), "worker pool config is not used")
+}
+
+func TestReloadCachedState(t *testing.T) {
+	rm, state, _ := setup(t, reloadTest{
+		poolConfig:   `{"hot": 2, "cold": 1}`,
+		secretConfig: `{"secret": 1}`,
+	})
+	state.BaseWorkerConfig = nil
+
+	require.Error(t, rm.reload())
+
+	// the reload loop does not start at all
+	rm.runnercfg.ConfigReloadIntervalSeconds = 1
+	rm.WorkerStarted()
+	require.Nil(t, rm.cancel)
+	rm.WorkerFinished()
+}
@@ -0,0 +1,143 @@
+package run
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/perms"
+)
+
+// the minimum length of the secret from which the cache key is derived
+const minKeySecretLength = 32
+
+// the encryption scheme used for cache files, recorded in the file
+const cacheEncryptionScheme = "aes-256-gcm"
+
+// encryptedCache is the format of an encrypted cache file
+type encryptedCache struct {
+	Encryption string `json:"encryption"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// Unseal a TPM-sealed object, returning its contents.  This uses tpm2_unseal
+// from tpm2-tools, and can be replaced in testing.
+var tpmUnseal = func(path string) ([]byte, error) {
+	return exec.Command("tpm2_unseal", "-c", path).Output()
+}
+
+// ValidateCacheEncryption checks that the configuration names exactly one
+// source for the cache key.
+func ValidateCacheEncryption(config *cfg.CacheEncryptionConfig) error {
+	switch {
+	case config.KeyFile != "" && config.TPMSealedKey != "":
+		return errors.New("cacheEncryption: specify only one of keyFile and tpmSealedKey")
+	case config.KeyFile == "" && config.TPMSealedKey == "":
+		return errors.New("cacheEncryption: specify one of keyFile and tpmSealedKey")
+	}
+	return nil
+}
+
+// LoadCacheKey gets the key with which to encrypt the cache file, from the
+// source given in the configuration.  The key is derived from a secret,
+// which must be at least 32 bytes long.
+func LoadCacheKey(config *cfg.CacheEncryptionConfig) ([]byte, error) {
+	err := ValidateCacheEncryption(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret []byte
+	if config.KeyFile != "" {
+		secret, err = perms.ReadPrivateFile(config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cacheEncryption: could not read key file: %w", err)
+		}
+	} else {
+		secret, err = tpmUnseal(config.TPMSealedKey)
+		if err != nil {
+			return nil, fmt.Errorf("cacheEncryption: could not unseal key from TPM: %w", err)
+		}
+	}
+
+	if len(secret) < minKeySecretLength {
+		return nil, fmt.Errorf("cacheEncryption: key must be at least %d bytes", minKeySecretLength)
+	}
+	key := sha256.Sum256(secret)
+	return key[:], nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt the encoded state with the given key
+func encryptCache(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&encryptedCache{
+		Encryption: cacheEncryptionScheme,
+		Nonce:      nonce,
+		Data:       gcm.Seal(nil, nonce, plaintext, nil),
+	})
+}
+
+// Decrypt the contents of a cache file with the given key, returning the
+// encoded state.  If key is nil, the file must not be encrypted, and if it is
+// not nil, the file must be encrypted with that key.
+func decryptCache(key []byte, contents []byte) ([]byte, error) {
+	var ec encryptedCache
+	// a cache file that is not encrypted will not have an `encryption`
+	// property, so ignore any errors here
+	_ = json.Unmarshal(contents, &ec)
+	encrypted := ec.Encryption != ""
+
+	if key == nil {
+		if encrypted {
+			return nil, errors.New("cache file is encrypted, but cacheEncryption is not configured")
+		}
+		return contents, nil
+	}
+
+	if !encrypted {
+		return nil, errors.New("cache file is not encrypted")
+	}
+	if ec.Encryption != cacheEncryptionScheme {
+		return nil, fmt.Errorf("unsupported cache encryption %q", ec.Encryption)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ec.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce in cache file")
+	}
+
+	plaintext, err := gcm.Open(nil, ec.Nonce, ec.Data, nil)
+	if err != nil {
+		// this is the error when the key is wrong
+		return nil, fmt.Errorf("could not decrypt cache file: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,137 @@
+package run
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Flaque/filet"
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/perms"
+)
+
+func TestLoadCacheKey(t *testing.T) {
+	defer filet.CleanUp(t)
+	dir := filet.TmpDir(t, "")
+	keySecret := []byte(strings.Repeat("k", 32))
+
+	t.Run("KeyFile", func(t *testing.T) {
+		keyFile := filepath.Join(dir, "key")
+		require.NoError(t, perms.WritePrivateFile(keyFile, keySecret))
+
+		key, err := LoadCacheKey(&cfg.CacheEncryptionConfig{KeyFile: keyFile})
+		require.NoError(t, err)
+		require.Len(t, key, 32)
+
+		// the same secret gives the same key
+		key2, err := LoadCacheKey(&cfg.CacheEncryptionConfig{KeyFile: keyFile})
+		require.NoError(t, err)
+		require.Equal(t, key, key2)
+	})
+
+	t.Run("KeyFileMissing", func(t *testing.T) {
+		_, err := LoadCacheKey(&cfg.CacheEncryptionConfig{KeyFile: filepath.Join(dir, "missing")})
+		require.Error(t, err)
+	})
+
+	t.Run("KeyTooShort", func(t *testing.T) {
+		keyFile := filepath.Join(dir, "short-key")
+		require.NoError(t, perms.WritePrivateFile(keyFile, []byte("short")))
+
+		_, err := LoadCacheKey(&cfg.CacheEncryptionConfig{KeyFile: keyFile})
+		require.Error(t, err)
+	})
+
+	t.Run("TPMSealedKey", func(t *testing.T) {
+		defer func(old func(string) ([]byte, error)) { tpmUnseal = old }(tpmUnseal)
+		tpmUnseal = func(path string) ([]byte, error) {
+			if path != "/sealed" {
+				return nil, errors.New("no such object")
+			}
+			return keySecret, nil
+		}
+
+		key, err := LoadCacheKey(&cfg.CacheEncryptionConfig{TPMSealedKey: "/sealed"})
+		require.NoError(t, err)
+		require.Len(t, key, 32)
+
+		_, err = LoadCacheKey(&cfg.CacheEncryptionConfig{TPMSealedKey: "/other"})
+		require.Error(t, err)
+	})
+
+	t.Run("NoSource", func(t *testing.T) {
+		_, err := LoadCacheKey(&cfg.CacheEncryptionConfig{})
+		require.Error(t, err)
+	})
+
+	t.Run("TwoSources", func(t *testing.T) {
+		_, err := LoadCacheKey(&cfg.CacheEncryptionConfig{KeyFile: "/key", TPMSealedKey: "/sealed"})
+		require.Error(t, err)
+	})
+}
+
+func TestValidateCacheEncryption(t *testing.T) {
+	require.NoError(t, ValidateCacheEncryption(&cfg.CacheEncryptionConfig{KeyFile: "/key"}))
+	require.NoError(t, ValidateCacheEncryption(&cfg.CacheEncryptionConfig{TPMSealedKey: "/sealed"}))
+	require.Error(t, ValidateCacheEncryption(&cfg.CacheEncryptionConfig{}))
+	require.Error(t, ValidateCacheEncryption(&cfg.CacheEncryptionConfig{KeyFile: "/key", TPMSealedKey: "/sealed"}))
+}
+
+func TestEncryptedCacheFile(t *testing.T) {
+	defer filet.CleanUp(t)
+	dir := filet.TmpDir(t, "")
+	cachePath := filepath.Join(dir, "cache.json")
+	plainCachePath := filepath.Join(dir, "plain-cache.json")
+	key := []byte(strings.Repeat("a", 32))
+	otherKey := []byte(strings.Repeat("b", 32))
+
+	cachedState := &State{RootURL: "foo", RegistrationSecret: "sekrit"}
+	cachedState.SetCacheKey(key)
+	require.NoError(t, cachedState.WriteCacheFile(cachePath))
+	require.NoError(t, (&State{RootURL: "foo"}).WriteCacheFile(plainCachePath))
+
+	contents, err := os.ReadFile(cachePath)
+	require.NoError(t, err)
+	require.NotContains(t, string(contents), "sekrit")
+
+	read := func(key []byte, path string) (bool, *State) {
+		t.Helper()
+		var state State
+		state.SetCacheKey(key)
+		found, err := ReadCacheFile(&state, path)
+		if found {
+			require.NoError(t, err)
+		} else {
+			require.ErrorIs(t, err, ErrCacheNotDecrypted)
+		}
+		return found, &state
+	}
+
+	t.Run("SameKey", func(t *testing.T) {
+		found, state := read(key, cachePath)
+		require.True(t, found)
+		require.Equal(t, "foo", state.RootURL)
+		require.Equal(t, "sekrit", state.RegistrationSecret)
+	})
+
+	t.Run("OtherKey", func(t *testing.T) {
+		found, state := read(otherKey, cachePath)
+		require.False(t, found, "undecryptable file is not used")
+		require.Equal(t, "", state.RootURL)
+	})
+
+	t.Run("NoKey", func(t *testing.T) {
+		found, state := read(nil, cachePath)
+		require.False(t, found, "encrypted file is not used without a key")
+		require.Equal(t, "", state.RootURL)
+	})
+
+	t.Run("NotEncrypted", func(t *testing.T) {
+		found, state := read(key, plainCachePath)
+		require.False(t, found, "unencrypted file is not used with a key")
+		require.Equal(t, "", state.RootURL)
+	})
+}
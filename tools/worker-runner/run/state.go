@@ -2,6 +2,7 @@ package run
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -58,8 +59,28 @@ type State struct {
 	WorkerConfig *cfg.WorkerConfig
 	Files        []files.File
 
+	// the parts of WorkerConfig that can change while the worker is running,
+	// kept so that WorkerConfig can be rebuilt when reloading configuration:
+	// the config before the worker registered, and the config from the
+	// registration and from the worker pool's secrets
+	BaseWorkerConfig         *cfg.WorkerConfig
+	RegistrationWorkerConfig *cfg.WorkerConfig
+	SecretWorkerConfig       *cfg.WorkerConfig
+
 	// The worker location configuration
 	WorkerLocation map[string]string
+
+	// the key with which to encrypt the cache file, if it is encrypted; this
+	// is not itself cached
+	cacheKey []byte
+}
+
+// Encrypt the cache file with the given key, from LoadCacheKey.  This must be
+// called before reading or writing the cache file.
+func (state *State) SetCacheKey(key []byte) {
+	state.Lock()
+	defer state.Unlock()
+	state.cacheKey = key
 }
 
 // Check that the provided provided the information it was supposed to.
@@ -102,6 +123,32 @@ func (state *State) CheckProviderResults() error {
 	return nil
 }
 
+// Clone returns a copy of the state, for calculating changes without modifying
+// the state itself.  Maps, slices and WorkerConfigs are shared between the two
+// copies, so they must be replaced rather than modified.
+func (state *State) Clone() *State {
+	state.RLock()
+	defer state.RUnlock()
+
+	return &State{
+		RootURL:                  state.RootURL,
+		Credentials:              state.Credentials,
+		CredentialsExpire:        state.CredentialsExpire,
+		RegistrationSecret:       state.RegistrationSecret,
+		WorkerPoolID:             state.WorkerPoolID,
+		WorkerGroup:              state.WorkerGroup,
+		WorkerID:                 state.WorkerID,
+		ProviderID:               state.ProviderID,
+		ProviderMetadata:         state.ProviderMetadata,
+		WorkerConfig:             state.WorkerConfig,
+		Files:                    state.Files,
+		BaseWorkerConfig:         state.BaseWorkerConfig,
+		RegistrationWorkerConfig: state.RegistrationWorkerConfig,
+		SecretWorkerConfig:       state.SecretWorkerConfig,
+		WorkerLocation:           state.WorkerLocation,
+	}
+}
+
 // Write the state to the given cache file, checking permissions along the way
 func (state *State) WriteCacheFile(filename string) error {
 	log.Printf("Caching worker-runner state at %s", filename)
@@ -110,6 +157,12 @@ func (state *State) WriteCacheFile(filename string) error {
 	if err != nil {
 		return err
 	}
+	if state.cacheKey != nil {
+		encoded, err = encryptCache(state.cacheKey, encoded)
+		if err != nil {
+			return fmt.Errorf("could not encrypt cache file: %w", err)
+		}
+	}
 	err = perms.WritePrivateFile(filename, encoded)
 	if err != nil {
 		return err
@@ -118,8 +171,14 @@ func (state *State) WriteCacheFile(filename string) error {
 	return nil
 }
 
+// ErrCacheNotDecrypted is returned (wrapped) by ReadCacheFile when the file
+// cannot be decrypted with the state's cache key.
+var ErrCacheNotDecrypted = errors.New("cached state cannot be decrypted")
+
 // Read a file written by WriteCacheFile.  First return value is true
-// if the file existed and false otherwise.
+// if the file existed and false otherwise.  If the file cannot be decrypted,
+// the first return value is false and the error wraps ErrCacheNotDecrypted,
+// so that the caller can decide whether to register the worker again.
 func ReadCacheFile(state *State, filename string) (bool, error) {
 	var encoded []byte
 
@@ -127,6 +186,11 @@ func ReadCacheFile(state *State, filename string) (bool, error) {
 	if err == nil {
 		log.Printf("Loading cached state from %s", filename)
 
+		encoded, err = decryptCache(state.cacheKey, encoded)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrCacheNotDecrypted, err)
+		}
+
 		err = json.Unmarshal(encoded, state)
 		if err != nil {
 			return true, err
@@ -115,3 +115,13 @@ func TestReadCacheFile(t *testing.T) {
 		})
 	}
 }
+
+func TestClone(t *testing.T) {
+	state := makeState()
+	clone := state.Clone()
+	require.Equal(t, "wid", clone.WorkerID)
+	require.Equal(t, "mushroom", clone.WorkerLocation["cloud"])
+
+	clone.WorkerID = "other"
+	require.Equal(t, "wid", state.WorkerID)
+}
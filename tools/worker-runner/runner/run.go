@@ -1,8 +1,11 @@
 package runner
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/errorreport"
@@ -12,8 +15,10 @@ import (
 	loggingProtocol "github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/protocol"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/provider"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/registration"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/reload"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/secrets"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/status"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/worker"
 	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
 )
@@ -31,9 +36,54 @@ func Run(configFile string) (state run.State, err error) {
 
 	logging.Configure(runnercfg)
 
+	sm, err := status.New(runnercfg, &state)
+	if err != nil {
+		return
+	}
+	defer sm.Close()
+	logging.SetStatus(sm)
+
 	runCached := false
-	if runnercfg.CacheOverRestarts != "" {
+	useCache := runnercfg.CacheOverRestarts != ""
+	registersAgain := provider.RegistersAgain(runnercfg)
+	if useCache && runnercfg.CacheEncryption != nil {
+		err = run.ValidateCacheEncryption(runnercfg.CacheEncryption)
+		if err != nil {
+			return
+		}
+
+		// if the key is not available, re-register rather than failing, and
+		// do not write the cache at all rather than writing it in plaintext.
+		// Without the cache, the worker would have to register again after
+		// every restart, so fail if the provider does not allow that.
+		key, err2 := run.LoadCacheKey(runnercfg.CacheEncryption)
+		if err2 != nil && !registersAgain {
+			err = fmt.Errorf("%w; the %s provider cannot register the worker again after a restart, so it cannot run without the cache key", err2, runnercfg.Provider.ProviderType)
+			return
+		}
+		if err2 != nil {
+			log.Printf("Not caching the run over restarts, and registering again: %s", err2)
+			useCache = false
+			// any existing cache file is from an earlier registration
+			err = os.Remove(runnercfg.CacheOverRestarts)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return
+			}
+			err = nil
+		} else {
+			state.SetCacheKey(key)
+		}
+	}
+	if useCache {
 		runCached, err = run.ReadCacheFile(&state, runnercfg.CacheOverRestarts)
+		if errors.Is(err, run.ErrCacheNotDecrypted) {
+			if !registersAgain {
+				err = fmt.Errorf("%w; the %s provider cannot register the worker again, so it cannot start", err, runnercfg.Provider.ProviderType)
+				return
+			}
+			log.Printf("Ignoring cached state, and registering again: %v", err)
+			err = nil
+		}
 		if err != nil {
 			return
 		}
@@ -53,6 +103,7 @@ func Run(configFile string) (state run.State, err error) {
 	reg := registration.New(runnercfg, &state)
 	er := errorreport.New(&state)
 	em := exit.New(runnercfg, &state)
+	sm.SetRegistration(reg)
 
 	if !runCached {
 		log.Printf("Configuring with provider %s", runnercfg.Provider.ProviderType)
@@ -61,6 +112,11 @@ func Run(configFile string) (state run.State, err error) {
 			return
 		}
 
+		// keep the config from before registration, for reloading
+		state.Lock()
+		state.BaseWorkerConfig = state.WorkerConfig
+		state.Unlock()
+
 		workerIdentityProof, err2 := provider.GetWorkerIdentityProof()
 		if err2 != nil {
 			err = err2
@@ -89,6 +145,9 @@ func Run(configFile string) (state run.State, err error) {
 		return
 	}
 
+	// the worker identity is now known, so include it in log messages
+	logging.SetRunState(&state)
+
 	// log the worker identity; this is useful for finding the worker in logfiles
 	state.Lock()
 	log.Printf("Identified as worker %s/%s", state.WorkerGroup, state.WorkerID)
@@ -126,7 +185,7 @@ func Run(configFile string) (state run.State, err error) {
 
 	// cache the state if we might end up restarting
 
-	if !runCached && runnercfg.CacheOverRestarts != "" {
+	if !runCached && useCache {
 		err = state.WriteCacheFile(runnercfg.CacheOverRestarts)
 		if err != nil {
 			return
@@ -145,44 +204,95 @@ func Run(configFile string) (state run.State, err error) {
 		}
 	}
 
-	// start
+	// start, restarting the worker if it crashes and supervision is enabled,
+	// or to apply reloaded config
 
-	log.Printf("Starting worker")
-	transp, err := worker.StartWorker(&state)
-	if err != nil {
-		return
-	}
+	sv := newSupervisor(runnercfg.Supervise)
+	rl := reload.New(runnercfg, &state, worker)
+	for {
+		log.Printf("Starting worker")
+		var transp workerproto.Transport
+		transp, err = worker.StartWorker(&state)
+		if err != nil {
+			return
+		}
 
-	// set up protocol
+		// set up protocol
 
-	proto := workerproto.NewProtocol(transp)
+		proto := workerproto.NewProtocol(transp)
 
-	// inform other components about the protocol
-	loggingProtocol.SetProtocol(proto)
-	provider.SetProtocol(proto)
-	worker.SetProtocol(proto)
-	reg.SetProtocol(proto)
-	er.SetProtocol(proto)
-	em.SetProtocol(proto)
+		// inform other components about the protocol
+		loggingProtocol.SetProtocol(proto)
+		provider.SetProtocol(proto)
+		worker.SetProtocol(proto)
+		reg.SetProtocol(proto)
+		er.SetProtocol(proto)
+		em.SetProtocol(proto)
+		sm.SetProtocol(proto)
+		rl.SetProtocol(proto)
 
-	// call the WorkerStarted methods before starting the proto so that there
-	// are no race conditions around the capabilities negotiation
-	err = reg.WorkerStarted()
-	if err != nil {
-		return
-	}
+		// call the WorkerStarted methods before starting the proto so that there
+		// are no race conditions around the capabilities negotiation
+		err = reg.WorkerStarted()
+		if err != nil {
+			return
+		}
 
-	err = provider.WorkerStarted(&state)
-	if err != nil {
-		return
-	}
+		err = provider.WorkerStarted(&state)
+		if err != nil {
+			return
+		}
+
+		sm.WorkerStarted()
+		rl.WorkerStarted()
+		startedAt := time.Now()
+		proto.Start(false)
+
+		// wait for the worker to terminate, first reading everything from the
+		// protocol to capture any output just before the process exited
+		proto.WaitForEOF()
+		err = worker.Wait()
+		sm.WorkerExited(err)
+		rl.WorkerFinished()
+
+		var backoff time.Duration
+		if rl.RestartRequested() {
+			// the worker was asked to stop, so its exit status is expected
+			log.Printf("Worker stopped (%v); restarting with reloaded config", err)
+			err = nil
+		} else if sv.isCrash(worker, err) {
+			var restart bool
+			backoff, restart = sv.crashed(time.Since(startedAt))
+			er.ReportWorkerCrash(err, sv.crashes, restart)
+			if !restart {
+				log.Printf("Worker crashed %d times in a row; giving up", sv.crashes)
+				break
+			}
+			log.Printf("Worker crashed: %v; restarting in %s", err, backoff)
+		} else {
+			break
+		}
+
+		// stop anything started for the previous worker
+		err = provider.WorkerFinished(&state)
+		if err != nil {
+			return
+		}
+
+		err = reg.WorkerFinished()
+		if err != nil {
+			return
+		}
 
-	proto.Start(false)
+		time.Sleep(backoff)
 
-	// wait for the worker to terminate, first reading everything from the
-	// protocol to capture any output just before the process exited
-	proto.WaitForEOF()
-	err = worker.Wait()
+		// reconfigure the worker, so that it starts with the current
+		// credentials, which may have been renewed while it ran
+		err = worker.ConfigureRun(&state)
+		if err != nil {
+			return
+		}
+	}
 	if err != nil {
 		return
 	}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging"
 	loggingCommon "github.com/taskcluster/taskcluster/v84/tools/worker-runner/logging/logging"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/perms"
 )
 
 func buildFakeGenericWorker(workerPath string) error {
@@ -165,3 +167,79 @@ worker:
 
 	require.Equal(t, true, run.WorkerConfig.MustGet("fromFirstRun"))
 }
+
+func TestDummyCacheKeyMissing(t *testing.T) {
+	defer filet.CleanUp(t)
+	dir := filet.TmpDir(t, "")
+	configPath := filepath.Join(dir, "runner.yaml")
+	cachePath := filepath.Join(dir, "cache.json")
+
+	writeConfig := func(cacheEncryption string) {
+		err := os.WriteFile(configPath, fmt.Appendf(nil, `
+provider:
+  providerType: standalone
+  rootURL: https://tc.example.com
+  clientID: fake
+  accessToken: fake
+  workerPoolID: pp/ww
+  workerGroup: wg
+  workerID: wi
+getSecrets: false
+cacheOverRestarts: %s
+cacheEncryption:
+%s
+worker:
+  implementation: dummy
+`, cachePath, cacheEncryption), 0755)
+		require.NoError(t, err)
+	}
+
+	// a cache file from an earlier run
+	require.NoError(t, os.WriteFile(cachePath, []byte("stale"), 0600))
+
+	// the worker still runs if the key file is missing, but does not cache
+	// the run, and removes the earlier cache file
+	writeConfig(fmt.Sprintf("  keyFile: %s", filepath.Join(dir, "missing-key")))
+	run, err := Run(configPath)
+	require.NoError(t, err)
+	require.Equal(t, "fake", run.Credentials.ClientID)
+	require.NoFileExists(t, cachePath)
+
+	// but a bad configuration is still an error
+	writeConfig(fmt.Sprintf("  keyFile: %s\n  tpmSealedKey: /sealed", filepath.Join(dir, "missing-key")))
+	_, err = Run(configPath)
+	require.Error(t, err)
+}
+
+// Workers of providers such as aws can only register once, so they cannot
+// fall back to registering again when the cache cannot be used.
+func TestCacheUnusableWithoutRegisteringAgain(t *testing.T) {
+	defer filet.CleanUp(t)
+	dir := filet.TmpDir(t, "")
+	configPath := filepath.Join(dir, "runner.yaml")
+	cachePath := filepath.Join(dir, "cache.json")
+	keyPath := filepath.Join(dir, "key")
+
+	err := os.WriteFile(configPath, fmt.Appendf(nil, `
+provider:
+  providerType: aws
+getSecrets: false
+cacheOverRestarts: %s
+cacheEncryption:
+  keyFile: %s
+worker:
+  implementation: dummy
+`, cachePath, keyPath), 0755)
+	require.NoError(t, err)
+
+	// the key file is missing
+	_, err = Run(configPath)
+	require.ErrorContains(t, err, "the aws provider cannot register the worker again")
+
+	// the cache cannot be decrypted with the key
+	require.NoError(t, perms.WritePrivateFile(keyPath, []byte(strings.Repeat("k", 32))))
+	require.NoError(t, os.WriteFile(cachePath, []byte("{}"), 0600))
+	_, err = Run(configPath)
+	require.ErrorContains(t, err, "the aws provider cannot register the worker again")
+	require.FileExists(t, cachePath)
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/worker/worker"
+)
+
+// supervisor decides whether, and when, to restart a worker that has crashed.
+// A nil supervisor never restarts the worker.
+type supervisor struct {
+	config *cfg.SupervisionConfig
+
+	// number of consecutive crashes
+	crashes int
+}
+
+func newSupervisor(config *cfg.SupervisionConfig) *supervisor {
+	if config == nil {
+		return nil
+	}
+	return &supervisor{config: config}
+}
+
+// Determine whether the given error from the worker's Wait method indicates
+// a crash.
+func (sv *supervisor) isCrash(w worker.Worker, err error) bool {
+	if sv == nil || err == nil {
+		return false
+	}
+	if cd, ok := w.(worker.CrashDetector); ok {
+		return cd.IsCrash(err)
+	}
+	return true
+}
+
+// Record a crash of a worker that ran for the given duration, returning the
+// time to wait before restarting it, or false if it should not be restarted.
+func (sv *supervisor) crashed(ranFor time.Duration) (time.Duration, bool) {
+	// a worker that ran for a good while before crashing is not crashing
+	// repeatedly, so start counting again
+	if ranFor >= time.Duration(sv.config.ResetAfterSeconds)*time.Second {
+		sv.crashes = 0
+	}
+	sv.crashes++
+	if sv.crashes > sv.config.MaxRestarts {
+		return 0, false
+	}
+
+	backoff := time.Duration(sv.config.InitialBackoffSeconds) * time.Second
+	maxBackoff := time.Duration(sv.config.MaxBackoffSeconds) * time.Second
+	for i := 1; i < sv.crashes && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff), true
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/worker/dummy"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/worker/worker"
+)
+
+// crashDetectingWorker is a worker that only considers "crash" to be a crash
+type crashDetectingWorker struct {
+	worker.Worker
+}
+
+func (w *crashDetectingWorker) IsCrash(err error) bool {
+	return err.Error() == "crash"
+}
+
+func TestSupervisorIsCrash(t *testing.T) {
+	w, err := dummy.New(&cfg.RunnerConfig{})
+	require.NoError(t, err)
+	cdw := &crashDetectingWorker{w}
+
+	var sv *supervisor
+	require.False(t, sv.isCrash(w, errors.New("crash")), "nil supervisor never restarts")
+
+	sv = newSupervisor(&cfg.SupervisionConfig{})
+	require.False(t, sv.isCrash(w, nil), "successful exit")
+	require.True(t, sv.isCrash(w, errors.New("exit status 1")), "any error is a crash by default")
+	require.True(t, sv.isCrash(cdw, errors.New("crash")))
+	require.False(t, sv.isCrash(cdw, errors.New("exit status 67")), "worker decides what is a crash")
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	sv := newSupervisor(&cfg.SupervisionConfig{
+		MaxRestarts:           5,
+		InitialBackoffSeconds: 10,
+		MaxBackoffSeconds:     60,
+		ResetAfterSeconds:     3600,
+	})
+
+	for _, expected := range []time.Duration{10, 20, 40, 60, 60} {
+		backoff, restart := sv.crashed(time.Minute)
+		require.True(t, restart)
+		require.Equal(t, expected*time.Second, backoff)
+	}
+
+	_, restart := sv.crashed(time.Minute)
+	require.False(t, restart, "gives up after maxRestarts")
+
+	// after running for long enough, the count is reset
+	backoff, restart := sv.crashed(2 * time.Hour)
+	require.True(t, restart)
+	require.Equal(t, 10*time.Second, backoff)
+}
@@ -40,6 +40,81 @@ the following fields:
   implementations that restart the system as part of their normal operation
   and expect to start up with the same config after a restart.
 
+* |cacheEncryption|: if set, the |cacheOverRestarts| file is encrypted, as it
+  contains the worker's credentials and other secrets.  The encryption key is
+  derived from a secret of at least 32 bytes, from exactly one of:
+
+  * |keyFile|: a file containing the secret, readable only by the owner.  This
+    is intended for a file on a tmpfs, created at boot, so that the secret is
+    not written to disk.
+
+  * |tpmSealedKey|: the path of a TPM-sealed object containing the secret,
+    which is unsealed with |tpm2_unseal| from tpm2-tools.
+
+  If the file cannot be decrypted, such as when the key has changed, or when
+  it was written without encryption, it is ignored and the worker registers
+  with worker-manager again.  If the secret cannot be read, such as when the
+  tmpfs holding it was not created, any existing file is deleted, the worker
+  registers again, and the run is not cached over restarts.
+
+  Only workers of the |standalone| and |static| providers can register again.
+  Worker-manager's |aws|, |azure| and |google| providers only allow a worker
+  to register once, so with those (and with the |command| and |kubernetes|
+  providers), worker-runner instead fails with an error if the file cannot be
+  decrypted or the secret cannot be read.
+
+* |statusEndpoint|: if set, worker-runner serves a read-only HTTP status
+  endpoint at this address, either |<host>:<port>| or |unix:<path>| for a
+  Unix socket.  This is intended for node-level health checks, so it should
+  not be exposed beyond the host; prefer a Unix socket or |localhost|.  |GET
+  /status| returns a JSON description of the worker's identity, credential
+  expiration and renewal time (but not the credentials themselves), the
+  negotiated protocol capabilities, the last message received from the
+  worker, the state of the worker process, and (for workers that support the
+  |task-claimed|, |task-resolved| and |idle| messages) the running and most
+  recently resolved tasks.  |GET /health| returns 200 if
+  the worker process is running and its credentials have not expired, and 503
+  otherwise.
+
+* |supervise|: if set, worker-runner restarts the worker process when it
+  crashes, rather than exiting.  The worker is restarted with the same state
+  and credentials, without re-registering, and each crash is reported to
+  worker-manager as a worker error.  Use |supervise: {}| to enable this with
+  the defaults.  Which exits are crashes depends on the worker implementation:
+  for generic-worker, these are an internal error (exit code 69), an
+  unrecovered panic (exit code 2), or termination by a signal, but not
+  deliberate exits such as for a reboot or an idle timeout.  For other
+  workers, any unsuccessful exit is a crash.
+
+  * |maxRestarts|: the number of consecutive crashes after which
+    worker-runner gives up and exits (default 5).
+
+  * |initialBackoffSeconds|: the time to wait before restarting after the
+    first crash; this doubles with each consecutive crash (default 10).
+
+  * |maxBackoffSeconds|: the maximum time to wait before restarting (default
+    600).
+
+  * |resetAfterSeconds|: if the worker runs for at least this long before
+    crashing, the crash is not considered consecutive with earlier crashes
+    (default 3600).
+
+* |configReloadIntervalSeconds|: if set, worker-runner re-fetches the worker
+  configuration at this interval while the worker is running.  The
+  configuration comes from the worker pool's secrets (if |getSecrets| is true)
+  and, for the |static| provider, the worker pool's |workerConfig|, which
+  requires the |worker-manager:get-worker-pool:<workerPoolId>| scope.  If the
+  configuration has changed, worker-runner sends the changed properties to
+  the worker in a |config-update| message, if the worker supports it and can
+  apply all of them while running.  Otherwise, worker-runner asks the worker
+  to stop after its current tasks, and starts it again with the new
+  configuration.  Changes to files are not applied.  Generic-worker can apply
+  changes to |checkForNewDeploymentEverySecs|, |disableReboots|,
+  |idleTimeoutSecs|, |maxTaskRunTime|, |numberOfTasksToRun|,
+  |requiredDiskSpaceMegabytes|, |shutdownMachineOnIdle|,
+  |shutdownMachineOnInternalError|, and |taskTerminationGracePeriodSecs|
+  between tasks.
+
 **NOTE** for Windows users: the configuration file must be a UNIX-style text file.
 DOS-style newlines and encodings other than utf-8 are not supported.`, "|", "`")
 }
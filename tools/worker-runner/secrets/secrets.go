@@ -27,15 +27,37 @@ func configureRun(runnercfg *cfg.RunnerConfig, state *run.State, secretsClientFa
 	state.Lock()
 	defer state.Unlock()
 
-	secretsClient, err := secretsClientFactory(state.RootURL, &state.Credentials)
+	secretConfig, err := fetchWorkerConfig(state.RootURL, &state.Credentials, state.WorkerPoolID, secretsClientFactory)
 	if err != nil {
 		return err
 	}
 
+	if secretConfig == nil {
+		log.Printf("WARNING: No worker secrets for worker pool %v.", state.WorkerPoolID)
+		return nil
+	}
+
+	state.WorkerConfig = state.WorkerConfig.Merge(secretConfig)
+	state.SecretWorkerConfig = secretConfig
+	return nil
+}
+
+// FetchWorkerConfig gets the worker config from the worker pool's secrets,
+// without modifying the state.  It returns nil if there are no such secrets.
+func FetchWorkerConfig(rootURL string, credentials *tcclient.Credentials, workerPoolID string) (*cfg.WorkerConfig, error) {
+	return fetchWorkerConfig(rootURL, credentials, workerPoolID, clientFactory)
+}
+
+func fetchWorkerConfig(rootURL string, credentials *tcclient.Credentials, workerPoolID string, secretsClientFactory tc.SecretsClientFactory) (*cfg.WorkerConfig, error) {
+	secretsClient, err := secretsClientFactory(rootURL, credentials)
+	if err != nil {
+		return nil, err
+	}
+
 	// Consult secrets named both `worker-type:..` and (preferred) `worker-pool:..`.
-	found := false
+	var secretConfig *cfg.WorkerConfig
 	for _, prefix := range []string{"worker-type:", "worker-pool:"} {
-		secretName := prefix + state.WorkerPoolID
+		secretName := prefix + workerPoolID
 		secResponse, err := secretsClient.Get(secretName)
 		if err != nil {
 			if apiCallException, isAPICallException := err.(*tcclient.APICallException); isAPICallException {
@@ -57,7 +79,7 @@ func configureRun(runnercfg *cfg.RunnerConfig, state *run.State, secretsClientFa
 					}
 				}
 			}
-			return err
+			return nil, err
 		}
 
 		// some secrets contain raw configuration, while others contain the preferred {config: .., files: ..}.  If we have
@@ -74,20 +96,16 @@ func configureRun(runnercfg *cfg.RunnerConfig, state *run.State, secretsClientFa
 			log.Printf("Falling back to legacy secret format without top-level config/files properties")
 			err := json.Unmarshal(secResponse.Secret, &secret.Config)
 			if err != nil {
-				return fmt.Errorf("secret value is not a JSON object")
+				return nil, fmt.Errorf("secret value is not a JSON object")
 			}
 		}
 
-		found = true
-		state.WorkerConfig = state.WorkerConfig.Merge(secret.Config)
+		secretConfig = secretConfig.Merge(secret.Config)
 
 		if len(secret.Files) != 0 {
-			return fmt.Errorf("secret files are nonempty - files are not supported yet")
+			return nil, fmt.Errorf("secret files are nonempty - files are not supported yet")
 		}
 	}
 
-	if !found {
-		log.Printf("WARNING: No worker secrets for worker pool %v.", state.WorkerPoolID)
-	}
-	return nil
+	return secretConfig, nil
 }
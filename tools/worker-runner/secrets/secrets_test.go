@@ -86,6 +86,20 @@ func TestGetSecretConfigFilesFormat(t *testing.T) {
 	assert.NoError(t, err, "expected great success")
 	assert.Equal(t, true, state.WorkerConfig.MustGet("from-runner-cfg"), "value for from-runner-cfg")
 	assert.Equal(t, true, state.WorkerConfig.MustGet("from-secret"), "value for from-secret")
+	assert.Equal(t, []string{"from-secret"}, state.SecretWorkerConfig.Diff(nil), "secret config is kept")
+}
+
+func TestFetchWorkerConfig(t *testing.T) {
+	_, state := setup(t)
+
+	tc.FakeSecretsCreateSecret("worker-pool:pp/wt", &tcsecrets.Secret{
+		Secret: []byte(`{"config": {"from-secret": true}}`),
+	})
+
+	secretConfig, err := fetchWorkerConfig(state.RootURL, &state.Credentials, state.WorkerPoolID, tc.FakeSecretsClientFactory)
+	assert.NoError(t, err, "expected great success")
+	assert.Equal(t, true, secretConfig.MustGet("from-secret"), "value for from-secret")
+	assert.Equal(t, false, state.WorkerConfig.MustGet("from-secret"), "state is not modified")
 }
 
 func TestGetSecretNotFound(t *testing.T) {
@@ -95,4 +109,5 @@ func TestGetSecretNotFound(t *testing.T) {
 	assert.NoError(t, err, "expected great success")
 	assert.Equal(t, true, state.WorkerConfig.MustGet("from-runner-cfg"), "value for from-runner-cfg")
 	assert.Equal(t, false, state.WorkerConfig.MustGet("from-secret"), "value for from-secret (not found)")
+	assert.Nil(t, state.SecretWorkerConfig, "no secret config")
 }
@@ -0,0 +1,403 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taskcluster/taskcluster/v84/internal"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/registration"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
+)
+
+// states of the worker process
+const (
+	WorkerNotStarted = "not-started"
+	WorkerRunning    = "running"
+	WorkerExited     = "exited"
+)
+
+// Status is the response from the status endpoint.  It deliberately omits
+// any secrets, such as the worker's access token or registration secret.
+type Status struct {
+	Version     string      `json:"version"`
+	Identity    Identity    `json:"identity"`
+	Credentials Credentials `json:"credentials"`
+	Protocol    Protocol    `json:"protocol"`
+	Worker      Worker      `json:"worker"`
+	Tasks       Tasks       `json:"tasks"`
+}
+
+// Identity describes this worker
+type Identity struct {
+	RootURL          string            `json:"rootURL"`
+	ProviderID       string            `json:"providerId"`
+	WorkerPoolID     string            `json:"workerPoolId"`
+	WorkerGroup      string            `json:"workerGroup"`
+	WorkerID         string            `json:"workerId"`
+	WorkerLocation   map[string]string `json:"workerLocation"`
+	ProviderMetadata map[string]any    `json:"providerMetadata"`
+}
+
+// Credentials describes the worker's Taskcluster credentials
+type Credentials struct {
+	ClientID string `json:"clientId"`
+	// expiration time of the credentials, if they expire
+	Expires *time.Time `json:"expires,omitempty"`
+	// seconds until the credentials expire, if they expire
+	SecondsUntilExpiry *int64 `json:"secondsUntilExpiry,omitempty"`
+	// time at which the credentials will be renewed, if that is scheduled
+	RenewAt *time.Time `json:"renewAt,omitempty"`
+}
+
+// Protocol describes the state of the worker-runner protocol
+type Protocol struct {
+	Initialized  bool     `json:"initialized"`
+	Capabilities []string `json:"capabilities"`
+	// the most recent message received from the worker
+	LastMessage *Message `json:"lastMessage,omitempty"`
+	// the time of the most recent message of each type
+	LastReceived map[string]time.Time `json:"lastReceived"`
+}
+
+// Message describes a message received from the worker
+type Message struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+}
+
+// Worker describes the worker process
+type Worker struct {
+	State     string     `json:"state"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	ExitedAt  *time.Time `json:"exitedAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// the number of times the worker has been restarted after crashing
+	Restarts int `json:"restarts"`
+}
+
+// Tasks describes the tasks run by the worker, as reported by the worker
+// using the task-claimed, task-resolved and idle messages.
+type Tasks struct {
+	// false if the worker does not report task lifecycle messages
+	Reported bool `json:"reported"`
+	// tasks claimed but not yet resolved
+	Running []Task `json:"running"`
+	// the most recently resolved task
+	LastResolved *Task `json:"lastResolved,omitempty"`
+	// the number of tasks resolved by this worker process
+	ResolvedCount int `json:"resolvedCount"`
+	// the time since which the worker has been idle, if it is idle
+	IdleSince *time.Time `json:"idleSince,omitempty"`
+}
+
+// Task describes a task run
+type Task struct {
+	TaskID     string     `json:"taskId"`
+	RunID      int        `json:"runId"`
+	ClaimedAt  time.Time  `json:"claimedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	// the duration of the task run, in seconds, as measured by the worker
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// Health is the response from the health endpoint
+type Health struct {
+	Healthy bool     `json:"healthy"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// StatusManager serves the status endpoint, if one is configured, and keeps
+// track of the information it reports.
+type StatusManager struct {
+	state    *run.State
+	listener net.Listener
+	server   *http.Server
+
+	mutex  sync.Mutex
+	proto  *workerproto.Protocol
+	reg    *registration.RegistrationManager
+	worker Worker
+	tasks  Tasks
+}
+
+// Make a new StatusManager, and start serving the status endpoint if
+// runnercfg.StatusEndpoint is set.
+func New(runnercfg *cfg.RunnerConfig, state *run.State) (*StatusManager, error) {
+	sm := &StatusManager{
+		state:  state,
+		worker: Worker{State: WorkerNotStarted},
+	}
+
+	if runnercfg.StatusEndpoint == "" {
+		return sm, nil
+	}
+
+	listener, err := listen(runnercfg.StatusEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on status endpoint %s: %w", runnercfg.StatusEndpoint, err)
+	}
+	sm.listener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", sm.handleStatus)
+	mux.HandleFunc("GET /status", sm.handleStatus)
+	mux.HandleFunc("GET /health", sm.handleHealth)
+	sm.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Serving status on %s", runnercfg.StatusEndpoint)
+	go func() {
+		err := sm.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Error serving status endpoint: %v", err)
+		}
+	}()
+
+	return sm, nil
+}
+
+// listen on the given endpoint, either `unix:<path>` or `<host>:<port>`
+func listen(endpoint string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(endpoint, "unix:"); ok {
+		// remove any socket left over from a previous run
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", endpoint)
+}
+
+// Addr returns the address on which the status endpoint is listening, or nil
+func (sm *StatusManager) Addr() net.Addr {
+	if sm.listener == nil {
+		return nil
+	}
+	return sm.listener.Addr()
+}
+
+func (sm *StatusManager) SetProtocol(proto *workerproto.Protocol) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.proto = proto
+
+	proto.Register("task-claimed", sm.handleTaskClaimed)
+	proto.AddCapability("task-claimed")
+	proto.Register("task-resolved", sm.handleTaskResolved)
+	proto.AddCapability("task-resolved")
+	proto.Register("idle", sm.handleIdle)
+	proto.AddCapability("idle")
+}
+
+func (sm *StatusManager) handleTaskClaimed(msg workerproto.Message) {
+	taskID, _ := msg.Properties["task-id"].(string)
+	runID, _ := msg.Properties["run-id"].(float64)
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.tasks.Reported = true
+	sm.tasks.IdleSince = nil
+	sm.tasks.Running = append(sm.tasks.Running, Task{
+		TaskID:    taskID,
+		RunID:     int(runID),
+		ClaimedAt: time.Now(),
+	})
+}
+
+func (sm *StatusManager) handleTaskResolved(msg workerproto.Message) {
+	taskID, _ := msg.Properties["task-id"].(string)
+	runID, _ := msg.Properties["run-id"].(float64)
+	now := time.Now()
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.tasks.Reported = true
+
+	task := Task{TaskID: taskID, RunID: int(runID)}
+	for i, running := range sm.tasks.Running {
+		if running.TaskID == task.TaskID && running.RunID == task.RunID {
+			task = running
+			sm.tasks.Running = append(sm.tasks.Running[:i], sm.tasks.Running[i+1:]...)
+			break
+		}
+	}
+	task.ResolvedAt = &now
+	task.Status, _ = msg.Properties["status"].(string)
+	task.Reason, _ = msg.Properties["reason"].(string)
+	task.Duration, _ = msg.Properties["duration"].(float64)
+
+	sm.tasks.LastResolved = &task
+	sm.tasks.ResolvedCount++
+}
+
+func (sm *StatusManager) handleIdle(msg workerproto.Message) {
+	idleSince := time.Now()
+	if since, ok := msg.Properties["idle-since"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			idleSince = t
+		}
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.tasks.Reported = true
+	sm.tasks.IdleSince = &idleSince
+}
+
+func (sm *StatusManager) SetRegistration(reg *registration.RegistrationManager) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.reg = reg
+}
+
+// The worker process has started
+func (sm *StatusManager) WorkerStarted() {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	now := time.Now()
+	restarts := 0
+	if sm.worker.State != WorkerNotStarted {
+		restarts = sm.worker.Restarts + 1
+	}
+	sm.worker = Worker{
+		State:     WorkerRunning,
+		StartedAt: &now,
+		Restarts:  restarts,
+	}
+}
+
+// The worker process has exited, with the given error from waiting for it
+func (sm *StatusManager) WorkerExited(err error) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	now := time.Now()
+	sm.worker.State = WorkerExited
+	sm.worker.ExitedAt = &now
+	if err != nil {
+		sm.worker.Error = err.Error()
+	}
+}
+
+// Stop serving the status endpoint
+func (sm *StatusManager) Close() error {
+	if sm.server == nil {
+		return nil
+	}
+	return sm.server.Close()
+}
+
+// Get the current status
+func (sm *StatusManager) Status() Status {
+	status := Status{Version: internal.Version}
+
+	sm.state.RLock()
+	status.Identity = Identity{
+		RootURL:          sm.state.RootURL,
+		ProviderID:       sm.state.ProviderID,
+		WorkerPoolID:     sm.state.WorkerPoolID,
+		WorkerGroup:      sm.state.WorkerGroup,
+		WorkerID:         sm.state.WorkerID,
+		WorkerLocation:   maps.Clone(sm.state.WorkerLocation),
+		ProviderMetadata: maps.Clone(sm.state.ProviderMetadata),
+	}
+	status.Credentials.ClientID = sm.state.Credentials.ClientID
+	sm.state.RUnlock()
+
+	sm.mutex.Lock()
+	proto := sm.proto
+	reg := sm.reg
+	status.Worker = sm.worker
+	status.Tasks = sm.tasks
+	status.Tasks.Running = slices.Clone(sm.tasks.Running)
+	sm.mutex.Unlock()
+	if status.Tasks.Running == nil {
+		status.Tasks.Running = []Task{}
+	}
+
+	if reg != nil {
+		rs := reg.Status()
+		if !rs.CredentialsExpire.IsZero() {
+			seconds := int64(rs.UntilExpires / time.Second)
+			status.Credentials.Expires = &rs.CredentialsExpire
+			status.Credentials.SecondsUntilExpiry = &seconds
+		}
+		if !rs.RenewAt.IsZero() {
+			status.Credentials.RenewAt = &rs.RenewAt
+		}
+	}
+
+	status.Protocol.Capabilities = []string{}
+	status.Protocol.LastReceived = map[string]time.Time{}
+	if proto != nil {
+		status.Protocol.Initialized = proto.Initialized()
+		if caps := proto.Capabilities(); caps != nil {
+			status.Protocol.Capabilities = caps
+		}
+		status.Protocol.LastReceived = proto.LastReceived()
+		for ty, t := range status.Protocol.LastReceived {
+			if status.Protocol.LastMessage == nil || t.After(status.Protocol.LastMessage.Time) {
+				status.Protocol.LastMessage = &Message{Type: ty, Time: t}
+			}
+		}
+	}
+
+	return status
+}
+
+// Get the health of the worker, based on the given status.  The worker is
+// healthy if its process is running and its credentials have not expired.
+func (status *Status) Health() Health {
+	health := Health{Healthy: true}
+	unhealthy := func(reason string) {
+		health.Healthy = false
+		health.Reasons = append(health.Reasons, reason)
+	}
+
+	if status.Worker.State != WorkerRunning {
+		unhealthy(fmt.Sprintf("worker is %s", status.Worker.State))
+	}
+	if status.Credentials.Expires != nil && !status.Credentials.Expires.After(time.Now()) {
+		unhealthy("credentials have expired")
+	}
+	return health
+}
+
+func (sm *StatusManager) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sm.Status())
+}
+
+func (sm *StatusManager) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := sm.Status()
+	health := status.Health()
+	code := http.StatusOK
+	if !health.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, health)
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("Error writing status response: %v", err)
+	}
+}
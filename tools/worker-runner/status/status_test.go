@@ -0,0 +1,273 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/registration"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
+	ptesting "github.com/taskcluster/taskcluster/v84/tools/workerproto/testing"
+)
+
+func makeState() *run.State {
+	return &run.State{
+		RootURL: "https://tc.example.com",
+		Credentials: tcclient.Credentials{
+			ClientID:    "worker/w/p/wg/wi",
+			AccessToken: "sekrit-token",
+			Certificate: "sekrit-cert",
+		},
+		RegistrationSecret: "sekrit-secret",
+		WorkerPoolID:       "w/p",
+		WorkerGroup:        "wg",
+		WorkerID:           "wi",
+		ProviderID:         "prov",
+		WorkerLocation:     map[string]string{"cloud": "somewhere"},
+		ProviderMetadata:   map[string]any{"instance-type": "large"},
+	}
+}
+
+// get the given path from the status manager and decode the JSON response
+func get(t *testing.T, client *http.Client, url string, out any) int {
+	t.Helper()
+	res, err := client.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(res.Body).Decode(out))
+	return res.StatusCode
+}
+
+func TestNoEndpoint(t *testing.T) {
+	sm, err := New(&cfg.RunnerConfig{}, makeState())
+	require.NoError(t, err)
+	require.Nil(t, sm.Addr())
+	require.NoError(t, sm.Close())
+
+	status := sm.Status()
+	require.Equal(t, "wi", status.Identity.WorkerID)
+	require.Equal(t, WorkerNotStarted, status.Worker.State)
+	require.Equal(t, []string{}, status.Protocol.Capabilities)
+}
+
+func TestStatus(t *testing.T) {
+	state := makeState()
+	state.CredentialsExpire = tcclient.Time(time.Now().Add(90 * time.Minute))
+	runnercfg := &cfg.RunnerConfig{StatusEndpoint: "127.0.0.1:0"}
+
+	sm, err := New(runnercfg, state)
+	require.NoError(t, err)
+	defer sm.Close()
+	baseURL := "http://" + sm.Addr().String()
+
+	reg := registration.New(runnercfg, state)
+	sm.SetRegistration(reg)
+
+	wkr := ptesting.NewFakeWorkerWithCapabilities("error-report")
+	defer wkr.Close()
+	wkr.RunnerProtocol.Register("error-report", func(msg workerproto.Message) {})
+	wkr.RunnerProtocol.AddCapability("error-report")
+	wkr.RunnerProtocol.AddCapability("shutdown")
+	sm.SetProtocol(wkr.RunnerProtocol)
+
+	var health Health
+	code := get(t, http.DefaultClient, baseURL+"/health", &health)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, Health{Healthy: false, Reasons: []string{"worker is not-started"}}, health)
+
+	sm.WorkerStarted()
+	wkr.RunnerProtocol.Start(false)
+	wkr.RunnerProtocol.WaitUntilInitialized()
+	wkr.WorkerProtocol.Send(workerproto.Message{Type: "error-report"})
+	require.Eventually(t, func() bool {
+		return len(wkr.RunnerProtocol.LastReceived()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	health = Health{}
+	code = get(t, http.DefaultClient, baseURL+"/health", &health)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, Health{Healthy: true}, health)
+
+	for _, path := range []string{"/", "/status"} {
+		t.Run(path, func(t *testing.T) {
+			var raw map[string]any
+			code := get(t, http.DefaultClient, baseURL+path, &raw)
+			require.Equal(t, http.StatusOK, code)
+
+			// no secrets appear in the response
+			encoded, err := json.Marshal(raw)
+			require.NoError(t, err)
+			require.NotContains(t, string(encoded), "sekrit")
+
+			var status Status
+			require.NoError(t, json.Unmarshal(encoded, &status))
+			require.Equal(t, Identity{
+				RootURL:          "https://tc.example.com",
+				ProviderID:       "prov",
+				WorkerPoolID:     "w/p",
+				WorkerGroup:      "wg",
+				WorkerID:         "wi",
+				WorkerLocation:   map[string]string{"cloud": "somewhere"},
+				ProviderMetadata: map[string]any{"instance-type": "large"},
+			}, status.Identity)
+
+			require.Equal(t, "worker/w/p/wg/wi", status.Credentials.ClientID)
+			require.WithinDuration(t, time.Time(state.CredentialsExpire), *status.Credentials.Expires, time.Second)
+			require.InDelta(t, 90*60, *status.Credentials.SecondsUntilExpiry, 5)
+			require.Nil(t, status.Credentials.RenewAt, "registration timer not started")
+
+			require.True(t, status.Protocol.Initialized)
+			require.Equal(t, []string{"error-report"}, status.Protocol.Capabilities)
+			require.Equal(t, "error-report", status.Protocol.LastMessage.Type)
+			require.Contains(t, status.Protocol.LastReceived, "hello")
+
+			require.Equal(t, WorkerRunning, status.Worker.State)
+			require.NotNil(t, status.Worker.StartedAt)
+			require.Nil(t, status.Worker.ExitedAt)
+		})
+	}
+
+	sm.WorkerExited(errors.New("exit status 1"))
+
+	status := sm.Status()
+	require.Equal(t, WorkerExited, status.Worker.State)
+	require.NotNil(t, status.Worker.ExitedAt)
+	require.Equal(t, "exit status 1", status.Worker.Error)
+
+	health = Health{}
+	code = get(t, http.DefaultClient, baseURL+"/health", &health)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, []string{"worker is exited"}, health.Reasons)
+
+	// a restarted worker is running again
+	sm.WorkerStarted()
+	status = sm.Status()
+	require.Equal(t, WorkerRunning, status.Worker.State)
+	require.Equal(t, 1, status.Worker.Restarts)
+	require.Equal(t, "", status.Worker.Error)
+}
+
+func TestHealthExpiredCredentials(t *testing.T) {
+	expires := time.Now().Add(-time.Minute)
+	status := Status{
+		Worker:      Worker{State: WorkerRunning},
+		Credentials: Credentials{Expires: &expires},
+	}
+	require.Equal(t, Health{Healthy: false, Reasons: []string{"credentials have expired"}}, status.Health())
+}
+
+func TestUnixSocket(t *testing.T) {
+	// use a short directory name, as socket paths are limited in length
+	dir, err := os.MkdirTemp("", "status")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "status.sock")
+
+	// a stale socket file is replaced
+	require.NoError(t, os.WriteFile(socketPath, []byte{}, 0600))
+
+	sm, err := New(&cfg.RunnerConfig{StatusEndpoint: "unix:" + socketPath}, makeState())
+	require.NoError(t, err)
+	defer sm.Close()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	var status Status
+	code := get(t, client, "http://status/status", &status)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "wi", status.Identity.WorkerID)
+}
+
+func TestBadEndpoint(t *testing.T) {
+	_, err := New(&cfg.RunnerConfig{StatusEndpoint: "not an address"}, makeState())
+	require.Error(t, err)
+}
+
+func TestTasks(t *testing.T) {
+	sm, err := New(&cfg.RunnerConfig{}, makeState())
+	require.NoError(t, err)
+
+	wkr := ptesting.NewFakeWorkerWithCapabilities("task-claimed", "task-resolved", "idle")
+	defer wkr.Close()
+	sm.SetProtocol(wkr.RunnerProtocol)
+	wkr.RunnerProtocol.Start(false)
+	wkr.RunnerProtocol.WaitUntilInitialized()
+
+	status := sm.Status()
+	require.Equal(t, []string{"idle", "task-claimed", "task-resolved"}, status.Protocol.Capabilities)
+	require.Equal(t, Tasks{Running: []Task{}}, status.Tasks)
+
+	// wait until the status manager has seen the given number of messages of the given type
+	waitFor := func(msgType string, count int) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			status := sm.Status()
+			switch msgType {
+			case "task-claimed":
+				return len(status.Tasks.Running) == count
+			case "task-resolved":
+				return status.Tasks.ResolvedCount == count
+			default:
+				return status.Tasks.IdleSince != nil
+			}
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	wkr.WorkerProtocol.Send(workerproto.Message{
+		Type:       "idle",
+		Properties: map[string]any{"idle-since": "2025-01-02T03:04:05Z"},
+	})
+	waitFor("idle", 1)
+	require.Equal(t, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), *sm.Status().Tasks.IdleSince)
+
+	wkr.WorkerProtocol.Send(workerproto.Message{
+		Type:       "task-claimed",
+		Properties: map[string]any{"task-id": "abc", "run-id": 1},
+	})
+	waitFor("task-claimed", 1)
+
+	status = sm.Status()
+	require.True(t, status.Tasks.Reported)
+	require.Nil(t, status.Tasks.IdleSince, "no longer idle")
+	require.Equal(t, "abc", status.Tasks.Running[0].TaskID)
+	require.Equal(t, 1, status.Tasks.Running[0].RunID)
+
+	wkr.WorkerProtocol.Send(workerproto.Message{
+		Type: "task-resolved",
+		Properties: map[string]any{
+			"task-id":  "abc",
+			"run-id":   1,
+			"status":   "exception",
+			"reason":   "malformed-payload",
+			"duration": 12.5,
+		},
+	})
+	waitFor("task-resolved", 1)
+
+	status = sm.Status()
+	require.Equal(t, []Task{}, status.Tasks.Running)
+	last := status.Tasks.LastResolved
+	require.Equal(t, "abc", last.TaskID)
+	require.Equal(t, "exception", last.Status)
+	require.Equal(t, "malformed-payload", last.Reason)
+	require.Equal(t, 12.5, last.Duration)
+	require.False(t, last.ClaimedAt.IsZero())
+	require.NotNil(t, last.ResolvedAt)
+}
@@ -25,6 +25,9 @@ var (
 	// the secret reregisterWorker will look for.  This is set by registerWorker but can
 	// also be manipulated by test code
 	workerSecret string
+
+	// the config of the worker pool returned by workerPool
+	workerPoolConfig json.RawMessage
 )
 
 type removedWorker struct {
@@ -117,6 +120,17 @@ func (wm *FakeWorkerManager) ReportWorkerError(workerPoolID string, payload *tcw
 	return &workerPoolError, nil
 }
 
+func (wm *FakeWorkerManager) WorkerPool(workerPoolID string) (*tcworkermanager.WorkerPoolFullDefinition, error) {
+	if !wm.authenticated {
+		return nil, fmt.Errorf("must use an authenticated client to get a worker pool")
+	}
+
+	return &tcworkermanager.WorkerPoolFullDefinition{
+		WorkerPoolID: workerPoolID,
+		Config:       workerPoolConfig,
+	}, nil
+}
+
 func FakeWorkerManagerWorkerErrorReports() ([]*tcworkermanager.WorkerErrorReport, error) {
 	wmWorkerErrorReportsLock.Lock()
 	defer wmWorkerErrorReportsLock.Unlock()
@@ -173,6 +187,10 @@ func SetFakeWorkerManagerWorkerSecret(secret string) {
 	workerSecret = secret
 }
 
+func SetFakeWorkerManagerWorkerPoolConfig(config json.RawMessage) {
+	workerPoolConfig = config
+}
+
 func GetFakeWorkerManagerWorkerSecret() string {
 	return workerSecret
 }
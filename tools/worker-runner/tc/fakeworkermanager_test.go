@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
 	"github.com/taskcluster/taskcluster/v84/clients/client-go/tcworkermanager"
 )
 
@@ -25,3 +26,18 @@ func TestWorkerManagerRemoveWorker(t *testing.T) {
 	wm, _ := FakeWorkerManagerClientFactory("https://tc.example.com", nil)
 	require.NoError(t, wm.RemoveWorker("w/p", "wg", "wid"))
 }
+
+func TestWorkerManagerWorkerPool(t *testing.T) {
+	SetFakeWorkerManagerWorkerPoolConfig(json.RawMessage(`{"workerConfig": {}}`))
+	defer SetFakeWorkerManagerWorkerPoolConfig(nil)
+
+	wm, _ := FakeWorkerManagerClientFactory("https://tc.example.com", nil)
+	_, err := wm.WorkerPool("w/p")
+	require.Error(t, err)
+
+	wm, _ = FakeWorkerManagerClientFactory("https://tc.example.com", &tcclient.Credentials{ClientID: "c"})
+	wp, err := wm.WorkerPool("w/p")
+	require.NoError(t, err)
+	require.Equal(t, "w/p", wp.WorkerPoolID)
+	require.JSONEq(t, `{"workerConfig": {}}`, string(wp.Config))
+}
@@ -12,6 +12,7 @@ type WorkerManager interface {
 	ReportWorkerError(workerPoolID string, payload *tcworkermanager.WorkerErrorReport) (*tcworkermanager.WorkerPoolError, error)
 	ReregisterWorker(payload *tcworkermanager.ReregisterWorkerRequest) (*tcworkermanager.ReregisterWorkerResponse, error)
 	RemoveWorker(workerPoolID, workerGroup, workerID string) error
+	WorkerPool(workerPoolID string) (*tcworkermanager.WorkerPoolFullDefinition, error)
 }
 
 // A factory type that can create new instances of the WorkerManager interface.
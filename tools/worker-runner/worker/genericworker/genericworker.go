@@ -2,9 +2,12 @@ package genericworker
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
@@ -146,6 +149,42 @@ func (d *genericworker) Wait() error {
 	return d.runMethod.wait()
 }
 
+// generic-worker uses its exit code to indicate why it exited, and most exit
+// codes are deliberate.  A crash is an INTERNAL_ERROR (69), from which
+// generic-worker exits after recovering from a panic; an exit status of 2, from
+// an unrecovered panic; or termination by a signal.  Errors other than exit
+// statuses, such as from monitoring a Windows service, are not crashes.
+func (d *genericworker) IsCrash(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	switch exitErr.ExitCode() {
+	case -1, 2, 69:
+		return true
+	}
+	return false
+}
+
+// The config properties that generic-worker can apply between tasks when it
+// receives a config-update message.  This must match the list in
+// generic-worker, which TestHotReloadableMatchesGenericWorker checks.
+var hotReloadableConfig = []string{
+	"checkForNewDeploymentEverySecs",
+	"disableReboots",
+	"idleTimeoutSecs",
+	"maxTaskRunTime",
+	"numberOfTasksToRun",
+	"requiredDiskSpaceMegabytes",
+	"shutdownMachineOnIdle",
+	"shutdownMachineOnInternalError",
+	"taskTerminationGracePeriodSecs",
+}
+
+func (d *genericworker) HotReloadable(key string) bool {
+	return slices.Contains(hotReloadableConfig, key)
+}
+
 func New(runnercfg *cfg.RunnerConfig) (worker.Worker, error) {
 	rv := genericworker{runnercfg, genericworkerConfig{}, nil}
 	err := runnercfg.WorkerImplementation.Unpack(&rv.wicfg)
@@ -1,14 +1,34 @@
 package genericworker
 
 import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	taskcluster "github.com/taskcluster/taskcluster/v84/clients/client-go"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/cfg"
 	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/run"
+	"github.com/taskcluster/taskcluster/v84/tools/worker-runner/worker/worker"
 )
 
+// TestMain allows the test binary to act as a worker that exits with the exit
+// code given in FAKE_GENERIC_WORKER_EXIT.
+func TestMain(m *testing.M) {
+	if code, ok := os.LookupEnv("FAKE_GENERIC_WORKER_EXIT"); ok {
+		exitCode, _ := strconv.Atoi(code)
+		os.Exit(exitCode)
+	}
+	os.Exit(m.Run())
+}
+
 func CallConfigureRun(t *testing.T, state *run.State) {
 	t.Helper()
 	runnercfg := &cfg.RunnerConfig{
@@ -117,3 +137,53 @@ func TestConfigureRunWithoutProviderMetadataConfig(t *testing.T) {
 	require.Equal(t, "cert", state.WorkerConfig.MustGet("certificate"))
 	require.Equal(t, map[string]any{}, state.WorkerConfig.MustGet("workerTypeMetadata"))
 }
+
+func TestIsCrash(t *testing.T) {
+	gw := &genericworker{}
+
+	// exitWith runs the test binary, which exits with the given code (see TestMain)
+	exitWith := func(code int) error {
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), fmt.Sprintf("FAKE_GENERIC_WORKER_EXIT=%d", code))
+		return cmd.Run()
+	}
+
+	require.True(t, gw.IsCrash(exitWith(69)), "INTERNAL_ERROR")
+	require.True(t, gw.IsCrash(exitWith(2)), "unrecovered panic")
+	require.False(t, gw.IsCrash(exitWith(67)), "REBOOT_REQUIRED")
+	require.False(t, gw.IsCrash(exitWith(68)), "IDLE_TIMEOUT")
+	require.False(t, gw.IsCrash(errors.New("error querying service")))
+}
+
+func TestHotReloadable(t *testing.T) {
+	var w worker.Worker = &genericworker{}
+	cr, ok := w.(worker.ConfigReloader)
+	require.True(t, ok)
+	require.True(t, cr.HotReloadable("idleTimeoutSecs"))
+	require.False(t, cr.HotReloadable("cachesDir"))
+	require.False(t, cr.HotReloadable("rootURL"))
+}
+
+// generic-worker keeps its own copy of hotReloadableConfig, as it is a
+// separate program, so check that the two lists match.
+func TestHotReloadableMatchesGenericWorker(t *testing.T) {
+	filename := filepath.Join("..", "..", "..", "..", "workers", "generic-worker", "workerrunner.go")
+	file, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+	require.NoError(t, err)
+
+	var gwConfig []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || len(spec.Names) != 1 || spec.Names[0].Name != "hotReloadableConfig" {
+			return true
+		}
+		for _, elt := range spec.Values[0].(*ast.CompositeLit).Elts {
+			key, err := strconv.Unquote(elt.(*ast.BasicLit).Value)
+			require.NoError(t, err)
+			gwConfig = append(gwConfig, key)
+		}
+		return false
+	})
+	require.NotEmpty(t, gwConfig, "hotReloadableConfig not found in %s", filename)
+	require.ElementsMatch(t, hotReloadableConfig, gwConfig)
+}
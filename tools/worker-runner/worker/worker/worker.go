@@ -27,3 +27,23 @@ type Worker interface {
 	// Wait for the worker to terminate
 	Wait() error
 }
+
+// CrashDetector can optionally be implemented by a Worker to distinguish a
+// crash from a deliberate exit, such as for a reboot or an idle timeout.  When
+// supervision is enabled, only crashes cause the worker to be restarted.
+// Workers that do not implement this interface are considered to have crashed
+// whenever Wait returns an error.
+type CrashDetector interface {
+	// Determine whether the given error, returned from Wait, indicates a crash
+	IsCrash(err error) bool
+}
+
+// ConfigReloader can optionally be implemented by a Worker that can apply some
+// changes to its configuration while it is running, via config-update
+// messages.  Changes to any other configuration, or to workers that do not
+// implement this interface, require the worker to be restarted.
+type ConfigReloader interface {
+	// Determine whether a change to the given top-level worker config
+	// property can be applied without restarting the worker
+	HotReloadable(key string) bool
+}
@@ -2,7 +2,9 @@ package workerproto
 
 import (
 	"log"
+	"maps"
 	"sync"
+	"time"
 )
 
 type MessageCallback func(msg Message)
@@ -29,6 +31,10 @@ type Protocol struct {
 	// tracking for EOF from the read side of the transport
 	eof     bool
 	eofCond sync.Cond
+
+	// the time at which a message of each type was last received
+	lastReceived      map[string]time.Time
+	lastReceivedMutex sync.Mutex
 }
 
 func NewProtocol(transport Transport) *Protocol {
@@ -44,6 +50,7 @@ func NewProtocol(transport Transport) *Protocol {
 		eofCond: sync.Cond{
 			L: &sync.Mutex{},
 		},
+		lastReceived: make(map[string]time.Time),
 	}
 }
 
@@ -121,6 +128,35 @@ func (prot *Protocol) WaitUntilInitialized() {
 	}
 }
 
+// Check whether this protocol is initialized, without waiting.
+func (prot *Protocol) Initialized() bool {
+	prot.initializedCond.L.Lock()
+	defer prot.initializedCond.L.Unlock()
+	return prot.initialized
+}
+
+// Get the capabilities supported by both ends of the protocol, or nil if the
+// protocol is not yet initialized.  This does not wait for initialization.
+func (prot *Protocol) Capabilities() []string {
+	if !prot.Initialized() {
+		return nil
+	}
+	rv := []string{}
+	for _, c := range prot.localCapabilities.List() {
+		if prot.remoteCapabilities.Has(c) {
+			rv = append(rv, c)
+		}
+	}
+	return rv
+}
+
+// Get the time at which a message of each type was last received.
+func (prot *Protocol) LastReceived() map[string]time.Time {
+	prot.lastReceivedMutex.Lock()
+	defer prot.lastReceivedMutex.Unlock()
+	return maps.Clone(prot.lastReceived)
+}
+
 // Add the given capability to the local capabilities
 func (prot *Protocol) AddCapability(c string) {
 	prot.startedMutex.Lock()
@@ -164,6 +200,9 @@ func (prot *Protocol) recvLoop() {
 			prot.eofCond.L.Unlock()
 			return
 		}
+		prot.lastReceivedMutex.Lock()
+		prot.lastReceived[msg.Type] = time.Now()
+		prot.lastReceivedMutex.Unlock()
 		callbacks, ok := prot.callbacks[msg.Type]
 		if ok {
 			for _, cb := range callbacks {
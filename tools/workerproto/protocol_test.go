@@ -50,6 +50,8 @@ func TestCapabilityNegotiation(t *testing.T) {
 
 		RequireInitialized(t, runnerProto, false)
 		RequireInitialized(t, workerProto, false)
+		require.False(t, runnerProto.Initialized())
+		require.Nil(t, runnerProto.Capabilities())
 
 		runnerProto.Start(false)
 
@@ -77,11 +79,17 @@ func TestCapabilityNegotiation(t *testing.T) {
 			require.Equal(t, helloCaps, []string{})
 		}
 
+		require.True(t, runnerProto.Initialized())
+		require.Contains(t, runnerProto.LastReceived(), "hello")
+		require.Contains(t, workerProto.LastReceived(), "welcome")
+
 		// Capable should only return true when both have the capability
 		if runnerHasCap && workerHasCap {
+			require.Equal(t, []string{"test-capability"}, runnerProto.Capabilities())
 			require.Equal(t, true, workerProto.Capable("test-capability"))
 			require.Equal(t, true, runnerProto.Capable("test-capability"))
 		} else {
+			require.Equal(t, []string{}, runnerProto.Capabilities())
 			require.Equal(t, false, workerProto.Capable("test-capability"))
 			require.Equal(t, false, runnerProto.Capable("test-capability"))
 		}
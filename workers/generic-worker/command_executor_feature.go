@@ -1,6 +1,8 @@
 package main
 
 import (
+	"slices"
+
 	"github.com/taskcluster/taskcluster/v84/internal/scopes"
 )
 
@@ -39,18 +41,70 @@ func (cetf *CommandExecutorTaskFeature) ReservedArtifacts() []string {
 	return []string{}
 }
 
+// RequiredScopes returns the scopes needed to reboot or quarantine the worker
+// through task.payload.onExitStatus, since these actions affect the tasks of
+// other task authors.
 func (cetf *CommandExecutorTaskFeature) RequiredScopes() scopes.Required {
-	return scopes.Required{}
+	requiredScopes := []string{}
+	onExitStatus := cetf.task.Payload.OnExitStatus
+	if len(onExitStatus.RebootWorker) > 0 {
+		requiredScopes = append(requiredScopes, "generic-worker:reboot-worker:"+config.ProvisionerID+"/"+config.WorkerType)
+	}
+	if len(onExitStatus.QuarantineWorker) > 0 {
+		requiredScopes = append(requiredScopes, "generic-worker:quarantine-worker:"+config.ProvisionerID+"/"+config.WorkerType)
+	}
+	return scopes.Required{requiredScopes}
 }
 
+// Start runs the commands of the task in order, skipping those which should
+// not run given the outcome of the previous commands (see commandOptions). The
+// first failure is returned. No further commands are run once the task has
+// been aborted or cancelled.
 func (cetf *CommandExecutorTaskFeature) Start() *CommandExecutionError {
-	for i := range cetf.task.Payload.Command {
-		if err := cetf.task.ExecuteCommand(i); err != nil {
+	task := cetf.task
+	var failure *CommandExecutionError
+	for i := range task.Payload.Command {
+		switch runIf := task.commandOptions(i).RunIf; {
+		case runIf == "onFailure" && failure == nil:
+			task.Infof("Skipping command %v, since it only runs if a previous command failed", i)
+			continue
+		case runIf != "always" && runIf != "onFailure" && failure != nil:
+			task.Infof("Skipping command %v, since a previous command failed", i)
+			continue
+		}
+		err := task.ExecuteCommand(i)
+		if err == nil {
+			continue
+		}
+		if task.StatusManager.AbortException() != nil || task.StatusManager.LastKnownStatus() == cancelled {
 			return err
 		}
+		if failure == nil {
+			failure = err
+			task.failedResult = task.result
+		}
 	}
-	return nil
+	return failure
 }
 
+// Stop records whether the worker should reboot or be quarantined after the
+// task has been resolved, based on the exit status of the commands (see
+// exitResult).
 func (cetf *CommandExecutorTaskFeature) Stop(err *ExecutionErrors) {
+	task := cetf.task
+	result := task.exitResult()
+	// task commands may not have run if the task
+	// feature resolved as malformed-payload
+	if result == nil {
+		return
+	}
+	exitCode := int64(result.ExitCode())
+	if slices.Contains(task.Payload.OnExitStatus.RebootWorker, exitCode) {
+		task.Warnf("Worker will reboot after this task, since command had exit code %v which is listed in task.Payload.OnExitStatus.RebootWorker array", exitCode)
+		task.rebootWorker = true
+	}
+	if slices.Contains(task.Payload.OnExitStatus.QuarantineWorker, exitCode) {
+		task.Warnf("Worker will be quarantined after this task, since command had exit code %v which is listed in task.Payload.OnExitStatus.QuarantineWorker array", exitCode)
+		task.quarantineWorker = true
+	}
 }
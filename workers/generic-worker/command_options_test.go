@@ -0,0 +1,263 @@
+//go:build darwin || linux || freebsd
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mcuadros/go-defaults"
+)
+
+func TestCommandRunIf(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command: [][]string{
+			{"/usr/bin/env", "bash", "-c", "exit 3"},
+			{"echo", "Run0nSucc3ss"},
+			{"echo", "Run0nFa1lur3"},
+			{"echo", "RunAlw4ys"},
+		},
+		CommandOptions: []CommandOptions{
+			{},
+			{RunIf: "onSuccess"},
+			{RunIf: "onFailure"},
+			{RunIf: "always"},
+		},
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	for _, expected := range []string{
+		"Skipping command 1, since a previous command failed",
+		"Run0nFa1lur3",
+		"RunAlw4ys",
+	} {
+		if !strings.Contains(logtext, expected) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+		}
+	}
+	if strings.Contains(logtext, "Run0nSucc3ss") {
+		t.Log(logtext)
+		t.Fatal("Command 1 should not have run, since command 0 failed")
+	}
+}
+
+func TestCommandRunIfOnFailureSkipped(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command: [][]string{
+			{"echo", "hello world!"},
+			{"echo", "Run0nFa1lur3"},
+		},
+		CommandOptions: []CommandOptions{
+			{},
+			{RunIf: "onFailure"},
+		},
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "completed", "completed")
+
+	logtext := LogText(t)
+	if !strings.Contains(logtext, "Skipping command 1, since it only runs if a previous command failed") || strings.Contains(logtext, "Run0nFa1lur3") {
+		t.Log(logtext)
+		t.Fatal("Command 1 should have been skipped, since no previous command failed")
+	}
+}
+
+func TestCommandRetry(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command: [][]string{
+			{
+				"/usr/bin/env",
+				"bash",
+				"-c",
+				// fails with exit code 5 on the first two attempts
+				`n=$(( $(cat attempts 2>/dev/null || echo 0) + 1 )); echo $n > attempts; echo "Attempt $n"; [ $n -ge 3 ] || exit 5`,
+			},
+		},
+		CommandOptions: []CommandOptions{
+			{
+				Retry: CommandRetries{
+					ExitCodes:  []int64{5},
+					MaxRetries: 2,
+					Backoff:    1,
+				},
+			},
+		},
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "completed", "completed")
+
+	logtext := LogText(t)
+	for _, expected := range []string{
+		"Retrying command 0 in 1s (retry 1 of 2)",
+		"Retrying command 0 in 2s (retry 2 of 2)",
+		"Attempt 3",
+	} {
+		if !strings.Contains(logtext, expected) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+		}
+	}
+}
+
+func TestCommandRetryOtherExitCode(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command: returnExitCode(4),
+		CommandOptions: []CommandOptions{
+			{
+				Retry: CommandRetries{
+					ExitCodes: []int64{5},
+				},
+			},
+		},
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	if strings.Contains(logtext, "Retrying command 0") {
+		t.Log(logtext)
+		t.Fatal("Command 0 should not have been retried, since its exit code is not listed")
+	}
+}
+
+func TestCommandMaxRunTime(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command: append(
+			sleep(30),
+			[]string{"echo", "RunAlw4ys"},
+		),
+		CommandOptions: []CommandOptions{
+			{MaxRunTime: 2},
+			{RunIf: "always"},
+		},
+		MaxRunTime: 60,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	for _, expected := range []string{
+		"Command 0 exceeded its maxRunTime of 2 seconds",
+		"Command 0 was killed",
+		"RunAlw4ys",
+	} {
+		if !strings.Contains(logtext, expected) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+		}
+	}
+}
+
+func TestTooManyCommandOptions(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command: helloGoodbye(),
+		CommandOptions: []CommandOptions{
+			{},
+			{},
+			{RunIf: "always"},
+		},
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "exception", "malformed-payload")
+
+	logtext := LogText(t)
+	if !strings.Contains(logtext, "task payload has 3 entries in commandOptions, but 2 commands") {
+		t.Log(logtext)
+		t.Fatal("Was expecting log file to explain why the payload is malformed, but it doesn't")
+	}
+}
+
+func TestTooFewCommandOptions(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command: helloGoodbye(),
+		CommandOptions: []CommandOptions{
+			{RunIf: "always"},
+		},
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "exception", "malformed-payload")
+
+	logtext := LogText(t)
+	if !strings.Contains(logtext, "task payload has 1 entries in commandOptions, but 2 commands") {
+		t.Log(logtext)
+		t.Fatal("Was expecting log file to explain why the payload is malformed, but it doesn't")
+	}
+}
+
+// OnExitStatus should apply to the exit code of the command that failed, not
+// to that of a command that runs after it
+func TestCommandRunIfAlwaysPurgeCaches(t *testing.T) {
+	setup(t)
+	mounts := []MountEntry{
+		// requires scope "generic-worker:cache:banana-cache"
+		&WritableDirectoryCache{
+			CacheName: "banana-cache",
+			Directory: filepath.Join("my-task-caches", "bananas"),
+		},
+	}
+	payload := GenericWorkerPayload{
+		Command: [][]string{
+			{"/usr/bin/env", "bash", "-c", "exit 123"},
+			{"echo", "RunAlw4ys"},
+		},
+		CommandOptions: []CommandOptions{
+			{},
+			{RunIf: "always"},
+		},
+		MaxRunTime: 30,
+		Mounts:     toMountArray(t, mounts),
+		OnExitStatus: ExitCodeHandling{
+			PurgeCaches: []int64{123},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+	td.Scopes = []string{"generic-worker:cache:banana-cache"}
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	for _, expected := range []string{
+		"RunAlw4ys",
+		"[mounts] Purging caches since command had exit code 123",
+		"[mounts] Removing cache banana-cache from cache table",
+	} {
+		if !strings.Contains(logtext, expected) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+		}
+	}
+
+	ensureDirContainsNFiles(t, cachesDir, 0)
+}
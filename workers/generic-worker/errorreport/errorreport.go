@@ -7,14 +7,22 @@ import (
 )
 
 func Send(proto *workerproto.Protocol, message any, debugInfo map[string]string) {
+	// could support differentiating for panics
+	send(proto, "worker-error", "generic-worker error", message, debugInfo)
+}
+
+// SendQuarantine reports that the worker has been quarantined, and will not
+// claim any further tasks.
+func SendQuarantine(proto *workerproto.Protocol, message any, debugInfo map[string]string) {
+	send(proto, "worker-quarantined", "generic-worker quarantined", message, debugInfo)
+}
+
+func send(proto *workerproto.Protocol, kind, title string, message any, debugInfo map[string]string) {
 	if !proto.Capable("error-report") {
 		return
 	}
 
-	title := "generic-worker error"
 	description := fmt.Sprintf("%s", message)
-	// could support differentiating for panics
-	kind := "worker-error"
 	// convert debugInfo from map[string]string to map[string]any
 	extra := map[string]any{}
 	for k, v := range debugInfo {
@@ -85,3 +85,32 @@ func TestSendExtra(t *testing.T) {
 	defer lock.Unlock()
 	assert.True(t, errorReported, "No error-report was received")
 }
+
+func TestSendQuarantine(t *testing.T) {
+	errorReported := false
+	lock := sync.Mutex{}
+
+	workerProto, runnerProto := setupProtocols()
+
+	runnerProto.Register("error-report", func(msg workerproto.Message) {
+		assert.Equal(t, "generic-worker quarantined", msg.Properties["title"])
+		assert.Equal(t, "worker-quarantined", msg.Properties["kind"])
+		assert.Equal(t, "quarantined by task abc", msg.Properties["description"].(string))
+		assert.Equal(t, "abc", msg.Properties["extra"].(map[string]any)["taskId"].(string))
+		errorReported = true
+		lock.Unlock()
+	})
+
+	runnerProto.Start(false)
+	runnerProto.WaitUntilInitialized()
+
+	// unlocked in callback
+	lock.Lock()
+	SendQuarantine(workerProto, "quarantined by task abc", map[string]string{
+		"taskId": "abc",
+	})
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.True(t, errorReported, "No error-report was received")
+}
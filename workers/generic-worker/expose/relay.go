@@ -0,0 +1,155 @@
+package expose
+
+// Expose local HTTP servers and ports via a websocktunnel relay (see
+// `websocktunnel relay`), for local or stand-in deployments that do not run a
+// websocktunnel server.
+//
+// Unlike the websocktunnel exposer, all exposures share a single
+// websocktunnel client, so the worker makes a single outbound connection to
+// the relay, and needs no Taskcluster credentials to do so; instead it signs
+// its own tokens with a secret shared with the relay.  Each exposure is given
+// a random path segment under the client's URL, and requests arriving over
+// the tunnel are routed to the exposed port based on that segment.
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taskcluster/slugid-go/slugid"
+	"github.com/taskcluster/taskcluster/v84/tools/websocktunnel/client"
+	"github.com/taskcluster/taskcluster/v84/tools/websocktunnel/relay"
+)
+
+type relayExposer struct {
+	relayURL string
+	secret   []byte
+	clientId string
+
+	// mutex protects the fields below
+	mutex sync.Mutex
+	// the websocktunnel client shared by all exposures, created on first use
+	wstClient *client.Client
+	// handlers for the current exposures, keyed by path segment
+	handlers map[string]http.Handler
+}
+
+// Create a relay-based exposer implementation.  The tunnel clientId is based
+// on this worker's workerGroup and workerId, plus a random suffix so that
+// exposers never displace one another at the relay.
+func NewRelay(relayURL string, secret []byte, workerGroup, workerId string) (Exposer, error) {
+	if relayURL == "" {
+		return nil, fmt.Errorf("relay URL must be provided")
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("relay secret must be provided")
+	}
+	return &relayExposer{
+		relayURL: relayURL,
+		secret:   secret,
+		clientId: fmt.Sprintf("%s.%s.%s", workerGroup, workerId, slugid.Nice()),
+		handlers: map[string]http.Handler{},
+	}, nil
+}
+
+func (exposer *relayExposer) ExposeHTTP(targetPort uint16) (Exposure, error) {
+	targetURL, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", targetPort))
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+
+	// as for local exposures, flush any buffered data after 100ms, to support
+	// streaming responses that are not detected as such
+	proxy.FlushInterval = 100 * time.Millisecond
+
+	return exposer.expose(proxy)
+}
+
+func (exposer *relayExposer) ExposeTCPPort(targetPort uint16) (Exposure, error) {
+	return exposer.expose(websocketToTCPHandlerFunc(targetPort))
+}
+
+// expose routes requests for a new path segment to the given handler,
+// connecting to the relay if not already connected.
+func (exposer *relayExposer) expose(handler http.Handler) (Exposure, error) {
+	exposer.mutex.Lock()
+	defer exposer.mutex.Unlock()
+
+	if exposer.wstClient == nil {
+		wstClient, err := client.New(exposer.configure)
+		if err != nil {
+			return nil, err
+		}
+		exposer.wstClient = wstClient
+	}
+
+	exposure := &relayExposure{exposer: exposer, pathSegment: slugid.Nice()}
+	exposer.handlers[exposure.pathSegment] = handler
+	return exposure, nil
+}
+
+// configure generates the websocktunnel client config, with a freshly signed
+// token, each time the client (re)connects to the relay.
+func (exposer *relayExposer) configure() (client.Config, error) {
+	token, err := relay.Token(exposer.secret, exposer.clientId)
+	if err != nil {
+		return client.Config{}, err
+	}
+	return client.Config{
+		ID:         exposer.clientId,
+		TunnelAddr: exposer.relayURL,
+		Token:      token,
+		// As for the websocktunnel exposer, requests must be served again
+		// after each reconnect.
+		ConnectHook: func(cl *client.Client) {
+			server := http.Server{
+				Handler: exposer,
+			}
+			go func() {
+				_ = server.Serve(cl)
+			}()
+		},
+	}, nil
+}
+
+// ServeHTTP routes a request arriving over the tunnel to the handler for the
+// exposure named by the first segment of its path, stripping that segment.
+func (exposer *relayExposer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pathSegment, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	exposer.mutex.Lock()
+	handler, ok := exposer.handlers[pathSegment]
+	exposer.mutex.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = "/" + rest
+	r2.URL.RawPath = ""
+	handler.ServeHTTP(w, r2)
+}
+
+// relayExposure exposes either an HTTP server or a TCP port
+type relayExposure struct {
+	exposer     *relayExposer
+	pathSegment string
+}
+
+func (exposure *relayExposure) Close() error {
+	exposure.exposer.mutex.Lock()
+	defer exposure.exposer.mutex.Unlock()
+	delete(exposure.exposer.handlers, exposure.pathSegment)
+	return nil
+}
+
+func (exposure *relayExposure) GetURL() *url.URL {
+	url, _ := url.Parse(exposure.exposer.wstClient.URL() + "/" + exposure.pathSegment)
+	return url
+}
@@ -0,0 +1,246 @@
+package expose
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taskcluster/taskcluster/v84/tools/websocktunnel/relay"
+)
+
+const RELAY_SECRET = "relay-secret"
+
+// Encapsulation of a relay server with a close method
+type relayServer struct {
+	t      *testing.T
+	port   uint16
+	server *http.Server
+}
+
+func makeRelayServer(t *testing.T) relayServer {
+	t.Helper()
+	listener, port, err := listenOnRandomPort()
+	if err != nil {
+		t.Fatalf("listenOnRandomPort: %s", err)
+	}
+
+	handler, err := relay.New(relay.Config{
+		Secret:    []byte(RELAY_SECRET),
+		URLPrefix: "http://127.0.0.1:" + strconv.Itoa(int(port)),
+	})
+	if err != nil {
+		listener.Close()
+		t.Fatalf("relay.New: %s", err)
+	}
+
+	server := &http.Server{Addr: fmt.Sprintf("127.0.0.1:%d", port), Handler: handler}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return relayServer{t, port, server}
+}
+
+func (s *relayServer) url() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", s.port)
+}
+
+func (s *relayServer) close() {
+	err := s.server.Close()
+	if err != nil {
+		s.t.Fatalf("server.Close: %s", err)
+	}
+}
+
+func makeRelayExposer(t *testing.T, relayURL string) Exposer {
+	t.Helper()
+	exposer, err := NewRelay(relayURL, []byte(RELAY_SECRET), WST_WORKER_GROUP, WST_WORKER_ID)
+	if err != nil {
+		t.Fatalf("Constructor returned an error: %v", err)
+	}
+	return exposer
+}
+
+func serverPort(t *testing.T, serverURL string) uint16 {
+	t.Helper()
+	testURL, _ := url.Parse(serverURL)
+	_, testPortStr, _ := net.SplitHostPort(testURL.Host)
+	testPort, err := strconv.Atoi(testPortStr)
+	require.NoError(t, err)
+	return uint16(testPort)
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, string(body)
+}
+
+func TestRelayConstructorRequiresSecret(t *testing.T) {
+	_, err := NewRelay("http://127.0.0.1:1", nil, WST_WORKER_GROUP, WST_WORKER_ID)
+	require.Error(t, err)
+}
+
+// Test exposing a basic HTTP server
+func TestRelayExposeHTTP(t *testing.T) {
+	relayServer := makeRelayServer(t)
+	defer relayServer.close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello, world @ %s", r.URL.Path)
+	}))
+	defer ts.Close()
+
+	exposer := makeRelayExposer(t, relayServer.url())
+	exposure, err := exposer.ExposeHTTP(serverPort(t, ts.URL))
+	if err != nil {
+		t.Fatalf("ExposeHTTP returned an error: %v", err)
+	}
+	defer exposure.Close()
+
+	gotURL := exposure.GetURL()
+	assert.Equal(t, "http", gotURL.Scheme, "Should return URL with correct scheme")
+	host, port, _ := net.SplitHostPort(gotURL.Host)
+	assert.Equal(t, "127.0.0.1", host, "Should return the host for the relay")
+	assert.Equal(t, fmt.Sprintf("%d", relayServer.port), port, "Should return the port for the relay")
+
+	status, body := get(t, fmt.Sprintf("%s/abc/def", gotURL))
+	assert.Equal(t, 200, status, "got 200 response via proxy")
+	assert.Equal(t, "Hello, world @ /abc/def", body, "got greeting via proxy")
+}
+
+// Test that several exposures share a connection to the relay, and that
+// closed exposures are no longer reachable
+func TestRelayExposeMultiple(t *testing.T) {
+	relayServer := makeRelayServer(t)
+	defer relayServer.close()
+
+	ts1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "one @ %s", r.URL.Path)
+	}))
+	defer ts1.Close()
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "two @ %s", r.URL.Path)
+	}))
+	defer ts2.Close()
+
+	exposer := makeRelayExposer(t, relayServer.url())
+	exposure1, err := exposer.ExposeHTTP(serverPort(t, ts1.URL))
+	require.NoError(t, err)
+	exposure2, err := exposer.ExposeHTTP(serverPort(t, ts2.URL))
+	require.NoError(t, err)
+	defer exposure2.Close()
+
+	url1, url2 := exposure1.GetURL(), exposure2.GetURL()
+	assert.Equal(t, path.Dir(url1.Path), path.Dir(url2.Path), "Exposures should share a websocktunnel client")
+	assert.NotEqual(t, url1.Path, url2.Path, "Exposures should have distinct URLs")
+
+	status, body := get(t, url1.String()+"/x")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "one @ /x", body)
+	status, body = get(t, url2.String()+"/y")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "two @ /y", body)
+
+	require.NoError(t, exposure1.Close())
+	status, _ = get(t, url1.String()+"/x")
+	assert.Equal(t, 404, status, "Closed exposure should not be reachable")
+	status, _ = get(t, url2.String()+"/y")
+	assert.Equal(t, 200, status, "Other exposure should still be reachable")
+}
+
+// Test exposing an HTTP server that serves websockets
+func TestRelayExposeHTTPWebsocket(t *testing.T) {
+	relayServer := makeRelayServer(t)
+	defer relayServer.close()
+
+	ts := websockEchoServer(t)
+	defer ts.Close()
+
+	exposer := makeRelayExposer(t, relayServer.url())
+	exposure, err := exposer.ExposeHTTP(serverPort(t, ts.URL))
+	if err != nil {
+		t.Fatalf("ExposeHTTP returned an error: %v", err)
+	}
+	defer exposure.Close()
+
+	dialer := websocket.Dialer{}
+	url := strings.Replace(exposure.GetURL().String(), "http:", "ws:", 1)
+	ws, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("client Dial: %s", err)
+	}
+	defer resp.Body.Close()
+	defer ws.Close()
+
+	err = ws.WriteMessage(websocket.BinaryMessage, []byte("ECHO"))
+	if err != nil {
+		t.Fatalf("client WriteMessage: %s", err)
+	}
+
+	messageType, payload, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatalf("client ReadMessage: %s", err)
+	}
+	assert.Equal(t, messageType, websocket.BinaryMessage, "expected message type")
+	assert.Equal(t, payload, []byte("ECHO"), "expected payload")
+}
+
+// Test exposing a TCP port
+func TestRelayExposeTCPPort(t *testing.T) {
+	relayServer := makeRelayServer(t)
+	defer relayServer.close()
+
+	// set up a TCP echo server on a random port
+	tcpListener, tcpListenerPort, err := listenOnRandomPort()
+	if err != nil {
+		t.Fatalf("listenOnRandomPort: %s", err)
+	}
+	defer tcpListener.Close()
+	connClosed := tcpEchoServer(tcpListener)
+
+	exposer := makeRelayExposer(t, relayServer.url())
+	exposure, err := exposer.ExposeTCPPort(tcpListenerPort)
+	if err != nil {
+		t.Fatalf("ExposeTCPPort returned an error: %v", err)
+	}
+	defer exposure.Close()
+
+	dialer := websocket.Dialer{}
+	url := strings.Replace(exposure.GetURL().String(), "http:", "ws:", 1)
+	ws, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("client Dial: %s", err)
+	}
+	defer resp.Body.Close()
+
+	err = ws.WriteMessage(websocket.BinaryMessage, []byte("ECHO"))
+	if err != nil {
+		t.Fatalf("client WriteMessage: %s", err)
+	}
+
+	messageType, payload, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatalf("client ReadMessage: %s", err)
+	}
+	assert.Equal(t, messageType, websocket.BinaryMessage, "expected message type")
+	assert.Equal(t, payload, []byte("ECHO"), "expected payload")
+
+	ws.Close()
+
+	<-connClosed
+}
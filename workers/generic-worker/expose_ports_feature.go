@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
+	"github.com/taskcluster/taskcluster/v84/internal/scopes"
+	"github.com/taskcluster/taskcluster/v84/workers/generic-worker/artifacts"
+	"github.com/taskcluster/taskcluster/v84/workers/generic-worker/expose"
+)
+
+type (
+	ExposePortsFeature struct {
+	}
+
+	// ExposePortsTaskFeature exposes the ports listed in the exposePorts
+	// property of the task payload, for the duration of the task's commands.
+	ExposePortsTaskFeature struct {
+		task      *TaskRun
+		exposures []expose.Exposure
+	}
+)
+
+func (epf *ExposePortsFeature) Name() string {
+	return "Expose Ports"
+}
+
+func (epf *ExposePortsFeature) Initialise() error {
+	return nil
+}
+
+func (epf *ExposePortsFeature) IsEnabled() bool {
+	return true
+}
+
+func (epf *ExposePortsFeature) IsRequested(task *TaskRun) bool {
+	return len(task.Payload.ExposePorts) > 0
+}
+
+func (epf *ExposePortsFeature) NewTaskFeature(task *TaskRun) TaskFeature {
+	return &ExposePortsTaskFeature{
+		task: task,
+	}
+}
+
+func (eptf *ExposePortsTaskFeature) RequiredScopes() scopes.Required {
+	return scopes.Required{
+		{"generic-worker:expose-port:" + config.ProvisionerID + "/" + config.WorkerType},
+	}
+}
+
+func (eptf *ExposePortsTaskFeature) ReservedArtifacts() []string {
+	// duplicate names are reported by Start
+	reserved := []string{}
+	for _, port := range eptf.task.Payload.ExposePorts {
+		if name := exposedPortArtifactName(port); !slices.Contains(reserved, name) {
+			reserved = append(reserved, name)
+		}
+	}
+	return reserved
+}
+
+func exposedPortArtifactName(port ExposedPort) string {
+	return "private/generic-worker/exposed-ports/" + port.Name
+}
+
+// Start checks that the requested ports may be exposed, and exposes them. Only
+// ports listed in the allowedExposePorts worker config may be exposed, since
+// other ports on the host may belong to the worker (for example, the
+// taskcluster-proxy, which could leak credentials) or to other services.
+func (eptf *ExposePortsTaskFeature) Start() *CommandExecutionError {
+	names := map[string]bool{}
+	for _, port := range eptf.task.Payload.ExposePorts {
+		if names[port.Name] {
+			return MalformedPayloadError(fmt.Errorf("exposePorts contains more than one entry named %q", port.Name))
+		}
+		names[port.Name] = true
+		if !slices.Contains(config.AllowedExposePorts, uint16(port.Port)) {
+			return MalformedPayloadError(fmt.Errorf("exposePorts entry %q cannot expose port %v, since it is not listed in the allowedExposePorts config of the worker (%v)", port.Name, port.Port, config.AllowedExposePorts))
+		}
+	}
+
+	for _, port := range eptf.task.Payload.ExposePorts {
+		err := eptf.exposePort(port)
+		if err != nil {
+			// as for livelog and interactive, exposures are best effort
+			eptf.task.Warnf("[expose-ports] Could not expose port %v (%v): %v", port.Port, port.Name, err)
+		}
+	}
+	return nil
+}
+
+// exposePort exposes the given port, and uploads an artifact which redirects
+// to the URL at which it is exposed.
+func (eptf *ExposePortsTaskFeature) exposePort(port ExposedPort) error {
+	var exposure expose.Exposure
+	var err error
+	if port.Type == "tcp" {
+		exposure, err = exposer.ExposeTCPPort(uint16(port.Port))
+	} else {
+		exposure, err = exposer.ExposeHTTP(uint16(port.Port))
+	}
+	if err != nil {
+		return err
+	}
+	eptf.exposures = append(eptf.exposures, exposure)
+
+	exposeURL := exposure.GetURL()
+	// TCP ports are exposed as websockets
+	if port.Type == "tcp" {
+		switch exposeURL.Scheme {
+		case "https":
+			exposeURL.Scheme = "wss"
+		case "http":
+			exposeURL.Scheme = "ws"
+		}
+	}
+	artifactName := exposedPortArtifactName(port)
+
+	// add an extra 15 minutes, to adequately cover client/server clock drift or task initialisation delays
+	expires := time.Now().Add(time.Duration(eptf.task.Payload.MaxRunTime+900) * time.Second)
+	uploadErr := eptf.task.uploadArtifact(
+		&artifacts.RedirectArtifact{
+			BaseArtifact: &artifacts.BaseArtifact{
+				Name:    artifactName,
+				Expires: tcclient.Time(expires),
+			},
+			ContentType: "text/html; charset=utf-8",
+			URL:         exposeURL.String(),
+		},
+	)
+	if uploadErr != nil {
+		return uploadErr
+	}
+	eptf.task.Infof("[expose-ports] Exposed %v port %v (%v) at artifact %v", port.Type, port.Port, port.Name, artifactName)
+	// note this will be error(nil) not *CommandExecutionError(nil)
+	return nil
+}
+
+func (eptf *ExposePortsTaskFeature) Stop(err *ExecutionErrors) {
+	for _, exposure := range eptf.exposures {
+		closeErr := exposure.Close()
+		if closeErr != nil {
+			eptf.task.Warnf("[expose-ports] Could not close exposure %v: %v", exposure.GetURL(), closeErr)
+		}
+	}
+	eptf.exposures = nil
+}
@@ -0,0 +1,183 @@
+//go:build darwin || linux || freebsd
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcuadros/go-defaults"
+)
+
+// previewServer starts an HTTP server on the loopback interface, standing in
+// for a server started by a task, and returns it together with its port.
+func previewServer(t *testing.T) (*httptest.Server, int64) {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Pr3view @ %s", r.URL.Path)
+	}))
+	u, _ := url.Parse(ts.URL)
+	_, portStr, _ := net.SplitHostPort(u.Host)
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		ts.Close()
+		t.Fatalf("Could not determine port of preview server: %v", err)
+	}
+	return ts, int64(port)
+}
+
+func TestExposePorts(t *testing.T) {
+	setup(t)
+
+	// exposures are made by the local exposer, so they need to be reachable
+	config.PublicIP = net.ParseIP("127.0.0.1")
+
+	ts, port := previewServer(t)
+	defer ts.Close()
+	config.AllowedExposePorts = []uint16{uint16(port)}
+
+	payload := GenericWorkerPayload{
+		Command:    sleep(10),
+		MaxRunTime: 30,
+		ExposePorts: []ExposedPort{
+			{
+				Port: port,
+				Name: "preview",
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+	td.Scopes = append(td.Scopes, "generic-worker:expose-port:"+td.ProvisionerID+"/"+td.WorkerType)
+
+	taskID := scheduleTask(t, td, payload)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ensureResolution(t, taskID, "completed", "completed")
+	}()
+
+	// the artifact redirects to the exposed port, so fetching the artifact
+	// while the task is running returns a response from the preview server
+	queue := serviceFactory.Queue(config.Credentials(), config.RootURL)
+	var content []byte
+	var err error
+	timeout := time.After(20 * time.Second)
+	tick := time.Tick(500 * time.Millisecond)
+	for content == nil {
+		select {
+		case <-timeout:
+			t.Fatalf("Timed out fetching exposed port via artifact: %v", err)
+		case <-tick:
+			content, _, _, err = queue.DownloadArtifactToBuf(taskID, -1, "private/generic-worker/exposed-ports/preview")
+		}
+	}
+	if string(content) != "Pr3view @ /" {
+		t.Fatalf("Was expecting response from preview server, but got %q", content)
+	}
+
+	<-done
+
+	logtext := LogText(t)
+	expected := fmt.Sprintf("Exposed http port %v (preview) at artifact private/generic-worker/exposed-ports/preview", port)
+	if !strings.Contains(logtext, expected) {
+		t.Log(logtext)
+		t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+	}
+}
+
+func TestExposePortsMissingScopes(t *testing.T) {
+	setup(t)
+
+	payload := GenericWorkerPayload{
+		Command:    helloGoodbye(),
+		MaxRunTime: 30,
+		ExposePorts: []ExposedPort{
+			{
+				Port: 3000,
+				Name: "preview",
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	// don't set any scopes
+	_ = submitAndAssert(t, td, payload, "exception", "malformed-payload")
+
+	logtext := LogText(t)
+	if !strings.Contains(logtext, "generic-worker:expose-port:"+td.ProvisionerID+"/"+td.WorkerType) {
+		t.Log(logtext)
+		t.Fatalf("Was expecting log file to contain missing scopes, but it doesn't")
+	}
+}
+
+func TestExposePortsNotAllowed(t *testing.T) {
+	setup(t)
+	config.AllowedExposePorts = []uint16{3000}
+
+	payload := GenericWorkerPayload{
+		Command:    helloGoodbye(),
+		MaxRunTime: 30,
+		ExposePorts: []ExposedPort{
+			{
+				Port: 3000,
+				Name: "preview",
+			},
+			{
+				Port: int64(config.TaskclusterProxyPort),
+				Name: "proxy",
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+	td.Scopes = append(td.Scopes, "generic-worker:expose-port:"+td.ProvisionerID+"/"+td.WorkerType)
+
+	_ = submitAndAssert(t, td, payload, "exception", "malformed-payload")
+
+	logtext := LogText(t)
+	expected := fmt.Sprintf("exposePorts entry \"proxy\" cannot expose port %v, since it is not listed in the allowedExposePorts config of the worker ([3000])", config.TaskclusterProxyPort)
+	if !strings.Contains(logtext, expected) {
+		t.Log(logtext)
+		t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+	}
+}
+
+func TestExposePortsDuplicateNames(t *testing.T) {
+	setup(t)
+	config.AllowedExposePorts = []uint16{3000, 6006}
+
+	payload := GenericWorkerPayload{
+		Command:    helloGoodbye(),
+		MaxRunTime: 30,
+		ExposePorts: []ExposedPort{
+			{
+				Port: 3000,
+				Name: "preview",
+			},
+			{
+				Port: 6006,
+				Name: "preview",
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+	td.Scopes = append(td.Scopes, "generic-worker:expose-port:"+td.ProvisionerID+"/"+td.WorkerType)
+
+	_ = submitAndAssert(t, td, payload, "exception", "malformed-payload")
+
+	logtext := LogText(t)
+	if !strings.Contains(logtext, "exposePorts contains more than one entry named \"preview\"") {
+		t.Log(logtext)
+		t.Fatalf("Was expecting log file to explain why the payload is malformed, but it doesn't")
+	}
+}
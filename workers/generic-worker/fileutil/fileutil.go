@@ -116,6 +116,24 @@ func CreateFile(file string) (err error) {
 	return
 }
 
+// WriteFile creates (or truncates) file, and writes everything read from r
+// to it.
+func WriteFile(file string, r io.Reader) (err error) {
+	var f *os.File
+	f, err = os.Create(file)
+	if err != nil {
+		return
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	_, err = io.Copy(f, r)
+	return
+}
+
 func CreateDir(dir string) error {
 	return os.MkdirAll(dir, 0700)
 }
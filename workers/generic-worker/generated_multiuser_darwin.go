@@ -138,6 +138,25 @@ type (
 		Base64 string `json:"base64"`
 	}
 
+	CachePurgeRule struct {
+
+		// Names of the writable directory caches to purge.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Array items:
+		CacheNames []string `json:"cacheNames"`
+
+		// Exit statuses of the first command that failed, or of the last
+		// command if none failed, that cause the caches to be purged.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Array items:
+		// Mininum:    0
+		ExitCodes []int64 `json:"exitCodes"`
+	}
+
 	// Set of capabilities that must be enabled or made available to the task container Example: ```{ "capabilities": { "privileged": true }```
 	Capabilities struct {
 
@@ -155,6 +174,65 @@ type (
 		Privileged bool `json:"privileged" default:"false"`
 	}
 
+	CommandOptions struct {
+
+		// If set, the command is terminated if it runs for more than this many
+		// seconds, which fails the task. The `maxRunTime` of the task still
+		// applies.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Mininum:    1
+		MaxRunTime int64 `json:"maxRunTime,omitempty"`
+
+		// If the command exits with one of the given exit codes, it is run
+		// again, up to `maxRetries` times. Before the first retry, the worker waits
+		// `backoff` seconds (by default, it doesn't wait), doubling the wait for
+		// each further retry.
+		//
+		// Since: generic-worker 84.2.0
+		Retry CommandRetries `json:"retry,omitzero"`
+
+		// Whether the command runs depending on the outcome of the previous
+		// commands. `onSuccess` runs the command only if no previous command
+		// failed, `onFailure` only if a previous command failed, and `always`
+		// runs it in either case. Commands are never run once the task has
+		// been aborted, for example because it exceeded its `maxRunTime`, or
+		// was cancelled. The task fails if any command that ran failed.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Possible values:
+		//   * "onSuccess"
+		//   * "onFailure"
+		//   * "always"
+		//
+		// Default:    "onSuccess"
+		RunIf string `json:"runIf" default:"onSuccess"`
+	}
+
+	// If the command exits with one of the given exit codes, it is run
+	// again, up to `maxRetries` times. Before the first retry, the worker waits
+	// `backoff` seconds (by default, it doesn't wait), doubling the wait for
+	// each further retry.
+	//
+	// Since: generic-worker 84.2.0
+	CommandRetries struct {
+
+		// Mininum:    0
+		// Maximum:    3600
+		Backoff int64 `json:"backoff,omitempty"`
+
+		// Array items:
+		// Mininum:    1
+		ExitCodes []int64 `json:"exitCodes"`
+
+		// Default:    1
+		// Mininum:    1
+		// Maximum:    10
+		MaxRetries int64 `json:"maxRetries,omitempty"`
+	}
+
 	// Allows devices from the host system to be attached to a task container similar to using `--device` in docker.
 	Devices struct {
 
@@ -315,6 +393,28 @@ type (
 		SupersederURL string `json:"supersederUrl,omitempty"`
 	}
 
+	ExceptionResolution struct {
+
+		// Exit statuses that cause the task to be resolved as an exception
+		// with the given reason.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Array items:
+		// Mininum:    1
+		ExitCodes []int64 `json:"exitCodes"`
+
+		// The reason to resolve the task exception with.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Possible values:
+		//   * "malformed-payload"
+		//   * "resource-unavailable"
+		//   * "internal-error"
+		Reason string `json:"reason"`
+	}
+
 	// By default tasks will be resolved with `state/reasonResolved`: `completed/completed`
 	// if all task commands have a zero exit code, or `failed/failed` if any command has a
 	// non-zero exit code. This payload property allows customsation of the task resolution
@@ -330,6 +430,51 @@ type (
 		// Mininum:    0
 		PurgeCaches []int64 `json:"purgeCaches,omitempty"`
 
+		// Like `purgeCaches`, but only purges the named caches, rather than all
+		// caches associated with the task. Each entry lists the exit codes that
+		// cause the given caches to be purged. Only caches that were mounted by
+		// the task can be purged; any other cache names are ignored.
+		//
+		// Since: generic-worker 84.2.0
+		PurgeNamedCaches []CachePurgeRule `json:"purgeNamedCaches,omitempty"`
+
+		// If the first command that failed, or the last command if none failed,
+		// exits with one of these exit statuses, the worker stops claiming tasks
+		// after resolving the task, and reports an error to worker-manager, so
+		// that the worker can be inspected. The worker remains quarantined until
+		// it is asked to shut down, or until its `idleTimeoutSecs` expires.
+		//
+		// Requires scope `generic-worker:quarantine-worker:<provisionerId>/<workerType>`.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Array items:
+		// Mininum:    0
+		QuarantineWorker []int64 `json:"quarantineWorker,omitempty"`
+
+		// If the first command that failed, or the last command if none failed,
+		// exits with one of these exit statuses, the worker reboots after
+		// resolving the task, instead of claiming another task. Use this when an
+		// exit status means that the state of the machine is no longer
+		// trustworthy. Reboots are skipped if the worker is configured with
+		// `disableReboots`, in which case the worker exits.
+		//
+		// Requires scope `generic-worker:reboot-worker:<provisionerId>/<workerType>`.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Array items:
+		// Mininum:    0
+		RebootWorker []int64 `json:"rebootWorker,omitempty"`
+
+		// Exit statuses for any command in the task payload that cause the task
+		// to be resolved as `exception/<reason>` rather than `failed/failed`.
+		// If an exit status is also listed in `retry`, the task is resolved as
+		// `exception/intermittent-task`.
+		//
+		// Since: generic-worker 84.2.0
+		ResolveAs []ExceptionResolution `json:"resolveAs,omitempty"`
+
 		// Exit codes for any command in the task payload to cause this task to
 		// be resolved as `exception/intermittent-task`. Typically the Queue
 		// will then schedule a new run of the existing `taskId` (rerun) if not
@@ -358,6 +503,38 @@ type (
 		Retry []int64 `json:"retry,omitempty"`
 	}
 
+	ExposedPort struct {
+
+		// The name of the exposure, used in the name of its artifact. Names
+		// must be unique within the task.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Syntax:     ^[a-zA-Z0-9_.-]{1,64}$
+		Name string `json:"name"`
+
+		// The port, on the loopback interface of the worker, to expose.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Mininum:    1
+		// Maximum:    65535
+		Port int64 `json:"port"`
+
+		// Whether the port serves HTTP (including websockets), or is a plain
+		// TCP port. HTTP ports are exposed through a reverse proxy; TCP ports
+		// are exposed as a websocket, which carries the TCP connection.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Possible values:
+		//   * "http"
+		//   * "tcp"
+		//
+		// Default:    "http"
+		Type string `json:"type" default:"http"`
+	}
+
 	// Feature flags enable additional functionality.
 	//
 	// Since: generic-worker 5.3.0
@@ -395,9 +572,46 @@ type (
 		// A user can then `docker exec` into the a running container, if there
 		// is one.
 		//
+		// A new shell session is started for each connection made through the
+		// `private/generic-worker/shell.html` artifact, unless a session name is
+		// given in the `session` query parameter of the WebSocket URL, in which case
+		// the running session of that name is joined, if there is one. Running
+		// sessions can be shared through the `private/generic-worker/shell-codriver.html`
+		// artifact, which allows typing in the session, and the
+		// `private/generic-worker/shell-observer.html` artifact, which only allows
+		// watching it. A list of the sessions, with the number of clients attached to
+		// each, is uploaded as `private/generic-worker/interactive-sessions.json`
+		// when the task ends.
+		//
 		// Since: generic-worker 49.2.0
 		Interactive bool `json:"interactive,omitempty"`
 
+		// Allows clients of the `interactive` feature which connect with the
+		// `channels=true` query parameter, and which may type in the session, to
+		// upload files to, and download files from, the task directory, as the
+		// task user.
+		//
+		// Requires scope
+		// `generic-worker:interactive-file-transfer:<provisionerId>/<workerType>`.
+		//
+		// Since: generic-worker 84.2.0
+		InteractiveFileTransfer bool `json:"interactiveFileTransfer,omitempty"`
+
+		// Allows clients of the `interactive` feature which connect with the
+		// `channels=true` query parameter, and which may type in the session, to
+		// forward connections to TCP ports on localhost, for example to attach a
+		// debugger or a browser to a server running in the task. Only the ports
+		// listed in the `allowedExposePorts` config of the worker, other than those
+		// used by the worker itself, may be forwarded. Port forwarding is not
+		// available for tasks converted from docker-worker payloads, since the
+		// ports of their containers cannot be reached from the worker.
+		//
+		// Requires scope
+		// `generic-worker:interactive-port-forwarding:<provisionerId>/<workerType>`.
+		//
+		// Since: generic-worker 84.2.0
+		InteractivePortForwarding bool `json:"interactivePortForwarding,omitempty"`
+
 		// The live log feature streams the combined stderr and stdout to a task artifact
 		// so that the output is available while the task is running.
 		//
@@ -526,6 +740,17 @@ type (
 		// Array items:
 		Command [][]string `json:"command"`
 
+		// Options for the commands in `command`, matched by position: the first
+		// entry applies to the first command, and so on. If `commandOptions` is
+		// given, it must have exactly one entry per command; use an empty object
+		// for a command that needs the default options.
+		//
+		// Options make it possible, for example, to retry a flaky command, or to
+		// run a cleanup or report upload step even if an earlier command failed.
+		//
+		// Since: generic-worker 84.2.0
+		CommandOptions []CommandOptions `json:"commandOptions,omitempty"`
+
 		// Env vars must be string to __string__ mappings (not number or boolean). For example:
 		// ```
 		// {
@@ -568,11 +793,53 @@ type (
 		// Map entries:
 		Env map[string]string `json:"env,omitempty"`
 
+		// Ports on which the task serves, for example, a live preview of a
+		// web application, to be exposed while the task runs. Each exposed port
+		// is published as an artifact named
+		// `private/generic-worker/exposed-ports/<name>`, which redirects to the
+		// URL at which the port is exposed. The exposures are closed when the
+		// task's commands have finished.
+		//
+		// Only ports listed in the `allowedExposePorts` config of the worker can
+		// be exposed; by default, no ports can be exposed.
+		//
+		// Requires scope `generic-worker:expose-port:<provisionerId>/<workerType>`.
+		//
+		// Since: generic-worker 84.2.0
+		ExposePorts []ExposedPort `json:"exposePorts,omitempty"`
+
 		// Feature flags enable additional functionality.
 		//
 		// Since: generic-worker 5.3.0
 		Features FeatureFlags `json:"features,omitzero"`
 
+		// If set, and the task fails because one of its commands fails, the worker
+		// keeps the task environment (task directory, mounts and task user) after
+		// the commands have finished, for up to this many seconds, so that the
+		// failure can be investigated through the `interactive` feature. Artifacts
+		// are uploaded before the hold begins.
+		//
+		// The task is resolved, and the environment torn down, when the hold
+		// expires, when all interactive sessions that were running during the hold
+		// have ended, or when the task is cancelled. The hold ends five minutes
+		// before the task deadline at the latest.
+		//
+		// This property has no effect unless `features.interactive` is `true`.
+		// It is a separate property, rather than an option of
+		// `features.interactive`, since `features.interactive` is a boolean, and
+		// changing its type would break existing tasks. Like `maxRunTime`, the
+		// duration is given in seconds.
+		//
+		// Docker Worker tasks translated by d2g are not held, since their container
+		// has already exited when the commands have finished, so there is nothing to
+		// investigate in the task environment.
+		//
+		// Since: generic-worker 84.2.0
+		//
+		// Mininum:    0
+		// Maximum:    86400
+		InteractiveHoldOnFailure int64 `json:"interactiveHoldOnFailure,omitempty"`
+
 		// Configuration for task logs.
 		//
 		// Since: generic-worker 48.2.0
@@ -618,11 +885,16 @@ type (
 		// localhost interface (default) or search for a docker bridge
 		// interface (for tasks that wish to call the taskcluster
 		// proxy from inside a docker container that does not share
-		// the host network).
+		// the host network), or listen on a Unix domain socket that
+		// only the task user can connect to. In the latter case, the
+		// socket path is provided to the task in the environment
+		// variable `TASKCLUSTER_PROXY_SOCKET`, and can be bind-mounted
+		// into containers.
 		//
 		// Possible values:
 		//   * "localhost"
 		//   * "docker-bridge"
+		//   * "unix-socket"
 		//
 		// Default:    "localhost"
 		TaskclusterProxyInterface string `json:"taskclusterProxyInterface" default:"localhost"`
@@ -1170,6 +1442,73 @@ func JSONSchema() string {
           "type": "array",
           "uniqueItems": false
         },
+        "commandOptions": {
+          "description": "Options for the commands in ` + "`" + `command` + "`" + `, matched by position: the first\nentry applies to the first command, and so on. If ` + "`" + `commandOptions` + "`" + ` is\ngiven, it must have exactly one entry per command; use an empty object\nfor a command that needs the default options.\n\nOptions make it possible, for example, to retry a flaky command, or to\nrun a cleanup or report upload step even if an earlier command failed.\n\nSince: generic-worker 84.2.0",
+          "items": {
+            "additionalProperties": false,
+            "properties": {
+              "maxRunTime": {
+                "description": "If set, the command is terminated if it runs for more than this many\nseconds, which fails the task. The ` + "`" + `maxRunTime` + "`" + ` of the task still\napplies.\n\nSince: generic-worker 84.2.0",
+                "minimum": 1,
+                "multipleOf": 1,
+                "title": "Maximum run time of the command in seconds",
+                "type": "integer"
+              },
+              "retry": {
+                "additionalProperties": false,
+                "description": "If the command exits with one of the given exit codes, it is run\nagain, up to ` + "`" + `maxRetries` + "`" + ` times. Before the first retry, the worker waits\n` + "`" + `backoff` + "`" + ` seconds (by default, it doesn't wait), doubling the wait for\neach further retry.\n\nSince: generic-worker 84.2.0",
+                "properties": {
+                  "backoff": {
+                    "maximum": 3600,
+                    "minimum": 0,
+                    "title": "Seconds to wait before the first retry",
+                    "type": "integer"
+                  },
+                  "exitCodes": {
+                    "items": {
+                      "minimum": 1,
+                      "title": "Exit code",
+                      "type": "integer"
+                    },
+                    "minItems": 1,
+                    "title": "Exit codes to retry on",
+                    "type": "array",
+                    "uniqueItems": true
+                  },
+                  "maxRetries": {
+                    "default": 1,
+                    "maximum": 10,
+                    "minimum": 1,
+                    "title": "Maximum number of retries",
+                    "type": "integer"
+                  }
+                },
+                "required": [
+                  "exitCodes"
+                ],
+                "title": "Command retries",
+                "type": "object"
+              },
+              "runIf": {
+                "default": "onSuccess",
+                "description": "Whether the command runs depending on the outcome of the previous\ncommands. ` + "`" + `onSuccess` + "`" + ` runs the command only if no previous command\nfailed, ` + "`" + `onFailure` + "`" + ` only if a previous command failed, and ` + "`" + `always` + "`" + `\nruns it in either case. Commands are never run once the task has\nbeen aborted, for example because it exceeded its ` + "`" + `maxRunTime` + "`" + `, or\nwas cancelled. The task fails if any command that ran failed.\n\nSince: generic-worker 84.2.0",
+                "enum": [
+                  "onSuccess",
+                  "onFailure",
+                  "always"
+                ],
+                "title": "When to run the command",
+                "type": "string"
+              }
+            },
+            "required": [],
+            "title": "Command options",
+            "type": "object"
+          },
+          "title": "Options for each command",
+          "type": "array",
+          "uniqueItems": false
+        },
         "env": {
           "additionalProperties": {
             "type": "string"
@@ -1178,6 +1517,46 @@ func JSONSchema() string {
           "title": "Env vars",
           "type": "object"
         },
+        "exposePorts": {
+          "description": "Ports on which the task serves, for example, a live preview of a\nweb application, to be exposed while the task runs. Each exposed port\nis published as an artifact named\n` + "`" + `private/generic-worker/exposed-ports/\u003cname\u003e` + "`" + `, which redirects to the\nURL at which the port is exposed. The exposures are closed when the\ntask's commands have finished.\n\nOnly ports listed in the ` + "`" + `allowedExposePorts` + "`" + ` config of the worker can\nbe exposed; by default, no ports can be exposed.\n\nRequires scope ` + "`" + `generic-worker:expose-port:\u003cprovisionerId\u003e/\u003cworkerType\u003e` + "`" + `.\n\nSince: generic-worker 84.2.0",
+          "items": {
+            "additionalProperties": false,
+            "properties": {
+              "name": {
+                "description": "The name of the exposure, used in the name of its artifact. Names\nmust be unique within the task.\n\nSince: generic-worker 84.2.0",
+                "pattern": "^[a-zA-Z0-9_.-]{1,64}$",
+                "title": "Name",
+                "type": "string"
+              },
+              "port": {
+                "description": "The port, on the loopback interface of the worker, to expose.\n\nSince: generic-worker 84.2.0",
+                "maximum": 65535,
+                "minimum": 1,
+                "title": "Port",
+                "type": "integer"
+              },
+              "type": {
+                "default": "http",
+                "description": "Whether the port serves HTTP (including websockets), or is a plain\nTCP port. HTTP ports are exposed through a reverse proxy; TCP ports\nare exposed as a websocket, which carries the TCP connection.\n\nSince: generic-worker 84.2.0",
+                "enum": [
+                  "http",
+                  "tcp"
+                ],
+                "title": "Type",
+                "type": "string"
+              }
+            },
+            "required": [
+              "port",
+              "name"
+            ],
+            "title": "Exposed port",
+            "type": "object"
+          },
+          "title": "Exposed ports",
+          "type": "array",
+          "uniqueItems": false
+        },
         "features": {
           "additionalProperties": false,
           "description": "Feature flags enable additional functionality.\n\nSince: generic-worker 5.3.0",
@@ -1194,10 +1573,20 @@ func JSONSchema() string {
               "type": "boolean"
             },
             "interactive": {
-              "description": "This allows you to interactively run commands from within the worker\nas the task user. This may be useful for debugging purposes.\nCan be used for SSH-like access to the running worker.\nNote that this feature works differently from the ` + "`" + `interactive` + "`" + ` feature\nin docker worker, which ` + "`" + `docker exec` + "`" + `s into the running container.\nSince tasks on generic worker are not guaranteed to be running in a\ncontainer, a bash shell is started on the task user's account.\nA user can then ` + "`" + `docker exec` + "`" + ` into the a running container, if there\nis one.\n\nSince: generic-worker 49.2.0",
+              "description": "This allows you to interactively run commands from within the worker\nas the task user. This may be useful for debugging purposes.\nCan be used for SSH-like access to the running worker.\nNote that this feature works differently from the ` + "`" + `interactive` + "`" + ` feature\nin docker worker, which ` + "`" + `docker exec` + "`" + `s into the running container.\nSince tasks on generic worker are not guaranteed to be running in a\ncontainer, a bash shell is started on the task user's account.\nA user can then ` + "`" + `docker exec` + "`" + ` into the a running container, if there\nis one.\n\nA new shell session is started for each connection made through the\n` + "`" + `private/generic-worker/shell.html` + "`" + ` artifact, unless a session name is\ngiven in the ` + "`" + `session` + "`" + ` query parameter of the WebSocket URL, in which case\nthe running session of that name is joined, if there is one. Running\nsessions can be shared through the ` + "`" + `private/generic-worker/shell-codriver.html` + "`" + `\nartifact, which allows typing in the session, and the\n` + "`" + `private/generic-worker/shell-observer.html` + "`" + ` artifact, which only allows\nwatching it. A list of the sessions, with the number of clients attached to\neach, is uploaded as ` + "`" + `private/generic-worker/interactive-sessions.json` + "`" + `\nwhen the task ends.\n\nSince: generic-worker 49.2.0",
               "title": "Interactive shell",
               "type": "boolean"
             },
+            "interactiveFileTransfer": {
+              "description": "Allows clients of the ` + "`" + `interactive` + "`" + ` feature which connect with the\n` + "`" + `channels=true` + "`" + ` query parameter, and which may type in the session, to\nupload files to, and download files from, the task directory, as the\ntask user.\n\nRequires scope\n` + "`" + `generic-worker:interactive-file-transfer:\u003cprovisionerId\u003e/\u003cworkerType\u003e` + "`" + `.\n\nSince: generic-worker 84.2.0",
+              "title": "Interactive file transfer",
+              "type": "boolean"
+            },
+            "interactivePortForwarding": {
+              "description": "Allows clients of the ` + "`" + `interactive` + "`" + ` feature which connect with the\n` + "`" + `channels=true` + "`" + ` query parameter, and which may type in the session, to\nforward connections to TCP ports on localhost, for example to attach a\ndebugger or a browser to a server running in the task. Only the ports\nlisted in the ` + "`" + `allowedExposePorts` + "`" + ` config of the worker, other than those\nused by the worker itself, may be forwarded. Port forwarding is not\navailable for tasks converted from docker-worker payloads, since the\nports of their containers cannot be reached from the worker.\n\nRequires scope\n` + "`" + `generic-worker:interactive-port-forwarding:\u003cprovisionerId\u003e/\u003cworkerType\u003e` + "`" + `.\n\nSince: generic-worker 84.2.0",
+              "title": "Interactive port forwarding",
+              "type": "boolean"
+            },
             "liveLog": {
               "default": true,
               "description": "The live log feature streams the combined stderr and stdout to a task artifact\nso that the output is available while the task is running.\n\nSince: generic-worker 48.2.0",
@@ -1235,6 +1624,14 @@ func JSONSchema() string {
           "title": "Feature flags",
           "type": "object"
         },
+        "interactiveHoldOnFailure": {
+          "description": "If set, and the task fails because one of its commands fails, the worker\nkeeps the task environment (task directory, mounts and task user) after\nthe commands have finished, for up to this many seconds, so that the\nfailure can be investigated through the ` + "`" + `interactive` + "`" + ` feature. Artifacts\nare uploaded before the hold begins.\n\nThe task is resolved, and the environment torn down, when the hold\nexpires, when all interactive sessions that were running during the hold\nhave ended, or when the task is cancelled. The hold ends five minutes\nbefore the task deadline at the latest.\n\nThis property has no effect unless ` + "`" + `features.interactive` + "`" + ` is ` + "`" + `true` + "`" + `.\nIt is a separate property, rather than an option of\n` + "`" + `features.interactive` + "`" + `, since ` + "`" + `features.interactive` + "`" + ` is a boolean, and\nchanging its type would break existing tasks. Like ` + "`" + `maxRunTime` + "`" + `, the\nduration is given in seconds.\n\nDocker Worker tasks translated by d2g are not held, since their container\nhas already exited when the commands have finished, so there is nothing to\ninvestigate in the task environment.\n\nSince: generic-worker 84.2.0",
+          "maximum": 86400,
+          "minimum": 0,
+          "multipleOf": 1,
+          "title": "Interactive hold after failure",
+          "type": "integer"
+        },
         "logs": {
           "additionalProperties": false,
           "description": "Configuration for task logs.\n\nSince: generic-worker 48.2.0",
@@ -1287,6 +1684,105 @@ func JSONSchema() string {
               "type": "array",
               "uniqueItems": true
             },
+            "purgeNamedCaches": {
+              "description": "Like ` + "`" + `purgeCaches` + "`" + `, but only purges the named caches, rather than all\ncaches associated with the task. Each entry lists the exit codes that\ncause the given caches to be purged. Only caches that were mounted by\nthe task can be purged; any other cache names are ignored.\n\nSince: generic-worker 84.2.0",
+              "items": {
+                "additionalProperties": false,
+                "properties": {
+                  "cacheNames": {
+                    "description": "Names of the writable directory caches to purge.\n\nSince: generic-worker 84.2.0",
+                    "items": {
+                      "title": "Cache name",
+                      "type": "string"
+                    },
+                    "minItems": 1,
+                    "title": "Cache names",
+                    "type": "array",
+                    "uniqueItems": true
+                  },
+                  "exitCodes": {
+                    "description": "Exit statuses of the first command that failed, or of the last\ncommand if none failed, that cause the caches to be purged.\n\nSince: generic-worker 84.2.0",
+                    "items": {
+                      "minimum": 0,
+                      "title": "Exit status",
+                      "type": "integer"
+                    },
+                    "minItems": 1,
+                    "title": "Exit statuses",
+                    "type": "array",
+                    "uniqueItems": true
+                  }
+                },
+                "required": [
+                  "exitCodes",
+                  "cacheNames"
+                ],
+                "title": "Cache purge rule",
+                "type": "object"
+              },
+              "title": "Purge named caches exit statuses",
+              "type": "array"
+            },
+            "quarantineWorker": {
+              "description": "If the first command that failed, or the last command if none failed,\nexits with one of these exit statuses, the worker stops claiming tasks\nafter resolving the task, and reports an error to worker-manager, so\nthat the worker can be inspected. The worker remains quarantined until\nit is asked to shut down, or until its ` + "`" + `idleTimeoutSecs` + "`" + ` expires.\n\nRequires scope ` + "`" + `generic-worker:quarantine-worker:\u003cprovisionerId\u003e/\u003cworkerType\u003e` + "`" + `.\n\nSince: generic-worker 84.2.0",
+              "items": {
+                "minimum": 0,
+                "title": "Exit statuses",
+                "type": "integer"
+              },
+              "title": "Quarantine worker exit statuses",
+              "type": "array",
+              "uniqueItems": true
+            },
+            "rebootWorker": {
+              "description": "If the first command that failed, or the last command if none failed,\nexits with one of these exit statuses, the worker reboots after\nresolving the task, instead of claiming another task. Use this when an\nexit status means that the state of the machine is no longer\ntrustworthy. Reboots are skipped if the worker is configured with\n` + "`" + `disableReboots` + "`" + `, in which case the worker exits.\n\nRequires scope ` + "`" + `generic-worker:reboot-worker:\u003cprovisionerId\u003e/\u003cworkerType\u003e` + "`" + `.\n\nSince: generic-worker 84.2.0",
+              "items": {
+                "minimum": 0,
+                "title": "Exit statuses",
+                "type": "integer"
+              },
+              "title": "Reboot worker exit statuses",
+              "type": "array",
+              "uniqueItems": true
+            },
+            "resolveAs": {
+              "description": "Exit statuses for any command in the task payload that cause the task\nto be resolved as ` + "`" + `exception/\u003creason\u003e` + "`" + ` rather than ` + "`" + `failed/failed` + "`" + `.\nIf an exit status is also listed in ` + "`" + `retry` + "`" + `, the task is resolved as\n` + "`" + `exception/intermittent-task` + "`" + `.\n\nSince: generic-worker 84.2.0",
+              "items": {
+                "additionalProperties": false,
+                "properties": {
+                  "exitCodes": {
+                    "description": "Exit statuses that cause the task to be resolved as an exception\nwith the given reason.\n\nSince: generic-worker 84.2.0",
+                    "items": {
+                      "minimum": 1,
+                      "title": "Exit status",
+                      "type": "integer"
+                    },
+                    "minItems": 1,
+                    "title": "Exit statuses",
+                    "type": "array",
+                    "uniqueItems": true
+                  },
+                  "reason": {
+                    "description": "The reason to resolve the task exception with.\n\nSince: generic-worker 84.2.0",
+                    "enum": [
+                      "malformed-payload",
+                      "resource-unavailable",
+                      "internal-error"
+                    ],
+                    "title": "Exception reason",
+                    "type": "string"
+                  }
+                },
+                "required": [
+                  "exitCodes",
+                  "reason"
+                ],
+                "title": "Exception resolution",
+                "type": "object"
+              },
+              "title": "Exception exit statuses",
+              "type": "array"
+            },
             "retry": {
               "description": "Exit codes for any command in the task payload to cause this task to\nbe resolved as ` + "`" + `exception/intermittent-task` + "`" + `. Typically the Queue\nwill then schedule a new run of the existing ` + "`" + `taskId` + "`" + ` (rerun) if not\nall task runs have been exhausted.\n\nSee [itermittent tasks](https://docs.taskcluster.net/docs/reference/platform/taskcluster-queue/docs/worker-interaction#intermittent-tasks) for more detail.\n\nSince: generic-worker 10.10.0",
               "items": {
@@ -1319,10 +1815,11 @@ func JSONSchema() string {
         },
         "taskclusterProxyInterface": {
           "default": "localhost",
-          "description": "Specifies whether taskcluster-proxy should listen on\nlocalhost interface (default) or search for a docker bridge\ninterface (for tasks that wish to call the taskcluster\nproxy from inside a docker container that does not share\nthe host network).",
+          "description": "Specifies whether taskcluster-proxy should listen on\nlocalhost interface (default) or search for a docker bridge\ninterface (for tasks that wish to call the taskcluster\nproxy from inside a docker container that does not share\nthe host network), or listen on a Unix domain socket that\nonly the task user can connect to. In the latter case, the\nsocket path is provided to the task in the environment\nvariable ` + "`" + `TASKCLUSTER_PROXY_SOCKET` + "`" + `, and can be bind-mounted\ninto containers.",
           "enum": [
             "localhost",
-            "docker-bridge"
+            "docker-bridge",
+            "unix-socket"
           ],
           "title": "Network Interface for Taskcluster Proxy to listen on",
           "type": "string"
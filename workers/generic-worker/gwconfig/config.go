@@ -27,6 +27,7 @@ type (
 	PublicConfig struct {
 		PublicEngineConfig
 		PublicPlatformConfig
+		AllowedExposePorts             []uint16       `json:"allowedExposePorts"`
 		AvailabilityZone               string         `json:"availabilityZone"`
 		CachesDir                      string         `json:"cachesDir"`
 		CheckForNewDeploymentEverySecs uint           `json:"checkForNewDeploymentEverySecs"`
@@ -50,24 +51,33 @@ type (
 		InstanceID                     string         `json:"instanceId"`
 		InstanceType                   string         `json:"instanceType"`
 		InteractivePort                uint16         `json:"interactivePort"`
+		InteractiveRecording           bool           `json:"interactiveRecording"`
+		InteractiveRecordingStdin      bool           `json:"interactiveRecordingStdin"`
 		LiveLogExecutable              string         `json:"livelogExecutable"`
 		LiveLogPortBase                uint16         `json:"livelogPortBase"`
 		LiveLogExposePort              uint16         `json:"livelogExposePort"`
 		MaxTaskRunTime                 uint32         `json:"maxTaskRunTime"`
 		NumberOfTasksToRun             uint           `json:"numberOfTasksToRun"`
+		PostTaskCommand                []string       `json:"postTaskCommand"`
+		PreTaskCommand                 []string       `json:"preTaskCommand"`
 		PrivateIP                      net.IP         `json:"privateIP"`
 		ProvisionerID                  string         `json:"provisionerId"`
 		PublicIP                       net.IP         `json:"publicIP"`
 		Region                         string         `json:"region"`
+		RelayURL                       string         `json:"relayURL"`
 		RequiredDiskSpaceMegabytes     uint           `json:"requiredDiskSpaceMegabytes"`
 		RootURL                        string         `json:"rootURL"`
 		RunAfterUserCreation           string         `json:"runAfterUserCreation"`
 		SentryProject                  string         `json:"sentryProject"`
 		ShutdownMachineOnIdle          bool           `json:"shutdownMachineOnIdle"`
 		ShutdownMachineOnInternalError bool           `json:"shutdownMachineOnInternalError"`
+		TaskclusterProxyCacheSize      uint           `json:"taskclusterProxyCacheSize"`
+		TaskclusterProxyDeny           []string       `json:"taskclusterProxyDeny"`
 		TaskclusterProxyExecutable     string         `json:"taskclusterProxyExecutable"`
 		TaskclusterProxyPort           uint16         `json:"taskclusterProxyPort"`
+		TaskHookTimeoutSecs            uint           `json:"taskHookTimeoutSecs"`
 		TasksDir                       string         `json:"tasksDir"`
+		TaskTerminationGracePeriodSecs uint           `json:"taskTerminationGracePeriodSecs"`
 		WorkerGroup                    string         `json:"workerGroup"`
 		WorkerID                       string         `json:"workerId"`
 		WorkerLocation                 string         `json:"workerLocation,omitempty"`
@@ -80,6 +90,7 @@ type (
 	PrivateConfig struct {
 		AccessToken string `json:"accessToken"`
 		Certificate string `json:"certificate"`
+		RelaySecret string `json:"relaySecret"`
 	}
 
 	MissingConfigError struct {
@@ -14,20 +14,21 @@ type PublicPlatformConfig struct {
 func DefaultPublicPlatformConfig() *PublicPlatformConfig {
 	return &PublicPlatformConfig{
 		D2GConfig: map[string]any{
-			"enableD2G":             false,
-			"allowChainOfTrust":     true,
-			"allowDisableSeccomp":   true,
-			"allowGPUs":             false,
-			"allowHostSharedMemory": true,
-			"allowInteractive":      true,
-			"allowKVM":              true,
-			"allowLoopbackAudio":    true,
-			"allowLoopbackVideo":    true,
-			"allowPrivileged":       true,
-			"allowPtrace":           true,
-			"allowTaskclusterProxy": true,
-			"gpus":                  "all",
-			"logTranslation":        true,
+			"enableD2G":                  false,
+			"allowChainOfTrust":          true,
+			"allowDisableSeccomp":        true,
+			"allowGPUs":                  false,
+			"allowHostSharedMemory":      true,
+			"allowInteractive":           true,
+			"allowKVM":                   true,
+			"allowLoopbackAudio":         true,
+			"allowLoopbackVideo":         true,
+			"allowPrivileged":            true,
+			"allowPtrace":                true,
+			"allowTaskclusterProxy":      true,
+			"gpus":                       "all",
+			"logTranslation":             true,
+			"taskclusterProxyUnixSocket": false,
 		},
 		DisableNativePayloads:     false,
 		EnableLoopbackAudio:       true,
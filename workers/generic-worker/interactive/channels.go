@@ -0,0 +1,297 @@
+package interactive
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+)
+
+// Channel message types. Clients which connect with the query parameter
+// channels=true may open channels for transferring files and forwarding TCP
+// connections, which are multiplexed over the WebSocket connection alongside
+// the terminal. Since the server then needs to send more than terminal output,
+// all messages it sends on such connections start with their type too, like
+// the messages sent by clients, and terminal output is sent as MsgOutput.
+//
+// The type of channel messages is followed by the channel ID, chosen by the
+// client when opening the channel, as a little endian uint32.
+const (
+	// MsgOutput is terminal output, sent by the server.
+	MsgOutput = 3
+	// MsgUploadFile [id][path] opens a channel for writing the file at the
+	// given path, relative to the task directory. The client sends the
+	// content of the file as MsgData, followed by MsgClose, which the server
+	// acknowledges with MsgClose once the file has been written.
+	MsgUploadFile = 4
+	// MsgDownloadFile [id][path] opens a channel for reading the file at the
+	// given path, relative to the task directory. The server sends the content
+	// of the file as MsgData, followed by MsgClose.
+	MsgDownloadFile = 5
+	// MsgForwardPort [id][port] opens a channel connected to the given TCP
+	// port (a little endian uint16) on localhost, which must be one of the
+	// ports that may be forwarded. Data is sent in both directions as
+	// MsgData, until either side sends MsgClose.
+	MsgForwardPort = 6
+	// MsgData [id][data] is data sent over a channel.
+	MsgData = 7
+	// MsgClose [id] closes a channel.
+	MsgClose = 8
+	// MsgError [id][message] is sent by the server if a channel fails, which
+	// closes the channel.
+	MsgError = 9
+)
+
+// maximum number of channels a client may have open at the same time
+const maxChannels = 64
+
+// Channels determines which channels clients may open. Channels can only be
+// opened by clients which are allowed to type in a session.
+type Channels struct {
+	// OpenFile, if set, opens the file at the given path, relative to the
+	// task directory, for downloading.
+	OpenFile func(path string) (io.ReadCloser, error)
+	// CreateFile, if set, creates (or truncates) the file at the given path,
+	// relative to the task directory, for uploading.
+	CreateFile func(path string) (io.WriteCloser, error)
+	// ForwardPorts lists the TCP ports on localhost to which clients may
+	// forward connections.
+	ForwardPorts []uint16
+}
+
+// channel is an open channel of a client.
+type channel struct {
+	// where data sent by the client is written to, nil for downloads
+	in io.WriteCloser
+	// where data sent to the client is read from, nil for uploads; closed
+	// when the client closes the channel
+	out io.ReadCloser
+}
+
+func (c *client) handleChannelMessage(msg []byte) {
+	if len(msg) < 5 {
+		log.Printf("Invalid channel message received from interactive client")
+		return
+	}
+	id := binary.LittleEndian.Uint32(msg[1:5])
+	body := msg[5:]
+	var err error
+	switch msg[0] {
+	case MsgUploadFile:
+		err = c.uploadFile(id, string(body))
+	case MsgDownloadFile:
+		err = c.downloadFile(id, string(body))
+	case MsgForwardPort:
+		err = c.forwardPort(id, body)
+	case MsgData:
+		err = c.channelData(id, body)
+	case MsgClose:
+		c.closeChannel(id)
+	default:
+		log.Printf("Unknown message code received from interactive task")
+	}
+	if err != nil {
+		_ = c.sendChannelMessage(MsgError, id, []byte(err.Error()))
+	}
+}
+
+func (c *client) uploadFile(id uint32, path string) error {
+	if c.channels.CreateFile == nil {
+		return fmt.Errorf("uploading files is not enabled for this task")
+	}
+	path, err := localPath(path)
+	if err != nil {
+		return err
+	}
+	return c.openChannel(id, func() (*channel, error) {
+		w, err := c.channels.CreateFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &channel{in: w}, nil
+	})
+}
+
+func (c *client) downloadFile(id uint32, path string) error {
+	if c.channels.OpenFile == nil {
+		return fmt.Errorf("downloading files is not enabled for this task")
+	}
+	path, err := localPath(path)
+	if err != nil {
+		return err
+	}
+	return c.openChannel(id, func() (*channel, error) {
+		r, err := c.channels.OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &channel{out: r}, nil
+	})
+}
+
+func (c *client) forwardPort(id uint32, body []byte) error {
+	if len(c.channels.ForwardPorts) == 0 {
+		return fmt.Errorf("port forwarding is not enabled for this task")
+	}
+	if len(body) != 2 {
+		return fmt.Errorf("invalid port forwarding request")
+	}
+	port := binary.LittleEndian.Uint16(body)
+	if !slices.Contains(c.channels.ForwardPorts, port) {
+		return fmt.Errorf("port %v may not be forwarded; only ports %v may be", port, c.channels.ForwardPorts)
+	}
+	return c.openChannel(id, func() (*channel, error) {
+		conn, err := net.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(int(port))))
+		if err != nil {
+			return nil, err
+		}
+		return &channel{in: conn, out: conn}, nil
+	})
+}
+
+// openChannel registers the channel returned by open under the given id, if
+// the id is not already in use, and starts sending its output to the client.
+func (c *client) openChannel(id uint32, open func() (*channel, error)) error {
+	c.channelsLock.Lock()
+	defer c.channelsLock.Unlock()
+	if _, exists := c.open[id]; exists {
+		return fmt.Errorf("channel %v is already open", id)
+	}
+	if len(c.open) >= maxChannels {
+		return fmt.Errorf("too many open channels")
+	}
+	ch, err := open()
+	if err != nil {
+		return err
+	}
+	c.open[id] = ch
+	if ch.out != nil {
+		go c.send(id, ch.out)
+	}
+	return nil
+}
+
+func (c *client) channelData(id uint32, data []byte) error {
+	c.channelsLock.Lock()
+	ch := c.open[id]
+	c.channelsLock.Unlock()
+	if ch == nil || ch.in == nil {
+		return fmt.Errorf("channel %v is not open for writing", id)
+	}
+	_, err := ch.in.Write(data)
+	if err != nil {
+		c.removeChannel(id)
+		c.closeQuietly(ch)
+	}
+	return err
+}
+
+// closeChannel closes a channel at the request of the client. For uploads,
+// the client is told whether the file was written successfully.
+func (c *client) closeChannel(id uint32) {
+	ch := c.removeChannel(id)
+	if ch == nil {
+		return
+	}
+	if ch.out != nil {
+		c.closeQuietly(ch)
+		return
+	}
+	err := ch.in.Close()
+	if err != nil {
+		_ = c.sendChannelMessage(MsgError, id, []byte(err.Error()))
+		return
+	}
+	_ = c.sendChannelMessage(MsgClose, id, nil)
+}
+
+// closeChannels closes all open channels, when the client detaches.
+func (c *client) closeChannels() {
+	c.channelsLock.Lock()
+	open := c.open
+	c.open = map[uint32]*channel{}
+	c.channelsLock.Unlock()
+	for _, ch := range open {
+		c.closeQuietly(ch)
+	}
+}
+
+func (c *client) removeChannel(id uint32) *channel {
+	c.channelsLock.Lock()
+	defer c.channelsLock.Unlock()
+	ch := c.open[id]
+	delete(c.open, id)
+	return ch
+}
+
+func (c *client) closeQuietly(ch *channel) {
+	if ch.out != nil {
+		_ = ch.out.Close()
+	}
+	if ch.in != nil {
+		// for forwarded ports, this closes the connection a second time,
+		// which is harmless
+		_ = ch.in.Close()
+	}
+}
+
+// send sends everything read from r to the client as MsgData, followed by
+// MsgClose, or MsgError if reading fails. Nothing more is sent once the
+// channel has been closed by the client.
+func (c *client) send(id uint32, r io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if !c.isOpen(id) {
+				return
+			}
+			if writeErr := c.sendChannelMessage(MsgData, id, buf[:n]); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			ch := c.removeChannel(id)
+			if ch == nil {
+				// closed by the client
+				return
+			}
+			c.closeQuietly(ch)
+			if err == io.EOF {
+				_ = c.sendChannelMessage(MsgClose, id, nil)
+			} else {
+				_ = c.sendChannelMessage(MsgError, id, []byte(err.Error()))
+			}
+			return
+		}
+	}
+}
+
+func (c *client) isOpen(id uint32) bool {
+	c.channelsLock.Lock()
+	defer c.channelsLock.Unlock()
+	return c.open[id] != nil
+}
+
+func (c *client) sendChannelMessage(msgType byte, id uint32, body []byte) error {
+	msg := make([]byte, 5, 5+len(body))
+	msg[0] = msgType
+	binary.LittleEndian.PutUint32(msg[1:5], id)
+	return c.writeWsMessage(websocket.BinaryMessage, append(msg, body...))
+}
+
+// localPath checks that path, which uses forward slashes, is a relative path
+// within the task directory, and converts it to a native path.
+func localPath(path string) (string, error) {
+	native := filepath.FromSlash(path)
+	if !filepath.IsLocal(native) {
+		return "", fmt.Errorf("invalid path %q: must be relative to the task directory, without ..", path)
+	}
+	return native, nil
+}
@@ -3,12 +3,15 @@ package interactive
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -28,37 +31,100 @@ var upgrader = &websocket.Upgrader{
 	},
 }
 
+// Role is the role of a client connected to an interactive session, which is
+// determined by the access token it connects with.
+type Role string
+
+const (
+	// Owner may start new sessions, and attach to existing ones.
+	Owner Role = "owner"
+	// CoDriver may attach to existing sessions, and type in them.
+	CoDriver Role = "coDriver"
+	// Observer may attach to existing sessions, but only sees their output.
+	Observer Role = "observer"
+)
+
+func (role Role) canDrive() bool {
+	return role == Owner || role == CoDriver
+}
+
+// valid session names, given in the session query parameter
+var sessionNameRegExp = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
 type Interactive struct {
 	TCPPort             uint16
 	GetURL              string
+	GetCoDriverURL      string
+	GetObserverURL      string
+	Recorder            *Recorder // if set, records all sessions
+	Channels            Channels  // channels that clients may open
 	secret              string
+	coDriverSecret      string
+	observerSecret      string
 	ctx                 context.Context
 	interactiveCommands InteractiveCommands
+	// held while starting a session, so that two clients cannot start
+	// sessions with the same name
+	createLock   sync.Mutex
+	sessionsLock sync.Mutex
+	// all sessions, including ended ones, in the order they were started
+	sessions []*session
+}
+
+// session is a named InteractiveJob.
+type session struct {
+	name    string
+	job     *InteractiveJob
+	started time.Time
+	ended   time.Time
+}
+
+// SessionInfo describes an interactive session.
+type SessionInfo struct {
+	Name    string     `json:"name"`
+	Started time.Time  `json:"started"`
+	Ended   *time.Time `json:"ended,omitempty"`
+	// number of clients currently attached, per role
+	Clients map[Role]int `json:"clients"`
+	// number of clients attached since the session started, per role
+	TotalClients map[Role]int `json:"totalClients"`
 }
 
 func New(port uint16, interactiveCommands InteractiveCommands, ctx context.Context) (it *Interactive, err error) {
 	it = &Interactive{
 		TCPPort:             port,
 		secret:              slugid.Nice(),
+		coDriverSecret:      slugid.Nice(),
+		observerSecret:      slugid.Nice(),
 		interactiveCommands: interactiveCommands,
 		ctx:                 ctx,
 	}
 
 	it.setRequestURL()
 	os.Setenv("INTERACTIVE_ACCESS_TOKEN", it.secret)
+	os.Setenv("INTERACTIVE_CODRIVER_ACCESS_TOKEN", it.coDriverSecret)
+	os.Setenv("INTERACTIVE_OBSERVER_ACCESS_TOKEN", it.observerSecret)
 
 	return
 }
 
+// Handler serves the WebSocket connections of interactive sessions, at
+// /shell/<access token>. The optional session query parameter names the
+// session to attach to. If it is not given, owners start a new session, and
+// other clients attach to the most recently started session that is still
+// running. Clients which pass channels=true may open channels, see
+// MsgUploadFile, MsgDownloadFile and MsgForwardPort.
 func (it *Interactive) Handler(w http.ResponseWriter, r *http.Request) {
-	accessToken := os.Getenv("INTERACTIVE_ACCESS_TOKEN")
-	secret := strings.TrimPrefix(r.URL.Path, "/shell/")
-	// Authenticate the request with accessToken, this is good enough because
-	// interactive shells are short-lived.
-	if secret != accessToken {
+	role, ok := it.authenticate(strings.TrimPrefix(r.URL.Path, "/shell/"))
+	if !ok {
 		http.Error(w, "Access denied", http.StatusUnauthorized)
 		return
 	}
+	name := r.URL.Query().Get("session")
+	if name != "" && !sessionNameRegExp.MatchString(name) {
+		http.Error(w, "Invalid session name", http.StatusBadRequest)
+		return
+	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -67,32 +133,167 @@ func (it *Interactive) Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = it.waitUntilReady(conn)
-	if err != nil {
-		log.Printf("Failed while waiting to create an interactive job, closing connection. %v", err)
-		http.Error(w, "Failed to start interactive command", http.StatusInternalServerError)
-		return
-	}
-	itj, err := CreateInteractiveJob(it.interactiveCommands.InteractiveCmd, conn, it.ctx)
-	if err != nil {
-		log.Printf("Error while spawning interactive job: %v", err)
-		return
-	}
-
 	defer func() {
 		if err := conn.Close(); err != nil {
 			log.Printf("WebSocket close error: %v", err)
 		}
 	}()
 
+	var channels *Channels
+	if r.URL.Query().Get("channels") == "true" {
+		channels = &it.Channels
+	}
+	term := terminal{conn: conn, framed: channels != nil}
+
+	var sess *session
+	if role == Owner {
+		sess, err = it.findOrStartSession(term, name)
+		if err != nil {
+			log.Printf("Error while starting interactive session: %v", err)
+			_ = term.WriteMessage(websocket.BinaryMessage, []byte(err.Error()))
+			return
+		}
+	} else {
+		sess = it.findSession(name)
+		if sess == nil {
+			_ = term.WriteMessage(websocket.BinaryMessage, []byte("No running interactive session to attach to.\r\n"))
+			return
+		}
+	}
+
+	detached := sess.job.Attach(conn, role, channels)
+
 	select {
 	case <-it.ctx.Done():
-	case <-itj.done:
+	case <-sess.job.Done():
+	case <-detached:
+	}
+}
+
+// SessionsHandler serves the list of interactive sessions as json, at
+// /sessions/<access token>, to clients with any role.
+func (it *Interactive) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := it.authenticate(strings.TrimPrefix(r.URL.Path, "/sessions/")); !ok {
+		http.Error(w, "Access denied", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(it.Sessions())
+	if err != nil {
+		log.Printf("Error writing interactive sessions: %v", err)
+	}
+}
+
+// Sessions returns information about all interactive sessions that have been
+// started, in the order they were started.
+func (it *Interactive) Sessions() []SessionInfo {
+	it.sessionsLock.Lock()
+	defer it.sessionsLock.Unlock()
+	infos := make([]SessionInfo, 0, len(it.sessions))
+	for _, sess := range it.sessions {
+		info := SessionInfo{
+			Name:    sess.name,
+			Started: sess.started,
+		}
+		if !sess.ended.IsZero() {
+			ended := sess.ended
+			info.Ended = &ended
+		}
+		info.Clients, info.TotalClients = sess.job.Clients()
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// authenticate returns the role of the given access token. This is good
+// enough because interactive shells are short-lived.
+func (it *Interactive) authenticate(accessToken string) (Role, bool) {
+	switch accessToken {
+	case it.secret:
+		return Owner, true
+	case it.coDriverSecret:
+		return CoDriver, true
+	case it.observerSecret:
+		return Observer, true
+	}
+	return "", false
+}
+
+// findSession returns the running session with the given name, or if name is
+// empty, the most recently started running session. It returns nil if there
+// is no such session.
+func (it *Interactive) findSession(name string) *session {
+	it.sessionsLock.Lock()
+	defer it.sessionsLock.Unlock()
+	for i := len(it.sessions) - 1; i >= 0; i-- {
+		sess := it.sessions[i]
+		if sess.ended.IsZero() && (name == "" || sess.name == name) {
+			return sess
+		}
+	}
+	return nil
+}
+
+// findOrStartSession returns the running session with the given name, or
+// starts a new one. If name is empty, a new session is always started, with a
+// generated name.
+func (it *Interactive) findOrStartSession(conn terminal, name string) (*session, error) {
+	it.createLock.Lock()
+	defer it.createLock.Unlock()
+	if name != "" {
+		if sess := it.findSession(name); sess != nil {
+			return sess, nil
+		}
+	}
+
+	err := it.waitUntilReady(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed while waiting to create an interactive job: %v", err)
+	}
+	itj, err := CreateInteractiveJob(it.interactiveCommands.InteractiveCmd, it.Recorder, it.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	it.sessionsLock.Lock()
+	defer it.sessionsLock.Unlock()
+	if name == "" {
+		name = fmt.Sprintf("shell-%d", len(it.sessions)+1)
+	}
+	sess := &session{
+		name:    name,
+		job:     itj,
+		started: time.Now(),
+	}
+	it.sessions = append(it.sessions, sess)
+	go func() {
+		select {
+		case <-it.ctx.Done():
+		case <-itj.Done():
+		}
+		it.sessionsLock.Lock()
+		defer it.sessionsLock.Unlock()
+		sess.ended = time.Now()
+	}()
+	return sess, nil
+}
+
+// terminal writes terminal output to a client which is not attached to a
+// session yet.
+type terminal struct {
+	conn   *websocket.Conn
+	framed bool
+}
+
+func (t terminal) WriteMessage(messageType int, data []byte) error {
+	if t.framed {
+		data = append([]byte{MsgOutput}, data...)
 	}
+	return t.conn.WriteMessage(messageType, data)
 }
 
 // If the interactive task has a `IsReadyCmd` declared, run that command until it succeeds or until timeout.
-func (it *Interactive) waitUntilReady(conn *websocket.Conn) (err error) {
+func (it *Interactive) waitUntilReady(conn terminal) (err error) {
 	if it.interactiveCommands.IsReadyCmd == nil {
 		return nil
 	}
@@ -137,6 +338,7 @@ func (it *Interactive) waitUntilReady(conn *websocket.Conn) (err error) {
 func (it *Interactive) ListenAndServe(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/shell/", it.Handler)
+	mux.HandleFunc("/sessions/", it.SessionsHandler)
 	server := http.Server{
 		Addr:    fmt.Sprintf(":%d", it.TCPPort),
 		Handler: mux,
@@ -161,4 +363,6 @@ func (it *Interactive) ListenAndServe(ctx context.Context) error {
 
 func (it *Interactive) setRequestURL() {
 	it.GetURL = fmt.Sprintf("http://localhost:%v/shell/%v", it.TCPPort, it.secret)
+	it.GetCoDriverURL = fmt.Sprintf("http://localhost:%v/shell/%v", it.TCPPort, it.coDriverSecret)
+	it.GetObserverURL = fmt.Sprintf("http://localhost:%v/shell/%v", it.TCPPort, it.observerSecret)
 }
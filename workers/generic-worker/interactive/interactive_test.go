@@ -5,15 +5,20 @@ package interactive
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/gorilla/websocket"
+	"github.com/taskcluster/taskcluster/v84/internal/asciicast"
 )
 
 func TestInteractiveWithReadyCommand(t *testing.T) {
@@ -31,7 +36,7 @@ func TestInteractiveWithReadyCommand(t *testing.T) {
 		InteractiveCmd: cmd,
 	}
 
-	testInteractive(t, 53766, interactiveCommands, ctx)
+	testInteractive(t, 53766, interactiveCommands, nil, ctx)
 
 	if !isReadyCreated {
 		t.Fatalf("The isReady command never got created")
@@ -47,16 +52,328 @@ func TestInteractiveNormal(t *testing.T) {
 		InteractiveCmd: cmd,
 	}
 
-	testInteractive(t, 53765, interactiveCommands, ctx)
+	testInteractive(t, 53765, interactiveCommands, nil, ctx)
 }
 
-func testInteractive(t *testing.T, port uint16, interactiveCommands InteractiveCommands, ctx context.Context) {
+func TestInteractiveRecording(t *testing.T) {
+	ctx := t.Context()
+
+	cmd := func() (*exec.Cmd, error) { return exec.CommandContext(ctx, "bash"), nil }
+	interactiveCommands := InteractiveCommands{
+		IsReadyCmd:     nil,
+		InteractiveCmd: cmd,
+	}
+
+	for _, recordStdin := range []bool{false, true} {
+		recording := &bytes.Buffer{}
+		recorder, err := NewRecorder(recording, recordStdin)
+		if err != nil {
+			t.Fatalf("could not create recorder: %v", err)
+		}
+		testInteractive(t, 53767, interactiveCommands, recorder, ctx)
+		recorder.Close()
+
+		reader, err := asciicast.NewReader(recording)
+		if err != nil {
+			t.Fatalf("could not read recording: %v", err)
+		}
+		output := ""
+		inputs := 0
+		for {
+			event, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("invalid recording event: %v", err)
+			}
+			switch event.Type {
+			case asciicast.Output:
+				output += event.Data
+			case asciicast.Input:
+				inputs++
+			}
+		}
+		if !strings.Contains(output, "S3ntin3lValue") {
+			t.Fatalf("Recorded output %q does not contain the session output", output)
+		}
+		if recordStdin != (inputs > 0) {
+			t.Fatalf("Recorded %v input events with recordStdin %v", inputs, recordStdin)
+		}
+	}
+}
+
+func TestInteractiveSharedSession(t *testing.T) {
+	ctx := t.Context()
+
+	cmd := func() (*exec.Cmd, error) { return exec.CommandContext(ctx, "bash"), nil }
+	interactive, err := New(53768, InteractiveCommands{InteractiveCmd: cmd}, ctx)
+	if err != nil {
+		t.Fatalf("could not create interactive session: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(interactive.Handler))
+	defer server.Close()
+
+	// observers cannot start sessions
+	observer := dial(t, server, os.Getenv("INTERACTIVE_OBSERVER_ACCESS_TOKEN"), "session=debug")
+	_, msg, err := observer.ReadMessage()
+	if err != nil || !strings.Contains(string(msg), "No running interactive session") {
+		t.Fatalf("Expected observer to be refused, got %q (%v)", msg, err)
+	}
+	_ = observer.Close()
+
+	owner := dial(t, server, os.Getenv("INTERACTIVE_ACCESS_TOKEN"), "session=debug")
+	defer owner.Close()
+	sendInput(t, owner, "echo Own3rValue\n")
+	readUntil(t, owner, "Own3rValue", 2)
+
+	observer = dial(t, server, os.Getenv("INTERACTIVE_OBSERVER_ACCESS_TOKEN"), "session=debug")
+	defer observer.Close()
+	coDriver := dial(t, server, os.Getenv("INTERACTIVE_CODRIVER_ACCESS_TOKEN"), "")
+	defer coDriver.Close()
+
+	// input of observers is ignored, input of co-drivers is sent to the
+	// shared shell, and the output is sent to all clients
+	sendInput(t, observer, "echo Obs3rverValue\n")
+	sendInput(t, coDriver, "echo Obs3rverValue | tr O 0\n")
+	for _, conn := range []*websocket.Conn{owner, observer, coDriver} {
+		output := readUntil(t, conn, "0bs3rverValue", 1)
+		if strings.Contains(output, "Obs3rverValue\r\n") {
+			t.Fatalf("Input of observer was not ignored: %q", output)
+		}
+	}
+
+	sessions := interactive.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("Expected 1 session, but got %#v", sessions)
+	}
+	if sessions[0].Name != "debug" || sessions[0].Ended != nil {
+		t.Fatalf("Unexpected session %#v", sessions[0])
+	}
+	for _, role := range []Role{Owner, CoDriver, Observer} {
+		if sessions[0].Clients[role] != 1 || sessions[0].TotalClients[role] != 1 {
+			t.Fatalf("Expected one %v attached, but got %#v", role, sessions[0])
+		}
+	}
+
+	// the sessions are also served as json
+	res := httptest.NewRecorder()
+	interactive.SessionsHandler(res, httptest.NewRequest("GET", "/sessions/"+os.Getenv("INTERACTIVE_OBSERVER_ACCESS_TOKEN"), nil))
+	if res.Code != 200 || !strings.Contains(res.Body.String(), `"name":"debug"`) {
+		t.Fatalf("Unexpected sessions response %v: %v", res.Code, res.Body)
+	}
+	res = httptest.NewRecorder()
+	interactive.SessionsHandler(res, httptest.NewRequest("GET", "/sessions/wrong", nil))
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected sessions request with wrong token to be refused, but got %v", res.Code)
+	}
+}
+
+func TestInteractiveChannels(t *testing.T) {
+	ctx := t.Context()
+
+	dir := t.TempDir()
+	cmd := func() (*exec.Cmd, error) { return exec.CommandContext(ctx, "bash"), nil }
+	interactive, err := New(53769, InteractiveCommands{InteractiveCmd: cmd}, ctx)
+	if err != nil {
+		t.Fatalf("could not create interactive session: %v", err)
+	}
+
+	// a TCP server which echoes what it reads
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	interactive.Channels = Channels{
+		OpenFile: func(path string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(dir, path))
+		},
+		CreateFile: func(path string) (io.WriteCloser, error) {
+			return os.Create(filepath.Join(dir, path))
+		},
+		ForwardPorts: []uint16{uint16(listener.Addr().(*net.TCPAddr).Port)},
+	}
+	server := httptest.NewServer(http.HandlerFunc(interactive.Handler))
+	defer server.Close()
+
+	conn := dial(t, server, os.Getenv("INTERACTIVE_ACCESS_TOKEN"), "channels=true")
+	defer conn.Close()
+
+	// terminal output is framed
+	sendInput(t, conn, "echo Fr4m3dValue\n")
+	output := ""
+	for !strings.Contains(output, "Fr4m3dValue\r\n") {
+		msgType, _, data := readChannelMessage(t, conn)
+		if msgType != MsgOutput {
+			t.Fatalf("Expected terminal output, but got message type %v", msgType)
+		}
+		output += string(data)
+	}
+
+	// upload
+	sendChannelMessage(t, conn, MsgUploadFile, 1, []byte("uploaded.txt"))
+	sendChannelMessage(t, conn, MsgData, 1, []byte("hello "))
+	sendChannelMessage(t, conn, MsgData, 1, []byte("world"))
+	sendChannelMessage(t, conn, MsgClose, 1, nil)
+	if data, msgType := readChannel(t, conn, 1); msgType != MsgClose || len(data) != 0 {
+		t.Fatalf("Upload failed: %v %q", msgType, data)
+	}
+	uploaded, err := os.ReadFile(filepath.Join(dir, "uploaded.txt"))
+	if err != nil || string(uploaded) != "hello world" {
+		t.Fatalf("Unexpected uploaded file %q (%v)", uploaded, err)
+	}
+
+	// download
+	sendChannelMessage(t, conn, MsgDownloadFile, 2, []byte("uploaded.txt"))
+	if data, msgType := readChannel(t, conn, 2); msgType != MsgClose || string(data) != "hello world" {
+		t.Fatalf("Download failed: %v %q", msgType, data)
+	}
+
+	// files outside the task directory cannot be accessed
+	sendChannelMessage(t, conn, MsgDownloadFile, 3, []byte("../uploaded.txt"))
+	if data, msgType := readChannel(t, conn, 3); msgType != MsgError || !strings.Contains(string(data), "invalid path") {
+		t.Fatalf("Expected error for path outside task directory, but got %v %q", msgType, data)
+	}
+
+	// port forwarding
+	port := make([]byte, 2)
+	binary.LittleEndian.PutUint16(port, uint16(listener.Addr().(*net.TCPAddr).Port))
+	sendChannelMessage(t, conn, MsgForwardPort, 4, port)
+	sendChannelMessage(t, conn, MsgData, 4, []byte("ping"))
+	received := ""
+	for received != "ping" {
+		msgType, id, data := readChannelMessage(t, conn)
+		if msgType == MsgOutput {
+			continue
+		}
+		if msgType != MsgData || id != 4 {
+			t.Fatalf("Expected data from forwarded port, but got %v for channel %v: %q", msgType, id, data)
+		}
+		received += string(data)
+	}
+	sendChannelMessage(t, conn, MsgClose, 4, nil)
+
+	// other ports cannot be forwarded
+	binary.LittleEndian.PutUint16(port, uint16(listener.Addr().(*net.TCPAddr).Port)+1)
+	sendChannelMessage(t, conn, MsgForwardPort, 5, port)
+	if data, msgType := readChannel(t, conn, 5); msgType != MsgError || !strings.Contains(string(data), "may not be forwarded") {
+		t.Fatalf("Expected error for port that may not be forwarded, but got %v %q", msgType, data)
+	}
+}
+
+func sendChannelMessage(t *testing.T, conn *websocket.Conn, msgType byte, id uint32, body []byte) {
+	t.Helper()
+	msg := binary.LittleEndian.AppendUint32([]byte{msgType}, id)
+	err := conn.WriteMessage(websocket.BinaryMessage, append(msg, body...))
+	if err != nil {
+		t.Fatal("write error:", err)
+	}
+}
+
+// readChannelMessage reads a message from a connection made with
+// channels=true, returning the channel id 0 for terminal output.
+func readChannelMessage(t *testing.T, conn *websocket.Conn) (msgType byte, id uint32, data []byte) {
+	t.Helper()
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if len(msg) == 0 {
+		t.Fatalf("empty message")
+	}
+	if msg[0] == MsgOutput {
+		return msg[0], 0, msg[1:]
+	}
+	if len(msg) < 5 {
+		t.Fatalf("invalid channel message %v", msg)
+	}
+	return msg[0], binary.LittleEndian.Uint32(msg[1:5]), msg[5:]
+}
+
+// readChannel reads the data sent over the given channel, until it is closed
+// with MsgClose or MsgError, which is returned. For MsgError, the error
+// message is returned.
+func readChannel(t *testing.T, conn *websocket.Conn, channel uint32) (data []byte, closedWith byte) {
+	t.Helper()
+	for {
+		msgType, id, body := readChannelMessage(t, conn)
+		if msgType == MsgOutput {
+			continue
+		}
+		if id != channel {
+			t.Fatalf("Unexpected message %v for channel %v", msgType, id)
+		}
+		switch msgType {
+		case MsgData:
+			data = append(data, body...)
+		case MsgClose:
+			return data, MsgClose
+		case MsgError:
+			return body, MsgError
+		default:
+			t.Fatalf("Unexpected message type %v", msgType)
+		}
+	}
+}
+
+func dial(t *testing.T, server *httptest.Server, accessToken string, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/shell/" + accessToken
+	if query != "" {
+		url += "?" + query
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal("dial error:", err)
+	}
+	return conn
+}
+
+func sendInput(t *testing.T, conn *websocket.Conn, input string) {
+	t.Helper()
+	err := conn.WriteMessage(websocket.BinaryMessage, append([]byte{MsgStdin}, input...))
+	if err != nil {
+		t.Fatal("write error:", err)
+	}
+}
+
+// readUntil reads from conn until expected has been read count times, and
+// returns everything read.
+func readUntil(t *testing.T, conn *websocket.Conn, expected string, count int) string {
+	t.Helper()
+	output := ""
+	for range 50 {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read error: %v (output so far %q)", err, output)
+		}
+		output += string(msg)
+		if strings.Count(output, expected) >= count {
+			return output
+		}
+	}
+	t.Fatalf("Couldn't find %q %v times in output %q", expected, count, output)
+	return output
+}
+
+func testInteractive(t *testing.T, port uint16, interactiveCommands InteractiveCommands, recorder *Recorder, ctx context.Context) {
 	t.Helper()
 	// Start an interactive session on a test server
 	interactive, err := New(port, interactiveCommands, ctx)
 	if err != nil {
 		t.Fatalf("could not create interactive session: %v", err)
 	}
+	interactive.Recorder = recorder
 	server := httptest.NewServer(http.HandlerFunc(interactive.Handler))
 	defer server.Close()
 
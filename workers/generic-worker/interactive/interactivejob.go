@@ -16,39 +16,103 @@ const (
 	MsgResize = 2
 )
 
+// InteractiveJob is a command running in a pty, which any number of
+// WebSocket clients can be attached to. Output of the command is sent to all
+// attached clients, and input is accepted from the clients whose role allows
+// it. The command is terminated when the last client detaches.
 type InteractiveJob struct {
-	inner  InteractiveInnerType
-	errors chan error
-	done   chan struct{}
-	wsLock sync.Mutex
-	conn   *websocket.Conn
-	ctx    context.Context
+	inner       InteractiveInnerType
+	errors      chan error
+	done        chan struct{}
+	ctx         context.Context
+	recorder    *Recorder
+	clientsLock sync.Mutex
+	clients     map[*client]struct{}
+	// number of clients attached over the lifetime of the job, per role
+	attached map[Role]int
 }
 
-func CreateInteractiveJob(createCmd CreateInteractiveProcess, conn *websocket.Conn, ctx context.Context) (itj *InteractiveJob, err error) {
+// client is a WebSocket connection attached to an InteractiveJob.
+type client struct {
+	conn     *websocket.Conn
+	role     Role
+	wsLock   sync.Mutex
+	detached chan struct{}
+	// nil unless the client connected with channels=true
+	channels     *Channels
+	channelsLock sync.Mutex
+	open         map[uint32]*channel
+}
+
+func CreateInteractiveJob(createCmd CreateInteractiveProcess, recorder *Recorder, ctx context.Context) (itj *InteractiveJob, err error) {
 	itj = &InteractiveJob{
-		// size of 3 is because there
-		// are only ever 3 goroutines
-		// who write to this channel
-		// and we don't want to block
-		errors: make(chan error, 3),
-		done:   make(chan struct{}),
-		wsLock: sync.Mutex{},
-		conn:   conn,
-		ctx:    ctx,
+		// size of 1 is because only the
+		// goroutine waiting for the command
+		// writes to this channel, once
+		errors:   make(chan error, 1),
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		recorder: recorder,
+		clients:  map[*client]struct{}{},
+		attached: map[Role]int{},
 	}
 
 	cmd, err := createCmd()
 	if err != nil {
-		itj.reportError(fmt.Sprintf("Error while getting command %v", err))
-		return
+		return nil, fmt.Errorf("error while getting command: %v", err)
+	}
+	err = itj.Setup(cmd)
+	if err != nil {
+		return nil, err
 	}
-	itj.Setup(cmd)
 
 	go itj.copyCommandOutputStream()
-	go itj.handleWebsocketMessages()
+	go itj.waitForCommand()
+
+	return itj, nil
+}
+
+// Attach attaches conn to the job, with the given role, and returns a channel
+// which is closed once the client has detached again. If channels is not nil,
+// the client may open channels, and all messages sent to it are prefixed with
+// their type.
+func (itj *InteractiveJob) Attach(conn *websocket.Conn, role Role, channels *Channels) <-chan struct{} {
+	c := &client{
+		conn:     conn,
+		role:     role,
+		detached: make(chan struct{}),
+		channels: channels,
+		open:     map[uint32]*channel{},
+	}
+	itj.clientsLock.Lock()
+	itj.clients[c] = struct{}{}
+	itj.attached[role]++
+	itj.clientsLock.Unlock()
 
-	return itj, err
+	go itj.handleWebsocketMessages(c)
+
+	return c.detached
+}
+
+// Clients returns the number of clients currently attached to the job, and
+// the number of clients attached over its lifetime, per role.
+func (itj *InteractiveJob) Clients() (current map[Role]int, total map[Role]int) {
+	itj.clientsLock.Lock()
+	defer itj.clientsLock.Unlock()
+	current = map[Role]int{}
+	for c := range itj.clients {
+		current[c.role]++
+	}
+	total = map[Role]int{}
+	for role, n := range itj.attached {
+		total[role] = n
+	}
+	return
+}
+
+// Done returns a channel which is closed when the command has exited.
+func (itj *InteractiveJob) Done() <-chan struct{} {
+	return itj.done
 }
 
 func (itj *InteractiveJob) Terminate() (err error) {
@@ -60,6 +124,36 @@ func (itj *InteractiveJob) Terminate() (err error) {
 	}
 }
 
+func (itj *InteractiveJob) detach(c *client) {
+	itj.clientsLock.Lock()
+	_, attached := itj.clients[c]
+	delete(itj.clients, c)
+	remaining := len(itj.clients)
+	itj.clientsLock.Unlock()
+
+	if !attached {
+		return
+	}
+	c.closeChannels()
+	close(c.detached)
+	if remaining == 0 {
+		err := itj.Terminate()
+		if err != nil {
+			log.Printf("Error while terminating process: %v", err)
+		}
+	}
+}
+
+func (itj *InteractiveJob) waitForCommand() {
+	select {
+	case <-itj.ctx.Done():
+	case err := <-itj.errors:
+		if err != nil {
+			itj.reportError(fmt.Sprintf("Error occured: %v", err))
+		}
+	}
+}
+
 func (itj *InteractiveJob) copyCommandOutputStream() {
 	buf := make([]byte, 4096)
 	for {
@@ -79,72 +173,102 @@ func (itj *InteractiveJob) copyCommandOutputStream() {
 			if n == 0 {
 				continue
 			}
-			if err := itj.writeWsMessage(websocket.BinaryMessage, buf[:n]); err != nil {
-				itj.errors <- err
-				return
-			}
+			itj.recorder.output(buf[:n])
+			itj.broadcast(buf[:n])
+		}
+	}
+}
+
+// broadcast sends message to all attached clients. A client which cannot be
+// written to is disconnected, without affecting the other clients.
+func (itj *InteractiveJob) broadcast(message []byte) {
+	itj.clientsLock.Lock()
+	clients := make([]*client, 0, len(itj.clients))
+	for c := range itj.clients {
+		clients = append(clients, c)
+	}
+	itj.clientsLock.Unlock()
+
+	framed := append([]byte{MsgOutput}, message...)
+	for _, c := range clients {
+		msg := message
+		if c.channels != nil {
+			msg = framed
+		}
+		if err := c.writeWsMessage(websocket.BinaryMessage, msg); err != nil {
+			log.Printf("Error writing to interactive client, disconnecting it: %v", err)
+			// the read in handleWebsocketMessages fails, which detaches the client
+			_ = c.conn.Close()
 		}
 	}
 }
 
-func (itj *InteractiveJob) handleWebsocketMessages() {
+func (itj *InteractiveJob) handleWebsocketMessages(c *client) {
+	defer itj.detach(c)
 	for {
-		select {
-		case <-itj.ctx.Done():
-			return
-		case <-itj.done:
-			return
-		case err := <-itj.errors:
-			if err != nil {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-itj.done:
+			case <-itj.ctx.Done():
+			default:
 				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-					itj.reportError(fmt.Sprintf("Error occured: %v", err))
+					log.Printf("Error reading from interactive client: %v", err)
 				}
 			}
-			err = itj.Terminate()
-			if err != nil {
-				log.Printf("Error while terminating process: %v", err)
-			}
 			return
-		default:
-			_, msg, err := itj.conn.ReadMessage()
-			if err != nil {
-				itj.errors <- err
-				continue
-			}
+		}
 
-			if len(msg) == 0 {
+		if len(msg) == 0 {
+			return
+		}
+
+		// observers can watch, but not type or resize the terminal
+		if !c.role.canDrive() {
+			continue
+		}
+
+		switch msg[0] {
+		case MsgStdin:
+			itj.recorder.input(msg[1:])
+			if _, err := itj.writePty(msg[1:]); err != nil {
+				itj.fail(err)
 				return
 			}
-
-			switch msg[0] {
-			case MsgStdin:
-				if _, err := itj.writePty(msg[1:]); err != nil {
-					itj.errors <- err
-				}
-			case MsgResize:
-				width := binary.LittleEndian.Uint16(msg[1:3])
-				height := binary.LittleEndian.Uint16(msg[3:])
-				err = itj.resizePty(width, height)
-				if err != nil {
-					itj.errors <- err
-				}
-			default:
-				log.Printf("Unknown message code received from interactive task")
+		case MsgResize:
+			width := binary.LittleEndian.Uint16(msg[1:3])
+			height := binary.LittleEndian.Uint16(msg[3:])
+			itj.recorder.resize(width, height)
+			if err := itj.resizePty(width, height); err != nil {
+				itj.fail(err)
+				return
 			}
+		default:
+			if c.channels != nil {
+				c.handleChannelMessage(msg)
+				continue
+			}
+			log.Printf("Unknown message code received from interactive task")
 		}
 	}
 }
 
-func (itj *InteractiveJob) reportError(errorMessage string) {
-	log.Println(errorMessage)
-	err := itj.writeWsMessage(websocket.BinaryMessage, []byte(errorMessage))
+// fail reports err to all clients, and terminates the command.
+func (itj *InteractiveJob) fail(err error) {
+	itj.reportError(fmt.Sprintf("Error occured: %v", err))
+	err = itj.Terminate()
 	if err != nil {
-		log.Println("Error while reporting error to client")
+		log.Printf("Error while terminating process: %v", err)
 	}
 }
 
-func (itj *InteractiveJob) writeWsMessage(messageType int, message []byte) (err error) {
-	itj.wsLock.Lock()
-	defer itj.wsLock.Unlock()
-	return itj.conn.WriteMessage(messageType, message)
+func (itj *InteractiveJob) reportError(errorMessage string) {
+	log.Println(errorMessage)
+	itj.broadcast([]byte(errorMessage))
+}
+
+func (c *client) writeWsMessage(messageType int, message []byte) (err error) {
+	c.wsLock.Lock()
+	defer c.wsLock.Unlock()
+	return c.conn.WriteMessage(messageType, message)
 }
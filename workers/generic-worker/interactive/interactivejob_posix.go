@@ -18,7 +18,7 @@ type CmdPty struct {
 type InteractiveCmdType = *exec.Cmd
 type InteractiveInnerType = CmdPty
 
-func (itj *InteractiveJob) Setup(cmd InteractiveCmdType) {
+func (itj *InteractiveJob) Setup(cmd InteractiveCmdType) error {
 	if cmd.SysProcAttr == nil {
 		cmd.SysProcAttr = &syscall.SysProcAttr{}
 	}
@@ -28,8 +28,7 @@ func (itj *InteractiveJob) Setup(cmd InteractiveCmdType) {
 
 	pty, err := pty.StartWithAttrs(cmd, nil, cmd.SysProcAttr)
 	if err != nil {
-		itj.reportError(fmt.Sprintf("Error while spawning command %v", err))
-		return
+		return fmt.Errorf("error while spawning command: %v", err)
 	}
 	itj.inner.pty = pty
 	itj.inner.cmd = cmd
@@ -39,6 +38,7 @@ func (itj *InteractiveJob) Setup(cmd InteractiveCmdType) {
 		close(itj.done)
 	}()
 
+	return nil
 }
 
 func (itj *InteractiveJob) resizePty(width uint16, height uint16) error {
@@ -3,13 +3,15 @@ package interactive
 type InteractiveInnerType = *ConPty
 type InteractiveCmdType = *ConPty
 
-func (itj *InteractiveJob) Setup(pty InteractiveCmdType) {
+func (itj *InteractiveJob) Setup(pty InteractiveCmdType) error {
 	itj.inner = pty
 
 	go func() {
 		itj.errors <- itj.inner.Wait(itj.ctx)
 		close(itj.done)
 	}()
+
+	return nil
 }
 
 func (itj *InteractiveJob) resizePty(width uint16, height uint16) error {
@@ -0,0 +1,105 @@
+package interactive
+
+import (
+	"io"
+	"log"
+	"sync"
+
+	"github.com/taskcluster/taskcluster/v84/internal/asciicast"
+)
+
+// Recorder records the terminal output (and optionally input) of interactive
+// sessions, as an asciicast v2 recording. All sessions of an Interactive
+// share the same recording. A nil *Recorder records nothing.
+type Recorder struct {
+	cast        *asciicast.Writer
+	recordStdin bool
+	mu          sync.Mutex
+	closed      bool
+	failed      bool
+}
+
+// NewRecorder returns a Recorder writing to w. Input sent to sessions is only
+// recorded if recordStdin is true, since it may include secrets such as
+// passwords, which are not echoed in the output.
+func NewRecorder(w io.Writer, recordStdin bool) (*Recorder, error) {
+	cast, err := asciicast.NewWriter(w, asciicast.Header{
+		// the real size is recorded once the client sends it
+		Width:  80,
+		Height: 24,
+		Env: map[string]string{
+			"TERM": "xterm-256color",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		cast:        cast,
+		recordStdin: recordStdin,
+	}, nil
+}
+
+// Close stops recording. Events from sessions which are still open are
+// discarded.
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+}
+
+// Events returns the number of events recorded.
+func (r *Recorder) Events() int {
+	if r == nil {
+		return 0
+	}
+	return r.cast.Events()
+}
+
+func (r *Recorder) output(data []byte) {
+	r.record(asciicast.Output, data)
+}
+
+func (r *Recorder) input(data []byte) {
+	if r != nil && r.recordStdin {
+		r.record(asciicast.Input, data)
+	}
+}
+
+// resize records a terminal resize, with the dimensions in the order that
+// MsgResize messages hold them.
+func (r *Recorder) resize(rows uint16, columns uint16) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.failed {
+		return
+	}
+	r.check(r.cast.Resize(int(columns), int(rows)))
+}
+
+func (r *Recorder) record(eventType string, data []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.failed {
+		return
+	}
+	r.check(r.cast.Write(eventType, data))
+}
+
+// check stops recording after the first error, rather than logging an error
+// for every subsequent event. The caller must hold r.mu.
+func (r *Recorder) check(err error) {
+	if err != nil {
+		log.Printf("Error recording interactive session, recording stopped: %v", err)
+		r.failed = true
+	}
+}
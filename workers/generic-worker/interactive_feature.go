@@ -1,18 +1,29 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/url"
+	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
 	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
 	"github.com/taskcluster/taskcluster/v84/internal/scopes"
 	"github.com/taskcluster/taskcluster/v84/workers/generic-worker/artifacts"
 	"github.com/taskcluster/taskcluster/v84/workers/generic-worker/expose"
+	"github.com/taskcluster/taskcluster/v84/workers/generic-worker/graceful"
 	"github.com/taskcluster/taskcluster/v84/workers/generic-worker/interactive"
+	"github.com/taskcluster/taskcluster/v84/workers/generic-worker/process"
+	gwruntime "github.com/taskcluster/taskcluster/v84/workers/generic-worker/runtime"
 )
 
 type InteractiveFeature struct {
@@ -35,27 +46,60 @@ func (feature *InteractiveFeature) IsRequested(task *TaskRun) bool {
 }
 
 type InteractiveTask struct {
-	task         *TaskRun
-	interactive  *interactive.Interactive
-	exposure     expose.Exposure
-	artifactName string
-	cancel       context.CancelFunc
+	task                  *TaskRun
+	interactive           *interactive.Interactive
+	exposure              expose.Exposure
+	artifactName          string
+	coDriverArtifactName  string
+	observerArtifactName  string
+	sessionsArtifactName  string
+	recordingArtifactName string
+	recording             *os.File
+	cancel                context.CancelFunc
 }
 
+var (
+	interactiveRecordingPath = filepath.Join("generic-worker", "interactive-session.cast")
+	interactiveSessionsPath  = filepath.Join("generic-worker", "interactive-sessions.json")
+)
+
+// how often the state of the task and its interactive sessions is checked
+// while the task environment is held after a failure
+var interactiveHoldCheckInterval = 5 * time.Second
+
 func (feature *InteractiveFeature) NewTaskFeature(task *TaskRun) TaskFeature {
 	return &InteractiveTask{
-		task:         task,
-		artifactName: "private/generic-worker/shell.html",
+		task:                  task,
+		artifactName:          "private/generic-worker/shell.html",
+		coDriverArtifactName:  "private/generic-worker/shell-codriver.html",
+		observerArtifactName:  "private/generic-worker/shell-observer.html",
+		sessionsArtifactName:  "private/generic-worker/interactive-sessions.json",
+		recordingArtifactName: "private/generic-worker/interactive-session.cast",
 	}
 }
 
 func (it *InteractiveTask) RequiredScopes() scopes.Required {
-	return scopes.Required{}
+	pool := config.ProvisionerID + "/" + config.WorkerType
+	required := []string{}
+	if it.task.Payload.Features.InteractiveFileTransfer {
+		required = append(required, "generic-worker:interactive-file-transfer:"+pool)
+	}
+	if it.task.Payload.Features.InteractivePortForwarding {
+		required = append(required, "generic-worker:interactive-port-forwarding:"+pool)
+	}
+	if len(required) == 0 {
+		return scopes.Required{}
+	}
+	return scopes.Required{required}
 }
 
 func (it *InteractiveTask) ReservedArtifacts() []string {
 	return []string{
 		it.artifactName,
+		it.coDriverArtifactName,
+		it.observerArtifactName,
+		it.sessionsArtifactName,
+		it.recordingArtifactName,
 	}
 }
 
@@ -86,14 +130,38 @@ func (it *InteractiveTask) Start() *CommandExecutionError {
 	it.interactive = interactive
 	it.cancel = cancel
 
+	if it.task.Payload.Features.InteractivePortForwarding {
+		ports := forwardablePorts()
+		switch {
+		case it.task.D2GInfo != nil:
+			it.task.Warn("[interactive] Port forwarding is not available for docker-worker tasks, since the ports of the container cannot be reached from the worker")
+		case len(ports) == 0:
+			it.task.Warn("[interactive] Port forwarding is not available, since no ports are listed in the allowedExposePorts config of the worker")
+		default:
+			it.interactive.Channels.ForwardPorts = ports
+			it.task.Infof("[interactive] Connections may be forwarded to ports %v", ports)
+		}
+	}
+	if it.task.Payload.Features.InteractiveFileTransfer {
+		it.interactive.Channels.OpenFile = it.openFileAsTaskUser
+		it.interactive.Channels.CreateFile = it.createFileAsTaskUser
+	}
+
+	if config.InteractiveRecording {
+		err = it.startRecording()
+		if err != nil {
+			it.task.Warnf("[interactive] could not start recording interactive sessions: %v", err)
+		}
+	}
+
 	done := make(chan error, 1)
 	go func() {
 		done <- it.interactive.ListenAndServe(ctx)
 	}()
 
-	err = it.uploadInteractiveArtifact()
+	err = it.uploadInteractiveArtifacts()
 	if err != nil {
-		it.task.Warnf("[interactive] could not upload interactive artifact: %v", err)
+		it.task.Warnf("[interactive] could not upload interactive artifacts: %v", err)
 	}
 
 	select {
@@ -114,6 +182,18 @@ func (it *InteractiveTask) Stop(err *ExecutionErrors) {
 		return
 	}
 
+	if it.task.Payload.InteractiveHoldOnFailure > 0 && err.Failed() {
+		if it.task.D2GInfo != nil {
+			// the container of a d2g task has already exited, so there
+			// would be nothing left to investigate
+			it.task.Warn("[interactive] Not holding task environment after failure, since the docker container of the task has already exited")
+		} else {
+			it.holdOnFailure()
+		}
+	}
+
+	err.add(it.uploadSessionsArtifact())
+
 	it.cancel()
 
 	if it.exposure != nil {
@@ -123,17 +203,268 @@ func (it *InteractiveTask) Stop(err *ExecutionErrors) {
 			it.task.Warnf("[interactive] could not terminate interactive exposure: %v", closeErr)
 		}
 	}
+
+	if it.recording != nil {
+		err.add(it.stopRecording())
+	}
+}
+
+// forwardablePorts returns the ports that interactive clients may forward
+// connections to: those listed in the allowedExposePorts config, except for
+// any that the worker itself uses.
+func forwardablePorts() []uint16 {
+	workerPorts := []uint16{
+		config.InteractivePort,
+		config.LiveLogPortBase,
+		config.LiveLogPortBase + 1,
+		config.LiveLogExposePort,
+		config.TaskclusterProxyPort,
+	}
+	ports := []uint16{}
+	for _, port := range config.AllowedExposePorts {
+		if !slices.Contains(workerPorts, port) {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// holdOnFailure keeps the task environment, and the interactive server, alive
+// after the task has failed, so that the failure can be investigated. By the
+// time this is called, the artifacts of the task have been uploaded, but the
+// task has not been resolved yet, so it is still being reclaimed. The hold
+// ends when it expires, when all interactive sessions that were running
+// during the hold have ended, or when the task is cancelled or the worker is
+// asked to shut down.
+func (it *InteractiveTask) holdOnFailure() {
+	hold := time.Duration(it.task.Payload.InteractiveHoldOnFailure) * time.Second
+	// leave time to clean up and resolve the task before its deadline
+	if untilDeadline := time.Until(time.Time(it.task.Definition.Deadline).Add(-5 * time.Minute)); untilDeadline < hold {
+		hold = untilDeadline
+	}
+	if hold <= 0 {
+		it.task.Warn("[interactive] Not holding task environment after failure, since the task deadline is too close")
+		return
+	}
+	it.task.Infof("[interactive] Task failed, holding task environment for interactive sessions for up to %v", hold.Round(time.Second))
+
+	terminationRequested := make(chan struct{})
+	var once sync.Once
+	stopHandlingGracefulTermination := graceful.OnTerminationRequest(func(finishTasks bool) {
+		once.Do(func() { close(terminationRequested) })
+	})
+	defer stopHandlingGracefulTermination()
+
+	expired := time.NewTimer(hold)
+	defer expired.Stop()
+	check := time.NewTicker(interactiveHoldCheckInterval)
+	defer check.Stop()
+
+	sessionsRan := false
+	for {
+		select {
+		case <-expired.C:
+			it.task.Info("[interactive] Hold expired, releasing task environment")
+			return
+		case <-terminationRequested:
+			it.task.Info("[interactive] Worker is shutting down, releasing task environment")
+			return
+		case <-check.C:
+			if status := it.task.StatusManager.LastKnownStatus(); status != claimed && status != reclaimed {
+				it.task.Infof("[interactive] Task is no longer running (%v), releasing task environment", status)
+				return
+			}
+			running := 0
+			for _, session := range it.interactive.Sessions() {
+				if session.Ended == nil {
+					running++
+				}
+			}
+			if running > 0 {
+				sessionsRan = true
+			} else if sessionsRan {
+				it.task.Info("[interactive] All interactive sessions have ended, releasing task environment")
+				return
+			}
+		}
+	}
 }
 
-func (it *InteractiveTask) uploadInteractiveArtifact() error {
+// startRecording records all interactive sessions of the task to a file in
+// the task directory, for uploading when the task ends.
+func (it *InteractiveTask) startRecording() error {
+	var err error
+	it.recording, err = os.Create(filepath.Join(taskContext.TaskDir, interactiveRecordingPath))
+	if err != nil {
+		return err
+	}
+	it.interactive.Recorder, err = interactive.NewRecorder(it.recording, config.InteractiveRecordingStdin)
+	if err != nil {
+		_ = it.recording.Close()
+		it.recording = nil
+		return err
+	}
+	it.task.Infof("[interactive] Interactive sessions will be recorded to artifact %v", it.recordingArtifactName)
+	return nil
+}
+
+// stopRecording uploads the recording of the interactive sessions, if there
+// were any.
+func (it *InteractiveTask) stopRecording() *CommandExecutionError {
+	it.interactive.Recorder.Close()
+	closeErr := it.recording.Close()
+	it.recording = nil
+	if closeErr != nil {
+		return executionError(internalError, errored, fmt.Errorf("could not close interactive session recording: %v", closeErr))
+	}
+	if it.interactive.Recorder.Events() == 0 {
+		return nil
+	}
+	return it.task.uploadArtifact(
+		createDataArtifact(
+			&artifacts.BaseArtifact{
+				Name:    it.recordingArtifactName,
+				Expires: it.task.Definition.Expires,
+			},
+			filepath.Join(taskContext.TaskDir, interactiveRecordingPath),
+			filepath.Join(taskContext.TaskDir, interactiveRecordingPath),
+			"application/x-asciicast",
+			"gzip",
+		),
+	)
+}
+
+// openFileAsTaskUser opens the file at the given path, relative to the task
+// directory, for downloading through the interactive session. The file is
+// copied to a temporary file as the task user first, so that only files
+// which the task user can read can be downloaded.
+func (it *InteractiveTask) openFileAsTaskUser(path string) (io.ReadCloser, error) {
+	fullPath := filepath.Join(taskContext.TaskDir, path)
+	tempPath, err := copyToTempFileAsTaskUser(fullPath, it.task.pd)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file %v as task user: %v", path, err)
+	}
+	f, err := os.Open(tempPath)
+	if err != nil || tempPath == fullPath {
+		return f, err
+	}
+	return &tempFile{File: f}, nil
+}
+
+// tempFile is a temporary file which is deleted when closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	removeErr := os.Remove(f.Name())
+	if err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// createFileAsTaskUser creates the file at the given path, relative to the
+// task directory, for uploading through the interactive session. The file is
+// written by a generic-worker process running as the task user, so that the
+// task user owns it.
+func (it *InteractiveTask) createFileAsTaskUser(path string) (io.WriteCloser, error) {
+	cmd, err := process.NewCommandNoOutputStreams([]string{gwruntime.GenericWorkerBinary(), "write-file", "--write-file", filepath.Join(taskContext.TaskDir, path)}, taskContext.TaskDir, []string{}, it.task.pd)
+	if err != nil {
+		return nil, fmt.Errorf("could not create command to write file %v as task user: %v", path, err)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("could not write file %v as task user: %v", path, err)
+	}
+	return &commandInput{WriteCloser: stdin, cmd: cmd, path: path, stderr: stderr}, nil
+}
+
+// commandInput is the standard input of a command. Closing it waits for the
+// command to exit.
+type commandInput struct {
+	io.WriteCloser
+	cmd    *process.Command
+	path   string
+	stderr *bytes.Buffer
+}
+
+func (c *commandInput) Close() error {
+	closeErr := c.WriteCloser.Close()
+	err := c.cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("could not write file %v as task user: %v: %s", c.path, err, bytes.TrimSpace(c.stderr.Bytes()))
+	}
+	return closeErr
+}
+
+// uploadSessionsArtifact uploads the list of interactive sessions of the
+// task, with the number of clients attached to each, if there were any.
+func (it *InteractiveTask) uploadSessionsArtifact() *CommandExecutionError {
+	sessions := it.interactive.Sessions()
+	if len(sessions) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(map[string]any{"sessions": sessions}, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	sessionsFile := filepath.Join(taskContext.TaskDir, interactiveSessionsPath)
+	err = os.WriteFile(sessionsFile, data, 0644)
+	if err != nil {
+		return executionError(internalError, errored, fmt.Errorf("could not write interactive sessions file: %v", err))
+	}
+	return it.task.uploadArtifact(
+		createDataArtifact(
+			&artifacts.BaseArtifact{
+				Name:    it.sessionsArtifactName,
+				Expires: it.task.Definition.Expires,
+			},
+			sessionsFile,
+			sessionsFile,
+			"application/json",
+			"gzip",
+		),
+	)
+}
+
+// uploadInteractiveArtifacts exposes the interactive server, and uploads an
+// artifact for each role, redirecting to the shell page of the UI with the
+// WebSocket URL for that role. Since the access tokens are in the artifacts,
+// the scopes to read them determine who may connect with which role.
+func (it *InteractiveTask) uploadInteractiveArtifacts() error {
 	var err error
 	it.exposure, err = exposer.ExposeHTTP(it.interactive.TCPPort)
 	if err != nil {
 		return err
 	}
 
+	for _, shell := range []struct {
+		artifactName   string
+		interactiveURL string
+	}{
+		{it.artifactName, it.interactive.GetURL},
+		{it.coDriverArtifactName, it.interactive.GetCoDriverURL},
+		{it.observerArtifactName, it.interactive.GetObserverURL},
+	} {
+		err = it.uploadShellArtifact(shell.artifactName, shell.interactiveURL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (it *InteractiveTask) uploadShellArtifact(artifactName string, interactiveURLString string) error {
 	// combine the path from the interactive URL with the expose URL
-	interactiveURL, err := url.Parse(it.interactive.GetURL)
+	interactiveURL, err := url.Parse(interactiveURLString)
 	if err != nil {
 		return err
 	}
@@ -165,11 +496,11 @@ func (it *InteractiveTask) uploadInteractiveArtifact() error {
 	u.RawQuery = queryParams.Encode()
 	url := u.String()
 
-	expires := time.Now().Add(time.Duration(it.task.Payload.MaxRunTime+900) * time.Second)
+	expires := time.Now().Add(time.Duration(it.task.Payload.MaxRunTime+it.task.Payload.InteractiveHoldOnFailure+900) * time.Second)
 	uploadErr := it.task.uploadArtifact(
 		&artifacts.RedirectArtifact{
 			BaseArtifact: &artifacts.BaseArtifact{
-				Name:    it.artifactName,
+				Name:    artifactName,
 				Expires: tcclient.Time(expires),
 			},
 			ContentType: "text/html; charset=utf-8",
@@ -5,12 +5,16 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/mcuadros/go-defaults"
+	"github.com/taskcluster/taskcluster/v84/clients/client-go/tcqueue"
 )
 
 func TestInteractiveArtifact(t *testing.T) {
@@ -34,17 +38,143 @@ func TestInteractiveArtifact(t *testing.T) {
 
 	taskID := submitAndAssert(t, td, payload, "completed", "completed")
 
-	expectedArtifacts := ExpectedArtifacts{
+	expectedArtifacts := interactiveArtifacts(td)
+	liveLog := expectedArtifacts["public/logs/live.log"]
+	liveLog.Extracts = []string{
+		"exit 0",
+		"=== Task Finished ===",
+	}
+	expectedArtifacts["public/logs/live.log"] = liveLog
+
+	expectedArtifacts.Validate(t, taskID, 0)
+}
+
+func TestInteractiveChannelsMissingScopes(t *testing.T) {
+	setup(t)
+
+	oldEnableInteractive := config.EnableInteractive
+	defer func(oldEnableInteractive bool) {
+		config.EnableInteractive = oldEnableInteractive
+	}(oldEnableInteractive)
+	config.EnableInteractive = true
+
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(0),
+		MaxRunTime: 10,
+		Features: FeatureFlags{
+			Interactive:               true,
+			InteractiveFileTransfer:   true,
+			InteractivePortForwarding: true,
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	// don't set any scopes
+	_ = submitAndAssert(t, td, payload, "exception", "malformed-payload")
+
+	logtext := LogText(t)
+	pool := td.ProvisionerID + "/" + td.WorkerType
+	if !strings.Contains(logtext, "generic-worker:interactive-file-transfer:"+pool) || !strings.Contains(logtext, "generic-worker:interactive-port-forwarding:"+pool) {
+		t.Log(logtext)
+		t.Fatalf("Was expecting log file to contain missing scopes, but it doesn't")
+	}
+}
+
+func TestInteractiveHoldOnFailure(t *testing.T) {
+	setup(t)
+
+	oldEnableInteractive := config.EnableInteractive
+	oldInteractiveHoldCheckInterval := interactiveHoldCheckInterval
+	defer func() {
+		config.EnableInteractive = oldEnableInteractive
+		interactiveHoldCheckInterval = oldInteractiveHoldCheckInterval
+	}()
+	config.EnableInteractive = true
+	interactiveHoldCheckInterval = 500 * time.Millisecond
+
+	payload := GenericWorkerPayload{
+		Command:                  returnExitCode(1),
+		MaxRunTime:               10,
+		InteractiveHoldOnFailure: 60,
+		Features: FeatureFlags{
+			Interactive: true,
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	done := make(chan string, 1)
+	go func() {
+		done <- submitAndAssert(t, td, payload, "failed", "failed")
+	}()
+
+	// the interactive server is available as soon as the task starts, so
+	// give the command time to fail before starting a session during the hold
+	waitForInteractiveServer(t)
+	time.Sleep(3 * time.Second)
+	runInteractiveSession(t, "H0ld0nFa1lur3")
+
+	var taskID string
+	select {
+	case taskID = <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("Task was not resolved after its interactive session ended")
+	}
+
+	expectedArtifacts := interactiveArtifacts(td)
+	liveLog := expectedArtifacts["public/logs/live.log"]
+	liveLog.Extracts = []string{
+		"exit 1",
+		"Task failed, holding task environment for interactive sessions for up to 1m0s",
+		"All interactive sessions have ended, releasing task environment",
+	}
+	expectedArtifacts["public/logs/live.log"] = liveLog
+	expectedArtifacts["private/generic-worker/interactive-sessions.json"] = ArtifactTraits{
+		Extracts: []string{
+			`"name": "shell-1"`,
+		},
+		ContentType:     "application/json",
+		ContentEncoding: "gzip",
+		Expires:         td.Expires,
+	}
+
+	expectedArtifacts.Validate(t, taskID, 0)
+}
+
+// waitForInteractiveServer waits until the interactive server of the running
+// task accepts connections, without starting a session.
+func waitForInteractiveServer(t *testing.T) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	tick := time.Tick(500 * time.Millisecond)
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for server to start")
+		case <-tick:
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%v/sessions/%v", config.InteractivePort, os.Getenv("INTERACTIVE_ACCESS_TOKEN")))
+			if err != nil {
+				continue
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+	}
+}
+
+// interactiveArtifacts returns the artifacts that every task with the
+// interactive feature enabled has.
+func interactiveArtifacts(td *tcqueue.TaskDefinitionRequest) ExpectedArtifacts {
+	return ExpectedArtifacts{
 		"public/logs/live_backing.log": {
 			ContentType:     "text/plain; charset=utf-8",
 			ContentEncoding: "gzip",
 			Expires:         td.Expires,
 		},
 		"public/logs/live.log": {
-			Extracts: []string{
-				"exit 0",
-				"=== Task Finished ===",
-			},
 			ContentType:     "text/plain; charset=utf-8",
 			ContentEncoding: "gzip",
 			Expires:         td.Expires,
@@ -53,9 +183,15 @@ func TestInteractiveArtifact(t *testing.T) {
 			ContentType:      "text/html; charset=utf-8",
 			SkipContentCheck: true,
 		},
+		"private/generic-worker/shell-codriver.html": {
+			ContentType:      "text/html; charset=utf-8",
+			SkipContentCheck: true,
+		},
+		"private/generic-worker/shell-observer.html": {
+			ContentType:      "text/html; charset=utf-8",
+			SkipContentCheck: true,
+		},
 	}
-
-	expectedArtifacts.Validate(t, taskID, 0)
 }
 
 func TestInteractiveCommand(t *testing.T) {
@@ -82,13 +218,81 @@ func TestInteractiveCommand(t *testing.T) {
 		done <- submitAndAssert(t, td, payload, "completed", "completed")
 	}()
 
+	runInteractiveSession(t, "S3ntin3lValue")
+	taskID := <-done
+
+	expectedArtifacts := interactiveArtifacts(td)
+	expectedArtifacts["private/generic-worker/interactive-sessions.json"] = ArtifactTraits{
+		Extracts: []string{
+			`"name": "shell-1"`,
+			`"owner": 1`,
+		},
+		ContentType:     "application/json",
+		ContentEncoding: "gzip",
+		Expires:         td.Expires,
+	}
+
+	expectedArtifacts.Validate(t, taskID, 0)
+}
+
+func TestInteractiveRecording(t *testing.T) {
+	setup(t)
+
+	oldEnableInteractive := config.EnableInteractive
+	oldInteractiveRecording := config.InteractiveRecording
+	defer func() {
+		config.EnableInteractive = oldEnableInteractive
+		config.InteractiveRecording = oldInteractiveRecording
+	}()
+	config.EnableInteractive = true
+	config.InteractiveRecording = true
+
+	payload := GenericWorkerPayload{
+		Command:    sleep(5),
+		MaxRunTime: 10,
+		Features: FeatureFlags{
+			Interactive: true,
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	done := make(chan string, 1)
+	go func() {
+		done <- submitAndAssert(t, td, payload, "completed", "completed")
+	}()
+
+	runInteractiveSession(t, "R3c0rd3dValue")
+	taskID := <-done
+
+	expectedArtifacts := interactiveArtifacts(td)
+	expectedArtifacts["private/generic-worker/interactive-sessions.json"] = ArtifactTraits{
+		ContentType:      "application/json",
+		SkipContentCheck: true,
+	}
+	expectedArtifacts["private/generic-worker/interactive-session.cast"] = ArtifactTraits{
+		Extracts: []string{
+			`{"version":2,"width":80,"height":24`,
+			"R3c0rd3dValue",
+		},
+		ContentType:     "application/x-asciicast",
+		ContentEncoding: "gzip",
+		Expires:         td.Expires,
+	}
+
+	expectedArtifacts.Validate(t, taskID, 0)
+}
+
+// runInteractiveSession connects to the interactive shell of the running
+// task, and checks that a command echoing the given sentinel value is run.
+func runInteractiveSession(t *testing.T, sentinel string) {
+	t.Helper()
 	// Wait for server to start
 	timeout := time.After(10 * time.Second)
 	tick := time.Tick(500 * time.Millisecond)
 
 	var conn *websocket.Conn
 	var err error
-	const SENTINEL = "S3ntin3lValue"
 
 	for {
 		select {
@@ -100,13 +304,13 @@ func TestInteractiveCommand(t *testing.T) {
 			url := fmt.Sprintf("ws://localhost:%v/shell/%v", config.InteractivePort, os.Getenv("INTERACTIVE_ACCESS_TOKEN"))
 			conn, _, err = websocket.DefaultDialer.Dial(url, nil)
 			if err == nil {
-				err = conn.WriteMessage(websocket.TextMessage, fmt.Appendf(nil, "\x01echo %s\n", SENTINEL))
+				err = conn.WriteMessage(websocket.TextMessage, fmt.Appendf(nil, "\x01echo %s\n", sentinel))
 				if err != nil {
 					t.Fatalf("write error: %v", err)
 				}
 
 				var output []byte
-				expectedBytes := []byte(SENTINEL)
+				expectedBytes := []byte(sentinel)
 				completeOutput := []byte{}
 				ok := false
 				for range 20 {
@@ -142,7 +346,6 @@ func TestInteractiveCommand(t *testing.T) {
 					t.Fatalf("Error closing WebSocket connection: %v", err)
 				}
 
-				<-done
 				return
 			} else {
 				t.Logf("error connecting to server: %v", err)
@@ -220,3 +423,12 @@ func TestInteractiveNoConfigSetMalformedPayload(t *testing.T) {
 
 	_ = submitAndAssert(t, td, payload, "exception", "malformed-payload")
 }
+
+func TestInteractiveForwardablePorts(t *testing.T) {
+	setup(t)
+	config.AllowedExposePorts = []uint16{config.InteractivePort, 3000, config.LiveLogPortBase, config.TaskclusterProxyPort, 8080}
+	ports := forwardablePorts()
+	if !slices.Equal(ports, []uint16{3000, 8080}) {
+		t.Fatalf("Expected only ports 3000 and 8080 to be forwardable, but got %v", ports)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net/url"
 	"os"
 	"os/signal"
@@ -22,6 +23,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"slices"
@@ -76,11 +78,13 @@ func initialiseFeatures() (err error) {
 		&PayloadValidatorFeature{},
 		&CommandGeneratorFeature{},
 		&LiveLogFeature{},
+		&TaskHooksFeature{},
 		&TaskclusterProxyFeature{},
 		&OSGroupsFeature{},
 		&MountsFeature{},
 		&ResourceMonitorFeature{},
 		&InteractiveFeature{},
+		&ExposePortsFeature{},
 		&MetadataFeature{},
 	}
 	features = append(features, platformFeatures()...)
@@ -201,6 +205,9 @@ func main() {
 	case arguments["create-file"]:
 		err := fileutil.CreateFile(arguments["--create-file"].(string))
 		exitOnError(CANT_CREATE_FILE, err, "Error creating file %v", arguments["--create-file"].(string))
+	case arguments["write-file"]:
+		err := fileutil.WriteFile(arguments["--write-file"].(string), os.Stdin)
+		exitOnError(CANT_WRITE_FILE, err, "Error writing file %v", arguments["--write-file"].(string))
 	case arguments["create-dir"]:
 		err := fileutil.CreateDir(arguments["--create-dir"].(string))
 		exitOnError(CANT_CREATE_DIRECTORY, err, "Error creating directory %v", arguments["--create-dir"].(string))
@@ -224,6 +231,7 @@ func loadConfig(configFile *gwconfig.File) error {
 		PublicConfig: gwconfig.PublicConfig{
 			PublicEngineConfig:             *gwconfig.DefaultPublicEngineConfig(),
 			PublicPlatformConfig:           *gwconfig.DefaultPublicPlatformConfig(),
+			AllowedExposePorts:             []uint16{},
 			CachesDir:                      "caches",
 			CheckForNewDeploymentEverySecs: 1800,
 			CleanUpTaskDirs:                true,
@@ -240,10 +248,14 @@ func loadConfig(configFile *gwconfig.File) error {
 			EnableTaskclusterProxy:         true,
 			IdleTimeoutSecs:                0,
 			InteractivePort:                53654,
+			InteractiveRecording:           false,
+			InteractiveRecordingStdin:      false,
 			LiveLogExecutable:              "livelog",
 			LiveLogPortBase:                60098,
 			MaxTaskRunTime:                 86400, // 86400s is 24 hours
 			NumberOfTasksToRun:             0,
+			PostTaskCommand:                []string{},
+			PreTaskCommand:                 []string{},
 			ProvisionerID:                  "test-provisioner",
 			RequiredDiskSpaceMegabytes:     10240,
 			RootURL:                        "",
@@ -251,9 +263,13 @@ func loadConfig(configFile *gwconfig.File) error {
 			SentryProject:                  "generic-worker",
 			ShutdownMachineOnIdle:          false,
 			ShutdownMachineOnInternalError: false,
+			TaskclusterProxyCacheSize:      0,
+			TaskclusterProxyDeny:           []string{},
 			TaskclusterProxyExecutable:     "taskcluster-proxy",
 			TaskclusterProxyPort:           80,
+			TaskHookTimeoutSecs:            600,
 			TasksDir:                       defaultTasksDir(),
+			TaskTerminationGracePeriodSecs: 0,
 			WorkerGroup:                    "test-worker-group",
 			WorkerLocation:                 "",
 			WorkerTypeMetadata:             map[string]any{},
@@ -317,6 +333,13 @@ func setupExposer() (err error) {
 			config.WorkerID,
 			authClientFactory,
 		)
+	} else if config.RelayURL != "" {
+		exposer, err = expose.NewRelay(
+			config.RelayURL,
+			[]byte(config.RelaySecret),
+			config.WorkerGroup,
+			config.WorkerID,
+		)
 	} else {
 		exposer, err = expose.NewLocal(config.PublicIP, config.LiveLogExposePort)
 	}
@@ -338,6 +361,22 @@ func ReadTasksResolvedFile() uint {
 	return uint(i)
 }
 
+// remainingTasks returns the number of tasks the worker should still run
+// before exiting, given the number of tasks it has resolved, or -1 if
+// numberOfTasksToRun is not set (=0). Since numberOfTasksToRun can be lowered
+// by a config update, it may be less than tasksResolved, in which case there
+// are no remaining tasks.
+func remainingTasks(tasksResolved uint) int {
+	switch {
+	case config.NumberOfTasksToRun == 0:
+		return -1
+	case tasksResolved >= config.NumberOfTasksToRun:
+		return 0
+	default:
+		return int(config.NumberOfTasksToRun - tasksResolved)
+	}
+}
+
 // Also called from tests, so avoid panic in this function since this could
 // cause tests to silently pass - instead require error handling.
 func UpdateTasksResolvedFile(t uint) error {
@@ -423,6 +462,12 @@ func RunWorker() (exitCode ExitCode) {
 	// use zero value, to be sure that a check is made before first task runs
 	lastCheckedDeploymentID := time.Time{}
 	lastReportedNoTasks := time.Now()
+	// whether worker-runner has been told that the worker is idle since
+	// lastActive
+	idleReported := false
+	// set when a task has quarantined the worker, after which no further
+	// tasks are claimed, until the idle timeout expires
+	quarantined := false
 	sigInterrupt := make(chan os.Signal, 1)
 	signal.Notify(sigInterrupt, os.Interrupt)
 	if RotateTaskEnvironment() {
@@ -430,6 +475,13 @@ func RunWorker() (exitCode ExitCode) {
 	}
 	for {
 
+		// apply any config changes from worker-runner before claiming the next task
+		applyConfigUpdate()
+		if remainingTasks(tasksResolved) == 0 {
+			log.Printf("Completed all task(s) (number of tasks to run = %v, tasks resolved = %v)", config.NumberOfTasksToRun, tasksResolved)
+			return TASKS_COMPLETE
+		}
+
 		// See https://bugzil.la/1298010 - routinely check if this worker type is
 		// outdated, and shut down if a new deployment is required.
 		// Round(0) forces wall time calculation instead of monotonic time in case machine slept etc
@@ -457,23 +509,33 @@ func RunWorker() (exitCode ExitCode) {
 			return INTERNAL_ERROR
 		}
 
-		task := ClaimWork()
+		var task *TaskRun
+		if !quarantined {
+			task = ClaimWork()
+		}
 
 		// make sure at least 5 seconds pass between tcqueue.ClaimWork API calls
 		wait5Seconds := time.NewTimer(time.Second * 5)
 
 		if task != nil {
+			sendTaskClaimed(task)
+			idleReported = false
 			logEvent("taskQueued", task, time.Time(task.Definition.Created))
-			logEvent("taskStart", task, time.Now())
+			taskStart := time.Now()
+			logEvent("taskStart", task, taskStart)
 
 			task.pd = pdTaskUser
 			errors := task.Run()
 
 			logEvent("taskFinish", task, time.Now())
+			sendTaskResolved(task, task.StatusManager.LastKnownStatus(), errors, time.Since(taskStart))
 			if errors.Occurred() {
 				log.Printf("ERROR(s) encountered: %v", errors)
 				task.Error(errors.Error())
 			}
+			if task.preTaskCommandFailed {
+				return PRE_TASK_COMMAND_FAILED
+			}
 			if errors.WorkerShutdown() {
 				return WORKER_SHUTDOWN
 			}
@@ -486,14 +548,24 @@ func RunWorker() (exitCode ExitCode) {
 				panic(err)
 			}
 			tasksResolved++
-			// remainingTasks will be -ve, if config.NumberOfTasksToRun is not set (=0)
-			remainingTasks := int(config.NumberOfTasksToRun - tasksResolved)
+			remaining := remainingTasks(tasksResolved)
 			remainingTaskCountText := ""
-			if remainingTasks > 0 {
-				remainingTaskCountText = fmt.Sprintf(" (will exit after resolving %v more)", remainingTasks)
+			if remaining > 0 {
+				remainingTaskCountText = fmt.Sprintf(" (will exit after resolving %v more)", remaining)
 			}
 			log.Printf("Resolved %v tasks in total so far%v.", tasksResolved, remainingTaskCountText)
-			if remainingTasks == 0 {
+			switch {
+			case task.quarantineWorker:
+				quarantine(task, fmt.Sprintf("its command exited with exit code %v", task.exitResult().ExitCode()))
+				quarantined = true
+			case task.postTaskCommandFailed:
+				quarantine(task, "the post-task command failed")
+				quarantined = true
+			case task.rebootWorker:
+				log.Printf("Rebooting worker, as requested by task %v", task.TaskID)
+				return REBOOT_REQUIRED
+			}
+			if remaining == 0 {
 				log.Printf("Completed all task(s) (number of tasks to run = %v)", config.NumberOfTasksToRun)
 				if deploymentIDUpdated() {
 					return NONCURRENT_DEPLOYMENT_ID
@@ -508,6 +580,13 @@ func RunWorker() (exitCode ExitCode) {
 				return REBOOT_REQUIRED
 			}
 		} else {
+			// a quarantined worker is not waiting for work, so worker-runner
+			// is not told it is idle; it already knows about the quarantine
+			// from the error report
+			if !idleReported && !quarantined {
+				sendIdle(lastActive)
+				idleReported = true
+			}
 			// Round(0) forces wall time calculation instead of monotonic time in case machine slept etc
 			idleTime := time.Now().Round(0).Sub(lastActive)
 			remainingIdleTimeText := ""
@@ -524,14 +603,15 @@ func RunWorker() (exitCode ExitCode) {
 			// Round(0) forces wall time calculation instead of monotonic time in case machine slept etc
 			if time.Now().Round(0).Sub(lastReportedNoTasks) > 1*time.Minute {
 				lastReportedNoTasks = time.Now()
-				// remainingTasks will be -ve, if config.NumberOfTasksToRun is not set (=0)
 				remainingTaskCountText := ""
-				if config.NumberOfTasksToRun > 0 {
-					if remainingTasks := int(config.NumberOfTasksToRun - tasksResolved); remainingTasks >= 0 {
-						remainingTaskCountText = fmt.Sprintf(" %v more tasks to run before exiting.", remainingTasks)
-					}
+				if remaining := remainingTasks(tasksResolved); remaining > 0 {
+					remainingTaskCountText = fmt.Sprintf(" %v more tasks to run before exiting.", remaining)
+				}
+				if quarantined {
+					log.Printf("Worker is quarantined, so not claiming tasks. Idle for %v%v.", idleTime, remainingIdleTimeText)
+				} else {
+					log.Printf("No task claimed. Idle for %v%v.%v", idleTime, remainingIdleTimeText, remainingTaskCountText)
 				}
-				log.Printf("No task claimed. Idle for %v%v.%v", idleTime, remainingIdleTimeText, remainingTaskCountText)
 			}
 		}
 
@@ -546,6 +626,23 @@ func RunWorker() (exitCode ExitCode) {
 	}
 }
 
+// quarantine reports to worker-manager that the worker has been quarantined
+// after the given task for the given reason, so that it can be inspected
+// before it is terminated.
+func quarantine(task *TaskRun, reason string) {
+	message := fmt.Sprintf("Worker quarantined after task %v, since %v, so no further tasks will be claimed", task.TaskID, reason)
+	log.Print(message)
+	if WorkerRunnerProtocol == nil {
+		return
+	}
+	extra := map[string]string{
+		"taskId": task.TaskID,
+		"runId":  strconv.Itoa(int(task.RunID)),
+	}
+	maps.Copy(extra, debugInfo)
+	errorreport.SendQuarantine(WorkerRunnerProtocol, message, extra)
+}
+
 func deploymentIDUpdated() bool {
 	latestDeploymentID, err := configFile.NewestDeploymentID()
 	switch {
@@ -779,19 +876,99 @@ func (task *TaskRun) IsIntermittentExitCode(c int64) bool {
 	return slices.Contains(task.Payload.OnExitStatus.Retry, c)
 }
 
+// ExceptionReason returns the reason that the task should be resolved as an
+// exception with, if the given exit code is listed in
+// task.payload.onExitStatus.resolveAs.
+func (task *TaskRun) ExceptionReason(c int64) (reason TaskUpdateReason, found bool) {
+	for _, resolution := range task.Payload.OnExitStatus.ResolveAs {
+		if slices.Contains(resolution.ExitCodes, c) {
+			return TaskUpdateReason(resolution.Reason), true
+		}
+	}
+	return "", false
+}
+
+// commandOptions returns the options given in the task payload for the
+// command with the given index, or the default options if none were given.
+func (task *TaskRun) commandOptions(index int) CommandOptions {
+	if index < len(task.Payload.CommandOptions) {
+		return task.Payload.CommandOptions[index]
+	}
+	return CommandOptions{
+		RunIf: "onSuccess",
+	}
+}
+
+// exitResult returns the result of the first command that failed or, if no
+// command failed, of the last command that ran. This is the result to which
+// task.payload.onExitStatus applies, so that a command which runs after a
+// failure (see commandOptions) does not mask the failure.
+func (task *TaskRun) exitResult() *process.Result {
+	if task.failedResult != nil {
+		return task.failedResult
+	}
+	return task.result
+}
+
 func (task *TaskRun) ExecuteCommand(index int) *CommandExecutionError {
+	options := task.commandOptions(index)
+	maxRetries := 0
+	if len(options.Retry.ExitCodes) > 0 {
+		maxRetries = max(int(options.Retry.MaxRetries), 1)
+	}
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			backoff := time.Duration(options.Retry.Backoff) * time.Second << (retry - 1)
+			task.Infof("Retrying command %v in %v (retry %v of %v)", index, backoff, retry, maxRetries)
+			task.Commands[index].Reset()
+			select {
+			case <-time.After(backoff):
+			case <-task.Commands[index].Aborted():
+			}
+		}
+		err, retryable := task.executeCommandOnce(index, options)
+		if err == nil || !retryable || retry == maxRetries || !slices.Contains(options.Retry.ExitCodes, int64(task.result.ExitCode())) {
+			return err
+		}
+	}
+}
+
+// executeCommandOnce runs the command with the given index. If the command
+// fails, retryable is false if the command should not be retried, regardless
+// of its exit code, since the task was aborted or the command exceeded its
+// maxRunTime.
+func (task *TaskRun) executeCommandOnce(index int, options CommandOptions) (err *CommandExecutionError, retryable bool) {
 	task.Infof("Executing command %v: %v", index, task.formatCommand(index))
 	log.Print("Executing command " + strconv.Itoa(index) + ": " + task.Commands[index].String())
 	cee := task.prepareCommand(index)
 	if cee != nil {
 		panic(cee)
 	}
+	var timedOut atomic.Bool
+	if options.MaxRunTime > 0 {
+		timer := time.AfterFunc(
+			time.Second*time.Duration(options.MaxRunTime),
+			func() {
+				timedOut.Store(true)
+				task.Warnf("Command %v exceeded its maxRunTime of %v seconds", index, options.MaxRunTime)
+				task.terminateCommand(index, task.Commands[index])
+			},
+		)
+		defer timer.Stop()
+	}
 	task.result = task.Commands[index].Execute()
 	if ae := task.StatusManager.AbortException(); ae != nil {
-		return ae
+		return ae, false
 	}
 	task.Infof("%v", task.result)
 
+	if timedOut.Load() {
+		return &CommandExecutionError{
+			Cause:      fmt.Errorf("command %v exceeded its maxRunTime of %v seconds", index, options.MaxRunTime),
+			TaskStatus: failed,
+		}, false
+	}
+
 	switch {
 	case task.result.Failed():
 		if task.IsIntermittentExitCode(int64(task.result.ExitCode())) {
@@ -799,17 +976,23 @@ func (task *TaskRun) ExecuteCommand(index int) *CommandExecutionError {
 				Cause:      fmt.Errorf("task appears to have failed intermittently - exit code %v found in task payload.onExitStatus list", task.result.ExitCode()),
 				Reason:     intermittentTask,
 				TaskStatus: errored,
-			}
+			}, !task.result.Aborted
+		} else if reason, found := task.ExceptionReason(int64(task.result.ExitCode())); found {
+			return &CommandExecutionError{
+				Cause:      fmt.Errorf("task resolved as exception/%v - exit code %v found in task payload.onExitStatus.resolveAs list", reason, task.result.ExitCode()),
+				Reason:     reason,
+				TaskStatus: errored,
+			}, !task.result.Aborted
 		} else {
 			return &CommandExecutionError{
 				Cause:      task.result.FailureCause(),
 				TaskStatus: failed,
-			}
+			}, !task.result.Aborted
 		}
 	case task.result.Crashed():
 		panic(task.result.CrashCause())
 	}
-	return nil
+	return nil, false
 }
 
 // ExecutionErrors is a growable slice of errors to collect command execution errors as they occur
@@ -846,6 +1029,17 @@ func (e *ExecutionErrors) WorkerShutdown() bool {
 	return false
 }
 
+// Failed returns true if any of the accumulated errors is a task failure, even
+// if an earlier error means that the task will not be resolved as failed
+func (e *ExecutionErrors) Failed() bool {
+	for _, err := range *e {
+		if err.TaskStatus == failed {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *ExecutionErrors) Occurred() bool {
 	return len(*e) > 0
 }
@@ -862,15 +1056,30 @@ func (task *TaskRun) resolve(e *ExecutionErrors) *CommandExecutionError {
 }
 
 func (task *TaskRun) kill() {
-	for _, command := range task.Commands {
-		output, err := command.Kill()
-		if len(output) > 0 {
-			task.Info(string(output))
-		}
-		if err != nil {
-			log.Printf("WARNING: %v", err)
-			task.Warnf("%v", err)
-		}
+	for i, command := range task.Commands {
+		task.terminateCommand(i, command)
+	}
+}
+
+// terminateCommand terminates the given command of the task, logging how it
+// was terminated.
+func (task *TaskRun) terminateCommand(index int, command *process.Command) {
+	gracePeriod := time.Duration(config.TaskTerminationGracePeriodSecs) * time.Second
+	stage, output, err := command.Terminate(gracePeriod)
+	switch {
+	case stage == process.Interrupted:
+		task.Infof("Command %v exited within the termination grace period of %v", index, gracePeriod)
+	case stage == process.Killed && gracePeriod > 0:
+		task.Warnf("Command %v did not exit within the termination grace period of %v, so it was killed", index, gracePeriod)
+	case stage == process.Killed:
+		task.Warnf("Command %v was killed", index)
+	}
+	if len(output) > 0 {
+		task.Info(string(output))
+	}
+	if err != nil {
+		log.Printf("WARNING: %v", err)
+		task.Warnf("%v", err)
 	}
 }
 
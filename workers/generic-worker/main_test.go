@@ -144,6 +144,28 @@ func TestExecutionErrorsText(t *testing.T) {
 	}
 }
 
+func TestExecutionErrorsFailed(t *testing.T) {
+	errors := ExecutionErrors{}
+	if errors.Failed() {
+		t.Fatal("No errors should not count as a failure")
+	}
+	errors.add(&CommandExecutionError{
+		Cause:      fmt.Errorf("Could not upload artifact"),
+		Reason:     malformedPayload,
+		TaskStatus: errored,
+	})
+	if errors.Failed() {
+		t.Fatal("An exception should not count as a failure")
+	}
+	errors.add(&CommandExecutionError{
+		Cause:      fmt.Errorf("Command exited with exit code 1"),
+		TaskStatus: failed,
+	})
+	if !errors.Failed() {
+		t.Fatal("A failure after an exception should count as a failure")
+	}
+}
+
 // If a task tries to execute a file that isn't executable for the current
 // user, it should result in a task failure, rather than a task exception,
 // since the task is at fault, not the worker.
@@ -0,0 +1,156 @@
+//go:build darwin || linux || freebsd
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcuadros/go-defaults"
+)
+
+func TestAbortAfterMaxRunTimeWithGracePeriod(t *testing.T) {
+	setup(t)
+
+	oldTaskTerminationGracePeriodSecs := config.TaskTerminationGracePeriodSecs
+	defer func() {
+		config.TaskTerminationGracePeriodSecs = oldTaskTerminationGracePeriodSecs
+	}()
+	config.TaskTerminationGracePeriodSecs = 10
+
+	payload := GenericWorkerPayload{
+		Command: append(
+			[][]string{
+				{
+					"/usr/bin/env",
+					"bash",
+					"-c",
+					`trap 'echo "Writing partial results"; echo partial > results.txt; exit 0' TERM; for ((i=0; i<30; i++)); do sleep 1; done`,
+				},
+			},
+			// also make sure subsequent commands after abort don't run
+			helloGoodbye()...,
+		),
+		MaxRunTime: 5,
+		Artifacts: []Artifact{
+			{
+				Path: "results.txt",
+				Name: "public/results.txt",
+				Type: "file",
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	taskID := submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	for _, expected := range []string{
+		"max run time exceeded",
+		"Writing partial results",
+		"Command 0 exited within the termination grace period of 10s",
+	} {
+		if !strings.Contains(logtext, expected) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+		}
+	}
+	if strings.Contains(logtext, "hello") {
+		t.Log(logtext)
+		t.Fatal("Task should have been aborted before 'hello' was logged, but log contains 'hello'")
+	}
+
+	if content := string(getArtifactContent(t, taskID, "public/results.txt")); content != "partial\n" {
+		t.Fatalf("Was expecting partial results to be uploaded, but got %q", content)
+	}
+}
+
+func TestAbortAfterMaxRunTimeGracePeriodExpires(t *testing.T) {
+	setup(t)
+
+	oldTaskTerminationGracePeriodSecs := config.TaskTerminationGracePeriodSecs
+	defer func() {
+		config.TaskTerminationGracePeriodSecs = oldTaskTerminationGracePeriodSecs
+	}()
+	config.TaskTerminationGracePeriodSecs = 2
+
+	payload := GenericWorkerPayload{
+		Command: [][]string{
+			{
+				"/usr/bin/env",
+				"bash",
+				"-c",
+				// ignoring SIGTERM is inherited by sleep
+				`trap '' TERM; for ((i=0; i<30; i++)); do sleep 1; done`,
+			},
+		},
+		MaxRunTime: 5,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	if !strings.Contains(logtext, "Command 0 did not exit within the termination grace period of 2s, so it was killed") {
+		t.Log(logtext)
+		t.Fatal("Was expecting log file to mention that the command was killed after the grace period, but it doesn't")
+	}
+}
+
+func TestAbortAfterMaxRunTimeKillsProcessesIgnoringInterrupt(t *testing.T) {
+	setup(t)
+
+	oldTaskTerminationGracePeriodSecs := config.TaskTerminationGracePeriodSecs
+	defer func() {
+		config.TaskTerminationGracePeriodSecs = oldTaskTerminationGracePeriodSecs
+	}()
+	config.TaskTerminationGracePeriodSecs = 10
+
+	// the background process ignores SIGTERM and keeps writing to a file
+	// outside the task directory, while the command exits on SIGTERM; its
+	// output is redirected so that the command is not waiting for it to exit
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatalf("Could not make %v writable: %v", dir, err)
+	}
+	heartbeat := filepath.Join(dir, "heartbeat.txt")
+	payload := GenericWorkerPayload{
+		Command: [][]string{
+			{
+				"/usr/bin/env",
+				"bash",
+				"-c",
+				`(trap '' TERM; while true; do echo alive >> '` + heartbeat + `'; sleep 0.1; done) > /dev/null 2>&1 & trap 'exit 0' TERM; for ((i=0; i<30; i++)); do sleep 1; done`,
+			},
+		},
+		MaxRunTime: 5,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	if !strings.Contains(logtext, "Command 0 exited within the termination grace period of 10s") {
+		t.Log(logtext)
+		t.Fatal("Was expecting log file to mention that the command exited within the grace period, but it doesn't")
+	}
+
+	before, err := os.ReadFile(heartbeat)
+	if err != nil {
+		t.Fatalf("Could not read %v: %v", heartbeat, err)
+	}
+	time.Sleep(time.Second)
+	after, err := os.ReadFile(heartbeat)
+	if err != nil {
+		t.Fatalf("Could not read %v: %v", heartbeat, err)
+	}
+	if len(after) != len(before) {
+		t.Fatal("Background process that ignored SIGTERM was still running after the task was resolved")
+	}
+}
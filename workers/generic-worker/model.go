@@ -34,6 +34,7 @@ type (
 		pd             *process.PlatformData
 		queueMux       sync.RWMutex
 		result         *process.Result
+		failedResult   *process.Result
 		Queue          tc.Queue           `json:"-"`
 		StatusManager  *TaskStatusManager `json:"-"`
 		LocalClaimTime time.Time          `json:"-"`
@@ -50,6 +51,16 @@ type (
 		featureArtifacts    map[string]string
 		D2GInfo             *d2g.ConversionInfo               `json:"-"`
 		DockerWorkerPayload *dockerworker.DockerWorkerPayload `json:"-"`
+
+		// Set when the exit status of the last command requests that the
+		// worker reboots or is quarantined after resolving the task (see
+		// task.payload.onExitStatus).
+		rebootWorker     bool
+		quarantineWorker bool
+		// Set when the preTaskCommand or postTaskCommand from the worker
+		// config fails.
+		preTaskCommandFailed  bool
+		postTaskCommandFailed bool
 	}
 
 	TaskStatus       string
@@ -430,11 +430,12 @@ func (taskMount *TaskMount) Start() *CommandExecutionError {
 // called when a task has completed
 func (taskMount *TaskMount) Stop(err *ExecutionErrors) {
 	purgeCaches := taskMount.shouldPurgeCaches()
+	purgeNamedCaches := taskMount.namedCachesToPurge()
 	// loop through all mounts described in payload
 	for i, mount := range taskMount.mounted {
-		if purgeCaches {
-			switch cache := mount.(type) {
-			case *WritableDirectoryCache:
+		switch cache := mount.(type) {
+		case *WritableDirectoryCache:
+			if purgeCaches || slices.Contains(purgeNamedCaches, cache.CacheName) {
 				err.add(Failure(directoryCaches[cache.CacheName].Evict(taskMount)))
 				continue
 			}
@@ -456,20 +457,42 @@ func (taskMount *TaskMount) Stop(err *ExecutionErrors) {
 }
 
 func (taskMount *TaskMount) shouldPurgeCaches() bool {
+	result := taskMount.task.exitResult()
 	// task commands may not have run if the task
 	// feature resolved as malformed-payload
-	if taskMount.task.result == nil {
+	if result == nil {
 		return false
 	}
 
-	if slices.Contains(taskMount.task.Payload.OnExitStatus.PurgeCaches, int64(taskMount.task.result.ExitCode())) {
-		taskMount.Infof("Purging caches since last command had exit code %v which is listed in task.Payload.OnExitStatus.PurgeCaches array", taskMount.task.result.ExitCode())
+	if slices.Contains(taskMount.task.Payload.OnExitStatus.PurgeCaches, int64(result.ExitCode())) {
+		taskMount.Infof("Purging caches since command had exit code %v which is listed in task.Payload.OnExitStatus.PurgeCaches array", result.ExitCode())
 		return true
 	}
 
 	return false
 }
 
+// namedCachesToPurge returns the names of the caches that should be purged
+// since the exit code of the commands (see exitResult) is listed in
+// task.payload.onExitStatus.purgeNamedCaches.
+func (taskMount *TaskMount) namedCachesToPurge() (cacheNames []string) {
+	result := taskMount.task.exitResult()
+	// task commands may not have run if the task
+	// feature resolved as malformed-payload
+	if result == nil {
+		return
+	}
+
+	exitCode := int64(result.ExitCode())
+	for _, rule := range taskMount.task.Payload.OnExitStatus.PurgeNamedCaches {
+		if slices.Contains(rule.ExitCodes, exitCode) {
+			taskMount.Infof("Purging caches %v since command had exit code %v which is listed in task.Payload.OnExitStatus.PurgeNamedCaches array", rule.CacheNames, exitCode)
+			cacheNames = append(cacheNames, rule.CacheNames...)
+		}
+	}
+	return
+}
+
 // Writable caches require scope generic-worker:cache:<cacheName>. Preloaded
 // caches from an artifact may also require scopes - handled separately.
 func (w *WritableDirectoryCache) RequiredScopes() []string {
@@ -381,6 +381,16 @@ func platformTargets(arguments map[string]any) ExitCode {
 			return CANT_GRANT_CONTROL_OF_WINSTA_AND_DESKTOP
 		}
 		return 0
+	case arguments["send-ctrl-break"]:
+		pid, err := strconv.ParseUint(arguments["--pid"].(string), 10, 32)
+		if err == nil {
+			err = win32.SendCtrlBreak(uint32(pid))
+		}
+		if err != nil {
+			log.Printf("Error sending CTRL_BREAK to process group %v: %v", arguments["--pid"], err)
+			return CANT_SEND_CTRL_BREAK
+		}
+		return 0
 	}
 	log.Print("Internal error - no target found to run, yet command line parsing successful")
 	return INTERNAL_ERROR
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mcuadros/go-defaults"
+)
+
+// Exit codes specified in OnExitStatus.ResolveAs should resolve as an
+// exception with the given reason
+func TestResolveAsException(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(123),
+		MaxRunTime: 30,
+		OnExitStatus: ExitCodeHandling{
+			ResolveAs: []ExceptionResolution{
+				{
+					ExitCodes: []int64{122, 123},
+					Reason:    "resource-unavailable",
+				},
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "exception", "resource-unavailable")
+}
+
+// Exit codes specified in OnExitStatus.Retry take precedence over those in
+// OnExitStatus.ResolveAs
+func TestResolveAsExceptionIntermittent(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(123),
+		MaxRunTime: 30,
+		OnExitStatus: ExitCodeHandling{
+			Retry: []int64{123},
+			ResolveAs: []ExceptionResolution{
+				{
+					ExitCodes: []int64{123},
+					Reason:    "internal-error",
+				},
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "exception", "intermittent-task")
+}
+
+// Exit codes _not_ specified in OnExitStatus.ResolveAs should resolve normally
+func TestResolveAsExceptionCommandFailure(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(456),
+		MaxRunTime: 30,
+		OnExitStatus: ExitCodeHandling{
+			ResolveAs: []ExceptionResolution{
+				{
+					ExitCodes: []int64{123},
+					Reason:    "malformed-payload",
+				},
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+}
+
+// Exit codes specified in OnExitStatus.RebootWorker should cause the worker to
+// reboot after the task is resolved
+func TestRebootWorker(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(123),
+		MaxRunTime: 30,
+		OnExitStatus: ExitCodeHandling{
+			RebootWorker: []int64{123},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+	td.Scopes = append(td.Scopes, "generic-worker:reboot-worker:"+td.ProvisionerID+"/"+td.WorkerType)
+
+	_ = scheduleTask(t, td, payload)
+	execute(t, REBOOT_REQUIRED)
+
+	logtext := LogText(t)
+	substring := "Worker will reboot after this task, since command had exit code 123"
+	if !strings.Contains(logtext, substring) {
+		t.Log(logtext)
+		t.Fatalf("Was expecting log to contain string %v.", substring)
+	}
+}
+
+// Exit codes _not_ specified in OnExitStatus.RebootWorker should not cause the
+// worker to reboot
+func TestRebootWorkerCommandSuccess(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(0),
+		MaxRunTime: 30,
+		OnExitStatus: ExitCodeHandling{
+			RebootWorker: []int64{123},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+	td.Scopes = append(td.Scopes, "generic-worker:reboot-worker:"+td.ProvisionerID+"/"+td.WorkerType)
+
+	_ = submitAndAssert(t, td, payload, "completed", "completed")
+}
+
+// Exit codes specified in OnExitStatus.QuarantineWorker should cause the
+// worker to stop claiming tasks, rather than rebooting
+func TestQuarantineWorker(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(123),
+		MaxRunTime: 30,
+		OnExitStatus: ExitCodeHandling{
+			QuarantineWorker: []int64{123},
+			RebootWorker:     []int64{123},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+	td.Scopes = append(td.Scopes,
+		"generic-worker:quarantine-worker:"+td.ProvisionerID+"/"+td.WorkerType,
+		"generic-worker:reboot-worker:"+td.ProvisionerID+"/"+td.WorkerType,
+	)
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	substring := "Worker will be quarantined after this task, since command had exit code 123"
+	if !strings.Contains(logtext, substring) {
+		t.Log(logtext)
+		t.Fatalf("Was expecting log to contain string %v.", substring)
+	}
+}
+
+// Rebooting or quarantining the worker requires scopes, since it affects the
+// tasks of other task authors
+func TestOnExitStatusWorkerActionsWithoutScopes(t *testing.T) {
+	setup(t)
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(0),
+		MaxRunTime: 30,
+		OnExitStatus: ExitCodeHandling{
+			QuarantineWorker: []int64{123},
+			RebootWorker:     []int64{124},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	// don't set any scopes
+	_ = submitAndAssert(t, td, payload, "exception", "malformed-payload")
+
+	logtext := LogText(t)
+	for _, scope := range []string{
+		"generic-worker:quarantine-worker:" + td.ProvisionerID + "/" + td.WorkerType,
+		"generic-worker:reboot-worker:" + td.ProvisionerID + "/" + td.WorkerType,
+	} {
+		if !strings.Contains(logtext, scope) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain missing scope %v, but it doesn't", scope)
+		}
+	}
+}
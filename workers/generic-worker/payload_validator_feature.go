@@ -79,6 +79,11 @@ If you need D2G to translate your Docker Worker payload so Generic Worker can pr
 			return executionError(internalError, errored, err)
 		}
 	}
+	// commandOptions are matched to commands by position, so a mismatch
+	// most likely means that options would apply to the wrong commands
+	if len(pvtf.task.Payload.CommandOptions) > 0 && len(pvtf.task.Payload.CommandOptions) != len(pvtf.task.Payload.Command) {
+		return MalformedPayloadError(fmt.Errorf("task payload has %v entries in commandOptions, but %v commands; there must be one entry per command", len(pvtf.task.Payload.CommandOptions), len(pvtf.task.Payload.Command)))
+	}
 	return nil
 }
 
@@ -91,11 +91,13 @@ func newCommand(f func() *exec.Cmd, workingDirectory string, env []string, pd *P
 	if !isWindows8OrGreater {
 		creationFlags |= win32.CREATE_BREAKAWAY_FROM_JOB
 	}
+	// the command needs its own console and process group, even when it runs
+	// as the current user, so that interrupt can send it CTRL_BREAK
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: creationFlags,
+	}
 	if accessToken != 0 {
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			Token:         accessToken,
-			CreationFlags: creationFlags,
-		}
+		cmd.SysProcAttr.Token = accessToken
 	}
 	return &Command{
 		Cmd:   cmd,
@@ -124,7 +126,11 @@ func (c *Command) Kill() (killOutput string, err error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	// abort even if process hasn't started
-	close(c.abort)
+	select {
+	case <-c.abort:
+	default:
+		close(c.abort)
+	}
 	if c.Process == nil {
 		// If process hasn't been started yet, nothing to kill
 		return "", nil
@@ -141,6 +147,26 @@ func (c *Command) Kill() (killOutput string, err error) {
 	return host.CombinedOutput("taskkill.exe", "/pid", strconv.Itoa(c.Process.Pid), "/f", "/t")
 }
 
+// killRemaining does nothing on Windows: once the command has exited, the
+// processes it started can no longer be found from its PID with taskkill.exe,
+// and the PID may already belong to an unrelated process.
+func (c *Command) killRemaining() error {
+	return nil
+}
+
+// interrupt asks the process tree of the command to exit, by sending
+// CTRL_BREAK to its process group. Since the command has its own console,
+// this is done by a separate generic-worker process, which attaches to that
+// console.
+func (c *Command) interrupt() error {
+	log.Printf("Sending CTRL_BREAK to process tree with parent PID %v... (%p)", c.Process.Pid, c)
+	output, err := host.CombinedOutput(gwruntime.GenericWorkerBinary(), "send-ctrl-break", "--pid", strconv.Itoa(c.Process.Pid))
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, output)
+	}
+	return nil
+}
+
 func (pd *PlatformData) RefreshLoginSession(user, pass string, winstaAccess bool) {
 	err := pd.LoginInfo.Release()
 	if err != nil {
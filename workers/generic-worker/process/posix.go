@@ -60,7 +60,11 @@ func (c *Command) Kill() (killOutput string, err error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	// abort even if process hasn't started
-	close(c.abort)
+	select {
+	case <-c.abort:
+	default:
+		close(c.abort)
+	}
 	if c.Process == nil {
 		// If process hasn't been started yet, nothing to kill
 		return "", nil
@@ -70,3 +74,22 @@ func (c *Command) Kill() (killOutput string, err error) {
 	// See https://medium.com/@felixge/killing-a-child-process-and-all-of-its-children-in-go-54079af94773
 	return "", syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
 }
+
+// killRemaining kills any processes left in the process group of the command
+// once the command itself has exited.
+func (c *Command) killRemaining() error {
+	log.Printf("Killing remaining processes of process tree with parent PID %v... (%p)", c.Process.Pid, c)
+	err := syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+	if err == syscall.ESRCH {
+		// no processes were left
+		return nil
+	}
+	return err
+}
+
+// interrupt asks the process tree of the command to exit, by sending SIGTERM
+// to its process group.
+func (c *Command) interrupt() error {
+	log.Printf("Sending SIGTERM to process tree with parent PID %v... (%p)", c.Process.Pid, c)
+	return syscall.Kill(-c.Process.Pid, syscall.SIGTERM)
+}
@@ -3,6 +3,7 @@ package process
 import (
 	"fmt"
 	"io"
+	"log"
 	"os/exec"
 	"sync"
 	"syscall"
@@ -28,6 +29,9 @@ type (
 		// return even if cmd.Wait() is blocked. This is useful since cmd.Wait()
 		// sometimes does not return promptly.
 		abort chan struct{}
+		// exited channel is closed when the process has exited, nil if the
+		// process has not been started
+		exited chan struct{}
 	}
 
 	Result struct {
@@ -86,7 +90,20 @@ func (c *Command) Execute() (r *Result) {
 	}
 
 	c.mutex.Lock()
+	select {
+	case <-c.abort:
+		// killed before it was started
+		c.mutex.Unlock()
+		r.SystemError = fmt.Errorf("process aborted")
+		r.Aborted = true
+		return
+	default:
+	}
 	err := c.Start()
+	exited := make(chan struct{})
+	if err == nil {
+		c.exited = exited
+	}
 	c.mutex.Unlock()
 	if err != nil {
 		r.SystemError = err
@@ -97,6 +114,7 @@ func (c *Command) Execute() (r *Result) {
 	// wait for command to complete in separate go routine, so we handle abortion in parallel to command termination
 	go func() {
 		err := c.Wait()
+		close(exited)
 		exitErr <- err
 	}()
 
@@ -122,6 +140,82 @@ func (c *Command) Execute() (r *Result) {
 	return
 }
 
+// Reset prepares the command to be executed again, once it has exited. If
+// the command has been killed, executing it again aborts straight away.
+func (c *Command) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Cmd = &exec.Cmd{
+		Path:        c.Path,
+		Args:        c.Args,
+		Env:         c.Env,
+		Dir:         c.Dir,
+		Stdin:       c.Stdin,
+		Stdout:      c.Stdout,
+		Stderr:      c.Stderr,
+		ExtraFiles:  c.ExtraFiles,
+		SysProcAttr: c.SysProcAttr,
+	}
+	c.exited = nil
+}
+
+// Aborted returns a channel which is closed when the command is killed.
+func (c *Command) Aborted() <-chan struct{} {
+	return c.abort
+}
+
+// TerminationStage describes how Terminate stopped a command.
+type TerminationStage int
+
+const (
+	// NotRunning means the command had not been started, or had already
+	// exited, so there was nothing to terminate.
+	NotRunning TerminationStage = iota
+	// Interrupted means the command exited after being interrupted.
+	Interrupted
+	// Killed means the process tree of the command was killed.
+	Killed
+)
+
+// Terminate stops the process tree of the command. If gracePeriod is
+// positive and the command is running, the process tree is first interrupted
+// (SIGTERM on posix, CTRL_BREAK on Windows) to give it the chance to clean up,
+// and killed if the command has not exited once gracePeriod has elapsed. If
+// the command exits within gracePeriod, any processes it started which are
+// still running (for example because they ignored the interrupt) are killed.
+func (c *Command) Terminate(gracePeriod time.Duration) (stage TerminationStage, killOutput string, err error) {
+	c.mutex.RLock()
+	exited := c.exited
+	c.mutex.RUnlock()
+	running := exited != nil
+	if running {
+		select {
+		case <-exited:
+			running = false
+		default:
+		}
+	}
+	if running && gracePeriod > 0 {
+		if interruptErr := c.interrupt(); interruptErr != nil {
+			log.Printf("Could not interrupt process tree with parent PID %v: %v", c.Process.Pid, interruptErr)
+		} else {
+			select {
+			case <-exited:
+				if err := c.killRemaining(); err != nil {
+					log.Printf("Could not kill remaining processes of process tree with parent PID %v: %v", c.Process.Pid, err)
+				}
+				return Interrupted, "", nil
+			case <-time.After(gracePeriod):
+			}
+		}
+	}
+	killOutput, err = c.Kill()
+	if !running {
+		return NotRunning, killOutput, err
+	}
+	return Killed, killOutput, err
+}
+
 func (c *Command) String() string {
 	return fmt.Sprintf("%q", c.Args)
 }
@@ -341,3 +341,67 @@ func TestPurgeTaskCaches(t *testing.T) {
 	// ensure task cache from previous task is still present
 	ensureDirContainsNFiles(t, cachesDir, 1)
 }
+
+// Exit codes specified in OnExitStatus.PurgeNamedCaches should only purge the
+// named caches
+func TestPurgeNamedCaches(t *testing.T) {
+	setup(t)
+	mounts := []MountEntry{
+		// requires scope "generic-worker:cache:apple-cache"
+		&WritableDirectoryCache{
+			CacheName: "apple-cache",
+			Directory: filepath.Join("my-task-caches", "apples"),
+		},
+		// requires scope "generic-worker:cache:banana-cache"
+		&WritableDirectoryCache{
+			CacheName: "banana-cache",
+			Directory: filepath.Join("my-task-caches", "bananas"),
+		},
+	}
+	payload := GenericWorkerPayload{
+		Command:    returnExitCode(123),
+		MaxRunTime: 30,
+		Mounts:     toMountArray(t, mounts),
+		OnExitStatus: ExitCodeHandling{
+			PurgeNamedCaches: []CachePurgeRule{
+				{
+					ExitCodes:  []int64{123},
+					CacheNames: []string{"banana-cache"},
+				},
+				{
+					ExitCodes:  []int64{124},
+					CacheNames: []string{"apple-cache"},
+				},
+			},
+		},
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+	td.Scopes = []string{
+		"generic-worker:cache:apple-cache",
+		"generic-worker:cache:banana-cache",
+	}
+
+	_ = submitAndAssert(t, td, payload, "failed", "failed")
+
+	logtext := LogText(t)
+	for _, substring := range []string{
+		"[mounts] Purging caches [banana-cache] since command had exit code 123",
+		"[mounts] Removing cache banana-cache from cache table",
+		"[mounts] Preserving cache: Moving",
+	} {
+		if !strings.Contains(logtext, substring) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log to contain string %v.", substring)
+		}
+	}
+
+	substring := "[mounts] Removing cache apple-cache from cache table"
+	if strings.Contains(logtext, substring) {
+		t.Log(logtext)
+		t.Fatalf("Was not expecting log to contain string %v.", substring)
+	}
+
+	// only apple-cache should have been preserved
+	ensureDirContainsNFiles(t, cachesDir, 1)
+}
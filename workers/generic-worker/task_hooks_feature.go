@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/taskcluster/taskcluster/v84/internal/scopes"
+	"github.com/taskcluster/taskcluster/v84/workers/generic-worker/process"
+)
+
+type (
+	TaskHooksFeature struct {
+	}
+
+	// TaskHooksTaskFeature runs the preTaskCommand and postTaskCommand from
+	// the worker config around the commands of a task, in order to check the
+	// health of the machine.
+	TaskHooksTaskFeature struct {
+		task *TaskRun
+	}
+
+	// hookOutput collects the output of a hook command. Writes are
+	// synchronised, since a hook which is killed after timing out may still
+	// be writing output when it is read.
+	hookOutput struct {
+		mutex  sync.Mutex
+		buffer bytes.Buffer
+	}
+)
+
+func (ho *hookOutput) Write(p []byte) (int, error) {
+	ho.mutex.Lock()
+	defer ho.mutex.Unlock()
+	return ho.buffer.Write(p)
+}
+
+func (ho *hookOutput) String() string {
+	ho.mutex.Lock()
+	defer ho.mutex.Unlock()
+	return ho.buffer.String()
+}
+
+func (thf *TaskHooksFeature) Name() string {
+	return "Task Hooks"
+}
+
+func (thf *TaskHooksFeature) Initialise() (err error) {
+	return nil
+}
+
+func (thf *TaskHooksFeature) IsEnabled() bool {
+	return true
+}
+
+func (thf *TaskHooksFeature) IsRequested(task *TaskRun) bool {
+	return len(config.PreTaskCommand) > 0 || len(config.PostTaskCommand) > 0
+}
+
+func (thf *TaskHooksFeature) NewTaskFeature(task *TaskRun) TaskFeature {
+	return &TaskHooksTaskFeature{
+		task: task,
+	}
+}
+
+func (thtf *TaskHooksTaskFeature) ReservedArtifacts() []string {
+	return []string{}
+}
+
+func (thtf *TaskHooksTaskFeature) RequiredScopes() scopes.Required {
+	return scopes.Required{}
+}
+
+// Start runs the pre-task command. If it fails, the task is resolved as
+// exception/worker-shutdown, so that the queue schedules a new run on a
+// (hopefully) healthy worker, and the worker exits.
+func (thtf *TaskHooksTaskFeature) Start() *CommandExecutionError {
+	if len(config.PreTaskCommand) == 0 {
+		return nil
+	}
+	err := thtf.runHook("pre-task", config.PreTaskCommand)
+	if err != nil {
+		thtf.task.preTaskCommandFailed = true
+		return executionError(workerShutdown, errored, fmt.Errorf("[pre-task] pre-task command failed, so the worker will shut down: %v", err))
+	}
+	return nil
+}
+
+// Stop runs the post-task command. If it fails, the task resolution is not
+// affected, but the worker stops claiming tasks after resolving this one.
+func (thtf *TaskHooksTaskFeature) Stop(err *ExecutionErrors) {
+	if len(config.PostTaskCommand) == 0 || thtf.task.preTaskCommandFailed {
+		return
+	}
+	e := thtf.runHook("post-task", config.PostTaskCommand)
+	if e != nil {
+		thtf.task.Errorf("[post-task] post-task command failed, so the worker will not claim any further tasks: %v", e)
+		thtf.task.postTaskCommandFailed = true
+	}
+}
+
+// runHook runs the given command as the user running generic-worker, writing
+// its output to both the worker log and the task log, in a task log section
+// with the given name. If the command does not complete within
+// taskHookTimeoutSecs, it is killed and treated as a failure, so that a hung
+// hook does not stop the worker from resolving tasks.
+func (thtf *TaskHooksTaskFeature) runHook(name string, commandLine []string) error {
+	task := thtf.task
+	task.Infof("[%v] Running %v command: %q", name, name, commandLine)
+	log.Printf("Running %v command for task %v: %q", name, task.TaskID, commandLine)
+	env := append(
+		os.Environ(),
+		"TASK_ID="+task.TaskID,
+		"RUN_ID="+strconv.Itoa(int(task.RunID)),
+	)
+	cmd, err := process.NewCommandNoOutputStreams(commandLine, "", env, &process.PlatformData{})
+	if err != nil {
+		return fmt.Errorf("could not create %v command: %v", name, err)
+	}
+	var output hookOutput
+	cmd.DirectOutput(&output)
+	var timedOut atomic.Bool
+	if config.TaskHookTimeoutSecs > 0 {
+		timer := time.AfterFunc(
+			time.Second*time.Duration(config.TaskHookTimeoutSecs),
+			func() {
+				timedOut.Store(true)
+				log.Printf("Killing %v command, since it exceeded taskHookTimeoutSecs (%v)", name, config.TaskHookTimeoutSecs)
+				_, err := cmd.Kill()
+				if err != nil {
+					log.Printf("WARNING: could not kill %v command: %v", name, err)
+				}
+			},
+		)
+		defer timer.Stop()
+	}
+	result := cmd.Execute()
+	if out := output.String(); len(out) > 0 {
+		log.Print(out)
+		task.Log("["+name+"] ", strings.TrimSuffix(out, "\n"))
+	}
+	log.Printf("%v command result: %v", name, result)
+	task.Infof("[%v] %v", name, result)
+	switch {
+	case timedOut.Load():
+		return fmt.Errorf("%v command did not complete within taskHookTimeoutSecs (%v seconds)", name, config.TaskHookTimeoutSecs)
+	case result.Crashed():
+		return result.CrashCause()
+	case result.Failed():
+		return result.FailureCause()
+	}
+	return nil
+}
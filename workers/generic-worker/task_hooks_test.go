@@ -0,0 +1,161 @@
+//go:build darwin || linux || freebsd
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcuadros/go-defaults"
+)
+
+func TestPreAndPostTaskCommands(t *testing.T) {
+	setup(t)
+
+	oldPreTaskCommand, oldPostTaskCommand := config.PreTaskCommand, config.PostTaskCommand
+	defer func() {
+		config.PreTaskCommand, config.PostTaskCommand = oldPreTaskCommand, oldPostTaskCommand
+	}()
+	config.PreTaskCommand = []string{"/usr/bin/env", "bash", "-c", `echo "Pre-task check for $TASK_ID"`}
+	config.PostTaskCommand = []string{"/usr/bin/env", "bash", "-c", `echo "Post-task check for $TASK_ID"`}
+
+	payload := GenericWorkerPayload{
+		Command:    helloGoodbye(),
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	taskID := submitAndAssert(t, td, payload, "completed", "completed")
+
+	logtext := LogText(t)
+	for _, expected := range []string{
+		"[pre-task] Pre-task check for " + taskID,
+		"[post-task] Post-task check for " + taskID,
+	} {
+		if !strings.Contains(logtext, expected) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+		}
+	}
+	if strings.Index(logtext, "[pre-task] Pre-task check") > strings.Index(logtext, "hello") {
+		t.Log(logtext)
+		t.Fatal("Pre-task command should have run before the task commands")
+	}
+}
+
+func TestPreTaskCommandFailure(t *testing.T) {
+	setup(t)
+
+	oldPreTaskCommand := config.PreTaskCommand
+	defer func() {
+		config.PreTaskCommand = oldPreTaskCommand
+	}()
+	config.PreTaskCommand = []string{"/usr/bin/env", "bash", "-c", "echo 'Disk is full'; exit 3"}
+
+	payload := GenericWorkerPayload{
+		Command:    helloGoodbye(),
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	taskID := scheduleTask(t, td, payload)
+	execute(t, PRE_TASK_COMMAND_FAILED)
+
+	queue := serviceFactory.Queue(config.Credentials(), config.RootURL)
+	status, err := queue.Status(taskID)
+	if err != nil {
+		t.Fatal("Error retrieving status from queue")
+	}
+	if run := status.Status.Runs[0]; run.State != "exception" || run.ReasonResolved != "worker-shutdown" {
+		t.Fatalf("Expected task %v to resolve as exception/worker-shutdown but resolved as %v/%v", taskID, run.State, run.ReasonResolved)
+	}
+
+	logtext := LogText(t)
+	for _, expected := range []string{
+		"[pre-task] Disk is full",
+		"pre-task command failed, so the worker will shut down",
+	} {
+		if !strings.Contains(logtext, expected) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+		}
+	}
+	if strings.Contains(logtext, "hello") {
+		t.Log(logtext)
+		t.Fatal("Task commands should not have run, since the pre-task command failed")
+	}
+}
+
+func TestPostTaskCommandFailure(t *testing.T) {
+	setup(t)
+
+	oldPostTaskCommand := config.PostTaskCommand
+	defer func() {
+		config.PostTaskCommand = oldPostTaskCommand
+	}()
+	config.PostTaskCommand = []string{"/usr/bin/env", "bash", "-c", "exit 4"}
+
+	payload := GenericWorkerPayload{
+		Command:    helloGoodbye(),
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	_ = submitAndAssert(t, td, payload, "completed", "completed")
+
+	logtext := LogText(t)
+	expected := "post-task command failed, so the worker will not claim any further tasks"
+	if !strings.Contains(logtext, expected) {
+		t.Log(logtext)
+		t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+	}
+}
+
+func TestPreTaskCommandTimeout(t *testing.T) {
+	setup(t)
+
+	oldPreTaskCommand, oldTaskHookTimeoutSecs := config.PreTaskCommand, config.TaskHookTimeoutSecs
+	defer func() {
+		config.PreTaskCommand, config.TaskHookTimeoutSecs = oldPreTaskCommand, oldTaskHookTimeoutSecs
+	}()
+	config.PreTaskCommand = []string{"/usr/bin/env", "bash", "-c", "echo 'Checking health'; sleep 60"}
+	config.TaskHookTimeoutSecs = 1
+
+	payload := GenericWorkerPayload{
+		Command:    helloGoodbye(),
+		MaxRunTime: 30,
+	}
+	defaults.SetDefaults(&payload)
+	td := testTask(t)
+
+	start := time.Now()
+	taskID := scheduleTask(t, td, payload)
+	execute(t, PRE_TASK_COMMAND_FAILED)
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("Pre-task command was not killed after taskHookTimeoutSecs; took %v", elapsed)
+	}
+
+	queue := serviceFactory.Queue(config.Credentials(), config.RootURL)
+	status, err := queue.Status(taskID)
+	if err != nil {
+		t.Fatal("Error retrieving status from queue")
+	}
+	if run := status.Status.Runs[0]; run.State != "exception" || run.ReasonResolved != "worker-shutdown" {
+		t.Fatalf("Expected task %v to resolve as exception/worker-shutdown but resolved as %v/%v", taskID, run.State, run.ReasonResolved)
+	}
+
+	logtext := LogText(t)
+	for _, expected := range []string{
+		"[pre-task] Checking health",
+		"pre-task command did not complete within taskHookTimeoutSecs (1 seconds)",
+	} {
+		if !strings.Contains(logtext, expected) {
+			t.Log(logtext)
+			t.Fatalf("Was expecting log file to contain %q, but it doesn't", expected)
+		}
+	}
+}
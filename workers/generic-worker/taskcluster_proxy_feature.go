@@ -7,6 +7,8 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 
 	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
@@ -39,15 +41,21 @@ type TaskclusterProxyTask struct {
 	task                     *TaskRun
 	taskStatusChangeListener *TaskStatusChangeListener
 	taskclusterProxyAddress  string
+	taskclusterProxySocket   string
+	auditArtifactName        string
+	auditLogPath             string
 }
 
 func (l *TaskclusterProxyTask) ReservedArtifacts() []string {
-	return []string{}
+	return []string{
+		l.auditArtifactName,
+	}
 }
 
 func (feature *TaskclusterProxyFeature) NewTaskFeature(task *TaskRun) TaskFeature {
 	return &TaskclusterProxyTask{
-		task: task,
+		task:              task,
+		auditArtifactName: "private/generic-worker/taskcluster-proxy-audit.jsonl",
 	}
 }
 
@@ -83,37 +91,72 @@ func (l *TaskclusterProxyTask) Start() *CommandExecutionError {
 		}
 	case "localhost":
 		l.taskclusterProxyAddress = "127.0.0.1"
+	case "unix-socket":
+		// The socket lives in its own directory, rather than the task
+		// directory, so that it cannot be picked up as an artifact.
+		socketDir, err := os.MkdirTemp("", "taskcluster-proxy")
+		if err != nil {
+			return executionError(internalError, errored, fmt.Errorf("could not create directory for taskcluster proxy socket: %s", err))
+		}
+		l.taskclusterProxySocket = filepath.Join(socketDir, "proxy.sock")
 	default:
 		return executionError(internalError, errored, fmt.Errorf("INTERNAL BUG: Unsupported taskcluster proxy interface enum option should not have made it here: %q", l.task.Payload.TaskclusterProxyInterface))
 	}
 
-	// Set TASKCLUSTER_PROXY_URL in the task environment
-	err := l.task.setVariable("TASKCLUSTER_PROXY_URL",
-		fmt.Sprintf("http://%s:%d", l.taskclusterProxyAddress, config.TaskclusterProxyPort))
+	// Set TASKCLUSTER_PROXY_URL (and TASKCLUSTER_PROXY_SOCKET when listening
+	// on a Unix socket) in the task environment
+	var err error
+	if l.taskclusterProxySocket != "" {
+		err = l.task.setVariable("TASKCLUSTER_PROXY_URL", "http://localhost")
+		if err == nil {
+			err = l.task.setVariable("TASKCLUSTER_PROXY_SOCKET", l.taskclusterProxySocket)
+		}
+	} else {
+		err = l.task.setVariable("TASKCLUSTER_PROXY_URL",
+			fmt.Sprintf("http://%s:%d", l.taskclusterProxyAddress, config.TaskclusterProxyPort))
+	}
 	if err != nil {
 		return MalformedPayloadError(err)
 	}
 
+	// The audit log lives in a directory that only the worker can write to,
+	// rather than the task directory, so that the task cannot rewrite it.
+	auditDir, err := os.MkdirTemp("", "taskcluster-proxy-audit")
+	if err != nil {
+		return executionError(internalError, errored, fmt.Errorf("could not create directory for taskcluster proxy audit log: %s", err))
+	}
+	l.auditLogPath = filepath.Join(auditDir, "taskcluster-proxy-audit.jsonl")
+
 	// include all scopes from task.scopes, as well as the scope to create artifacts on
 	// this task (which cannot be represented in task.scopes)
 	scopes := append(l.task.TaskClaimResponse.Task.Scopes,
 		fmt.Sprintf("queue:create-artifact:%s/%d", l.task.TaskID, l.task.RunID))
-	taskclusterProxy, err := tcproxy.New(
-		config.TaskclusterProxyExecutable,
-		l.taskclusterProxyAddress,
-		config.TaskclusterProxyPort,
-		config.RootURL,
-		&tcclient.Credentials{
+	taskclusterProxy, err := tcproxy.New(tcproxy.Options{
+		Executable: config.TaskclusterProxyExecutable,
+		IPAddress:  l.taskclusterProxyAddress,
+		HTTPPort:   config.TaskclusterProxyPort,
+		UnixSocket: l.taskclusterProxySocket,
+		RootURL:    config.RootURL,
+		Credentials: &tcclient.Credentials{
 			AccessToken:      l.task.TaskClaimResponse.Credentials.AccessToken,
 			Certificate:      l.task.TaskClaimResponse.Credentials.Certificate,
 			ClientID:         l.task.TaskClaimResponse.Credentials.ClientID,
 			AuthorizedScopes: scopes,
 		},
-	)
+		AuditLog:  l.auditLogPath,
+		Deny:      config.TaskclusterProxyDeny,
+		CacheSize: config.TaskclusterProxyCacheSize,
+	})
 	if err != nil {
 		return executionError(internalError, errored, fmt.Errorf("could not start taskcluster proxy: %s", err))
 	}
 	l.taskclusterProxy = taskclusterProxy
+	if l.taskclusterProxySocket != "" {
+		err = makeTaskclusterProxySocketAccessibleToTaskUser(filepath.Dir(l.taskclusterProxySocket))
+		if err != nil {
+			return executionError(internalError, errored, fmt.Errorf("could not grant task user access to taskcluster proxy socket: %s", err))
+		}
+	}
 	l.taskStatusChangeListener = &TaskStatusChangeListener{
 		Name: "taskcluster-proxy",
 		Callback: func(ts TaskStatus) {
@@ -127,12 +170,12 @@ func (l *TaskclusterProxyTask) Start() *CommandExecutionError {
 				panic(err)
 			}
 			buffer := bytes.NewBuffer(b)
-			putURL := fmt.Sprintf("http://%s:%v/credentials", l.taskclusterProxyAddress, config.TaskclusterProxyPort)
+			client, baseURL := l.taskclusterProxy.Client()
+			putURL := baseURL + "/credentials"
 			req, err := http.NewRequest("PUT", putURL, buffer)
 			if err != nil {
 				panic(fmt.Sprintf("Could not create PUT request to taskcluster-proxy /credentials endpoint: %v", err))
 			}
-			client := &http.Client{}
 			res, err := client.Do(req)
 			if err != nil {
 				panic(fmt.Sprintf("Could not PUT to %v: %v", putURL, err))
@@ -151,6 +194,12 @@ func (l *TaskclusterProxyTask) Start() *CommandExecutionError {
 
 func (l *TaskclusterProxyTask) Stop(err *ExecutionErrors) {
 	l.task.StatusManager.DeregisterListener(l.taskStatusChangeListener)
+	if l.taskclusterProxySocket != "" {
+		defer os.RemoveAll(filepath.Dir(l.taskclusterProxySocket))
+	}
+	if l.auditLogPath != "" {
+		defer os.RemoveAll(filepath.Dir(l.auditLogPath))
+	}
 	if l.taskclusterProxy == nil {
 		return
 	}
@@ -160,4 +209,9 @@ func (l *TaskclusterProxyTask) Stop(err *ExecutionErrors) {
 		l.task.Warnf("[taskcluster-proxy] Could not terminate taskcluster proxy process: %s", errTerminate)
 		log.Printf("WARNING: could not terminate taskcluster proxy writer: %s", errTerminate)
 	}
+	if _, statErr := os.Stat(l.auditLogPath); statErr != nil {
+		// proxy never started up far enough to create its audit log
+		return
+	}
+	err.add(l.task.uploadLog(l.auditArtifactName, l.auditLogPath))
 }
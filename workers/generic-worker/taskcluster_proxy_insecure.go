@@ -0,0 +1,9 @@
+//go:build insecure
+
+package main
+
+// makeTaskclusterProxySocketAccessibleToTaskUser is a no-op for the insecure
+// engine, since tasks run as the same user as the worker.
+func makeTaskclusterProxySocketAccessibleToTaskUser(socketDir string) error {
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build multiuser
+
+package main
+
+// makeTaskclusterProxySocketAccessibleToTaskUser hands ownership of the
+// directory containing the taskcluster proxy Unix socket (and the socket
+// itself) to the task user, since the socket is only writable by its owner.
+func makeTaskclusterProxySocketAccessibleToTaskUser(socketDir string) error {
+	return makeFileOrDirReadWritableForUser(true, socketDir, taskContext.User)
+}
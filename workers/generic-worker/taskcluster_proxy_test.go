@@ -75,6 +75,14 @@ func TestTaskclusterProxy(t *testing.T) {
 			ContentEncoding: "gzip",
 			Expires:         td.Expires,
 		},
+		"private/generic-worker/taskcluster-proxy-audit.jsonl": {
+			Extracts: []string{
+				`"method":"GET","service":"queue","endpoint":"/v1/task/` + td.Dependencies[0] + `/artifacts/SampleArtifacts%2F_%2FX.txt"`,
+			},
+			ContentType:     "text/plain; charset=utf-8",
+			ContentEncoding: "gzip",
+			Expires:         td.Expires,
+		},
 	}
 
 	expectedArtifacts.Validate(t, taskID, 0)
@@ -154,7 +154,8 @@ func (tsm *TaskStatusManager) ReportCompleted() error {
 }
 
 func (tsm *TaskStatusManager) reclaim() error {
-	return tsm.updateStatus(
+	reclaimFailed := false
+	err := tsm.updateStatus(
 		reclaimed,
 		func(task *TaskRun) error {
 			log.Printf("Reclaiming task %v...", task.TaskID)
@@ -164,9 +165,8 @@ func (tsm *TaskStatusManager) reclaim() error {
 
 			// check if an error occurred...
 			if err != nil {
-				// probably task was cancelled - in any case, we should kill the running task...
 				log.Printf("%v", err)
-				task.kill()
+				reclaimFailed = true
 				return err
 			}
 
@@ -192,6 +192,12 @@ func (tsm *TaskStatusManager) reclaim() error {
 		claimed,
 		reclaimed,
 	)
+	if reclaimFailed {
+		// probably task was cancelled - in any case, we should kill the
+		// running task, without holding the lock (see Abort)
+		tsm.task.kill()
+	}
+	return err
 }
 
 func (tsm *TaskStatusManager) AbortException() *CommandExecutionError {
@@ -207,11 +213,10 @@ func (tsm *TaskStatusManager) TakenUntil() tcclient.Time {
 }
 
 func (tsm *TaskStatusManager) Abort(cee *CommandExecutionError) error {
-	return tsm.updateStatus(
+	err := tsm.updateStatus(
 		aborted,
 		func(task *TaskRun) error {
 			task.Errorf("Aborting task...")
-			task.kill()
 			tsm.abortException = cee
 			return nil
 		},
@@ -224,6 +229,13 @@ func (tsm *TaskStatusManager) Abort(cee *CommandExecutionError) error {
 		// the task finally gets resolved after the task abortion completes.
 		aborted,
 	)
+	if err != nil {
+		return err
+	}
+	// The task is killed without holding the lock, since task commands are
+	// given a grace period to exit, during which reclaims need to continue.
+	tsm.task.kill()
+	return nil
 }
 
 func (tsm *TaskStatusManager) Cancel() error {
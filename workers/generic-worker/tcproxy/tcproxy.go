@@ -4,12 +4,15 @@
 package tcproxy
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,29 +21,70 @@ import (
 
 // TaskclusterProxy provides access to a taskcluster-proxy process running on the OS.
 type TaskclusterProxy struct {
-	mut      sync.Mutex
-	command  *exec.Cmd
-	HTTPPort uint16
-	Pid      int
+	mut        sync.Mutex
+	command    *exec.Cmd
+	ipAddress  string
+	HTTPPort   uint16
+	UnixSocket string
+	Pid        int
 }
 
-// New starts a tcproxy OS process using the executable specified, and returns
-// a *TaskclusterProxy.
-func New(taskclusterProxyExecutable string, ipAddress string, httpPort uint16, rootURL string, creds *tcclient.Credentials) (*TaskclusterProxy, error) {
+// Options configures a taskcluster-proxy process started by New.
+type Options struct {
+	// Executable is the path to the taskcluster-proxy executable.
+	Executable string
+	// IPAddress and HTTPPort are the TCP address the proxy listens on, unless
+	// UnixSocket is set.
+	IPAddress string
+	HTTPPort  uint16
+	// UnixSocket, if not empty, is the path of a Unix domain socket for the
+	// proxy to listen on, instead of IPAddress and HTTPPort.
+	UnixSocket  string
+	RootURL     string
+	Credentials *tcclient.Credentials
+	// AuditLog, if not empty, is the path of a file in which the proxy
+	// records every request it handles.
+	AuditLog string
+	// Deny lists API methods (e.g. auth.resetAccessToken) that the proxy
+	// refuses to call.
+	Deny []string
+	// CacheSize, if non-zero, is the number of megabytes of memory the proxy
+	// may use to cache responses to GET requests.
+	CacheSize uint
+}
+
+// New starts a taskcluster-proxy OS process configured by opts, and returns
+// a *TaskclusterProxy once the proxy is accepting connections.
+func New(opts Options) (*TaskclusterProxy, error) {
+	creds := opts.Credentials
 	args := []string{
-		"--port", strconv.Itoa(int(httpPort)),
-		"--root-url", rootURL,
+		"--port", strconv.Itoa(int(opts.HTTPPort)),
+		"--root-url", opts.RootURL,
 		"--client-id", creds.ClientID,
 		"--access-token", creds.AccessToken,
-		"--ip-address", ipAddress,
+		"--ip-address", opts.IPAddress,
 	}
 	if creds.Certificate != "" {
 		args = append(args, "--certificate", creds.Certificate)
 	}
+	if opts.UnixSocket != "" {
+		args = append(args, "--unix-socket", opts.UnixSocket)
+	}
+	if opts.AuditLog != "" {
+		args = append(args, "--audit-log", opts.AuditLog)
+	}
+	if len(opts.Deny) > 0 {
+		args = append(args, "--deny", strings.Join(opts.Deny, ","))
+	}
+	if opts.CacheSize > 0 {
+		args = append(args, "--cache-size", strconv.FormatUint(uint64(opts.CacheSize), 10))
+	}
 	args = append(args, creds.AuthorizedScopes...)
 	l := &TaskclusterProxy{
-		command:  exec.Command(taskclusterProxyExecutable, args...),
-		HTTPPort: httpPort,
+		command:    exec.Command(opts.Executable, args...),
+		ipAddress:  opts.IPAddress,
+		HTTPPort:   opts.HTTPPort,
+		UnixSocket: opts.UnixSocket,
 	}
 	l.command.Stdout = os.Stdout
 	l.command.Stderr = os.Stderr
@@ -53,10 +97,34 @@ func New(taskclusterProxyExecutable string, ipAddress string, httpPort uint16, r
 	l.Pid = l.command.Process.Pid
 	log.Printf("Started taskcluster proxy process (PID %v)", l.Pid)
 	// Just to be safe, let's make sure the port is actually active before returning.
-	err = waitForPortToBeActive(ipAddress, httpPort)
+	err = waitForListener(l.network())
 	return l, err
 }
 
+func (l *TaskclusterProxy) network() (network string, address string) {
+	if l.UnixSocket != "" {
+		return "unix", l.UnixSocket
+	}
+	return "tcp", l.ipAddress + ":" + strconv.Itoa(int(l.HTTPPort))
+}
+
+// Client returns an HTTP client which connects to the proxy, whether it
+// listens on a TCP port or a Unix domain socket, and the base URL to use with
+// it.
+func (l *TaskclusterProxy) Client() (client *http.Client, baseURL string) {
+	network, address := l.network()
+	if network == "tcp" {
+		return &http.Client{}, "http://" + address
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, address)
+			},
+		},
+	}, "http://localhost"
+}
+
 func (l *TaskclusterProxy) Terminate() error {
 	l.mut.Lock()
 	defer func() {
@@ -73,21 +141,22 @@ func (l *TaskclusterProxy) Terminate() error {
 		}
 		log.Printf("Stopped taskcluster proxy process (PID %v)", l.Pid)
 		l.HTTPPort = 0
+		l.UnixSocket = ""
 		l.Pid = 0
 		l.command = nil
 	}()
 	return l.command.Process.Kill()
 }
 
-func waitForPortToBeActive(ipAddress string, port uint16) error {
+func waitForListener(network string, address string) error {
 	deadline := time.Now().Add(60 * time.Second)
 	for time.Now().Before(deadline) {
-		conn, err := net.DialTimeout("tcp", ipAddress+":"+strconv.Itoa(int(port)), 60*time.Second)
+		conn, err := net.DialTimeout(network, address, 60*time.Second)
 		if err == nil {
 			_ = conn.Close()
 			return nil
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
-	return fmt.Errorf("timeout waiting for taskcluster-proxy %v:%v to be active", ipAddress, port)
+	return fmt.Errorf("timeout waiting for taskcluster-proxy %v to be active", address)
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"path/filepath"
 	"runtime"
 	"testing"
 
@@ -28,7 +29,13 @@ func TestTcProxy(t *testing.T) {
 		Certificate:      certificate,
 		AuthorizedScopes: []string{"queue:get-artifact:SampleArtifacts/_/X.txt"},
 	}
-	ll, err := New(executable, "127.0.0.1", 34570, rootURL, creds)
+	ll, err := New(Options{
+		Executable:  executable,
+		IPAddress:   "127.0.0.1",
+		HTTPPort:    34570,
+		RootURL:     rootURL,
+		Credentials: creds,
+	})
 	// Do defer before checking err since err could be a different error and
 	// process may have already started up.
 	defer func() {
@@ -62,3 +69,40 @@ func TestTcProxy(t *testing.T) {
 		t.Fatalf("Got current scopes %s that do not satisfy authorized scopes %s: %v", string(data), required, err)
 	}
 }
+
+func TestTcProxyUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix domain socket listener not supported by generic-worker on Windows")
+	}
+	rootURL, clientID, accessToken, certificate := testrooturl.GetWithCreds(t)
+	creds := &tcclient.Credentials{
+		ClientID:    clientID,
+		AccessToken: accessToken,
+		Certificate: certificate,
+	}
+	socket := filepath.Join(t.TempDir(), "proxy.sock")
+	ll, err := New(Options{
+		Executable:  "taskcluster-proxy",
+		UnixSocket:  socket,
+		RootURL:     rootURL,
+		Credentials: creds,
+	})
+	defer func() {
+		err := ll.Terminate()
+		if err != nil {
+			t.Fatalf("Failed to terminate taskcluster-proxy process:\n%s", err)
+		}
+	}()
+	if err != nil {
+		t.Fatalf("Could not initiate taskcluster-proxy process:\n%s", err)
+	}
+	client, baseURL := ll.Client()
+	res, err := client.Get(baseURL + "/auth/v1/scopes/current")
+	if err != nil {
+		t.Fatalf("Could not call taskcluster-proxy over Unix domain socket %v: %v", socket, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected HTTP 200 from taskcluster-proxy but got %v", res.Status)
+	}
+}
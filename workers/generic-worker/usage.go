@@ -22,6 +22,8 @@ const (
 	CANT_CREATE_FILE            ExitCode = 79
 	CANT_CREATE_DIRECTORY       ExitCode = 80
 	CANT_UNARCHIVE              ExitCode = 81
+	CANT_WRITE_FILE             ExitCode = 83
+	PRE_TASK_COMMAND_FAILED     ExitCode = 85
 )
 
 func usage(versionName string) string {
@@ -40,6 +42,7 @@ and reports back results to the queue.
     generic-worker new-ed25519-keypair      --file ED25519-PRIVATE-KEY-FILE` + customTargetsSummary() + `
     generic-worker copy-to-temp-file        --copy-file COPY-FILE
     generic-worker create-file              --create-file CREATE-FILE
+    generic-worker write-file               --write-file WRITE-FILE
     generic-worker create-dir               --create-dir CREATE-DIR
     generic-worker unarchive                --archive-src ARCHIVE-SRC --archive-dst ARCHIVE-DST --archive-fmt ARCHIVE-FMT
     generic-worker --help
@@ -65,6 +68,8 @@ and reports back results to the queue.
                                             to stdout. Intended for internal use.
     create-file                             This will create a file at the specified path.
                                             Intended for internal use.
+    write-file                              This will write standard input to a file at the
+                                            specified path. Intended for internal use.
     create-dir                              This will create a directory (including missing
                                             parent directories) at the specified path.
                                             Intended for internal use.
@@ -88,9 +93,10 @@ and reports back results to the queue.
     --file PRIVATE-KEY-FILE                 The path to the file to write the private key
                                             to. The parent directory must already exist.
                                             If the file exists it will be overwritten,
-                                            otherwise it will be created.` + sidSID() + `
+                                            otherwise it will be created.` + sidSID() + pidPID() + `
     --copy-file COPY-FILE                   The path to the file to copy.
     --create-file CREATE-FILE               The path to the file to create.
+    --write-file WRITE-FILE                 The path to the file to write.
     --create-dir CREATE-DIR                 The path to the directory to create.
     --archive-src ARCHIVE-SRC               The path to the archive file to unarchive.
     --archive-dst ARCHIVE-DST               The path to the directory to unarchive to.
@@ -131,6 +137,13 @@ and reports back results to the queue.
         ** OPTIONAL ** properties
         =========================
 
+          allowedExposePorts                The ports which tasks may expose with the exposePorts
+                                            property of the task payload, for example [3000, 8080],
+                                            and to which interactive sessions may forward
+                                            connections, if the task enables port forwarding.
+                                            Any other port, including those used by the worker
+                                            itself or by other services on the host, cannot be
+                                            exposed. [default: []]
           availabilityZone                  The EC2 availability zone of the worker.
           cachesDir                         The directory where task caches should be stored on
                                             the worker. The directory will be created if it does
@@ -211,6 +224,16 @@ and reports back results to the queue.
                                             is used to allow interactive access to the worker
                                             while it is running.
                                             [default: 53654]
+          interactiveRecording              Record interactive sessions of tasks as an asciicast
+                                            v2 file, uploaded as the private artifact
+                                            private/generic-worker/interactive-session.cast,
+                                            which can be replayed with
+                                            "taskcluster task replay <taskId>".
+                                            [default: false]
+          interactiveRecordingStdin         Also record the input sent to interactive sessions.
+                                            Note, this may include secrets that are not echoed
+                                            to the terminal, such as passwords. Only used if
+                                            interactiveRecording is true. [default: false]
           livelogExecutable                 Filepath of LiveLog executable to use; see
                                             https://github.com/taskcluster/livelog
                                             [default: "livelog"]
@@ -224,6 +247,26 @@ and reports back results to the queue.
                                             [default: 86400]
           numberOfTasksToRun                If zero, run tasks indefinitely. Otherwise, after
                                             this many tasks, exit. [default: 0]
+          postTaskCommand                   A command (array of strings) to run as the user
+                                            running generic-worker (root/Administrator in
+                                            the multiuser engine) after each task, before
+                                            the task is resolved, to check the health of the
+                                            machine. It runs in the worker's working
+                                            directory, with the environment variables
+                                            TASK_ID and RUN_ID set. Its output is written to
+                                            the worker log and to the task log. If it fails,
+                                            the task resolution is not affected, but the
+                                            worker stops claiming tasks and reports an error
+                                            to worker-manager. [default: []]
+          preTaskCommand                    A command (array of strings) to run as the user
+                                            running generic-worker (root/Administrator in
+                                            the multiuser engine) after each task is claimed,
+                                            before any of its commands run, to check the
+                                            health of the machine. It runs in the same way
+                                            as postTaskCommand. If it fails, the task is
+                                            resolved as exception/worker-shutdown, so that
+                                            it is retried on another worker, and the worker
+                                            exits with exit code 85. [default: []]
           privateIP                         The private IP of the worker, used by chain of trust.
           provisionerId                     The taskcluster provisioner which is taking care
                                             of provisioning environments with generic-worker
@@ -231,6 +274,14 @@ and reports back results to the queue.
           publicIP                          The IP address for VNC access.  Also used by chain of
                                             trust when present.
           region                            The EC2 region of the worker. Used by chain of trust.
+          relaySecret                       The secret shared with the websocktunnel relay at
+                                            relayURL, used to sign the worker's tunnel tokens.
+                                            Required if relayURL is set.
+          relayURL                          The URL of a websocktunnel relay (see "websocktunnel
+                                            relay") with which to expose live logs and interactive
+                                            sessions, for deployments without a websocktunnel
+                                            server.  Ignored if wstAudience and wstServerURL are
+                                            set.  Optional if not using a relay.
           requiredDiskSpaceMegabytes        The garbage collector will ensure at least this
                                             number of megabytes of disk space are available
                                             when each task starts. If it cannot free enough
@@ -267,14 +318,36 @@ and reports back results to the queue.
                                             for machines running in production, such as on AWS
                                             EC2 spot instances. Use with caution!
                                             [default: false]
+          taskclusterProxyCacheSize         If non-zero, the taskcluster-proxy caches responses to
+                                            GET requests in memory, using at most this many
+                                            megabytes. Cached responses are only served to the
+                                            task they were fetched for, and respect the
+                                            Cache-Control and ETag headers of the service.
+                                            [default: 0]
+          taskclusterProxyDeny              A list of API methods, of the form <service>.<method>
+                                            (for example "auth.resetAccessToken"), which tasks
+                                            may not call via the taskcluster-proxy, regardless
+                                            of their scopes. [default: []]
           taskclusterProxyExecutable        Filepath of taskcluster-proxy executable to use; see
                                             https://github.com/taskcluster/taskcluster/tree/main/tools/taskcluster-proxy
                                             [default: "taskcluster-proxy"]
           taskclusterProxyPort              Port number for taskcluster-proxy HTTP requests.
                                             [default: 80]
+          taskHookTimeoutSecs               The maximum number of seconds that preTaskCommand or
+                                            postTaskCommand may run for. A command which runs for
+                                            longer is killed, and treated as having failed. If
+                                            zero, there is no limit. [default: 600]
           tasksDir                          The location where task directories should be
                                             created on the worker.
                                             [default (varies by platform): ` + fmt.Sprintf("%q", defaultTasksDir()) + `]
+          taskTerminationGracePeriodSecs    When a task is aborted (for example because it exceeded
+                                            its maxRunTime, was cancelled, or was about to exhaust
+                                            the system memory), the running command is first sent
+                                            SIGTERM (CTRL_BREAK on Windows), and only killed if it
+                                            has not exited after this many seconds. This gives
+                                            the command a chance to write test reports or partial
+                                            artifacts. If zero, the command is killed immediately.
+                                            [default: 0]
           workerGroup                       Typically this would be an aws region - an
                                             identifier to uniquely identify which pool of
                                             workers this worker logically belongs to.
@@ -337,5 +410,8 @@ and reports back results to the queue.
     79     Not able to create file at --create-file path.
     80     Not able to create directory at --create-dir path.
     81     Not able to unarchive --archive-src to --archive-dst.` + exitCode82() + `
+    83     Not able to write standard input to --write-file path.` + exitCode84() + `
+    85     The pre-task command (see config setting preTaskCommand) failed, so the
+           claimed task was resolved as exception/worker-shutdown.
 `
 }
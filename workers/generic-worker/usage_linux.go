@@ -29,7 +29,11 @@ func d2gConfig() string {
                                               * gpus - The NVIDIA GPUs to make available to the running container.
                                                 Only used if allowGPUs is true. [default: "all"]
                                               * logTranslation (unused) - Logs the D2G-translated task definition to the task logs.
-                                                [default: true]`
+                                                [default: true]
+                                              * taskclusterProxyUnixSocket - Refuses to translate tasks that use the
+                                                Taskcluster Proxy, for workers that should only provide the proxy
+                                                over a Unix domain socket. Docker Worker tasks expect the proxy at
+                                                http://taskcluster, which a socket cannot provide. [default: false]`
 }
 
 func loopbackDeviceNumbers() string {
@@ -33,3 +33,11 @@ func exitCode74() string {
 func sidSID() string {
 	return ""
 }
+
+func exitCode84() string {
+	return ""
+}
+
+func pidPID() string {
+	return ""
+}
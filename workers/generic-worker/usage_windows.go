@@ -2,6 +2,7 @@ package main
 
 const (
 	CANT_GRANT_CONTROL_OF_WINSTA_AND_DESKTOP ExitCode = 74
+	CANT_SEND_CTRL_BREAK                     ExitCode = 84
 )
 
 func installServiceSummary() string {
@@ -13,7 +14,8 @@ func installServiceSummary() string {
 
 func customTargetsSummary() string {
 	return `
-    generic-worker grant-winsta-access      --sid SID`
+    generic-worker grant-winsta-access      --sid SID
+    generic-worker send-ctrl-break          --pid PID`
 }
 
 func installService() string {
@@ -35,7 +37,10 @@ func customTargets() string {
 	return `
     grant-winsta-access                     Used internally by generic-worker to grant a
                                             logon SID full control of the interactive
-                                            windows station and desktop.`
+                                            windows station and desktop.
+    send-ctrl-break                         Used internally by generic-worker to send
+                                            CTRL_BREAK to the process group of a task
+                                            command, before killing it.`
 }
 
 func platformCommandLineParameters() string {
@@ -65,6 +70,17 @@ func sidSID() string {
                                             example: 'S-1-5-5-0-41431533'.`
 }
 
+func exitCode84() string {
+	return `
+    84     Could not send CTRL_BREAK to the process group of provided PID.`
+}
+
+func pidPID() string {
+	return `
+    --pid PID                               The process ID of a task command, which is the
+                                            ID of its process group.`
+}
+
 func disableNativePayloads() string {
 	return ""
 }
@@ -52,6 +52,8 @@ var (
 	procGetUserObjectInformationW    = user32.NewProc("GetUserObjectInformationW")
 	procDeleteProfileW               = userenv.NewProc("DeleteProfileW")
 	procGetDiskFreeSpaceExW          = kernel32.NewProc("GetDiskFreeSpaceExW")
+	procFreeConsole                  = kernel32.NewProc("FreeConsole")
+	procAttachConsole                = kernel32.NewProc("AttachConsole")
 
 	FOLDERID_LocalAppData   = syscall.GUID{Data1: 0xF1B32785, Data2: 0x6FBA, Data3: 0x4FCF, Data4: [8]byte{0x9D, 0x55, 0x7B, 0x8E, 0x7F, 0x15, 0x70, 0x91}}
 	FOLDERID_RoamingAppData = syscall.GUID{Data1: 0x3EB685DB, Data2: 0x65F9, Data3: 0x4CF6, Data4: [8]byte{0xA0, 0x3A, 0xE3, 0xEF, 0x65, 0x72, 0x9F, 0x3D}}
@@ -400,6 +402,21 @@ func WTSGetActiveConsoleSessionId() (sessionId uint32, err error) {
 	return
 }
 
+// SendCtrlBreak sends CTRL_BREAK to the process group of the given process,
+// which must have been created with CREATE_NEW_PROCESS_GROUP. Console control
+// events can only be sent to processes which share the console of the calling
+// process, so the calling process detaches from its own console and attaches
+// to the console of the given process. It should therefore only be called
+// from a short-lived helper process.
+func SendCtrlBreak(pid uint32) (err error) {
+	_, _, _ = procFreeConsole.Call()
+	r1, _, e1 := procAttachConsole.Call(uintptr(pid))
+	if r1 == 0 {
+		return os.NewSyscallError("AttachConsole", e1)
+	}
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, pid)
+}
+
 // InteractiveUserToken returns a user token (security context) for the
 // interactive desktop session attached to the default console (i.e. what would
 // be seen on a display connected directly to the computer, rather than a
@@ -2,9 +2,14 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"log"
+	"maps"
 	"os"
+	"slices"
+	"sync"
+	"time"
 
 	tcclient "github.com/taskcluster/taskcluster/v84/clients/client-go"
 	"github.com/taskcluster/taskcluster/v84/tools/workerproto"
@@ -19,8 +24,31 @@ var (
 
 	// The transport behind WorkerRunnerProtocol
 	workerRunnerTransport workerproto.Transport
+
+	// Config changes received in config-update messages, waiting to be
+	// applied between tasks
+	pendingConfigUpdate      map[string]any
+	pendingConfigUpdateMutex sync.Mutex
 )
 
+// The config properties that can be changed by a config-update message while
+// the worker is running.  These are only read between tasks, or while starting
+// a task, so a change takes effect from the next task.  Worker-runner restarts
+// the worker to apply changes to any other properties, so keep this list in
+// sync with the generic-worker implementation in worker-runner (its tests
+// check that the lists match).
+var hotReloadableConfig = []string{
+	"checkForNewDeploymentEverySecs",
+	"disableReboots",
+	"idleTimeoutSecs",
+	"maxTaskRunTime",
+	"numberOfTasksToRun",
+	"requiredDiskSpaceMegabytes",
+	"shutdownMachineOnIdle",
+	"shutdownMachineOnInternalError",
+	"taskTerminationGracePeriodSecs",
+}
+
 // A loggingWriter implements io.Writer and should be passed to a `log` instance
 // as its Output.  It will translate all written messages into messages to
 // worker-runner, or if that is not supported output them to stderr as usual.
@@ -98,10 +126,128 @@ func startProtocol() {
 
 	WorkerRunnerProtocol.AddCapability("error-report")
 	WorkerRunnerProtocol.AddCapability("log")
+	WorkerRunnerProtocol.AddCapability("task-claimed")
+	WorkerRunnerProtocol.AddCapability("task-resolved")
+	WorkerRunnerProtocol.AddCapability("idle")
+
+	WorkerRunnerProtocol.AddCapability("config-update")
+	WorkerRunnerProtocol.Register("config-update", func(msg workerproto.Message) {
+		update, ok := msg.Properties["config"].(map[string]any)
+		if !ok {
+			log.Printf("Ignoring config-update message without a config object")
+			return
+		}
+		log.Printf("Got config-update for %v; applying before the next task", slices.Sorted(maps.Keys(update)))
+		pendingConfigUpdateMutex.Lock()
+		defer pendingConfigUpdateMutex.Unlock()
+		if pendingConfigUpdate == nil {
+			pendingConfigUpdate = map[string]any{}
+		}
+		maps.Copy(pendingConfigUpdate, update)
+	})
 
 	WorkerRunnerProtocol.Start(true)
 }
 
+// Apply any config changes received from worker-runner.  This must only be
+// called between tasks.  Properties that cannot be changed while the worker is
+// running are ignored.
+func applyConfigUpdate() {
+	pendingConfigUpdateMutex.Lock()
+	update := pendingConfigUpdate
+	pendingConfigUpdate = nil
+	pendingConfigUpdateMutex.Unlock()
+
+	if len(update) == 0 {
+		return
+	}
+
+	for key := range update {
+		if !slices.Contains(hotReloadableConfig, key) {
+			log.Printf("WARNING: config property %v cannot be changed while the worker is running; ignoring update", key)
+			delete(update, key)
+		}
+	}
+
+	// decode the update over the existing config, so that only the updated
+	// properties change
+	encoded, err := json.Marshal(update)
+	if err == nil {
+		err = json.Unmarshal(encoded, config)
+	}
+	if err != nil {
+		log.Printf("WARNING: could not apply config update: %v", err)
+		return
+	}
+	for _, key := range slices.Sorted(maps.Keys(update)) {
+		log.Printf("Config property %v updated to %v", key, update[key])
+	}
+}
+
+// Inform worker-runner that the given task has been claimed
+func sendTaskClaimed(task *TaskRun) {
+	if WorkerRunnerProtocol == nil || !WorkerRunnerProtocol.Capable("task-claimed") {
+		return
+	}
+	WorkerRunnerProtocol.Send(workerproto.Message{
+		Type: "task-claimed",
+		Properties: map[string]any{
+			"task-id": task.TaskID,
+			"run-id":  task.RunID,
+		},
+	})
+}
+
+// Inform worker-runner that the given task has been resolved, with the given
+// final status and execution errors, after running for the given duration
+func sendTaskResolved(task *TaskRun, status TaskStatus, errors *ExecutionErrors, duration time.Duration) {
+	if WorkerRunnerProtocol == nil || !WorkerRunnerProtocol.Capable("task-resolved") {
+		return
+	}
+	properties := map[string]any{
+		"task-id":  task.TaskID,
+		"run-id":   task.RunID,
+		"duration": duration.Seconds(),
+	}
+	// translate to the queue's terminology for task resolutions
+	switch status {
+	case succeeded:
+		properties["status"] = "completed"
+	case failed:
+		properties["status"] = "failed"
+	case errored:
+		properties["status"] = "exception"
+		if errors.Occurred() {
+			properties["reason"] = string((*errors)[0].Reason)
+		}
+	case cancelled:
+		properties["status"] = "exception"
+		properties["reason"] = "canceled"
+	case deadlineExceeded:
+		properties["status"] = "exception"
+		properties["reason"] = "deadline-exceeded"
+	default:
+		properties["status"] = "unknown"
+	}
+	WorkerRunnerProtocol.Send(workerproto.Message{
+		Type:       "task-resolved",
+		Properties: properties,
+	})
+}
+
+// Inform worker-runner that the worker has been idle since the given time
+func sendIdle(since time.Time) {
+	if WorkerRunnerProtocol == nil || !WorkerRunnerProtocol.Capable("idle") {
+		return
+	}
+	WorkerRunnerProtocol.Send(workerproto.Message{
+		Type: "idle",
+		Properties: map[string]any{
+			"idle-since": since.UTC().Format(time.RFC3339),
+		},
+	})
+}
+
 func teardownWorkerRunnerProtocol() {
 	log.SetOutput(os.Stderr)
 	log.SetFlags(log.LstdFlags)
@@ -13,6 +13,14 @@ import (
 )
 
 func setupWorkerRunnerTest(t *testing.T, runnerCapabilities ...string) *workerproto.Protocol {
+	t.Helper()
+	return setupWorkerRunnerTestWithCallbacks(t, nil, runnerCapabilities...)
+}
+
+// setupWorkerRunnerTestWithCallbacks is like setupWorkerRunnerTest, but also
+// registers the given callbacks on the runner side of the protocol, which
+// must happen before the protocol is started.
+func setupWorkerRunnerTestWithCallbacks(t *testing.T, callbacks map[string]workerproto.MessageCallback, runnerCapabilities ...string) *workerproto.Protocol {
 	t.Helper()
 	graceful.Reset()
 	workerTransport, runnerTransport := wptesting.NewLocalTransportPair()
@@ -22,6 +30,9 @@ func setupWorkerRunnerTest(t *testing.T, runnerCapabilities ...string) *workerpr
 	for _, cap := range runnerCapabilities {
 		runnerProto.AddCapability(cap)
 	}
+	for msgType, callback := range callbacks {
+		runnerProto.Register(msgType, callback)
+	}
 	runnerProto.Start(false)
 
 	// set up the worker side of the protocol
@@ -108,3 +119,122 @@ func TestNewCredentials(t *testing.T) {
 	t.Run("WithCertificate", test(true))
 	t.Run("WithoutCertificate", test(false))
 }
+
+func TestTaskLifecycleMessages(t *testing.T) {
+	received := make(chan workerproto.Message, 10)
+	receive := func(msg workerproto.Message) {
+		received <- msg
+	}
+	setupWorkerRunnerTestWithCallbacks(t, map[string]workerproto.MessageCallback{
+		"task-claimed":  receive,
+		"task-resolved": receive,
+		"idle":          receive,
+	}, "task-claimed", "task-resolved", "idle")
+
+	task := &TaskRun{TaskID: "abc", RunID: 2}
+	idleSince := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sendIdle(idleSince)
+	msg := <-received
+	require.Equal(t, "idle", msg.Type)
+	require.Equal(t, "2025-01-02T03:04:05Z", msg.Properties["idle-since"])
+
+	sendTaskClaimed(task)
+	msg = <-received
+	require.Equal(t, "task-claimed", msg.Type)
+	require.Equal(t, "abc", msg.Properties["task-id"])
+	require.Equal(t, float64(2), msg.Properties["run-id"])
+
+	for _, test := range []struct {
+		status         TaskStatus
+		errors         ExecutionErrors
+		expectedStatus string
+		expectedReason any
+	}{
+		{succeeded, nil, "completed", nil},
+		{failed, ExecutionErrors{Failure(fmt.Errorf("exit 1"))}, "failed", nil},
+		{errored, ExecutionErrors{MalformedPayloadError(fmt.Errorf("bad"))}, "exception", "malformed-payload"},
+		{cancelled, nil, "exception", "canceled"},
+		{deadlineExceeded, nil, "exception", "deadline-exceeded"},
+	} {
+		sendTaskResolved(task, test.status, &test.errors, 90*time.Second)
+		msg = <-received
+		require.Equal(t, "task-resolved", msg.Type)
+		require.Equal(t, "abc", msg.Properties["task-id"])
+		require.Equal(t, float64(90), msg.Properties["duration"])
+		require.Equal(t, test.expectedStatus, msg.Properties["status"], "status for %s", test.status)
+		require.Equal(t, test.expectedReason, msg.Properties["reason"], "reason for %s", test.status)
+	}
+}
+
+func TestTaskLifecycleMessagesNotSupported(t *testing.T) {
+	received := make(chan workerproto.Message, 10)
+	setupWorkerRunnerTestWithCallbacks(t, map[string]workerproto.MessageCallback{
+		"idle": func(msg workerproto.Message) {
+			received <- msg
+		},
+	})
+
+	require.False(t, WorkerRunnerProtocol.Capable("idle"))
+	sendIdle(time.Now())
+	sendTaskClaimed(&TaskRun{TaskID: "abc"})
+
+	select {
+	case msg := <-received:
+		t.Fatalf("unexpected message %v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestConfigUpdate(t *testing.T) {
+	runnerProto := setupWorkerRunnerTest(t, "config-update")
+	config = &gwconfig.Config{}
+	config.IdleTimeoutSecs = 60
+	config.CachesDir = "caches"
+
+	require.True(t, WorkerRunnerProtocol.Capable("config-update"))
+	runnerProto.Send(workerproto.Message{
+		Type: "config-update",
+		Properties: map[string]any{
+			"config": map[string]any{
+				"idleTimeoutSecs":    3600,
+				"numberOfTasksToRun": 5,
+				// not hot-reloadable
+				"cachesDir": "elsewhere",
+			},
+		},
+	})
+
+	// messages are handled asynchronously, so poll until the update is pending
+	require.Eventually(t, func() bool {
+		pendingConfigUpdateMutex.Lock()
+		defer pendingConfigUpdateMutex.Unlock()
+		return pendingConfigUpdate != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// nothing changes until the update is applied between tasks
+	require.Equal(t, uint(60), config.IdleTimeoutSecs)
+
+	applyConfigUpdate()
+	require.Equal(t, uint(3600), config.IdleTimeoutSecs)
+	require.Equal(t, uint(5), config.NumberOfTasksToRun)
+	require.Equal(t, "caches", config.CachesDir)
+
+	// applying again is a no-op
+	config.IdleTimeoutSecs = 60
+	applyConfigUpdate()
+	require.Equal(t, uint(60), config.IdleTimeoutSecs)
+}
+
+func TestRemainingTasks(t *testing.T) {
+	config = &gwconfig.Config{}
+	require.Equal(t, -1, remainingTasks(3), "unlimited")
+
+	config.NumberOfTasksToRun = 5
+	require.Equal(t, 2, remainingTasks(3))
+	require.Equal(t, 0, remainingTasks(5))
+
+	// a config update can lower the limit below the number of tasks resolved
+	config.NumberOfTasksToRun = 2
+	require.Equal(t, 0, remainingTasks(3))
+}